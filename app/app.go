@@ -53,7 +53,7 @@ func NewApp(cfg *config.AppConfig) *App {
 
 	logger.Info("Connecting to database...")
 	if err := db.Connect(ctx); err != nil {
-		logger.Fatal("error connect to database")
+		logger.Fatal("error connect to database: ", err)
 	}
 	logger.Info("Database connected successfully")
 
@@ -147,6 +147,19 @@ func (a *App) RegisterHandler() {
 	maintenanceRepo := repository.NewMaintenanceRepository(a.database)
 	equipmentMachineryRepo := repository.NewEquipmentMachineryRepo(a.database)
 	materialsRequestRepo := repository.NewMaterialsRequestRepository(a.database)
+	importProfileRepo := repository.NewImportProfileRepository(a.database)
+	estimateUploadRepo := repository.NewEstimateUploadRepository(a.database)
+	catalogRepo := repository.NewCatalogRepository(a.database)
+	priceRepo := repository.NewPriceRepository(a.database)
+	supplierRepo := repository.NewSupplierRepository(a.database)
+	stockRepo := repository.NewStockRepository(a.database)
+	stockMovementRepo := repository.NewStockMovementRepository(a.database)
+	stockTransferRepo := repository.NewStockTransferRepository(a.database)
+	warehouseRepo := repository.NewWarehouseRepository(a.database)
+	installedPartRepo := repository.NewInstalledPartRepository(a.database)
+	purchaseRequisitionRepo := repository.NewPurchaseRequisitionRepository(a.database)
+	counterRepo := repository.NewCounterRepository(a.database)
+	transactor := repository.NewTransactor(a.database)
 
 	jwtService := service.NewJWTService(
 		a.config.JWT.Secret,
@@ -155,25 +168,67 @@ func (a *App) RegisterHandler() {
 	)
 
 	uploadService := service.NewUploadService(a.config.Upload.BaseDir)
+	importJobService := service.NewImportJobService(a.redisClient, a.logger)
 
 	loginService := service.NewLoginService(jwtService, userRepo)
 	userService := service.NewUserService(userRepo)
 	maintenanceService := service.NewMaintenanceService(maintenanceRepo)
-	equipmentMachineryService := service.NewEquipmentMachineryService(equipmentMachineryRepo)
-	materialsProfileService := service.NewMaterialsProfileService(materialsProfileRepo, maintenanceRepo, equipmentMachineryRepo, uploadService)
+	equipmentMachineryService := service.NewEquipmentMachineryService(equipmentMachineryRepo, materialsProfileRepo, installedPartRepo, transactor)
+	importProfileService := service.NewImportProfileService(importProfileRepo)
+	catalogService := service.NewCatalogService(catalogRepo, priceRepo, materialsProfileRepo, materialsRequestRepo)
+	// the migrations run before the server starts, a stuck one is given up
+	// and retried on the next start
+	migrationCtx, cancelMigrations := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancelMigrations()
+	// materials created before names were normalized are not found by the
+	// importer until their normalized names are recorded
+	if updated, err := catalogService.BackfillNormalizedNames(migrationCtx); err != nil {
+		a.logger.Error("Failed to backfill normalized catalog names: ", err)
+	} else if updated > 0 {
+		a.logger.Info("Backfilled normalized names of catalog materials: ", updated)
+	}
+	matchingService := service.NewMatchingService(catalogRepo, priceRepo, supplierRepo, stockRepo, stockMovementRepo, stockTransferRepo, equipmentMachineryRepo, materialsProfileRepo, materialsRequestRepo)
+	materialsProfileService := service.NewMaterialsProfileService(materialsProfileRepo, materialsRequestRepo, maintenanceRepo, equipmentMachineryRepo, importProfileRepo, estimateUploadRepo, catalogRepo, priceRepo, transactor, uploadService, importJobService)
 	materialsRequestService := service.NewMaterialsRequestService(
 		materialsRequestRepo,
 		materialsProfileRepo,
 		maintenanceRepo,
 		equipmentMachineryRepo,
+		catalogRepo,
+		priceRepo,
 		a.config.MaterialsRequestConfig.TemplatePath,
 	)
+	reportService := service.NewReportService(materialsProfileRepo, materialsRequestRepo, maintenanceRepo, equipmentMachineryRepo, catalogRepo, priceRepo)
+	suggestionService := service.NewSuggestionService(materialsProfileRepo, maintenanceRepo)
+	supplierService := service.NewSupplierService(supplierRepo, catalogRepo)
+	warehouseService := service.NewWarehouseService(warehouseRepo, stockRepo, stockMovementRepo, counterRepo, transactor)
+	// the stock recorded before warehouses were registered names its
+	// warehouse instead of referring to it
+	if migrated, err := warehouseService.MigrateWarehouseNames(migrationCtx); err != nil {
+		a.logger.Error("Failed to migrate warehouse names of the stock: ", err)
+	} else if migrated > 0 {
+		a.logger.Info("Migrated warehouse names of the stock: ", migrated)
+	}
+	stockService := service.NewStockService(stockRepo, stockMovementRepo, stockTransferRepo, warehouseRepo, installedPartRepo, catalogRepo, materialsRequestRepo, materialsProfileRepo, equipmentMachineryRepo, maintenanceRepo, purchaseRequisitionRepo, counterRepo, transactor)
+	traceabilityService := service.NewTraceabilityService(installedPartRepo)
+	purchasingService := service.NewPurchasingService(purchaseRequisitionRepo, supplierRepo, stockRepo, stockTransferRepo, warehouseRepo, catalogRepo, materialsProfileRepo, maintenanceRepo, counterRepo, transactor)
 	loginHandler := handler.NewLoginHandler(loginService, a.logger)
 	userHandler := handler.NewUserHandler(userService)
 	materialProfileHandler := handler.NewMaterialProfileHandler(materialsProfileService, a.logger)
 	materialsRequestHandler := handler.NewMaterialRequestHandler(materialsRequestService, a.logger)
 	maintenanceHandler := handler.NewMaintenanceHandler(maintenanceService)
 	equipmentMachineryHandler := handler.NewEquipmentMachineryHandler(equipmentMachineryService)
+	importProfileHandler := handler.NewImportProfileHandler(importProfileService, a.logger)
+	catalogHandler := handler.NewCatalogHandler(catalogService, a.logger)
+	matchingHandler := handler.NewMatchingHandler(matchingService, a.logger)
+	importJobHandler := handler.NewImportJobHandler(materialsProfileService, importJobService, a.logger)
+	reportHandler := handler.NewReportHandler(reportService, a.logger)
+	suggestionHandler := handler.NewSuggestionHandler(suggestionService, a.logger)
+	supplierHandler := handler.NewSupplierHandler(supplierService, a.logger)
+	warehouseHandler := handler.NewWarehouseHandler(warehouseService, a.logger)
+	stockHandler := handler.NewStockHandler(stockService, a.logger)
+	traceabilityHandler := handler.NewTraceabilityHandler(traceabilityService, a.logger)
+	purchasingHandler := handler.NewPurchasingHandler(purchasingService, a.logger)
 
 	authMiddleware := middleware.NewAuthMiddleware(jwtService)
 
@@ -204,12 +259,19 @@ func (a *App) RegisterHandler() {
 	maintenanceGroup.GET("/:id", maintenanceHandler.GetMaintenance)
 	maintenanceGroup.POST("/filter", maintenanceHandler.FilterMaintenance)
 	maintenanceGroup.POST("/", maintenanceHandler.CreateMaintenance)
+	maintenanceGroup.POST("/schedule", maintenanceHandler.UpdateSchedule)
 
 	// EquipmentMachinery
 	equipmentMachineryGroup := a.api.Group("/api/v1/equipment-machinery")
 	equipmentMachineryGroup.Use(authMiddleware.AuthBearerMiddleware())
 	equipmentMachineryGroup.POST("/filter", equipmentMachineryHandler.FilterEquipmentMachinery)
 	equipmentMachineryGroup.POST("", equipmentMachineryHandler.CreateEquipmentMachinery)
+	equipmentMachineryGroup.GET("/tree", equipmentMachineryHandler.EquipmentTree)
+	equipmentMachineryGroup.GET("/:id", equipmentMachineryHandler.GetEquipmentMachinery)
+	// admins maintain the equipments the estimate importer resolves names to
+	equipmentMachineryGroup.POST("/update", authMiddleware.AdminMiddleware(), equipmentMachineryHandler.UpdateEquipmentMachinery)
+	equipmentMachineryGroup.POST("/delete/:id", authMiddleware.AdminMiddleware(), equipmentMachineryHandler.DeleteEquipmentMachinery)
+	equipmentMachineryGroup.POST("/merge", authMiddleware.AdminMiddleware(), equipmentMachineryHandler.MergeEquipmentMachineries)
 
 	// Materials Profile routes
 	materialsProfileGroup := a.api.Group("/api/v1/materials-profiles")
@@ -218,7 +280,56 @@ func (a *App) RegisterHandler() {
 	materialsProfileGroup.POST("/", materialProfileHandler.FilterMaterialsProfiles)
 	materialsProfileGroup.GET("/paginated", materialProfileHandler.PaginatedMaterialsProfiles)
 	materialsProfileGroup.POST("/upload-estimate", materialProfileHandler.UpdateMaterialsEstimateProfileBySheet)
+	materialsProfileGroup.POST("/upload-estimate-workbook", materialProfileHandler.UploadEstimateWorkbook)
 	materialsProfileGroup.POST("/create", materialProfileHandler.CreateNewMaterialsProfile)
+	materialsProfileGroup.POST("/export-estimate", materialProfileHandler.ExportEstimateSheet)
+	materialsProfileGroup.POST("/tree", materialProfileHandler.MaterialsProfileTree)
+	materialsProfileGroup.POST("/insert", materialProfileHandler.InsertMaterialsProfile)
+	materialsProfileGroup.POST("/move", materialProfileHandler.MoveMaterialsProfile)
+	materialsProfileGroup.POST("/validate-index", materialProfileHandler.ValidateIndexPaths)
+	materialsProfileGroup.POST("/copy-estimates", materialProfileHandler.CopyEstimates)
+	materialsProfileGroup.POST("/delete", materialProfileHandler.DeleteMaterialsProfile)
+	materialsProfileGroup.POST("/archive", materialProfileHandler.ArchiveMaterialsProfile)
+	materialsProfileGroup.POST("/restore/:id", materialProfileHandler.RestoreMaterialsProfile)
+	materialsProfileGroup.POST("/uploads", materialProfileHandler.EstimateUploadHistory)
+	materialsProfileGroup.GET("/uploads/:id/download", materialProfileHandler.DownloadEstimateUpload)
+
+	// Import Profile routes
+	importProfileGroup := a.api.Group("/api/v1/import-profiles")
+	importProfileGroup.Use(authMiddleware.AuthBearerMiddleware())
+	importProfileGroup.GET("", importProfileHandler.ListImportProfiles)
+	importProfileGroup.GET("/:id", importProfileHandler.GetImportProfile)
+	importProfileGroup.POST("", importProfileHandler.CreateImportProfile)
+	importProfileGroup.POST("/update", importProfileHandler.UpdateImportProfile)
+	importProfileGroup.POST("/delete/:id", importProfileHandler.DeleteImportProfile)
+
+	// Catalog routes
+	catalogGroup := a.api.Group("/api/v1/catalog")
+	catalogGroup.Use(authMiddleware.AuthBearerMiddleware())
+	catalogGroup.GET("/search", catalogHandler.SearchCatalog)
+	catalogGroup.GET("/units", catalogHandler.ListUnits)
+	catalogGroup.GET("/prices/:id", catalogHandler.PriceHistory)
+	catalogGroup.POST("/prices", catalogHandler.AddCatalogPrice)
+	catalogGroup.GET("/:id", catalogHandler.GetCatalogMaterial)
+	catalogGroup.POST("", catalogHandler.CreateCatalogMaterial)
+	catalogGroup.POST("/update", catalogHandler.UpdateCatalogMaterial)
+	catalogGroup.POST("/delete/:id", catalogHandler.DeleteCatalogMaterial)
+
+	// Matching routes
+	matchingGroup := a.api.Group("/api/v1/matching")
+	matchingGroup.Use(authMiddleware.AuthBearerMiddleware())
+	matchingGroup.POST("/materials", matchingHandler.SuggestMaterials)
+	matchingGroup.POST("/equipments", matchingHandler.SuggestEquipments)
+	matchingGroup.GET("/duplicates", matchingHandler.FindDuplicates)
+	matchingGroup.POST("/merge-materials", matchingHandler.MergeCatalogMaterials)
+
+	// Import Job routes
+	importJobGroup := a.api.Group("/api/v1/import-jobs")
+	importJobGroup.Use(authMiddleware.AuthBearerMiddleware())
+	importJobGroup.POST("/estimate-sheet", importJobHandler.StartEstimateSheetImport)
+	importJobGroup.POST("/estimate-workbook", importJobHandler.StartEstimateWorkbookImport)
+	importJobGroup.GET("/:id", importJobHandler.GetImportJob)
+	importJobGroup.POST("/cancel/:id", importJobHandler.CancelImportJob)
 
 	// Materials Request routes
 	materialsRequestGroup := a.api.Group("/api/v1/materials-request")
@@ -231,4 +342,71 @@ func (a *App) RegisterHandler() {
 	materialsRequestGroup.POST("/update", materialsRequestHandler.UpdateMaterialRequest)
 	materialsRequestGroup.POST("/cancel/:id", materialsRequestHandler.CancelMaterialRequest)
 
+	// Report routes
+	reportGroup := a.api.Group("/api/v1/reports")
+	reportGroup.Use(authMiddleware.AuthBearerMiddleware())
+	reportGroup.POST("/variance", reportHandler.VarianceReport)
+	reportGroup.POST("/variance/export", reportHandler.ExportVarianceReport)
+	reportGroup.POST("/cost", reportHandler.CostReport)
+	reportGroup.POST("/cost/export", reportHandler.ExportCostReport)
+	reportGroup.GET("/cost/profile/:id", reportHandler.ProfileCost)
+	reportGroup.GET("/cost/request/:id", reportHandler.RequestCost)
+
+	// Suggestion routes
+	suggestionGroup := a.api.Group("/api/v1/suggestions")
+	suggestionGroup.Use(authMiddleware.AuthBearerMiddleware())
+	suggestionGroup.POST("/estimate", suggestionHandler.SuggestEstimate)
+
+	// Supplier routes
+	supplierGroup := a.api.Group("/api/v1/suppliers")
+	supplierGroup.Use(authMiddleware.AuthBearerMiddleware())
+	supplierGroup.GET("/search", supplierHandler.SearchSuppliers)
+	supplierGroup.GET("/:id", supplierHandler.GetSupplier)
+	supplierGroup.POST("", supplierHandler.CreateSupplier)
+	supplierGroup.POST("/update", supplierHandler.UpdateSupplier)
+	supplierGroup.POST("/delete/:id", supplierHandler.DeleteSupplier)
+
+	// Warehouse routes
+	warehouseGroup := a.api.Group("/api/v1/warehouses")
+	warehouseGroup.Use(authMiddleware.AuthBearerMiddleware())
+	warehouseGroup.GET("", warehouseHandler.ListWarehouses)
+	warehouseGroup.GET("/:id", warehouseHandler.GetWarehouse)
+	warehouseGroup.POST("", warehouseHandler.CreateWarehouse)
+	warehouseGroup.POST("/update", warehouseHandler.UpdateWarehouse)
+	warehouseGroup.POST("/delete/:id", warehouseHandler.DeleteWarehouse)
+
+	// Stock routes
+	stockGroup := a.api.Group("/api/v1/stock")
+	stockGroup.Use(authMiddleware.AuthBearerMiddleware())
+	stockGroup.GET("/balances", stockHandler.StockBalances)
+	stockGroup.POST("/count", stockHandler.RecordStockCount)
+	stockGroup.POST("/receipts", stockHandler.ReceiveMaterials)
+	stockGroup.POST("/issues", stockHandler.IssueMaterials)
+	stockGroup.POST("/returns", stockHandler.ReturnMaterials)
+	stockGroup.POST("/adjustments", stockHandler.AdjustStock)
+	stockGroup.GET("/movements", stockHandler.StockLedger)
+	stockGroup.POST("/transfers", stockHandler.CreateTransfer)
+	stockGroup.GET("/transfers", stockHandler.SearchTransfers)
+	stockGroup.GET("/transfers/:id", stockHandler.GetTransfer)
+	stockGroup.POST("/transfers/receive/:id", stockHandler.ReceiveTransfer)
+	stockGroup.POST("/transfers/cancel/:id", stockHandler.CancelTransfer)
+	stockGroup.POST("/transfers/export/:id", stockHandler.ExportTransferNote)
+
+	// Traceability routes
+	traceabilityGroup := a.api.Group("/api/v1/traceability")
+	traceabilityGroup.Use(authMiddleware.AuthBearerMiddleware())
+	traceabilityGroup.GET("/serials/:serial", traceabilityHandler.SerialHistory)
+	traceabilityGroup.GET("/parts", traceabilityHandler.SearchInstalledParts)
+
+	// Purchasing routes
+	purchasingGroup := a.api.Group("/api/v1/purchasing")
+	purchasingGroup.Use(authMiddleware.AuthBearerMiddleware())
+	purchasingGroup.POST("/requisitions", purchasingHandler.CreatePurchaseRequisition)
+	purchasingGroup.POST("/requisitions/filter", purchasingHandler.FilterPurchaseRequisitions)
+	purchasingGroup.POST("/requisitions/status", purchasingHandler.UpdateRequisitionStatus)
+	purchasingGroup.POST("/requisitions/export/:id", purchasingHandler.ExportPurchaseRequisition)
+	purchasingGroup.GET("/requisitions/:id", purchasingHandler.GetPurchaseRequisition)
+	purchasingGroup.POST("/forecast", purchasingHandler.ForecastShortages)
+	purchasingGroup.POST("/forecast/export", purchasingHandler.ExportShortageForecast)
+
 }
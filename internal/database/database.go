@@ -1,6 +1,13 @@
 package database
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoDocument is returned by FindOneAndUpdate when no document matches the
+// filter.
+var ErrNoDocument = errors.New("no document matches the filter")
 
 type Database interface {
 	Connect(ctx context.Context) error
@@ -12,9 +19,24 @@ type Database interface {
 	FindAll(ctx context.Context, collection string, sort interface{}, data interface{}) error
 	Update(ctx context.Context, collection string, id string, data interface{}) error
 	UpdateMany(ctx context.Context, collection string, ids []string, data []interface{}) error
+	UpdateByFilter(ctx context.Context, collection string, filter interface{}, update interface{}) error
+	UpsertMany(ctx context.Context, collection string, filters []interface{}, updates []interface{}) error
+	// FindOneAndUpdate applies a raw update to the first document matching
+	// the filter and decodes the updated document into data. With upsert a
+	// document is inserted when none matches, otherwise ErrNoDocument is
+	// returned.
+	FindOneAndUpdate(ctx context.Context, collection string, filter interface{}, update interface{}, upsert bool, data interface{}) error
 	Delete(ctx context.Context, collection string, id string) error
 	DeleteMany(ctx context.Context, collection string, filter interface{}) error
 	Query(ctx context.Context, collection string, filter interface{}, skip int64, limit int64, sort interface{}, data interface{}) error
 	Aggregate(ctx context.Context, collection string, pipeline interface{}, data interface{}) error
 	Count(ctx context.Context, collection string, filter interface{}) (int64, error)
+	// CreateIndex creates an ascending index on the keys of the collection,
+	// nothing happens when it exists already.
+	CreateIndex(ctx context.Context, collection string, keys []string, unique bool) error
+	// WithTransaction runs fn in a transaction, committed when fn returns nil
+	// and aborted otherwise. The calls made with the context fn receives are
+	// part of the transaction. MongoDB supports transactions on replica sets
+	// only, which Connect checks.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
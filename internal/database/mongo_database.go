@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -36,10 +37,34 @@ func (m *mongoDatabase) Connect(ctx context.Context) error {
 	if err := client.Ping(ctx, nil); err != nil {
 		panic(err)
 	}
+	if err := checkTransactions(ctx, client); err != nil {
+		return err
+	}
 	m.mongoClient = client
 	return nil
 }
 
+// ErrTransactionsUnsupported is returned by Connect when the server is a
+// standalone mongod, on which every WithTransaction call would fail.
+var ErrTransactionsUnsupported = errors.New("mongodb server does not support transactions, run it as a replica set (a single node one will do)")
+
+// checkTransactions checks that the server is a member of a replica set or
+// a mongos router, the deployments supporting transactions.
+func checkTransactions(ctx context.Context, client *mongo.Client) error {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return err
+	}
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		return ErrTransactionsUnsupported
+	}
+	return nil
+}
+
 func (m *mongoDatabase) Disconnect(ctx context.Context) error {
 	if err := m.mongoClient.Disconnect(ctx); err != nil {
 		panic(err)
@@ -149,6 +174,52 @@ func (m *mongoDatabase) UpdateMany(ctx context.Context, collection string, ids [
 	return nil
 }
 
+// UpdateByFilter applies a raw update document, such as {"$inc": ...}, to
+// every document matching the filter.
+func (m *mongoDatabase) UpdateByFilter(ctx context.Context, collection string, filter interface{}, update interface{}) error {
+	coll := m.mongoClient.Database(m.database).Collection(collection)
+	if filter == nil {
+		filter = bson.D{}
+	}
+	_, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpsertMany applies raw update documents in a single bulk write. Each update
+// targets the first document matching its filter and inserts one when none
+// matches.
+func (m *mongoDatabase) UpsertMany(ctx context.Context, collection string, filters []interface{}, updates []interface{}) error {
+	if len(filters) == 0 {
+		return nil
+	}
+	coll := m.mongoClient.Database(m.database).Collection(collection)
+	models := make([]mongo.WriteModel, len(filters))
+	for i, filter := range filters {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(updates[i]).
+			SetUpsert(true)
+	}
+	_, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *mongoDatabase) FindOneAndUpdate(ctx context.Context, collection string, filter interface{}, update interface{}, upsert bool, data interface{}) error {
+	coll := m.mongoClient.Database(m.database).Collection(collection)
+	ops := options.FindOneAndUpdate().SetUpsert(upsert).SetReturnDocument(options.After)
+	err := coll.FindOneAndUpdate(ctx, filter, update, ops).Decode(data)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNoDocument
+	}
+	return err
+}
+
 func (m *mongoDatabase) Delete(ctx context.Context, collection string, id string) error {
 	coll := m.mongoClient.Database(m.database).Collection(collection)
 	objId, err := bson.ObjectIDFromHex(id)
@@ -229,6 +300,36 @@ func (m *mongoDatabase) Count(ctx context.Context, collection string, filter int
 	return count, nil
 }
 
+// WithTransaction runs fn in a session transaction, which requires a replica
+// set. A transaction started by the caller is joined rather than nested.
+func (m *mongoDatabase) CreateIndex(ctx context.Context, collection string, keys []string, unique bool) error {
+	coll := m.mongoClient.Database(m.database).Collection(collection)
+	index := bson.D{}
+	for _, key := range keys {
+		index = append(index, bson.E{Key: key, Value: 1})
+	}
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    index,
+		Options: options.Index().SetUnique(unique),
+	})
+	return err
+}
+
+func (m *mongoDatabase) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	session, err := m.mongoClient.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
 func (m *mongoDatabase) GetClient() *mongo.Client {
 	return m.mongoClient
 }
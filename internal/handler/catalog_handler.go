@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type CatalogHandler interface {
+	CreateCatalogMaterial(ctx *gin.Context)
+	UpdateCatalogMaterial(ctx *gin.Context)
+	GetCatalogMaterial(ctx *gin.Context)
+	SearchCatalog(ctx *gin.Context)
+	DeleteCatalogMaterial(ctx *gin.Context)
+	ListUnits(ctx *gin.Context)
+	AddCatalogPrice(ctx *gin.Context)
+	PriceHistory(ctx *gin.Context)
+}
+
+type catalogHandler struct {
+	catalogService service.CatalogService
+	logger         *logger.Logger
+}
+
+func NewCatalogHandler(catalogService service.CatalogService, logger *logger.Logger) CatalogHandler {
+	return &catalogHandler{
+		catalogService: catalogService,
+		logger:         logger,
+	}
+}
+
+// catalogErrorStatus maps the errors of the catalog service to a status code.
+func catalogErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrCatalogMaterialNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, types.ErrDuplicateCatalogCode), errors.Is(err, types.ErrCatalogMaterialInUse):
+		return http.StatusConflict
+	case errors.Is(err, types.ErrInvalidPrice):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// CreateCatalogMaterial godoc
+// @Summary Create a catalog material
+// @Description Add a material to the master catalog with its code, canonical name, specification, default unit, category and aliases
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Param request body types.CreateCatalogMaterialReq true "Catalog material creation request"
+// @Success 200 {object} types.Response{data=string} "Catalog material created successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 409 {object} types.Response "Material code already used"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /catalog [post]
+func (h *catalogHandler) CreateCatalogMaterial(ctx *gin.Context) {
+	var req types.CreateCatalogMaterialReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := h.catalogService.CreateCatalogMaterial(ctx, &req)
+	if err != nil {
+		h.logger.Error("CreateCatalogMaterial: Failed to create catalog material", "error", err)
+		ctx.JSON(catalogErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to create catalog material: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Catalog material created successfully",
+		Data:    id,
+	})
+}
+
+// UpdateCatalogMaterial godoc
+// @Summary Update a catalog material
+// @Description Replace the code, names and defaults of a catalog material
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Param request body types.UpdateCatalogMaterialReq true "Catalog material update request"
+// @Success 200 {object} types.Response "Catalog material updated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Catalog material not found"
+// @Failure 409 {object} types.Response "Material code already used"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /catalog/update [post]
+func (h *catalogHandler) UpdateCatalogMaterial(ctx *gin.Context) {
+	var req types.UpdateCatalogMaterialReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.catalogService.UpdateCatalogMaterial(ctx, &req); err != nil {
+		h.logger.Error("UpdateCatalogMaterial: Failed to update catalog material", "error", err)
+		ctx.JSON(catalogErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to update catalog material: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Catalog material updated successfully",
+	})
+}
+
+// GetCatalogMaterial godoc
+// @Summary Get catalog material by ID
+// @Description Retrieve a specific catalog material using its ID
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Param id path string true "Catalog material ID"
+// @Success 200 {object} types.Response{data=types.CatalogMaterial} "Catalog material retrieved successfully"
+// @Failure 404 {object} types.Response "Catalog material not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /catalog/{id} [get]
+func (h *catalogHandler) GetCatalogMaterial(ctx *gin.Context) {
+	id := ctx.Param("id")
+	material, err := h.catalogService.GetCatalogMaterial(ctx, id)
+	if err != nil {
+		ctx.JSON(catalogErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Catalog material retrieved successfully",
+		Data:    material,
+	})
+}
+
+// SearchCatalog godoc
+// @Summary Search the material catalog
+// @Description Retrieve a page of catalog materials sorted by code, matching part of the code, name, specification or an alias
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param q query string false "Part of the code, name, specification or an alias"
+// @Param category query string false "Category"
+// @Success 200 {object} types.PaginatedResponse{data=types.PaginatedData{items=[]types.CatalogMaterial}} "Catalog materials retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /catalog/search [get]
+func (h *catalogHandler) SearchCatalog(ctx *gin.Context) {
+	request := types.CatalogSearchRequest{
+		Page:  1,
+		Limit: 20,
+	}
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		h.logger.Warn("SearchCatalog: Invalid query parameters", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if request.Page <= 0 || request.Limit <= 0 {
+		h.logger.Warn("SearchCatalog: Invalid pagination parameters", "page", request.Page, "limit", request.Limit)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid pagination parameters",
+		})
+		return
+	}
+
+	materials, total, err := h.catalogService.SearchCatalog(ctx, &request)
+	if err != nil {
+		h.logger.Error("SearchCatalog: Failed to search the catalog", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.PaginatedResponse{
+		Status:  true,
+		Message: "Catalog materials retrieved successfully",
+		Data: types.PaginatedData{
+			Total: total,
+			Page:  request.Page,
+			Limit: request.Limit,
+			Items: materials,
+		},
+	})
+}
+
+// DeleteCatalogMaterial godoc
+// @Summary Delete a catalog material
+// @Description Delete a catalog material that no materials profile or material request references
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Param id path string true "Catalog material ID"
+// @Success 200 {object} types.Response "Catalog material deleted successfully"
+// @Failure 404 {object} types.Response "Catalog material not found"
+// @Failure 409 {object} types.Response "Catalog material is still referenced"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /catalog/delete/{id} [post]
+func (h *catalogHandler) DeleteCatalogMaterial(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := h.catalogService.DeleteCatalogMaterial(ctx, id); err != nil {
+		h.logger.Error("DeleteCatalogMaterial: Failed to delete catalog material", "error", err)
+		ctx.JSON(catalogErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to delete catalog material: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Catalog material deleted successfully",
+	})
+}
+
+// ListUnits godoc
+// @Summary List the units of measure
+// @Description Retrieve the units of measure recognized on import with their aliases, dimension and factor to the base unit of the dimension. Pack units convert with the pack sizes of a catalog material.
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Success 200 {object} types.Response{data=[]utils.Unit} "Units retrieved successfully"
+// @Security BearerAuth
+// @Router /catalog/units [get]
+func (h *catalogHandler) ListUnits(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Units retrieved successfully",
+		Data:    h.catalogService.ListUnits(),
+	})
+}
+
+// AddCatalogPrice godoc
+// @Summary Record a catalog material price
+// @Description Record the price of a catalog material per default unit, effective from a date. Earlier prices are kept as history and still price the lines of their period.
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Param request body types.AddCatalogPriceReq true "Catalog price"
+// @Success 200 {object} types.Response{data=string} "Catalog price recorded successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Catalog material not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /catalog/prices [post]
+func (h *catalogHandler) AddCatalogPrice(ctx *gin.Context) {
+	var req types.AddCatalogPriceReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := h.catalogService.AddCatalogPrice(ctx, &req)
+	if err != nil {
+		h.logger.Error("AddCatalogPrice: Failed to record catalog price", "error", err)
+		ctx.JSON(catalogErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to record catalog price: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Catalog price recorded successfully",
+		Data:    id,
+	})
+}
+
+// PriceHistory godoc
+// @Summary Get the price history of a catalog material
+// @Description Retrieve the prices recorded for a catalog material, latest effective date first
+// @Tags catalog
+// @Accept json
+// @Produce json
+// @Param id path string true "Catalog material ID"
+// @Success 200 {object} types.Response{data=[]types.CatalogPrice} "Price history retrieved successfully"
+// @Failure 404 {object} types.Response "Catalog material not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /catalog/prices/{id} [get]
+func (h *catalogHandler) PriceHistory(ctx *gin.Context) {
+	id := ctx.Param("id")
+	prices, err := h.catalogService.PriceHistory(ctx, id)
+	if err != nil {
+		h.logger.Error("PriceHistory: Failed to get price history", "error", err)
+		ctx.JSON(catalogErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Price history retrieved successfully",
+		Data:    prices,
+	})
+}
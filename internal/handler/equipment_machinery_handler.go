@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +12,11 @@ import (
 type EquipmentMachineryHandler interface {
 	CreateEquipmentMachinery(ctx *gin.Context)
 	FilterEquipmentMachinery(ctx *gin.Context)
+	GetEquipmentMachinery(ctx *gin.Context)
+	UpdateEquipmentMachinery(ctx *gin.Context)
+	DeleteEquipmentMachinery(ctx *gin.Context)
+	MergeEquipmentMachineries(ctx *gin.Context)
+	EquipmentTree(ctx *gin.Context)
 }
 
 type equipmentMachineryHandler struct {
@@ -23,9 +29,25 @@ func NewEquipmentMachineryHandler(equipmentMachineryService service.EquipmentMac
 	}
 }
 
+// equipmentMachineryErrorStatus maps the errors of the equipment machinery service to a status code.
+func equipmentMachineryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrEquipmentMachineryNotFound), errors.Is(err, types.ErrSomeEquipmentMachineryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, types.ErrEmptyEquipmentName), errors.Is(err, types.ErrInvalidSector),
+		errors.Is(err, types.ErrInvalidMergeSource), errors.Is(err, types.ErrEquipmentSectorMismatch),
+		errors.Is(err, types.ErrInvalidEquipmentParent), errors.Is(err, types.ErrInvalidSpecKey):
+		return http.StatusBadRequest
+	case errors.Is(err, types.ErrEquipmentMachineryInUse), errors.Is(err, types.ErrDuplicateEquipmentMachinery),
+		errors.Is(err, types.ErrDuplicateEquipmentAlias), errors.Is(err, types.ErrEquipmentHasChildren):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
 // CreateEquipmentMachinery godoc
 // @Summary Create a new equipment machinery
-// @Description Create a new equipment machinery with name, sector, and order
+// @Description Create a new equipment machinery with name, sector, order, its parent in the hierarchy its technical attributes and the aliases the estimate importer also resolves it by
 // @Tags equipment-machinery
 // @Accept json
 // @Produce json
@@ -47,7 +69,7 @@ func (h *equipmentMachineryHandler) CreateEquipmentMachinery(ctx *gin.Context) {
 
 	id, err := h.equipmentMachineryService.CreateEquipmentMachinery(ctx, &req)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, types.Response{
+		ctx.JSON(equipmentMachineryErrorStatus(err), types.Response{
 			Status:  false,
 			Message: "Failed to create equipment machinery: " + err.Error(),
 		})
@@ -62,7 +84,7 @@ func (h *equipmentMachineryHandler) CreateEquipmentMachinery(ctx *gin.Context) {
 
 // FilterEquipmentMachinery godoc
 // @Summary Filter equipment machinery
-// @Description Filter and retrieve equipment machinery based on sector and other criteria
+// @Description Filter and retrieve equipment machinery by sector, parent, name, model, manufacturer, rated specs or an entry of the spec sheet
 // @Tags equipment-machinery
 // @Accept json
 // @Produce json
@@ -96,3 +118,168 @@ func (h *equipmentMachineryHandler) FilterEquipmentMachinery(ctx *gin.Context) {
 		Data:   results,
 	})
 }
+
+// GetEquipmentMachinery godoc
+// @Summary Get equipment machinery by ID
+// @Description Get the details of an equipment machinery
+// @Tags equipment-machinery
+// @Produce json
+// @Param id path string true "Equipment machinery ID"
+// @Success 200 {object} types.Response{data=types.EquipmentMachinery} "Equipment machinery retrieved successfully"
+// @Failure 404 {object} types.Response "Equipment machinery not found"
+// @Failure 500 {object} types.Response "Failed to get equipment machinery"
+// @Security BearerAuth
+// @Router /equipment-machinery/{id} [get]
+func (h *equipmentMachineryHandler) GetEquipmentMachinery(ctx *gin.Context) {
+	id := ctx.Param("id")
+	equipmentMachinery, err := h.equipmentMachineryService.GetEquipmentMachinery(ctx, id)
+	if err != nil {
+		ctx.JSON(equipmentMachineryErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to get equipment machinery: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Equipment machinery retrieved successfully",
+		Data:    equipmentMachinery,
+	})
+}
+
+// UpdateEquipmentMachinery godoc
+// @Summary Update an equipment machinery
+// @Description Update the attributes, aliases and parent of an equipment machinery, or move it to another sector while no materials profile references it and it has no children
+// @Tags equipment-machinery
+// @Accept json
+// @Produce json
+// @Param request body types.UpdateEquipmentMachineryReq true "Equipment machinery update request"
+// @Success 200 {object} types.Response "Equipment machinery updated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 403 {object} types.Response "Admin role required"
+// @Failure 404 {object} types.Response "Equipment machinery not found"
+// @Failure 409 {object} types.Response "Name taken among its siblings, or equipment referenced by profiles or with children"
+// @Failure 500 {object} types.Response "Failed to update equipment machinery"
+// @Security BearerAuth
+// @Router /equipment-machinery/update [post]
+func (h *equipmentMachineryHandler) UpdateEquipmentMachinery(ctx *gin.Context) {
+	var req types.UpdateEquipmentMachineryReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.equipmentMachineryService.UpdateEquipmentMachinery(ctx, &req); err != nil {
+		ctx.JSON(equipmentMachineryErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to update equipment machinery: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Equipment machinery updated successfully",
+	})
+}
+
+// DeleteEquipmentMachinery godoc
+// @Summary Delete an equipment machinery
+// @Description Delete an equipment machinery without children no materials profile references, archived ones included
+// @Tags equipment-machinery
+// @Produce json
+// @Param id path string true "Equipment machinery ID"
+// @Success 200 {object} types.Response "Equipment machinery deleted successfully"
+// @Failure 403 {object} types.Response "Admin role required"
+// @Failure 404 {object} types.Response "Equipment machinery not found"
+// @Failure 409 {object} types.Response "Equipment machinery referenced by profiles or with children"
+// @Failure 500 {object} types.Response "Failed to delete equipment machinery"
+// @Security BearerAuth
+// @Router /equipment-machinery/delete/{id} [post]
+func (h *equipmentMachineryHandler) DeleteEquipmentMachinery(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := h.equipmentMachineryService.DeleteEquipmentMachinery(ctx, id); err != nil {
+		ctx.JSON(equipmentMachineryErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to delete equipment machinery: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Equipment machinery deleted successfully",
+	})
+}
+
+// MergeEquipmentMachineries godoc
+// @Summary Merge duplicate equipment machineries
+// @Description Re-point the materials profiles, the installed parts and the children of the source equipments to the target, of the same sector, and delete the sources, whose names become aliases of the target
+// @Tags equipment-machinery
+// @Accept json
+// @Produce json
+// @Param request body types.MergeEquipmentMachineriesReq true "Merge request"
+// @Success 200 {object} types.Response{data=types.MergeEquipmentMachineriesResult} "Equipment machineries merged successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 403 {object} types.Response "Admin role required"
+// @Failure 404 {object} types.Response "Equipment machinery not found"
+// @Failure 500 {object} types.Response "Failed to merge equipment machineries"
+// @Security BearerAuth
+// @Router /equipment-machinery/merge [post]
+func (h *equipmentMachineryHandler) MergeEquipmentMachineries(ctx *gin.Context) {
+	var req types.MergeEquipmentMachineriesReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	result, err := h.equipmentMachineryService.MergeEquipmentMachineries(ctx, &req)
+	if err != nil {
+		ctx.JSON(equipmentMachineryErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to merge equipment machineries: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Equipment machineries merged successfully",
+		Data:    result,
+	})
+}
+
+// EquipmentTree godoc
+// @Summary Get the equipment hierarchy
+// @Description Retrieve the equipment machineries, of one sector when given, as a tree of ship systems, assemblies and components sorted by order then name
+// @Tags equipment-machinery
+// @Produce json
+// @Param sector query string false "Sector"
+// @Success 200 {object} types.Response{data=[]types.EquipmentNode} "Equipment hierarchy retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid sector"
+// @Failure 500 {object} types.Response "Failed to get equipment hierarchy"
+// @Security BearerAuth
+// @Router /equipment-machinery/tree [get]
+func (h *equipmentMachineryHandler) EquipmentTree(ctx *gin.Context) {
+	tree, err := h.equipmentMachineryService.EquipmentTree(ctx, ctx.Query("sector"))
+	if err != nil {
+		ctx.JSON(equipmentMachineryErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to get equipment hierarchy: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Equipment hierarchy retrieved successfully",
+		Data:    tree,
+	})
+}
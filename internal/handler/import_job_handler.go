@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type ImportJobHandler interface {
+	StartEstimateSheetImport(ctx *gin.Context)
+	StartEstimateWorkbookImport(ctx *gin.Context)
+	GetImportJob(ctx *gin.Context)
+	CancelImportJob(ctx *gin.Context)
+}
+
+type importJobHandler struct {
+	materialProfileService service.MaterialsProfileService
+	importJobService       service.ImportJobService
+	logger                 *logger.Logger
+}
+
+func NewImportJobHandler(
+	materialProfileService service.MaterialsProfileService,
+	importJobService service.ImportJobService,
+	logger *logger.Logger,
+) ImportJobHandler {
+	return &importJobHandler{
+		materialProfileService: materialProfileService,
+		importJobService:       importJobService,
+		logger:                 logger,
+	}
+}
+
+// StartEstimateSheetImport godoc
+// @Summary Import an estimate sheet in the background
+// @Description Upload an Excel sheet and import it in a background job. The job ID is returned right away, the progress is reported by the job status endpoint.
+// @Tags import-jobs
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Excel file to upload"
+// @Param request formData string true "JSON request data containing maintenance_instance_id, sheet_name, sector, optional import_profile_id and force"
+// @Success 202 {object} types.Response{data=types.ImportJob} "Import job started"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /import-jobs/estimate-sheet [post]
+func (h *importJobHandler) StartEstimateSheetImport(ctx *gin.Context) {
+	var request types.UploadEstimateSheetRequest
+	file, ok := h.bindUploadForm(ctx, "StartEstimateSheetImport", &request)
+	if !ok {
+		return
+	}
+	request.Sheet = file
+
+	job, err := h.materialProfileService.StartEstimateSheetImport(ctx, &request)
+	if err != nil {
+		h.logger.Error("StartEstimateSheetImport: Failed to start import job", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, types.Response{
+		Status:  true,
+		Message: "Import job started",
+		Data:    job,
+	})
+}
+
+// StartEstimateWorkbookImport godoc
+// @Summary Import an estimate workbook in the background
+// @Description Upload a workbook with one tab per sector and import it in a background job. The job ID is returned right away, the progress is reported by the job status endpoint.
+// @Tags import-jobs
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Excel file to upload"
+// @Param request formData string true "JSON request data containing maintenance_instance_id, optional sheet_sectors, import_profile_id and force"
+// @Success 202 {object} types.Response{data=types.ImportJob} "Import job started"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /import-jobs/estimate-workbook [post]
+func (h *importJobHandler) StartEstimateWorkbookImport(ctx *gin.Context) {
+	var request types.UploadEstimateWorkbookRequest
+	file, ok := h.bindUploadForm(ctx, "StartEstimateWorkbookImport", &request)
+	if !ok {
+		return
+	}
+	request.Sheet = file
+
+	job, err := h.materialProfileService.StartEstimateWorkbookImport(ctx, &request)
+	if err != nil {
+		h.logger.Error("StartEstimateWorkbookImport: Failed to start import job", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, types.Response{
+		Status:  true,
+		Message: "Import job started",
+		Data:    job,
+	})
+}
+
+// GetImportJob godoc
+// @Summary Get the status of an import job
+// @Description Report the rows processed, the warnings and, once finished, the result or error of an import job. A job whose instance stopped is reported as failed.
+// @Tags import-jobs
+// @Produce json
+// @Param id path string true "Import job ID"
+// @Success 200 {object} types.Response{data=types.ImportJob} "Import job retrieved successfully"
+// @Failure 404 {object} types.Response "Import job not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /import-jobs/{id} [get]
+func (h *importJobHandler) GetImportJob(ctx *gin.Context) {
+	id := ctx.Param("id")
+	job, err := h.importJobService.GetJob(ctx, id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, types.ErrImportJobNotFound) {
+			status = http.StatusNotFound
+		}
+		h.logger.Error("GetImportJob: Failed to get import job", "id", id, "error", err)
+		ctx.JSON(status, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Import job retrieved successfully",
+		Data:    job,
+	})
+}
+
+// CancelImportJob godoc
+// @Summary Cancel an import job
+// @Description Request the cancellation of a running import job. The job stops before writing anything, an import that already started writing can no longer be cancelled.
+// @Tags import-jobs
+// @Produce json
+// @Param id path string true "Import job ID"
+// @Success 200 {object} types.Response "Cancellation requested"
+// @Failure 404 {object} types.Response "Import job not found"
+// @Failure 409 {object} types.Response "Import job has already finished or started writing"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /import-jobs/cancel/{id} [post]
+func (h *importJobHandler) CancelImportJob(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := h.importJobService.CancelJob(ctx, id); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, types.ErrImportJobNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, types.ErrImportJobFinished), errors.Is(err, types.ErrImportJobCommitting):
+			status = http.StatusConflict
+		}
+		h.logger.Error("CancelImportJob: Failed to cancel import job", "id", id, "error", err)
+		ctx.JSON(status, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Cancellation requested",
+	})
+}
+
+// bindUploadForm reads the uploaded file and the JSON request of a multipart
+// upload form, answering the request itself when either is missing.
+func (h *importJobHandler) bindUploadForm(ctx *gin.Context, name string, request any) (*multipart.FileHeader, bool) {
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		h.logger.Warn(name+": Failed to get file from form", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "File is required: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	requestStr := ctx.PostForm("request")
+	if requestStr == "" {
+		h.logger.Warn(name + ": Missing request data")
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Request data is required",
+		})
+		return nil, false
+	}
+
+	if err := json.Unmarshal([]byte(requestStr), request); err != nil {
+		h.logger.Warn(name+": Failed to parse request data", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return nil, false
+	}
+	return file, true
+}
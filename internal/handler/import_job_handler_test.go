@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeImportJobService answers with the job or error registered for an ID.
+type fakeImportJobService struct {
+	jobs        map[string]*types.ImportJob
+	cancelErrs  map[string]error
+	cancelledID string
+}
+
+func (s *fakeImportJobService) Start(ctx context.Context, job *types.ImportJob, run service.ImportJobFunc) (*types.ImportJob, error) {
+	return job, nil
+}
+
+func (s *fakeImportJobService) GetJob(ctx context.Context, id string) (*types.ImportJob, error) {
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, types.ErrImportJobNotFound
+	}
+	return job, nil
+}
+
+func (s *fakeImportJobService) CancelJob(ctx context.Context, id string) error {
+	if err, ok := s.cancelErrs[id]; ok {
+		return err
+	}
+	s.cancelledID = id
+	return nil
+}
+
+func newTestImportJobRouter(jobService service.ImportJobService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	h := NewImportJobHandler(nil, jobService, &logger.Logger{Logger: log})
+	router := gin.New()
+	router.GET("/import-jobs/:id", h.GetImportJob)
+	router.POST("/import-jobs/cancel/:id", h.CancelImportJob)
+	return router
+}
+
+func TestImportJobHandler(t *testing.T) {
+	jobService := &fakeImportJobService{
+		jobs: map[string]*types.ImportJob{
+			"running": {ID: "running", Status: types.IMPORT_JOB_STATUS_RUNNING},
+		},
+		cancelErrs: map[string]error{
+			"missing":    types.ErrImportJobNotFound,
+			"finished":   types.ErrImportJobFinished,
+			"committing": types.ErrImportJobCommitting,
+		},
+	}
+	router := newTestImportJobRouter(jobService)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/import-jobs/running", http.StatusOK},
+		{http.MethodGet, "/import-jobs/missing", http.StatusNotFound},
+		{http.MethodPost, "/import-jobs/cancel/running", http.StatusOK},
+		{http.MethodPost, "/import-jobs/cancel/missing", http.StatusNotFound},
+		{http.MethodPost, "/import-jobs/cancel/finished", http.StatusConflict},
+		{http.MethodPost, "/import-jobs/cancel/committing", http.StatusConflict},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+		if recorder.Code != test.status {
+			t.Errorf("%s %s: status %d, want %d", test.method, test.path, recorder.Code, test.status)
+		}
+	}
+	if jobService.cancelledID != "running" {
+		t.Errorf("cancelled job %q, want running", jobService.cancelledID)
+	}
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type ImportProfileHandler interface {
+	CreateImportProfile(ctx *gin.Context)
+	UpdateImportProfile(ctx *gin.Context)
+	GetImportProfile(ctx *gin.Context)
+	ListImportProfiles(ctx *gin.Context)
+	DeleteImportProfile(ctx *gin.Context)
+}
+
+type importProfileHandler struct {
+	importProfileService service.ImportProfileService
+	logger               *logger.Logger
+}
+
+func NewImportProfileHandler(importProfileService service.ImportProfileService, logger *logger.Logger) ImportProfileHandler {
+	return &importProfileHandler{
+		importProfileService: importProfileService,
+		logger:               logger,
+	}
+}
+
+// CreateImportProfile godoc
+// @Summary Create an import profile
+// @Description Create a named layout for estimate workbooks (header row, columns, section labels, material marker)
+// @Tags import-profiles
+// @Accept json
+// @Produce json
+// @Param request body types.CreateImportProfileReq true "Import profile creation request"
+// @Success 200 {object} types.Response{data=string} "Import profile created successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /import-profiles [post]
+func (h *importProfileHandler) CreateImportProfile(ctx *gin.Context) {
+	var req types.CreateImportProfileReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := h.importProfileService.CreateImportProfile(ctx, &req)
+	if err != nil {
+		h.logger.Error("CreateImportProfile: Failed to create import profile", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to create import profile: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Import profile created successfully",
+		Data:    id,
+	})
+}
+
+// UpdateImportProfile godoc
+// @Summary Update an import profile
+// @Description Replace the layout of an existing import profile
+// @Tags import-profiles
+// @Accept json
+// @Produce json
+// @Param request body types.UpdateImportProfileReq true "Import profile update request"
+// @Success 200 {object} types.Response "Import profile updated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /import-profiles/update [post]
+func (h *importProfileHandler) UpdateImportProfile(ctx *gin.Context) {
+	var req types.UpdateImportProfileReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.importProfileService.UpdateImportProfile(ctx, &req); err != nil {
+		h.logger.Error("UpdateImportProfile: Failed to update import profile", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to update import profile: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Import profile updated successfully",
+	})
+}
+
+// GetImportProfile godoc
+// @Summary Get import profile by ID
+// @Description Retrieve a specific import profile using its ID
+// @Tags import-profiles
+// @Accept json
+// @Produce json
+// @Param id path string true "Import Profile ID"
+// @Success 200 {object} types.Response{data=types.ImportProfile} "Import profile retrieved successfully"
+// @Failure 404 {object} types.Response "Import profile not found"
+// @Security BearerAuth
+// @Router /import-profiles/{id} [get]
+func (h *importProfileHandler) GetImportProfile(ctx *gin.Context) {
+	id := ctx.Param("id")
+	importProfile, err := h.importProfileService.GetImportProfile(ctx, id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Import profile retrieved successfully",
+		Data:    importProfile,
+	})
+}
+
+// ListImportProfiles godoc
+// @Summary List import profiles
+// @Description Retrieve all stored import profiles
+// @Tags import-profiles
+// @Accept json
+// @Produce json
+// @Success 200 {object} types.Response{data=[]types.ImportProfile} "Import profiles retrieved successfully"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /import-profiles [get]
+func (h *importProfileHandler) ListImportProfiles(ctx *gin.Context) {
+	importProfiles, err := h.importProfileService.ListImportProfiles(ctx)
+	if err != nil {
+		h.logger.Error("ListImportProfiles: Failed to list import profiles", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Import profiles retrieved successfully",
+		Data:    importProfiles,
+	})
+}
+
+// DeleteImportProfile godoc
+// @Summary Delete an import profile
+// @Description Delete an import profile by ID
+// @Tags import-profiles
+// @Accept json
+// @Produce json
+// @Param id path string true "Import Profile ID"
+// @Success 200 {object} types.Response "Import profile deleted successfully"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /import-profiles/delete/{id} [post]
+func (h *importProfileHandler) DeleteImportProfile(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := h.importProfileService.DeleteImportProfile(ctx, id); err != nil {
+		h.logger.Error("DeleteImportProfile: Failed to delete import profile", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to delete import profile: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Import profile deleted successfully",
+	})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +13,7 @@ type MaintenanceHandler interface {
 	GetMaintenance(ctx *gin.Context)
 	CreateMaintenance(ctx *gin.Context)
 	FilterMaintenance(ctx *gin.Context)
+	UpdateSchedule(ctx *gin.Context)
 }
 
 type maintenanceHandler struct {
@@ -24,6 +26,17 @@ func NewMaintenanceHandler(maintenanceService service.MaintenanceService) Mainte
 	}
 }
 
+// maintenanceErrorStatus maps the errors of the maintenance service to a status code.
+func maintenanceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrMaintenanceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, types.ErrInvalidSchedule), errors.Is(err, types.ErrDuplicateMaintenance):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 // CreateMaintenance godoc
 // @Summary Get maintenance by ID
 // @Description Get maintenance details by ID
@@ -85,7 +98,7 @@ func (h *maintenanceHandler) CreateMaintenance(ctx *gin.Context) {
 
 	id, err := h.maintenanceService.CreateMaintenance(ctx, &req)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, types.Response{
+		ctx.JSON(maintenanceErrorStatus(err), types.Response{
 			Status:  false,
 			Message: "Failed to create maintenance: " + err.Error(),
 		})
@@ -136,3 +149,40 @@ func (h *maintenanceHandler) FilterMaintenance(ctx *gin.Context) {
 		Data:    maintenances,
 	})
 }
+
+// UpdateSchedule godoc
+// @Summary Update the schedule of a maintenance
+// @Description Set the dates the ship arrives at and leaves the yard, from which the shortage forecast derives when materials are needed
+// @Tags maintenance
+// @Accept json
+// @Produce json
+// @Param request body types.UpdateMaintenanceScheduleReq true "Maintenance schedule"
+// @Success 200 {object} types.Response "Maintenance schedule updated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Maintenance not found"
+// @Failure 500 {object} types.Response "Failed to update maintenance schedule"
+// @Security BearerAuth
+// @Router /maintenance/schedule [post]
+func (h *maintenanceHandler) UpdateSchedule(ctx *gin.Context) {
+	req := types.UpdateMaintenanceScheduleReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.maintenanceService.UpdateSchedule(ctx, &req); err != nil {
+		ctx.JSON(maintenanceErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to update maintenance schedule: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Maintenance schedule updated successfully",
+	})
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type MatchingHandler interface {
+	SuggestMaterials(ctx *gin.Context)
+	SuggestEquipments(ctx *gin.Context)
+	FindDuplicates(ctx *gin.Context)
+	MergeCatalogMaterials(ctx *gin.Context)
+}
+
+type matchingHandler struct {
+	matchingService service.MatchingService
+	logger          *logger.Logger
+}
+
+func NewMatchingHandler(matchingService service.MatchingService, logger *logger.Logger) MatchingHandler {
+	return &matchingHandler{
+		matchingService: matchingService,
+		logger:          logger,
+	}
+}
+
+// SuggestMaterials godoc
+// @Summary Suggest catalog materials for names
+// @Description Propose, for each free-text material name, the catalog materials with the most similar name or alias and a confidence score from 0 to 1. Diacritics, case, punctuation and spacing are ignored.
+// @Tags matching
+// @Accept json
+// @Produce json
+// @Param request body types.SuggestMatchesReq true "Names to match"
+// @Success 200 {object} types.Response{data=[]types.NameSuggestions} "Suggestions retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /matching/materials [post]
+func (h *matchingHandler) SuggestMaterials(ctx *gin.Context) {
+	var req types.SuggestMatchesReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	suggestions, err := h.matchingService.SuggestMaterials(ctx, &req)
+	if err != nil {
+		h.logger.Error("SuggestMaterials: Failed to suggest catalog materials", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Suggestions retrieved successfully",
+		Data:    suggestions,
+	})
+}
+
+// SuggestEquipments godoc
+// @Summary Suggest equipments for names
+// @Description Propose, for each equipment name, the stored equipments of the sector with the most similar name and a confidence score from 0 to 1
+// @Tags matching
+// @Accept json
+// @Produce json
+// @Param request body types.SuggestMatchesReq true "Names to match"
+// @Success 200 {object} types.Response{data=[]types.NameSuggestions} "Suggestions retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /matching/equipments [post]
+func (h *matchingHandler) SuggestEquipments(ctx *gin.Context) {
+	var req types.SuggestMatchesReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	suggestions, err := h.matchingService.SuggestEquipments(ctx, &req)
+	if err != nil {
+		h.logger.Error("SuggestEquipments: Failed to suggest equipments", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Suggestions retrieved successfully",
+		Data:    suggestions,
+	})
+}
+
+// FindDuplicates godoc
+// @Summary Review likely duplicates
+// @Description Group the catalog materials or the equipments whose names likely denote the same thing, the most similar groups first. Equipments are only compared within their sector.
+// @Tags matching
+// @Accept json
+// @Produce json
+// @Param kind query string true "material or equipment"
+// @Param sector query string false "Sector of the equipments"
+// @Param min_score query number false "Similarity from which two names are grouped" default(0.85)
+// @Success 200 {object} types.Response{data=[]types.DuplicateGroup} "Duplicates retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /matching/duplicates [get]
+func (h *matchingHandler) FindDuplicates(ctx *gin.Context) {
+	var req types.DuplicatesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+
+	groups, err := h.matchingService.FindDuplicates(ctx, &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, types.ErrInvalidMatchKind) {
+			status = http.StatusBadRequest
+		}
+		h.logger.Error("FindDuplicates: Failed to find duplicates", "error", err)
+		ctx.JSON(status, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Duplicates retrieved successfully",
+		Data:    groups,
+	})
+}
+
+// MergeCatalogMaterials godoc
+// @Summary Merge duplicate catalog materials
+// @Description Re-point the profile and request lines and the suppliers referencing the source materials to the target, add their stock to the stock of the target, keep the source names as aliases of the target and delete the sources
+// @Tags matching
+// @Accept json
+// @Produce json
+// @Param request body types.MergeCatalogMaterialsReq true "Merge request"
+// @Success 200 {object} types.Response{data=types.MergeCatalogMaterialsResult} "Catalog materials merged successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Catalog material not found"
+// @Failure 409 {object} types.Response "Stock of a source does not convert to the unit of the target"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /matching/merge-materials [post]
+func (h *matchingHandler) MergeCatalogMaterials(ctx *gin.Context) {
+	var req types.MergeCatalogMaterialsReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	result, err := h.matchingService.MergeCatalogMaterials(ctx, &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, types.ErrInvalidMergeSource):
+			status = http.StatusBadRequest
+		case errors.Is(err, types.ErrSomeCatalogMaterialNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, types.ErrIncompatibleUnit), errors.Is(err, types.ErrCatalogMaterialInTransit):
+			status = http.StatusConflict
+		}
+		h.logger.Error("MergeCatalogMaterials: Failed to merge catalog materials", "error", err)
+		ctx.JSON(status, types.Response{
+			Status:  false,
+			Message: "Failed to merge catalog materials: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Catalog materials merged successfully",
+		Data:    result,
+	})
+}
@@ -2,8 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
+	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 	"github.com/remiehneppo/material-management/internal/logger"
@@ -15,8 +17,20 @@ type MaterialProfileHandler interface {
 	GetMaterialsProfileByID(ctx *gin.Context)
 	FilterMaterialsProfiles(ctx *gin.Context)
 	UpdateMaterialsEstimateProfileBySheet(ctx *gin.Context)
+	UploadEstimateWorkbook(ctx *gin.Context)
 	PaginatedMaterialsProfiles(ctx *gin.Context)
 	CreateNewMaterialsProfile(ctx *gin.Context)
+	ExportEstimateSheet(ctx *gin.Context)
+	MaterialsProfileTree(ctx *gin.Context)
+	InsertMaterialsProfile(ctx *gin.Context)
+	MoveMaterialsProfile(ctx *gin.Context)
+	ValidateIndexPaths(ctx *gin.Context)
+	CopyEstimates(ctx *gin.Context)
+	DeleteMaterialsProfile(ctx *gin.Context)
+	ArchiveMaterialsProfile(ctx *gin.Context)
+	RestoreMaterialsProfile(ctx *gin.Context)
+	EstimateUploadHistory(ctx *gin.Context)
+	DownloadEstimateUpload(ctx *gin.Context)
 }
 
 type materialProfileHandler struct {
@@ -117,8 +131,8 @@ func (h *materialProfileHandler) FilterMaterialsProfiles(ctx *gin.Context) {
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "Excel file to upload"
-// @Param request formData string true "JSON request data containing project, maintenance_tier, maintenance_number, sheet_name, sector"
-// @Success 200 {object} types.Response "Materials estimate profile updated successfully"
+// @Param request formData string true "JSON request data containing maintenance_instance_id, sheet_name, sector, optional import_profile_id and force"
+// @Success 200 {object} types.Response{data=types.EstimateImportResult} "Materials estimate profile updated successfully"
 // @Failure 400 {object} types.Response "Invalid request"
 // @Failure 500 {object} types.Response "Internal server error"
 // @Security BearerAuth
@@ -161,7 +175,8 @@ func (h *materialProfileHandler) UpdateMaterialsEstimateProfileBySheet(ctx *gin.
 	request.Sheet = file
 
 	// Upload and process the sheet
-	if err := h.materialProfileService.UploadEstimateSheet(ctx, &request); err != nil {
+	result, err := h.materialProfileService.UploadEstimateSheet(ctx, &request)
+	if err != nil {
 		h.logger.Error("UpdateMaterialsEstimateProfileBySheet: Failed to upload estimate sheet", "error", err)
 		ctx.JSON(http.StatusInternalServerError, types.Response{
 			Status:  false,
@@ -170,15 +185,88 @@ func (h *materialProfileHandler) UpdateMaterialsEstimateProfileBySheet(ctx *gin.
 		return
 	}
 
+	message := "Materials estimate profile updated successfully"
+	if result.Duplicate {
+		message = "Identical sheet was already imported, returning the earlier result"
+	}
 	ctx.JSON(http.StatusOK, types.Response{
 		Status:  true,
-		Message: "Materials estimate profile updated successfully",
+		Message: message,
+		Data:    result,
+	})
+}
+
+// UploadEstimateWorkbook godoc
+// @Summary Import every sector sheet of an estimate workbook
+// @Description Upload a workbook with one tab per sector. Tabs are mapped to sectors explicitly or inferred from the tab name, and all tabs are imported or none.
+// @Tags materials-profiles
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Excel file to upload"
+// @Param request formData string true "JSON request data containing maintenance_instance_id, optional sheet_sectors, import_profile_id and force"
+// @Success 200 {object} types.Response{data=types.EstimateWorkbookImportResult} "Estimate workbook imported successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/upload-estimate-workbook [post]
+func (h *materialProfileHandler) UploadEstimateWorkbook(ctx *gin.Context) {
+	var request types.UploadEstimateWorkbookRequest
+
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		h.logger.Warn("UploadEstimateWorkbook: Failed to get file from form", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "File is required: " + err.Error(),
+		})
+		return
+	}
+
+	requestStr := ctx.PostForm("request")
+	if requestStr == "" {
+		h.logger.Warn("UploadEstimateWorkbook: Missing request data")
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Request data is required",
+		})
+		return
+	}
+
+	if err := json.Unmarshal([]byte(requestStr), &request); err != nil {
+		h.logger.Warn("UploadEstimateWorkbook: Failed to parse request data", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	request.Sheet = file
+
+	result, err := h.materialProfileService.UploadEstimateWorkbook(ctx, &request)
+	if err != nil {
+		h.logger.Error("UploadEstimateWorkbook: Failed to import estimate workbook", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	message := "Estimate workbook imported successfully"
+	if result.Duplicate {
+		message = "Identical workbook was already imported, returning the earlier result"
+	}
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: message,
+		Data:    result,
 	})
 }
 
 // CreateNewMaterialsProfile godoc
 // @Summary Create a new materials profile
-// @Description Create a new materials profile for a specific maintenance instance and equipment machinery
+// @Description Create a new materials profile for a specific maintenance instance and equipment machinery, and empty profiles numbered below it for the equipments under it in the hierarchy when include_children is set
 // @Tags materials-profiles
 // @Accept json
 // @Produce json
@@ -217,36 +305,54 @@ func (h *materialProfileHandler) CreateNewMaterialsProfile(ctx *gin.Context) {
 }
 
 // PaginatedMaterialsProfiles godoc
-// @Summary Get paginated materials profiles
-// @Description Retrieve materials profiles with pagination
+// @Summary Search materials profiles with pagination
+// @Description Retrieve materials profiles matching the filters, one page at a time
 // @Tags materials-profiles
 // @Accept json
 // @Produce json
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Number of items per page" default(10)
-// @Success 200 {object} types.Response{data=object} "Paginated materials profiles retrieved successfully"
+// @Param maintenance_id query []string false "Maintenance IDs" collectionFormat(multi)
+// @Param sector query string false "Sector"
+// @Param project_code query string false "Project code"
+// @Param maintenance_tier query string false "Maintenance tier"
+// @Param year query int false "Maintenance year"
+// @Param equipment_name query string false "Part of the equipment name"
+// @Param material_name query string false "Part of a material name in estimate or reality"
+// @Param has_overrun query bool false "Only profiles where a material was consumed beyond its estimate"
+// @Param no_estimate query bool false "Only profiles without estimate"
+// @Param index_path query string false "Subtree such as 2.3"
+// @Param include_archived query bool false "Include archived profiles"
+// @Param sort_by query string false "Sort by index, equipment_name or overrun (quantity consumed beyond the estimates)" default(index)
+// @Param sort_desc query bool false "Sort in descending order"
+// @Success 200 {object} types.PaginatedResponse{data=types.PaginatedData{items=[]types.MaterialsProfileResponse}} "Paginated materials profiles retrieved successfully"
 // @Failure 400 {object} types.Response "Invalid request"
 // @Failure 500 {object} types.Response "Internal server error"
 // @Security BearerAuth
 // @Router /materials-profiles/paginated [get]
 func (h *materialProfileHandler) PaginatedMaterialsProfiles(ctx *gin.Context) {
-	page := ctx.DefaultQuery("page", "1")
-	limit := ctx.DefaultQuery("limit", "10")
-
-	var request types.PaginatedRequest
-	var err error
-	request.Page, err = strconv.ParseInt(page, 10, 64)
-	if err != nil || request.Page <= 0 {
-		h.logger.Warn("PaginatedMaterialsProfiles: Invalid page parameter", "page", page)
+	request := types.MaterialsProfileSearchRequest{
+		Page:  1,
+		Limit: 10,
+	}
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		h.logger.Warn("PaginatedMaterialsProfiles: Invalid query parameters", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if request.Page <= 0 {
+		h.logger.Warn("PaginatedMaterialsProfiles: Invalid page parameter", "page", request.Page)
 		ctx.JSON(http.StatusBadRequest, types.Response{
 			Status:  false,
 			Message: "Invalid page parameter",
 		})
 		return
 	}
-	request.Limit, err = strconv.ParseInt(limit, 10, 64)
-	if err != nil || request.Limit <= 0 {
-		h.logger.Warn("PaginatedMaterialsProfiles: Invalid limit parameter", "limit", limit)
+	if request.Limit <= 0 {
+		h.logger.Warn("PaginatedMaterialsProfiles: Invalid limit parameter", "limit", request.Limit)
 		ctx.JSON(http.StatusBadRequest, types.Response{
 			Status:  false,
 			Message: "Invalid limit parameter",
@@ -275,3 +381,443 @@ func (h *materialProfileHandler) PaginatedMaterialsProfiles(ctx *gin.Context) {
 		},
 	})
 }
+
+// ExportEstimateSheet godoc
+// @Summary Export estimate and reality of a sector to XLSX
+// @Description Download the materials profiles of a maintenance and sector in the layout accepted by the estimate upload, with extra reality and remaining columns that are ignored on re-import
+// @Tags materials-profiles
+// @Accept json
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param export body types.ExportEstimateSheetReq true "Export request data"
+// @Success 200 {file} file "XLSX file download"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/export-estimate [post]
+func (h *materialProfileHandler) ExportEstimateSheet(ctx *gin.Context) {
+	var request types.ExportEstimateSheetReq
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		h.logger.Warn("ExportEstimateSheet: Invalid request data", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	file, err := h.materialProfileService.ExportEstimateSheet(ctx, &request)
+	if err != nil {
+		h.logger.Error("ExportEstimateSheet: Failed to export estimate sheet", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to export estimate sheet: " + err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		h.logger.Error("ExportEstimateSheet: Failed to get file info", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to get file info",
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(file.Name())))
+	ctx.Header("Content-Transfer-Encoding", "binary")
+	ctx.Header("Expires", "0")
+	ctx.Header("Cache-Control", "must-revalidate")
+	ctx.Header("Pragma", "public")
+
+	ctx.DataFromReader(http.StatusOK, fileInfo.Size(), "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", file, nil)
+}
+
+// MaterialsProfileTree godoc
+// @Summary Materials profiles as a tree
+// @Description Nest the materials profiles of a maintenance sector by index path (system, subsystem, equipment) with the variance line counts rolled up at every level. Set index_path to get a subtree only, e.g. everything under "2.3"
+// @Tags materials-profiles
+// @Accept json
+// @Produce json
+// @Param request body types.MaterialsProfileTreeReq true "Materials profile tree request"
+// @Success 200 {object} types.Response{data=types.MaterialsProfileTree} "Materials profile tree retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/tree [post]
+func (h *materialProfileHandler) MaterialsProfileTree(ctx *gin.Context) {
+	var req types.MaterialsProfileTreeReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	tree, err := h.materialProfileService.MaterialsProfileTree(ctx, &req)
+	if err != nil {
+		h.logger.Error("MaterialsProfileTree: Failed to build materials profile tree", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to build materials profile tree: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Materials profile tree retrieved successfully",
+		Data:    tree,
+	})
+}
+
+// InsertMaterialsProfile godoc
+// @Summary Insert a materials profile
+// @Description Create a materials profile at an index path. When the path is taken, the profiles from that path onward are shifted one position together with their subtrees, e.g. inserting 2.2 moves 2.2 to 2.3 and 2.3.1 to 2.4.1. With include_children, empty profiles are numbered below it for the equipments under it in the hierarchy
+// @Tags materials-profiles
+// @Accept json
+// @Produce json
+// @Param materials_profile body types.CreateMaterialProfileReq true "Materials profile creation request"
+// @Success 200 {object} types.Response{data=string} "Materials profile inserted successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/insert [post]
+func (h *materialProfileHandler) InsertMaterialsProfile(ctx *gin.Context) {
+	var request types.CreateMaterialProfileReq
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		h.logger.Warn("InsertMaterialsProfile: Invalid request data", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	materialsProfileID, err := h.materialProfileService.InsertMaterialsProfile(ctx, &request)
+	if err != nil {
+		h.logger.Error("InsertMaterialsProfile: Failed to insert materials profile", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Materials profile inserted successfully",
+		Data:    materialsProfileID,
+	})
+}
+
+// MoveMaterialsProfile godoc
+// @Summary Move a materials profile
+// @Description Move the materials profile at an index path together with its subtree to another index path. The target must be free unless shift_siblings is set
+// @Tags materials-profiles
+// @Accept json
+// @Produce json
+// @Param request body types.MoveMaterialsProfileReq true "Materials profile move request"
+// @Success 200 {object} types.Response "Materials profile moved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/move [post]
+func (h *materialProfileHandler) MoveMaterialsProfile(ctx *gin.Context) {
+	var request types.MoveMaterialsProfileReq
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		h.logger.Warn("MoveMaterialsProfile: Invalid request data", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.materialProfileService.MoveMaterialsProfile(ctx, &request); err != nil {
+		h.logger.Error("MoveMaterialsProfile: Failed to move materials profile", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Materials profile moved successfully",
+	})
+}
+
+// ValidateIndexPaths godoc
+// @Summary Validate index paths
+// @Description List the index paths shared by several materials profiles of a maintenance sector
+// @Tags materials-profiles
+// @Accept json
+// @Produce json
+// @Param request body types.ValidateIndexPathsReq true "Index path validation request"
+// @Success 200 {object} types.Response{data=[]types.DuplicateIndexPath} "Index paths validated successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/validate-index [post]
+func (h *materialProfileHandler) ValidateIndexPaths(ctx *gin.Context) {
+	var request types.ValidateIndexPathsReq
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	duplicates, err := h.materialProfileService.ValidateIndexPaths(ctx, &request)
+	if err != nil {
+		h.logger.Error("ValidateIndexPaths: Failed to validate index paths", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Index paths validated successfully",
+		Data:    duplicates,
+	})
+}
+
+// CopyEstimates godoc
+// @Summary Seed estimates from a previous maintenance
+// @Description Copy the materials profiles (index, equipment, estimate) of the chosen sectors of a source maintenance into a target maintenance, optionally scaling every quantity. Reality is left empty and profiles already in the target are reported instead of duplicated
+// @Tags materials-profiles
+// @Accept json
+// @Produce json
+// @Param request body types.CopyEstimatesReq true "Copy estimates request"
+// @Success 200 {object} types.Response{data=types.CopyEstimatesResult} "Estimates copied successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/copy-estimates [post]
+func (h *materialProfileHandler) CopyEstimates(ctx *gin.Context) {
+	var request types.CopyEstimatesReq
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		h.logger.Warn("CopyEstimates: Invalid request data", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	result, err := h.materialProfileService.CopyEstimates(ctx, &request)
+	if err != nil {
+		h.logger.Error("CopyEstimates: Failed to copy estimates", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Estimates copied successfully",
+		Data:    result,
+	})
+}
+
+// DeleteMaterialsProfile godoc
+// @Summary Delete a materials profile
+// @Description Delete a materials profile created by mistake. Refused while material requests reference it, unless reassign_to_id names another profile of the same maintenance and sector to move the request lines and reality to
+// @Tags materials-profiles
+// @Accept json
+// @Produce json
+// @Param request body types.RemoveMaterialsProfileReq true "Materials profile removal request"
+// @Success 200 {object} types.Response "Materials profile deleted successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/delete [post]
+func (h *materialProfileHandler) DeleteMaterialsProfile(ctx *gin.Context) {
+	var request types.RemoveMaterialsProfileReq
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		h.logger.Warn("DeleteMaterialsProfile: Invalid request data", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.materialProfileService.DeleteMaterialsProfile(ctx, &request); err != nil {
+		h.logger.Error("DeleteMaterialsProfile: Failed to delete materials profile", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Materials profile deleted successfully",
+	})
+}
+
+// ArchiveMaterialsProfile godoc
+// @Summary Archive a materials profile
+// @Description Hide a materials profile from filters. Same reference checks and reassignment as deletion
+// @Tags materials-profiles
+// @Accept json
+// @Produce json
+// @Param request body types.RemoveMaterialsProfileReq true "Materials profile removal request"
+// @Success 200 {object} types.Response "Materials profile archived successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/archive [post]
+func (h *materialProfileHandler) ArchiveMaterialsProfile(ctx *gin.Context) {
+	var request types.RemoveMaterialsProfileReq
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		h.logger.Warn("ArchiveMaterialsProfile: Invalid request data", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.materialProfileService.ArchiveMaterialsProfile(ctx, &request); err != nil {
+		h.logger.Error("ArchiveMaterialsProfile: Failed to archive materials profile", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Materials profile archived successfully",
+	})
+}
+
+// RestoreMaterialsProfile godoc
+// @Summary Restore an archived materials profile
+// @Description Make an archived materials profile visible again
+// @Tags materials-profiles
+// @Accept json
+// @Produce json
+// @Param id path string true "Materials Profile ID"
+// @Success 200 {object} types.Response "Materials profile restored successfully"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/restore/{id} [post]
+func (h *materialProfileHandler) RestoreMaterialsProfile(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := h.materialProfileService.RestoreMaterialsProfile(ctx, id); err != nil {
+		h.logger.Error("RestoreMaterialsProfile: Failed to restore materials profile", "id", id, "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Materials profile restored successfully",
+	})
+}
+
+// EstimateUploadHistory godoc
+// @Summary List the estimate uploads of a maintenance
+// @Description List every uploaded estimate sheet and workbook of a maintenance, newest first, with its import result and a download link to the original file
+// @Tags materials-profiles
+// @Accept json
+// @Produce json
+// @Param request body types.EstimateUploadHistoryReq true "Upload history request"
+// @Success 200 {object} types.Response{data=[]types.EstimateUploadResponse} "Upload history retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/uploads [post]
+func (h *materialProfileHandler) EstimateUploadHistory(ctx *gin.Context) {
+	var request types.EstimateUploadHistoryReq
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		h.logger.Warn("EstimateUploadHistory: Invalid request data", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	history, err := h.materialProfileService.EstimateUploadHistory(ctx, &request)
+	if err != nil {
+		h.logger.Error("EstimateUploadHistory: Failed to list estimate uploads", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Upload history retrieved successfully",
+		Data:    history,
+	})
+}
+
+// DownloadEstimateUpload godoc
+// @Summary Download an uploaded estimate file
+// @Description Download the original file of an estimate upload
+// @Tags materials-profiles
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param id path string true "Estimate upload ID"
+// @Success 200 {file} file "XLSX file download"
+// @Failure 404 {object} types.Response "Estimate upload not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /materials-profiles/uploads/{id}/download [get]
+func (h *materialProfileHandler) DownloadEstimateUpload(ctx *gin.Context) {
+	id := ctx.Param("id")
+	upload, file, err := h.materialProfileService.OpenEstimateUpload(ctx, id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, types.ErrEstimateUploadNotFound) {
+			status = http.StatusNotFound
+		}
+		h.logger.Error("DownloadEstimateUpload: Failed to open estimate upload", "id", id, "error", err)
+		ctx.JSON(status, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		h.logger.Error("DownloadEstimateUpload: Failed to get file info", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to get file info",
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(upload.FileName)))
+	ctx.Header("Content-Transfer-Encoding", "binary")
+	ctx.Header("Expires", "0")
+	ctx.Header("Cache-Control", "must-revalidate")
+	ctx.Header("Pragma", "public")
+
+	ctx.DataFromReader(http.StatusOK, fileInfo.Size(), "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", file, nil)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
@@ -36,12 +37,12 @@ func NewMaterialRequestHandler(materialRequestService service.MaterialsRequestSe
 
 // CreateMaterialRequest godoc
 // @Summary Create a new material request
-// @Description Create a new material request with the provided details
+// @Description Create a new material request with the provided details. Catalog materials resembling the names of the lines that reference none are proposed with their similarity score
 // @Tags material-requests
 // @Accept json
 // @Produce json
 // @Param request body types.CreateMaterialRequestReq true "Material request data"
-// @Success 200 {object} types.Response{data=string} "Material request created successfully"
+// @Success 200 {object} types.Response{data=types.CreateMaterialRequestResult} "Material request created successfully"
 // @Failure 400 {object} types.Response "Invalid request data"
 // @Failure 500 {object} types.Response "Internal server error"
 // @Security BearerAuth
@@ -57,7 +58,7 @@ func (h *materialRequestHandler) CreateMaterialRequest(ctx *gin.Context) {
 		return
 	}
 
-	id, err := h.materialRequestService.CreateMaterialsRequest(
+	result, err := h.materialRequestService.CreateMaterialsRequest(
 		ctx,
 		&req,
 	)
@@ -73,7 +74,7 @@ func (h *materialRequestHandler) CreateMaterialRequest(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, types.Response{
 		Status:  true,
 		Message: "Material request created successfully",
-		Data:    id,
+		Data:    result,
 	})
 }
 
@@ -348,7 +349,11 @@ func (h *materialRequestHandler) CancelMaterialRequest(ctx *gin.Context) {
 	err := h.materialRequestService.DeleteMaterialsRequest(ctx, id)
 	if err != nil {
 		h.logger.Error("Failed to cancel material request: " + err.Error())
-		ctx.JSON(http.StatusInternalServerError, types.Response{
+		status := http.StatusInternalServerError
+		if errors.Is(err, types.ErrMaterialRequestIssued) {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, types.Response{
 			Status:  false,
 			Message: "Failed to cancel material request: " + err.Error(),
 		})
@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type PurchasingHandler interface {
+	CreatePurchaseRequisition(ctx *gin.Context)
+	GetPurchaseRequisition(ctx *gin.Context)
+	FilterPurchaseRequisitions(ctx *gin.Context)
+	UpdateRequisitionStatus(ctx *gin.Context)
+	ExportPurchaseRequisition(ctx *gin.Context)
+	ForecastShortages(ctx *gin.Context)
+	ExportShortageForecast(ctx *gin.Context)
+}
+
+type purchasingHandler struct {
+	purchasingService service.PurchasingService
+	logger            *logger.Logger
+}
+
+func NewPurchasingHandler(purchasingService service.PurchasingService, logger *logger.Logger) PurchasingHandler {
+	return &purchasingHandler{
+		purchasingService: purchasingService,
+		logger:            logger,
+	}
+}
+
+// purchasingErrorStatus maps the errors of the purchasing service to a status code.
+func purchasingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrPurchaseRequisitionNotFound), errors.Is(err, types.ErrMaintenanceNotFound), errors.Is(err, types.ErrWarehouseNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, types.ErrInvalidSector), errors.Is(err, types.ErrInvalidRequisitionStatus):
+		return http.StatusBadRequest
+	case errors.Is(err, types.ErrNothingToPurchase), errors.Is(err, types.ErrInvalidStatusTransition):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// CreatePurchaseRequisition godoc
+// @Summary Generate a purchase requisition
+// @Description Generate a draft purchase requisition from the remaining estimate of a maintenance, of one sector when given, minus the stock on hand and in transit, of the given warehouses or all, and the open requisitions, grouped by preferred supplier
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param request body types.CreatePurchaseRequisitionReq true "Purchase requisition request"
+// @Success 200 {object} types.Response{data=types.PurchaseRequisition} "Purchase requisition created successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Maintenance not found"
+// @Failure 409 {object} types.Response "Nothing left to purchase"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /purchasing/requisitions [post]
+func (h *purchasingHandler) CreatePurchaseRequisition(ctx *gin.Context) {
+	var req types.CreatePurchaseRequisitionReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	requisition, err := h.purchasingService.CreatePurchaseRequisition(ctx, &req)
+	if err != nil {
+		h.logger.Error("CreatePurchaseRequisition: Failed to create purchase requisition", "error", err)
+		ctx.JSON(purchasingErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to create purchase requisition: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Purchase requisition created successfully",
+		Data:    requisition,
+	})
+}
+
+// GetPurchaseRequisition godoc
+// @Summary Get purchase requisition by ID
+// @Description Retrieve a specific purchase requisition using its ID
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param id path string true "Purchase requisition ID"
+// @Success 200 {object} types.Response{data=types.PurchaseRequisition} "Purchase requisition retrieved successfully"
+// @Failure 404 {object} types.Response "Purchase requisition not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /purchasing/requisitions/{id} [get]
+func (h *purchasingHandler) GetPurchaseRequisition(ctx *gin.Context) {
+	id := ctx.Param("id")
+	requisition, err := h.purchasingService.GetPurchaseRequisition(ctx, id)
+	if err != nil {
+		ctx.JSON(purchasingErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Purchase requisition retrieved successfully",
+		Data:    requisition,
+	})
+}
+
+// FilterPurchaseRequisitions godoc
+// @Summary Filter purchase requisitions
+// @Description Retrieve the purchase requisitions of a maintenance, sector or statuses, sorted by number
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param request body types.PurchaseRequisitionFilterReq true "Purchase requisition filter"
+// @Success 200 {object} types.Response{data=[]types.PurchaseRequisition} "Purchase requisitions retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /purchasing/requisitions/filter [post]
+func (h *purchasingHandler) FilterPurchaseRequisitions(ctx *gin.Context) {
+	var req types.PurchaseRequisitionFilterReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	requisitions, err := h.purchasingService.FilterPurchaseRequisitions(ctx, &req)
+	if err != nil {
+		h.logger.Error("FilterPurchaseRequisitions: Failed to filter purchase requisitions", "error", err)
+		ctx.JSON(purchasingErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Purchase requisitions retrieved successfully",
+		Data:    requisitions,
+	})
+}
+
+// UpdateRequisitionStatus godoc
+// @Summary Update the status of a purchase requisition
+// @Description Move a purchase requisition from draft to ordered and from ordered to received, or cancel it. Draft and ordered requisitions count as open purchases.
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param request body types.UpdateRequisitionStatusReq true "Status update"
+// @Success 200 {object} types.Response "Purchase requisition status updated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Purchase requisition not found"
+// @Failure 409 {object} types.Response "Status transition not allowed"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /purchasing/requisitions/status [post]
+func (h *purchasingHandler) UpdateRequisitionStatus(ctx *gin.Context) {
+	var req types.UpdateRequisitionStatusReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.purchasingService.UpdateRequisitionStatus(ctx, &req); err != nil {
+		h.logger.Error("UpdateRequisitionStatus: Failed to update purchase requisition status", "error", err)
+		ctx.JSON(purchasingErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to update purchase requisition status: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Purchase requisition status updated successfully",
+	})
+}
+
+// ExportPurchaseRequisition godoc
+// @Summary Export a purchase requisition to DOCX
+// @Description Export a purchase requisition to a printable DOCX document with one table per supplier
+// @Tags purchasing
+// @Produce application/vnd.openxmlformats-officedocument.wordprocessingml.document
+// @Param id path string true "Purchase requisition ID"
+// @Success 200 {file} file "DOCX file download"
+// @Failure 404 {object} types.Response "Purchase requisition not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /purchasing/requisitions/export/{id} [post]
+func (h *purchasingHandler) ExportPurchaseRequisition(ctx *gin.Context) {
+	id := ctx.Param("id")
+	file, err := h.purchasingService.ExportPurchaseRequisition(ctx, id)
+	if err != nil {
+		h.logger.Error("ExportPurchaseRequisition: Failed to export purchase requisition", "error", err)
+		ctx.JSON(purchasingErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to export purchase requisition: " + err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		h.logger.Error("ExportPurchaseRequisition: Failed to get file info", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to get file info",
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(file.Name())))
+	ctx.Header("Content-Transfer-Encoding", "binary")
+	ctx.Header("Expires", "0")
+	ctx.Header("Cache-Control", "must-revalidate")
+	ctx.Header("Pragma", "public")
+
+	ctx.DataFromReader(http.StatusOK, fileInfo.Size(), "application/vnd.openxmlformats-officedocument.wordprocessingml.document", file, nil)
+}
+
+// ForecastShortages godoc
+// @Summary Forecast the material shortages of maintenances
+// @Description List the materials upcoming maintenances will be short of: the remaining estimate, of one sector when given, minus the stock on hand and in transit, of the given warehouses or all, allocated to the maintenances starting first, and the open requisitions of each maintenance. Each shortage is needed by the start date of its maintenance and must be ordered by that date minus the lead time of the preferred supplier.
+// @Tags purchasing
+// @Accept json
+// @Produce json
+// @Param request body types.ShortageForecastReq true "Shortage forecast request"
+// @Success 200 {object} types.Response{data=types.ShortageForecast} "Shortage forecast generated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Maintenance not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /purchasing/forecast [post]
+func (h *purchasingHandler) ForecastShortages(ctx *gin.Context) {
+	var req types.ShortageForecastReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	forecast, err := h.purchasingService.ForecastShortages(ctx, &req)
+	if err != nil {
+		h.logger.Error("ForecastShortages: Failed to forecast shortages", "error", err)
+		ctx.JSON(purchasingErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to forecast shortages: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Shortage forecast generated successfully",
+		Data:    forecast,
+	})
+}
+
+// ExportShortageForecast godoc
+// @Summary Export the shortage forecast to XLSX
+// @Description Download the material shortage forecast of maintenances as a workbook
+// @Tags purchasing
+// @Accept json
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param request body types.ShortageForecastReq true "Shortage forecast request"
+// @Success 200 {file} file "XLSX file download"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Maintenance not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /purchasing/forecast/export [post]
+func (h *purchasingHandler) ExportShortageForecast(ctx *gin.Context) {
+	var req types.ShortageForecastReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	file, err := h.purchasingService.ExportShortageForecast(ctx, &req)
+	if err != nil {
+		h.logger.Error("ExportShortageForecast: Failed to export shortage forecast", "error", err)
+		ctx.JSON(purchasingErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to export shortage forecast: " + err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		h.logger.Error("ExportShortageForecast: Failed to get file info", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to get file info",
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(file.Name())))
+	ctx.Header("Content-Transfer-Encoding", "binary")
+	ctx.Header("Expires", "0")
+	ctx.Header("Cache-Control", "must-revalidate")
+	ctx.Header("Pragma", "public")
+
+	ctx.DataFromReader(http.StatusOK, fileInfo.Size(), "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", file, nil)
+}
@@ -0,0 +1,302 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type ReportHandler interface {
+	VarianceReport(ctx *gin.Context)
+	ExportVarianceReport(ctx *gin.Context)
+	CostReport(ctx *gin.Context)
+	ExportCostReport(ctx *gin.Context)
+	ProfileCost(ctx *gin.Context)
+	RequestCost(ctx *gin.Context)
+}
+
+type reportHandler struct {
+	reportService service.ReportService
+	logger        *logger.Logger
+}
+
+func NewReportHandler(reportService service.ReportService, logger *logger.Logger) ReportHandler {
+	return &reportHandler{
+		reportService: reportService,
+		logger:        logger,
+	}
+}
+
+// costErrorStatus maps the errors of the cost reports to a status code.
+func costErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrInvalidSector):
+		return http.StatusBadRequest
+	case errors.Is(err, types.ErrMaintenanceNotFound),
+		errors.Is(err, types.ErrSomeMaterialsProfileNotFound),
+		errors.Is(err, types.ErrMaterialRequestNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// VarianceReport godoc
+// @Summary Estimate versus reality variance report
+// @Description Compare estimated and consumed quantities per material line and count the overrun, unestimated and mismatched lines per equipment, sector and maintenance
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Param request body types.VarianceReportReq true "Variance report request"
+// @Success 200 {object} types.Response{data=types.VarianceReport} "Variance report generated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /reports/variance [post]
+func (h *reportHandler) VarianceReport(ctx *gin.Context) {
+	var req types.VarianceReportReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	report, err := h.reportService.VarianceReport(ctx, &req)
+	if err != nil {
+		h.logger.Error("VarianceReport: Failed to generate variance report", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to generate variance report: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Variance report generated successfully",
+		Data:    report,
+	})
+}
+
+// ExportVarianceReport godoc
+// @Summary Export variance report to XLSX
+// @Description Download the estimate versus reality variance report as a workbook
+// @Tags reports
+// @Accept json
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param request body types.VarianceReportReq true "Variance report request"
+// @Success 200 {file} file "XLSX file download"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /reports/variance/export [post]
+func (h *reportHandler) ExportVarianceReport(ctx *gin.Context) {
+	var req types.VarianceReportReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	file, err := h.reportService.ExportVarianceReport(ctx, &req)
+	if err != nil {
+		h.logger.Error("ExportVarianceReport: Failed to export variance report", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to export variance report: " + err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		h.logger.Error("ExportVarianceReport: Failed to get file info", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to get file info",
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(file.Name())))
+	ctx.Header("Content-Transfer-Encoding", "binary")
+	ctx.Header("Expires", "0")
+	ctx.Header("Cache-Control", "must-revalidate")
+	ctx.Header("Pragma", "public")
+
+	ctx.DataFromReader(http.StatusOK, fileInfo.Size(), "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", file, nil)
+}
+
+// CostReport godoc
+// @Summary Material cost report
+// @Description Price the estimate and reality of every materials profile of a maintenance at a date, and every material request at its request date, per equipment, sector and maintenance. Lines are priced from their own unit price or from the catalog price effective at the date.
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Param request body types.CostReportReq true "Cost report request"
+// @Success 200 {object} types.Response{data=types.CostReport} "Cost report generated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Maintenance not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /reports/cost [post]
+func (h *reportHandler) CostReport(ctx *gin.Context) {
+	var req types.CostReportReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	report, err := h.reportService.CostReport(ctx, &req)
+	if err != nil {
+		h.logger.Error("CostReport: Failed to generate cost report", "error", err)
+		ctx.JSON(costErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to generate cost report: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Cost report generated successfully",
+		Data:    report,
+	})
+}
+
+// ExportCostReport godoc
+// @Summary Export cost report to XLSX
+// @Description Download the material cost report as a workbook with the equipments and the material requests
+// @Tags reports
+// @Accept json
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param request body types.CostReportReq true "Cost report request"
+// @Success 200 {file} file "XLSX file download"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Maintenance not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /reports/cost/export [post]
+func (h *reportHandler) ExportCostReport(ctx *gin.Context) {
+	var req types.CostReportReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	file, err := h.reportService.ExportCostReport(ctx, &req)
+	if err != nil {
+		h.logger.Error("ExportCostReport: Failed to export cost report", "error", err)
+		ctx.JSON(costErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to export cost report: " + err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		h.logger.Error("ExportCostReport: Failed to get file info", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to get file info",
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(file.Name())))
+	ctx.Header("Content-Transfer-Encoding", "binary")
+	ctx.Header("Expires", "0")
+	ctx.Header("Cache-Control", "must-revalidate")
+	ctx.Header("Pragma", "public")
+
+	ctx.DataFromReader(http.StatusOK, fileInfo.Size(), "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", file, nil)
+}
+
+// ProfileCost godoc
+// @Summary Cost of a materials profile
+// @Description Price every estimate and reality line of a materials profile at a date
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Param id path string true "Materials profile ID"
+// @Param at query int false "Unix time of the prices, now when omitted"
+// @Success 200 {object} types.Response{data=types.ProfileCost} "Profile cost retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 404 {object} types.Response "Materials profile not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /reports/cost/profile/{id} [get]
+func (h *reportHandler) ProfileCost(ctx *gin.Context) {
+	var req types.ProfileCostRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+
+	cost, err := h.reportService.ProfileCost(ctx, ctx.Param("id"), req.At)
+	if err != nil {
+		h.logger.Error("ProfileCost: Failed to price materials profile", "error", err)
+		ctx.JSON(costErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Profile cost retrieved successfully",
+		Data:    cost,
+	})
+}
+
+// RequestCost godoc
+// @Summary Cost of a material request
+// @Description Price every line of a material request at its request date
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Param id path string true "Material request ID"
+// @Success 200 {object} types.Response{data=types.RequestCost} "Request cost retrieved successfully"
+// @Failure 404 {object} types.Response "Material request not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /reports/cost/request/{id} [get]
+func (h *reportHandler) RequestCost(ctx *gin.Context) {
+	cost, err := h.reportService.RequestCost(ctx, ctx.Param("id"))
+	if err != nil {
+		h.logger.Error("RequestCost: Failed to price material request", "error", err)
+		ctx.JSON(costErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Request cost retrieved successfully",
+		Data:    cost,
+	})
+}
@@ -0,0 +1,621 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type StockHandler interface {
+	ReceiveMaterials(ctx *gin.Context)
+	IssueMaterials(ctx *gin.Context)
+	ReturnMaterials(ctx *gin.Context)
+	AdjustStock(ctx *gin.Context)
+	RecordStockCount(ctx *gin.Context)
+	StockBalances(ctx *gin.Context)
+	StockLedger(ctx *gin.Context)
+	CreateTransfer(ctx *gin.Context)
+	ReceiveTransfer(ctx *gin.Context)
+	CancelTransfer(ctx *gin.Context)
+	GetTransfer(ctx *gin.Context)
+	SearchTransfers(ctx *gin.Context)
+	ExportTransferNote(ctx *gin.Context)
+}
+
+type stockHandler struct {
+	stockService service.StockService
+	logger       *logger.Logger
+}
+
+func NewStockHandler(stockService service.StockService, logger *logger.Logger) StockHandler {
+	return &stockHandler{
+		stockService: stockService,
+		logger:       logger,
+	}
+}
+
+// stockErrorStatus maps the errors of the stock service to a status code.
+func stockErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrCatalogMaterialNotFound),
+		errors.Is(err, types.ErrSomeCatalogMaterialNotFound),
+		errors.Is(err, types.ErrMaterialRequestNotFound),
+		errors.Is(err, types.ErrMaterialRequestLineNotFound),
+		errors.Is(err, types.ErrPurchaseRequisitionNotFound),
+		errors.Is(err, types.ErrWarehouseNotFound),
+		errors.Is(err, types.ErrStockTransferNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, types.ErrInvalidStockQuantity),
+		errors.Is(err, types.ErrIncompatibleUnit),
+		errors.Is(err, types.ErrInvalidStockMovementKind),
+		errors.Is(err, types.ErrMaterialNotInCatalog),
+		errors.Is(err, types.ErrSameWarehouse),
+		errors.Is(err, types.ErrInvalidTransferStatus),
+		errors.Is(err, types.ErrTrackingOnConsumable),
+		errors.Is(err, types.ErrSerialCountMismatch),
+		errors.Is(err, types.ErrDuplicateSerial):
+		return http.StatusBadRequest
+	case errors.Is(err, types.ErrInsufficientStock),
+		errors.Is(err, types.ErrMaterialRequestNotNumbered),
+		errors.Is(err, types.ErrIssueExceedsRequest),
+		errors.Is(err, types.ErrReturnExceedsIssued),
+		errors.Is(err, types.ErrNothingToIssue),
+		errors.Is(err, types.ErrNoWarehouseForSector),
+		errors.Is(err, types.ErrSeveralWarehousesForSector),
+		errors.Is(err, types.ErrTransferNotInTransit),
+		errors.Is(err, types.ErrSerialAlreadyInstalled),
+		errors.Is(err, types.ErrSerialNotIssued):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// ReceiveMaterials godoc
+// @Summary Receive materials into a warehouse
+// @Description Record a receipt of catalog materials, optionally against a purchase requisition
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param request body types.StockReceiptReq true "Receipt"
+// @Success 200 {object} types.Response{data=types.StockMovement} "Materials received successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Catalog material or purchase requisition not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/receipts [post]
+func (h *stockHandler) ReceiveMaterials(ctx *gin.Context) {
+	var req types.StockReceiptReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	movement, err := h.stockService.ReceiveMaterials(ctx, &req)
+	if err != nil {
+		h.logger.Error("ReceiveMaterials: Failed to receive materials", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to receive materials: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Materials received successfully",
+		Data:    movement,
+	})
+}
+
+// IssueMaterials godoc
+// @Summary Issue materials against a material request
+// @Description Issue lines of a numbered material request from a warehouse, all the outstanding catalog lines when none are given, with the serial or lot numbers of the replacement parts
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param request body types.IssueMaterialsReq true "Issue"
+// @Success 200 {object} types.Response{data=types.StockMovement} "Materials issued successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Material request or line not found"
+// @Failure 409 {object} types.Response "Insufficient stock or more than requested"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/issues [post]
+func (h *stockHandler) IssueMaterials(ctx *gin.Context) {
+	var req types.IssueMaterialsReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	movement, err := h.stockService.IssueMaterials(ctx, &req)
+	if err != nil {
+		h.logger.Error("IssueMaterials: Failed to issue materials", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to issue materials: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Materials issued successfully",
+		Data:    movement,
+	})
+}
+
+// ReturnMaterials godoc
+// @Summary Return materials issued against a material request
+// @Description Take back into a warehouse materials issued against a material request
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param request body types.ReturnMaterialsReq true "Return"
+// @Success 200 {object} types.Response{data=types.StockMovement} "Materials returned successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Material request or line not found"
+// @Failure 409 {object} types.Response "More than issued"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/returns [post]
+func (h *stockHandler) ReturnMaterials(ctx *gin.Context) {
+	var req types.ReturnMaterialsReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	movement, err := h.stockService.ReturnMaterials(ctx, &req)
+	if err != nil {
+		h.logger.Error("ReturnMaterials: Failed to return materials", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to return materials: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Materials returned successfully",
+		Data:    movement,
+	})
+}
+
+// AdjustStock godoc
+// @Summary Adjust the stock
+// @Description Correct the balances of a warehouse by signed quantities
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param request body types.StockAdjustmentReq true "Adjustment"
+// @Success 200 {object} types.Response{data=types.StockMovement} "Stock adjusted successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Catalog material not found"
+// @Failure 409 {object} types.Response "Insufficient stock"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/adjustments [post]
+func (h *stockHandler) AdjustStock(ctx *gin.Context) {
+	var req types.StockAdjustmentReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	movement, err := h.stockService.AdjustStock(ctx, &req)
+	if err != nil {
+		h.logger.Error("AdjustStock: Failed to adjust stock", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to adjust stock: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Stock adjusted successfully",
+		Data:    movement,
+	})
+}
+
+// RecordStockCount godoc
+// @Summary Record a stock count
+// @Description Set the quantity on hand of a catalog material in a warehouse, converted to the default unit of the material, by an adjustment
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param request body types.RecordStockCountReq true "Stock count"
+// @Success 200 {object} types.Response{data=types.StockMovement} "Stock count recorded successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Catalog material not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/count [post]
+func (h *stockHandler) RecordStockCount(ctx *gin.Context) {
+	var req types.RecordStockCountReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	movement, err := h.stockService.RecordStockCount(ctx, &req)
+	if err != nil {
+		h.logger.Error("RecordStockCount: Failed to record stock count", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to record stock count: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Stock count recorded successfully",
+		Data:    movement,
+	})
+}
+
+// StockBalances godoc
+// @Summary Get the stock balances
+// @Description Retrieve the quantity on hand per catalog material and warehouse
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param catalog_id query string false "Catalog material ID"
+// @Param warehouse query string false "Warehouse"
+// @Success 200 {object} types.Response{data=[]types.StockBalance} "Stock balances retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/balances [get]
+func (h *stockHandler) StockBalances(ctx *gin.Context) {
+	var req types.StockBalanceRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+
+	balances, err := h.stockService.StockBalances(ctx, &req)
+	if err != nil {
+		h.logger.Error("StockBalances: Failed to get stock balances", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Stock balances retrieved successfully",
+		Data:    balances,
+	})
+}
+
+// StockLedger godoc
+// @Summary Search the stock ledger
+// @Description Retrieve the stock movements, latest first
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param kind query string false "Movement kind: receipt, issue, return or adjustment"
+// @Param warehouse query string false "Warehouse"
+// @Param catalog_id query string false "Catalog material ID"
+// @Param material_request_id query string false "Material request ID"
+// @Param from query int false "Created from (unix seconds)"
+// @Param to query int false "Created to (unix seconds)"
+// @Success 200 {object} types.PaginatedResponse{data=types.PaginatedData{items=[]types.StockMovement}} "Stock movements retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/movements [get]
+func (h *stockHandler) StockLedger(ctx *gin.Context) {
+	request := types.StockMovementSearchRequest{
+		Page:  1,
+		Limit: 20,
+	}
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		h.logger.Warn("StockLedger: Invalid query parameters", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if request.Page <= 0 || request.Limit <= 0 {
+		h.logger.Warn("StockLedger: Invalid pagination parameters", "page", request.Page, "limit", request.Limit)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid pagination parameters",
+		})
+		return
+	}
+
+	movements, total, err := h.stockService.StockLedger(ctx, &request)
+	if err != nil {
+		h.logger.Error("StockLedger: Failed to search stock movements", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.PaginatedResponse{
+		Status:  true,
+		Message: "Stock movements retrieved successfully",
+		Data: types.PaginatedData{
+			Total: total,
+			Page:  request.Page,
+			Limit: request.Limit,
+			Items: movements,
+		},
+	})
+}
+
+// CreateTransfer godoc
+// @Summary Ship materials to another warehouse
+// @Description Take materials out of a warehouse, in transit until received into the other warehouse
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param request body types.CreateStockTransferReq true "Transfer"
+// @Success 200 {object} types.Response{data=types.StockTransfer} "Transfer created successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Warehouse or catalog material not found"
+// @Failure 409 {object} types.Response "Insufficient stock"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/transfers [post]
+func (h *stockHandler) CreateTransfer(ctx *gin.Context) {
+	var req types.CreateStockTransferReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	transfer, err := h.stockService.CreateTransfer(ctx, &req)
+	if err != nil {
+		h.logger.Error("CreateTransfer: Failed to create transfer", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to create transfer: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Transfer created successfully",
+		Data:    transfer,
+	})
+}
+
+// ReceiveTransfer godoc
+// @Summary Receive a transfer
+// @Description Put the materials of a transfer in transit into its destination warehouse
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} types.Response{data=types.StockTransfer} "Transfer received successfully"
+// @Failure 404 {object} types.Response "Transfer not found"
+// @Failure 409 {object} types.Response "Transfer not in transit"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/transfers/receive/{id} [post]
+func (h *stockHandler) ReceiveTransfer(ctx *gin.Context) {
+	id := ctx.Param("id")
+	transfer, err := h.stockService.ReceiveTransfer(ctx, id)
+	if err != nil {
+		h.logger.Error("ReceiveTransfer: Failed to receive transfer", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to receive transfer: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Transfer received successfully",
+		Data:    transfer,
+	})
+}
+
+// CancelTransfer godoc
+// @Summary Cancel a transfer
+// @Description Put the materials of a transfer in transit back into its origin warehouse
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} types.Response{data=types.StockTransfer} "Transfer cancelled successfully"
+// @Failure 404 {object} types.Response "Transfer not found"
+// @Failure 409 {object} types.Response "Transfer not in transit"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/transfers/cancel/{id} [post]
+func (h *stockHandler) CancelTransfer(ctx *gin.Context) {
+	id := ctx.Param("id")
+	transfer, err := h.stockService.CancelTransfer(ctx, id)
+	if err != nil {
+		h.logger.Error("CancelTransfer: Failed to cancel transfer", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to cancel transfer: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Transfer cancelled successfully",
+		Data:    transfer,
+	})
+}
+
+// GetTransfer godoc
+// @Summary Get transfer by ID
+// @Description Retrieve a specific stock transfer using its ID
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} types.Response{data=types.StockTransfer} "Transfer retrieved successfully"
+// @Failure 404 {object} types.Response "Transfer not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/transfers/{id} [get]
+func (h *stockHandler) GetTransfer(ctx *gin.Context) {
+	id := ctx.Param("id")
+	transfer, err := h.stockService.GetTransfer(ctx, id)
+	if err != nil {
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Transfer retrieved successfully",
+		Data:    transfer,
+	})
+}
+
+// SearchTransfers godoc
+// @Summary Search the stock transfers
+// @Description Retrieve a page of stock transfers, latest first
+// @Tags stock
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param warehouse query string false "Origin or destination warehouse"
+// @Param status query string false "Status: in_transit, received or cancelled"
+// @Param catalog_id query string false "Catalog material ID"
+// @Success 200 {object} types.PaginatedResponse{data=types.PaginatedData{items=[]types.StockTransfer}} "Transfers retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/transfers [get]
+func (h *stockHandler) SearchTransfers(ctx *gin.Context) {
+	request := types.StockTransferSearchRequest{
+		Page:  1,
+		Limit: 20,
+	}
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		h.logger.Warn("SearchTransfers: Invalid query parameters", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if request.Page <= 0 || request.Limit <= 0 {
+		h.logger.Warn("SearchTransfers: Invalid pagination parameters", "page", request.Page, "limit", request.Limit)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid pagination parameters",
+		})
+		return
+	}
+
+	transfers, total, err := h.stockService.SearchTransfers(ctx, &request)
+	if err != nil {
+		h.logger.Error("SearchTransfers: Failed to search transfers", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.PaginatedResponse{
+		Status:  true,
+		Message: "Transfers retrieved successfully",
+		Data: types.PaginatedData{
+			Total: total,
+			Page:  request.Page,
+			Limit: request.Limit,
+			Items: transfers,
+		},
+	})
+}
+
+// ExportTransferNote godoc
+// @Summary Export a transfer note to DOCX
+// @Description Export a stock transfer to a printable DOCX transfer note
+// @Tags stock
+// @Produce application/vnd.openxmlformats-officedocument.wordprocessingml.document
+// @Param id path string true "Transfer ID"
+// @Success 200 {file} file "DOCX file download"
+// @Failure 404 {object} types.Response "Transfer not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /stock/transfers/export/{id} [post]
+func (h *stockHandler) ExportTransferNote(ctx *gin.Context) {
+	id := ctx.Param("id")
+	file, err := h.stockService.ExportTransferNote(ctx, id)
+	if err != nil {
+		h.logger.Error("ExportTransferNote: Failed to export transfer note", "error", err)
+		ctx.JSON(stockErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to export transfer note: " + err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		h.logger.Error("ExportTransferNote: Failed to get file info", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to get file info",
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(file.Name())))
+	ctx.Header("Content-Transfer-Encoding", "binary")
+	ctx.Header("Expires", "0")
+	ctx.Header("Cache-Control", "must-revalidate")
+	ctx.Header("Pragma", "public")
+
+	ctx.DataFromReader(http.StatusOK, fileInfo.Size(), "application/vnd.openxmlformats-officedocument.wordprocessingml.document", file, nil)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type SuggestionHandler interface {
+	SuggestEstimate(ctx *gin.Context)
+}
+
+type suggestionHandler struct {
+	suggestionService service.SuggestionService
+	logger            *logger.Logger
+}
+
+func NewSuggestionHandler(suggestionService service.SuggestionService, logger *logger.Logger) SuggestionHandler {
+	return &suggestionHandler{
+		suggestionService: suggestionService,
+		logger:            logger,
+	}
+}
+
+// SuggestEstimate godoc
+// @Summary Suggest an estimate from historical consumption
+// @Description Aggregate the past reality of an equipment over maintenances of the same tier and return per-material mean, median, max, occurrences and a suggested estimate. Pass materials_profile_id to review an existing profile against its current estimate
+// @Tags suggestions
+// @Accept json
+// @Produce json
+// @Param request body types.EstimateSuggestionReq true "Estimate suggestion request"
+// @Success 200 {object} types.Response{data=types.EstimateSuggestion} "Estimate suggestion generated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /suggestions/estimate [post]
+func (h *suggestionHandler) SuggestEstimate(ctx *gin.Context) {
+	var req types.EstimateSuggestionReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	suggestion, err := h.suggestionService.SuggestEstimate(ctx, &req)
+	if err != nil {
+		h.logger.Error("SuggestEstimate: Failed to suggest estimate", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: "Failed to suggest estimate: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Estimate suggestion generated successfully",
+		Data:    suggestion,
+	})
+}
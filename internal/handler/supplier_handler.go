@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type SupplierHandler interface {
+	CreateSupplier(ctx *gin.Context)
+	UpdateSupplier(ctx *gin.Context)
+	GetSupplier(ctx *gin.Context)
+	SearchSuppliers(ctx *gin.Context)
+	DeleteSupplier(ctx *gin.Context)
+}
+
+type supplierHandler struct {
+	supplierService service.SupplierService
+	logger          *logger.Logger
+}
+
+func NewSupplierHandler(supplierService service.SupplierService, logger *logger.Logger) SupplierHandler {
+	return &supplierHandler{
+		supplierService: supplierService,
+		logger:          logger,
+	}
+}
+
+// supplierErrorStatus maps the errors of the supplier service to a status code.
+func supplierErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrSupplierNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, types.ErrSomeCatalogMaterialNotFound),
+		errors.Is(err, types.ErrInvalidPreferredMaterial),
+		errors.Is(err, types.ErrInvalidLeadTime):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// CreateSupplier godoc
+// @Summary Create a supplier
+// @Description Register a supplier with its contacts, lead time, the catalog materials it supplies and those it is preferred for
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Param request body types.CreateSupplierReq true "Supplier creation request"
+// @Success 200 {object} types.Response{data=string} "Supplier created successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /suppliers [post]
+func (h *supplierHandler) CreateSupplier(ctx *gin.Context) {
+	var req types.CreateSupplierReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := h.supplierService.CreateSupplier(ctx, &req)
+	if err != nil {
+		h.logger.Error("CreateSupplier: Failed to create supplier", "error", err)
+		ctx.JSON(supplierErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to create supplier: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Supplier created successfully",
+		Data:    id,
+	})
+}
+
+// UpdateSupplier godoc
+// @Summary Update a supplier
+// @Description Replace the contacts, lead time and supplied materials of a supplier
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Param request body types.UpdateSupplierReq true "Supplier update request"
+// @Success 200 {object} types.Response "Supplier updated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Supplier not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /suppliers/update [post]
+func (h *supplierHandler) UpdateSupplier(ctx *gin.Context) {
+	var req types.UpdateSupplierReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.supplierService.UpdateSupplier(ctx, &req); err != nil {
+		h.logger.Error("UpdateSupplier: Failed to update supplier", "error", err)
+		ctx.JSON(supplierErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to update supplier: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Supplier updated successfully",
+	})
+}
+
+// GetSupplier godoc
+// @Summary Get supplier by ID
+// @Description Retrieve a specific supplier using its ID
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Param id path string true "Supplier ID"
+// @Success 200 {object} types.Response{data=types.Supplier} "Supplier retrieved successfully"
+// @Failure 404 {object} types.Response "Supplier not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /suppliers/{id} [get]
+func (h *supplierHandler) GetSupplier(ctx *gin.Context) {
+	id := ctx.Param("id")
+	supplier, err := h.supplierService.GetSupplier(ctx, id)
+	if err != nil {
+		ctx.JSON(supplierErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Supplier retrieved successfully",
+		Data:    supplier,
+	})
+}
+
+// SearchSuppliers godoc
+// @Summary Search the suppliers
+// @Description Retrieve a page of suppliers sorted by name, matching part of the name or of a contact name
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param q query string false "Part of the name or of a contact name"
+// @Param catalog_id query string false "Catalog material supplied"
+// @Success 200 {object} types.PaginatedResponse{data=types.PaginatedData{items=[]types.Supplier}} "Suppliers retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /suppliers/search [get]
+func (h *supplierHandler) SearchSuppliers(ctx *gin.Context) {
+	request := types.SupplierSearchRequest{
+		Page:  1,
+		Limit: 20,
+	}
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		h.logger.Warn("SearchSuppliers: Invalid query parameters", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if request.Page <= 0 || request.Limit <= 0 {
+		h.logger.Warn("SearchSuppliers: Invalid pagination parameters", "page", request.Page, "limit", request.Limit)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid pagination parameters",
+		})
+		return
+	}
+
+	suppliers, total, err := h.supplierService.SearchSuppliers(ctx, &request)
+	if err != nil {
+		h.logger.Error("SearchSuppliers: Failed to search suppliers", "error", err)
+		ctx.JSON(http.StatusInternalServerError, types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.PaginatedResponse{
+		Status:  true,
+		Message: "Suppliers retrieved successfully",
+		Data: types.PaginatedData{
+			Total: total,
+			Page:  request.Page,
+			Limit: request.Limit,
+			Items: suppliers,
+		},
+	})
+}
+
+// DeleteSupplier godoc
+// @Summary Delete a supplier
+// @Description Delete a supplier, purchase requisitions keep its name
+// @Tags suppliers
+// @Accept json
+// @Produce json
+// @Param id path string true "Supplier ID"
+// @Success 200 {object} types.Response "Supplier deleted successfully"
+// @Failure 404 {object} types.Response "Supplier not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /suppliers/delete/{id} [post]
+func (h *supplierHandler) DeleteSupplier(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := h.supplierService.DeleteSupplier(ctx, id); err != nil {
+		h.logger.Error("DeleteSupplier: Failed to delete supplier", "error", err)
+		ctx.JSON(supplierErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to delete supplier: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Supplier deleted successfully",
+	})
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type TraceabilityHandler interface {
+	SerialHistory(ctx *gin.Context)
+	SearchInstalledParts(ctx *gin.Context)
+}
+
+type traceabilityHandler struct {
+	traceabilityService service.TraceabilityService
+	logger              *logger.Logger
+}
+
+func NewTraceabilityHandler(traceabilityService service.TraceabilityService, logger *logger.Logger) TraceabilityHandler {
+	return &traceabilityHandler{
+		traceabilityService: traceabilityService,
+		logger:              logger,
+	}
+}
+
+// traceabilityErrorStatus maps the errors of the traceability service to a status code.
+func traceabilityErrorStatus(err error) int {
+	if errors.Is(err, types.ErrMissingPartQuery) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// SerialHistory godoc
+// @Summary Find where a serial number was fitted
+// @Description Retrieve the ships and equipments a replacement part with this serial number was issued to, latest first, including the pieces returned to stock since
+// @Tags traceability
+// @Accept json
+// @Produce json
+// @Param serial path string true "Serial number"
+// @Success 200 {object} types.Response{data=[]types.InstalledPart} "Installed parts retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /traceability/serials/{serial} [get]
+func (h *traceabilityHandler) SerialHistory(ctx *gin.Context) {
+	serial := ctx.Param("serial")
+	parts, err := h.traceabilityService.SerialHistory(ctx, serial)
+	if err != nil {
+		h.logger.Error("SerialHistory: Failed to find serial number", "error", err)
+		ctx.JSON(traceabilityErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Installed parts retrieved successfully",
+		Data:    parts,
+	})
+}
+
+// SearchInstalledParts godoc
+// @Summary Search the installed parts
+// @Description Retrieve a page of the replacement parts fitted to an equipment, of one ship when given, or carrying a serial number, latest first
+// @Tags traceability
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param serial query string false "Serial number"
+// @Param equipment_machinery_id query string false "Equipment machinery ID"
+// @Param maintenance_instance_id query string false "Maintenance ID"
+// @Param project_code query string false "Project code of the ship"
+// @Param include_returned query bool false "Include the pieces returned to stock"
+// @Success 200 {object} types.PaginatedResponse{data=types.PaginatedData{items=[]types.InstalledPart}} "Installed parts retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /traceability/parts [get]
+func (h *traceabilityHandler) SearchInstalledParts(ctx *gin.Context) {
+	request := types.InstalledPartSearchRequest{
+		Page:  1,
+		Limit: 20,
+	}
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		h.logger.Warn("SearchInstalledParts: Invalid query parameters", "error", err)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if request.Page <= 0 || request.Limit <= 0 {
+		h.logger.Warn("SearchInstalledParts: Invalid pagination parameters", "page", request.Page, "limit", request.Limit)
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid pagination parameters",
+		})
+		return
+	}
+
+	parts, total, err := h.traceabilityService.SearchInstalledParts(ctx, &request)
+	if err != nil {
+		h.logger.Error("SearchInstalledParts: Failed to search installed parts", "error", err)
+		ctx.JSON(traceabilityErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.PaginatedResponse{
+		Status:  true,
+		Message: "Installed parts retrieved successfully",
+		Data: types.PaginatedData{
+			Total: total,
+			Page:  request.Page,
+			Limit: request.Limit,
+			Items: parts,
+		},
+	})
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+type WarehouseHandler interface {
+	CreateWarehouse(ctx *gin.Context)
+	UpdateWarehouse(ctx *gin.Context)
+	GetWarehouse(ctx *gin.Context)
+	ListWarehouses(ctx *gin.Context)
+	DeleteWarehouse(ctx *gin.Context)
+}
+
+type warehouseHandler struct {
+	warehouseService service.WarehouseService
+	logger           *logger.Logger
+}
+
+func NewWarehouseHandler(warehouseService service.WarehouseService, logger *logger.Logger) WarehouseHandler {
+	return &warehouseHandler{
+		warehouseService: warehouseService,
+		logger:           logger,
+	}
+}
+
+// warehouseErrorStatus maps the errors of the warehouse service to a status code.
+func warehouseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, types.ErrWarehouseNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, types.ErrInvalidSector):
+		return http.StatusBadRequest
+	case errors.Is(err, types.ErrDuplicateWarehouseCode),
+		errors.Is(err, types.ErrSectorAlreadyServed),
+		errors.Is(err, types.ErrWarehouseInUse):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// CreateWarehouse godoc
+// @Summary Create a warehouse
+// @Description Register a warehouse with its location, the sector responsible for it and the sectors whose material requests it serves by default
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param request body types.CreateWarehouseReq true "Warehouse creation request"
+// @Success 200 {object} types.Response{data=string} "Warehouse created successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 409 {object} types.Response "Code or served sector already used"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /warehouses [post]
+func (h *warehouseHandler) CreateWarehouse(ctx *gin.Context) {
+	var req types.CreateWarehouseReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := h.warehouseService.CreateWarehouse(ctx, &req)
+	if err != nil {
+		h.logger.Error("CreateWarehouse: Failed to create warehouse", "error", err)
+		ctx.JSON(warehouseErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to create warehouse: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Warehouse created successfully",
+		Data:    id,
+	})
+}
+
+// UpdateWarehouse godoc
+// @Summary Update a warehouse
+// @Description Replace the location, responsible sector and served sectors of a warehouse
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param request body types.UpdateWarehouseReq true "Warehouse update request"
+// @Success 200 {object} types.Response "Warehouse updated successfully"
+// @Failure 400 {object} types.Response "Invalid request data"
+// @Failure 404 {object} types.Response "Warehouse not found"
+// @Failure 409 {object} types.Response "Code or served sector already used"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /warehouses/update [post]
+func (h *warehouseHandler) UpdateWarehouse(ctx *gin.Context) {
+	var req types.UpdateWarehouseReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.warehouseService.UpdateWarehouse(ctx, &req); err != nil {
+		h.logger.Error("UpdateWarehouse: Failed to update warehouse", "error", err)
+		ctx.JSON(warehouseErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to update warehouse: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Warehouse updated successfully",
+	})
+}
+
+// GetWarehouse godoc
+// @Summary Get warehouse by ID
+// @Description Retrieve a specific warehouse using its ID
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param id path string true "Warehouse ID"
+// @Success 200 {object} types.Response{data=types.Warehouse} "Warehouse retrieved successfully"
+// @Failure 404 {object} types.Response "Warehouse not found"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /warehouses/{id} [get]
+func (h *warehouseHandler) GetWarehouse(ctx *gin.Context) {
+	id := ctx.Param("id")
+	warehouse, err := h.warehouseService.GetWarehouse(ctx, id)
+	if err != nil {
+		ctx.JSON(warehouseErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Warehouse retrieved successfully",
+		Data:    warehouse,
+	})
+}
+
+// ListWarehouses godoc
+// @Summary List the warehouses
+// @Description Retrieve the warehouses sorted by name
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param served_sector query string false "Sector served by default"
+// @Success 200 {object} types.Response{data=[]types.Warehouse} "Warehouses retrieved successfully"
+// @Failure 400 {object} types.Response "Invalid request"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /warehouses [get]
+func (h *warehouseHandler) ListWarehouses(ctx *gin.Context) {
+	var req types.WarehouseListRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, types.Response{
+			Status:  false,
+			Message: "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+
+	warehouses, err := h.warehouseService.ListWarehouses(ctx, &req)
+	if err != nil {
+		h.logger.Error("ListWarehouses: Failed to list warehouses", "error", err)
+		ctx.JSON(warehouseErrorStatus(err), types.Response{
+			Status:  false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Warehouses retrieved successfully",
+		Data:    warehouses,
+	})
+}
+
+// DeleteWarehouse godoc
+// @Summary Delete a warehouse
+// @Description Delete a warehouse no stock has moved through
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param id path string true "Warehouse ID"
+// @Success 200 {object} types.Response "Warehouse deleted successfully"
+// @Failure 404 {object} types.Response "Warehouse not found"
+// @Failure 409 {object} types.Response "Warehouse has stock movements"
+// @Failure 500 {object} types.Response "Internal server error"
+// @Security BearerAuth
+// @Router /warehouses/delete/{id} [post]
+func (h *warehouseHandler) DeleteWarehouse(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := h.warehouseService.DeleteWarehouse(ctx, id); err != nil {
+		h.logger.Error("DeleteWarehouse: Failed to delete warehouse", "error", err)
+		ctx.JSON(warehouseErrorStatus(err), types.Response{
+			Status:  false,
+			Message: "Failed to delete warehouse: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.Response{
+		Status:  true,
+		Message: "Warehouse deleted successfully",
+	})
+}
@@ -64,3 +64,21 @@ func (a *AuthMiddleware) AuthBearerMiddleware() gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// AdminMiddleware lets through the users whose workspace role is admin. It
+// runs after AuthBearerMiddleware, which sets the user.
+func (a *AuthMiddleware) AdminMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := ctx.Value("user").(*types.User)
+		if !ok || user.WorkspaceRole != types.USER_ROLE_ADMIN {
+			res := types.Response{
+				Status:  false,
+				Message: "Admin role required",
+			}
+			ctx.JSON(http.StatusForbidden, res)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/remiehneppo/material-management/internal/service"
+	"github.com/remiehneppo/material-management/types"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtService := service.NewJWTService("secret", "test", 0)
+	authMiddleware := NewAuthMiddleware(jwtService)
+	router := gin.New()
+	router.POST("/merge", authMiddleware.AuthBearerMiddleware(), authMiddleware.AdminMiddleware(), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		role string
+		want int
+	}{
+		{types.USER_ROLE_ADMIN, http.StatusOK},
+		{"member", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		token, err := jwtService.GenerateAccessToken(&types.User{ID: "u1", Username: "u1", WorkspaceRole: tt.role})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/merge", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("role %q: status = %d, want %d", tt.role, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ CatalogRepository = &catalogRepository{}
+
+type CatalogRepository interface {
+	Save(ctx context.Context, material *types.CatalogMaterial) (string, error)
+	FindByID(ctx context.Context, id string) (*types.CatalogMaterial, error)
+	FindByIDs(ctx context.Context, ids []string) (map[string]*types.CatalogMaterial, error)
+	FindByCode(ctx context.Context, code string) (*types.CatalogMaterial, error)
+	// FindByNormalizedNames returns the materials whose name or an alias
+	// normalizes to one of names
+	FindByNormalizedNames(ctx context.Context, names []string) ([]*types.CatalogMaterial, error)
+	FindAll(ctx context.Context) ([]*types.CatalogMaterial, error)
+	Paginate(ctx context.Context, filter *types.CatalogMaterialFilter, page int64, limit int64) ([]*types.CatalogMaterial, int64, error)
+	Update(ctx context.Context, id string, material *types.CatalogMaterial) error
+	Delete(ctx context.Context, id string) error
+}
+
+type catalogRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewCatalogRepository(db database.Database) CatalogRepository {
+	return &catalogRepository{
+		database:   db,
+		collection: "material_catalog",
+	}
+}
+
+func (r *catalogRepository) Save(ctx context.Context, material *types.CatalogMaterial) (string, error) {
+	return r.database.Save(ctx, r.collection, material)
+}
+
+func (r *catalogRepository) FindByID(ctx context.Context, id string) (*types.CatalogMaterial, error) {
+	material := &types.CatalogMaterial{}
+	err := r.database.FindByID(ctx, r.collection, id, material)
+	if err != nil {
+		return nil, err
+	}
+	if material.ID == "" {
+		return nil, nil
+	}
+	return material, nil
+}
+
+func (r *catalogRepository) FindByIDs(ctx context.Context, ids []string) (map[string]*types.CatalogMaterial, error) {
+	objIds := make([]bson.ObjectID, len(ids))
+	for i, id := range ids {
+		objId, err := bson.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, err
+		}
+		objIds[i] = objId
+	}
+	materials := make([]*types.CatalogMaterial, 0)
+	err := r.database.Query(ctx, r.collection, bson.M{"_id": bson.M{"$in": objIds}}, 0, 0, nil, &materials)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*types.CatalogMaterial, len(materials))
+	for _, material := range materials {
+		result[material.ID] = material
+	}
+	return result, nil
+}
+
+func (r *catalogRepository) FindByCode(ctx context.Context, code string) (*types.CatalogMaterial, error) {
+	materials := make([]*types.CatalogMaterial, 0)
+	err := r.database.Query(ctx, r.collection, bson.M{"code": code}, 0, 1, nil, &materials)
+	if err != nil {
+		return nil, err
+	}
+	if len(materials) == 0 {
+		return nil, nil
+	}
+	return materials[0], nil
+}
+
+func (r *catalogRepository) FindByNormalizedNames(ctx context.Context, names []string) ([]*types.CatalogMaterial, error) {
+	materials := make([]*types.CatalogMaterial, 0)
+	if len(names) == 0 {
+		return materials, nil
+	}
+	err := r.database.Query(ctx, r.collection, bson.M{"normalized_names": bson.M{"$in": names}}, 0, 0, nil, &materials)
+	if err != nil {
+		return nil, err
+	}
+	return materials, nil
+}
+
+func (r *catalogRepository) FindAll(ctx context.Context) ([]*types.CatalogMaterial, error) {
+	materials := make([]*types.CatalogMaterial, 0)
+	sort := bson.D{{Key: "code", Value: 1}}
+	err := r.database.FindAll(ctx, r.collection, sort, &materials)
+	if err != nil {
+		return nil, err
+	}
+	return materials, nil
+}
+
+// Paginate returns a page (starting at 1) of the catalog sorted by code.
+func (r *catalogRepository) Paginate(ctx context.Context, filter *types.CatalogMaterialFilter, page int64, limit int64) ([]*types.CatalogMaterial, int64, error) {
+	bsonFilter := bson.M{}
+	if filter.Query != "" {
+		pattern := bson.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		conditions := bson.A{
+			bson.M{"code": pattern},
+			bson.M{"name": pattern},
+			bson.M{"specification": pattern},
+			bson.M{"aliases": pattern},
+		}
+		// matches the query typed without diacritics
+		if normalized := utils.NormalizeName(filter.Query); normalized != "" {
+			conditions = append(conditions, bson.M{"normalized_names": bson.Regex{Pattern: regexp.QuoteMeta(normalized)}})
+		}
+		bsonFilter["$or"] = conditions
+	}
+	if filter.Category != "" {
+		bsonFilter["category"] = filter.Category
+	}
+	total, err := r.database.Count(ctx, r.collection, bsonFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	skip := int64(0)
+	if page > 1 && limit > 0 {
+		skip = (page - 1) * limit
+	}
+	materials := make([]*types.CatalogMaterial, 0)
+	sort := bson.D{{Key: "code", Value: 1}}
+	err = r.database.Query(ctx, r.collection, bsonFilter, skip, limit, sort, &materials)
+	if err != nil {
+		return nil, 0, err
+	}
+	return materials, total, nil
+}
+
+func (r *catalogRepository) Update(ctx context.Context, id string, material *types.CatalogMaterial) error {
+	material.ID = ""
+	return r.database.Update(ctx, r.collection, id, material)
+}
+
+func (r *catalogRepository) Delete(ctx context.Context, id string) error {
+	return r.database.Delete(ctx, r.collection, id)
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ CounterRepository = &counterRepository{}
+
+// CounterRepository hands out the numbers of document sequences, such as the
+// numbers of the purchase requisitions of a maintenance.
+type CounterRepository interface {
+	// Next returns the next number of the named sequence. Concurrent calls
+	// never get the same number. floor is the last number used before the
+	// sequence was counted, so that a sequence started over existing
+	// documents continues after them.
+	Next(ctx context.Context, name string, floor int) (int, error)
+	// Current returns the last number of the named sequence, 0 before the
+	// first one.
+	Current(ctx context.Context, name string) (int, error)
+}
+
+type counterRepository struct {
+	database   database.Database
+	collection string
+}
+
+type counter struct {
+	Value int `bson:"value"`
+}
+
+func NewCounterRepository(db database.Database) CounterRepository {
+	return &counterRepository{
+		database:   db,
+		collection: "counters",
+	}
+}
+
+func (r *counterRepository) Next(ctx context.Context, name string, floor int) (int, error) {
+	filter := bson.M{"_id": name}
+	err := r.database.UpsertMany(ctx, r.collection, []interface{}{filter}, []interface{}{
+		bson.M{"$max": bson.M{"value": floor}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	next := &counter{}
+	err = r.database.FindOneAndUpdate(ctx, r.collection, filter, bson.M{"$inc": bson.M{"value": 1}}, true, next)
+	if err != nil {
+		return 0, err
+	}
+	return next.Value, nil
+}
+
+func (r *counterRepository) Current(ctx context.Context, name string) (int, error) {
+	counters := make([]*counter, 0)
+	err := r.database.Query(ctx, r.collection, bson.M{"_id": name}, 0, 1, nil, &counters)
+	if err != nil {
+		return 0, err
+	}
+	if len(counters) == 0 {
+		return 0, nil
+	}
+	return counters[0].Value, nil
+}
@@ -15,6 +15,11 @@ type EquipmentMachineryRepo interface {
 	FindByID(ctx context.Context, id string) (*types.EquipmentMachinery, error)
 	FindByIDs(ctx context.Context, ids []string) (map[string]*types.EquipmentMachinery, error)
 	Filter(ctx context.Context, filter *types.EquipmentMachineryFilter) ([]*types.EquipmentMachinery, error)
+	Update(ctx context.Context, id string, equipmentMachinery *types.EquipmentMachinery) error
+	Delete(ctx context.Context, id string) error
+	DeleteMany(ctx context.Context, ids []string) error
+	// Reparent moves the children of the equipments fromIDs under the equipment toID
+	Reparent(ctx context.Context, fromIDs []string, toID string) error
 }
 
 type equipmentMachineryRepo struct {
@@ -59,6 +64,28 @@ func (r *equipmentMachineryRepo) Filter(ctx context.Context, filter *types.Equip
 	if filter.Sector != "" {
 		bsonFilter["sector"] = filter.Sector
 	}
+	if filter.ParentID != "" {
+		bsonFilter["parent_id"] = filter.ParentID
+	} else if filter.RootsOnly {
+		// equipments created before the hierarchy have no parent_id
+		bsonFilter["parent_id"] = bson.M{"$in": bson.A{"", nil}}
+	}
+	if filter.Model != "" {
+		bsonFilter["model"] = bson.M{"$regex": regexp.QuoteMeta(filter.Model), "$options": "i"}
+	}
+	if filter.Manufacturer != "" {
+		bsonFilter["manufacturer"] = bson.M{"$regex": regexp.QuoteMeta(filter.Manufacturer), "$options": "i"}
+	}
+	if filter.RatedSpecs != "" {
+		bsonFilter["rated_specs"] = bson.M{"$regex": regexp.QuoteMeta(filter.RatedSpecs), "$options": "i"}
+	}
+	if filter.SpecKey != "" {
+		if filter.SpecValue != "" {
+			bsonFilter["specs."+filter.SpecKey] = bson.M{"$regex": regexp.QuoteMeta(filter.SpecValue), "$options": "i"}
+		} else {
+			bsonFilter["specs."+filter.SpecKey] = bson.M{"$exists": true}
+		}
+	}
 	// sort by increase order
 	sort := bson.M{"order": 1}
 	err := r.database.Query(ctx, r.collection, bsonFilter, 0, 0, sort, &equipmentMachineries)
@@ -90,3 +117,29 @@ func (r *equipmentMachineryRepo) FindByIDs(ctx context.Context, ids []string) (m
 	}
 	return result, nil
 }
+
+func (r *equipmentMachineryRepo) Update(ctx context.Context, id string, equipmentMachinery *types.EquipmentMachinery) error {
+	equipmentMachinery.ID = ""
+	return r.database.Update(ctx, r.collection, id, equipmentMachinery)
+}
+
+func (r *equipmentMachineryRepo) Delete(ctx context.Context, id string) error {
+	return r.database.Delete(ctx, r.collection, id)
+}
+
+func (r *equipmentMachineryRepo) DeleteMany(ctx context.Context, ids []string) error {
+	objIds := make([]bson.ObjectID, len(ids))
+	for i, id := range ids {
+		objId, err := bson.ObjectIDFromHex(id)
+		if err != nil {
+			return err
+		}
+		objIds[i] = objId
+	}
+	return r.database.DeleteMany(ctx, r.collection, bson.M{"_id": bson.M{"$in": objIds}})
+}
+
+func (r *equipmentMachineryRepo) Reparent(ctx context.Context, fromIDs []string, toID string) error {
+	filter := bson.M{"parent_id": bson.M{"$in": fromIDs}}
+	return r.database.UpdateByFilter(ctx, r.collection, filter, bson.M{"$set": bson.M{"parent_id": toID}})
+}
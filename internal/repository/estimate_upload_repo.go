@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ EstimateUploadRepository = &estimateUploadRepository{}
+
+type EstimateUploadRepository interface {
+	Save(ctx context.Context, upload *types.EstimateUpload) (string, error)
+	FindByID(ctx context.Context, id string) (*types.EstimateUpload, error)
+	// list the successful uploads of a file content for a maintenance, newest first
+	FindByContentHash(ctx context.Context, maintenanceInstanceID, contentHash string) ([]*types.EstimateUpload, error)
+	// list every upload of a maintenance, newest first
+	FindByMaintenance(ctx context.Context, maintenanceInstanceID string) ([]*types.EstimateUpload, error)
+}
+
+type estimateUploadRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewEstimateUploadRepository(db database.Database) EstimateUploadRepository {
+	return &estimateUploadRepository{
+		database:   db,
+		collection: "estimate_uploads",
+	}
+}
+
+func (r *estimateUploadRepository) Save(ctx context.Context, upload *types.EstimateUpload) (string, error) {
+	return r.database.Save(ctx, r.collection, upload)
+}
+
+func (r *estimateUploadRepository) FindByID(ctx context.Context, id string) (*types.EstimateUpload, error) {
+	upload := &types.EstimateUpload{}
+	err := r.database.FindByID(ctx, r.collection, id, upload)
+	if err != nil {
+		return nil, err
+	}
+	if upload.ID == "" {
+		return nil, nil
+	}
+	return upload, nil
+}
+
+func (r *estimateUploadRepository) FindByContentHash(ctx context.Context, maintenanceInstanceID, contentHash string) ([]*types.EstimateUpload, error) {
+	uploads := make([]*types.EstimateUpload, 0)
+	filter := bson.M{
+		"maintenance_instance_id": maintenanceInstanceID,
+		"content_hash":            contentHash,
+		"error":                   bson.M{"$exists": false},
+	}
+	sort := bson.D{{Key: "uploaded_at", Value: -1}}
+	err := r.database.Query(ctx, r.collection, filter, 0, 0, sort, &uploads)
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+func (r *estimateUploadRepository) FindByMaintenance(ctx context.Context, maintenanceInstanceID string) ([]*types.EstimateUpload, error) {
+	uploads := make([]*types.EstimateUpload, 0)
+	filter := bson.M{"maintenance_instance_id": maintenanceInstanceID}
+	sort := bson.D{{Key: "uploaded_at", Value: -1}}
+	err := r.database.Query(ctx, r.collection, filter, 0, 0, sort, &uploads)
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
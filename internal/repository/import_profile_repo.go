@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ ImportProfileRepository = &importProfileRepository{}
+
+type ImportProfileRepository interface {
+	Save(ctx context.Context, importProfile *types.ImportProfile) (string, error)
+	FindByID(ctx context.Context, id string) (*types.ImportProfile, error)
+	FindByName(ctx context.Context, name string) (*types.ImportProfile, error)
+	FindAll(ctx context.Context) ([]*types.ImportProfile, error)
+	Update(ctx context.Context, id string, importProfile *types.ImportProfile) error
+	Delete(ctx context.Context, id string) error
+}
+
+type importProfileRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewImportProfileRepository(db database.Database) ImportProfileRepository {
+	return &importProfileRepository{
+		database:   db,
+		collection: "import_profiles",
+	}
+}
+
+func (r *importProfileRepository) Save(ctx context.Context, importProfile *types.ImportProfile) (string, error) {
+	return r.database.Save(ctx, r.collection, importProfile)
+}
+
+func (r *importProfileRepository) FindByID(ctx context.Context, id string) (*types.ImportProfile, error) {
+	importProfile := &types.ImportProfile{}
+	err := r.database.FindByID(ctx, r.collection, id, importProfile)
+	if err != nil {
+		return nil, err
+	}
+	if importProfile.ID == "" {
+		return nil, nil
+	}
+	return importProfile, nil
+}
+
+func (r *importProfileRepository) FindByName(ctx context.Context, name string) (*types.ImportProfile, error) {
+	importProfiles := make([]*types.ImportProfile, 0)
+	err := r.database.Query(ctx, r.collection, bson.M{"name": name}, 0, 1, nil, &importProfiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(importProfiles) == 0 {
+		return nil, nil
+	}
+	return importProfiles[0], nil
+}
+
+func (r *importProfileRepository) FindAll(ctx context.Context) ([]*types.ImportProfile, error) {
+	importProfiles := make([]*types.ImportProfile, 0)
+	sort := bson.D{{Key: "name", Value: 1}}
+	err := r.database.FindAll(ctx, r.collection, sort, &importProfiles)
+	if err != nil {
+		return nil, err
+	}
+	return importProfiles, nil
+}
+
+func (r *importProfileRepository) Update(ctx context.Context, id string, importProfile *types.ImportProfile) error {
+	importProfile.ID = ""
+	return r.database.Update(ctx, r.collection, id, importProfile)
+}
+
+func (r *importProfileRepository) Delete(ctx context.Context, id string) error {
+	return r.database.Delete(ctx, r.collection, id)
+}
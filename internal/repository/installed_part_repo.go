@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ InstalledPartRepository = &installedPartRepository{}
+
+type InstalledPartRepository interface {
+	SaveMany(ctx context.Context, parts []*types.InstalledPart) error
+	Filter(ctx context.Context, filter *types.InstalledPartFilter) ([]*types.InstalledPart, error)
+	// Paginate returns a page (starting at 1) of the parts, latest issued first
+	Paginate(ctx context.Context, filter *types.InstalledPartFilter, page int64, limit int64) ([]*types.InstalledPart, int64, error)
+	// MarkReturned records that the pieces were returned to stock by a movement
+	MarkReturned(ctx context.Context, ids []string, movementID string, returnedAt int64) error
+	// ReassignEquipment points the parts fitted to the equipments fromIDs to
+	// the equipment toID named name
+	ReassignEquipment(ctx context.Context, fromIDs []string, toID string, name string) error
+}
+
+type installedPartRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewInstalledPartRepository(db database.Database) InstalledPartRepository {
+	return &installedPartRepository{
+		database:   db,
+		collection: "installed_parts",
+	}
+}
+
+func (r *installedPartRepository) SaveMany(ctx context.Context, parts []*types.InstalledPart) error {
+	if len(parts) == 0 {
+		return nil
+	}
+	data := make([]interface{}, len(parts))
+	for i, part := range parts {
+		data[i] = part
+	}
+	ids, err := r.database.SaveMany(ctx, r.collection, data)
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		parts[i].ID = id
+	}
+	return nil
+}
+
+func (r *installedPartRepository) bsonFilter(filter *types.InstalledPartFilter) bson.M {
+	bsonFilter := bson.M{}
+	if len(filter.Serials) > 0 {
+		bsonFilter["serial"] = bson.M{"$in": filter.Serials}
+	}
+	if filter.CatalogID != "" {
+		bsonFilter["catalog_id"] = filter.CatalogID
+	}
+	if filter.EquipmentMachineryID != "" {
+		bsonFilter["equipment_machinery_id"] = filter.EquipmentMachineryID
+	}
+	if filter.MaintenanceInstanceID != "" {
+		bsonFilter["maintenance_instance_id"] = filter.MaintenanceInstanceID
+	}
+	if filter.ProjectCode != "" {
+		bsonFilter["project_code"] = filter.ProjectCode
+	}
+	if filter.MaterialRequestID != "" {
+		bsonFilter["material_request_id"] = filter.MaterialRequestID
+	}
+	if !filter.IncludeReturned {
+		bsonFilter["returned_at"] = bson.M{"$exists": false}
+	}
+	return bsonFilter
+}
+
+func (r *installedPartRepository) Filter(ctx context.Context, filter *types.InstalledPartFilter) ([]*types.InstalledPart, error) {
+	parts := make([]*types.InstalledPart, 0)
+	sort := bson.D{{Key: "issued_at", Value: -1}}
+	err := r.database.Query(ctx, r.collection, r.bsonFilter(filter), 0, 0, sort, &parts)
+	if err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+func (r *installedPartRepository) Paginate(ctx context.Context, filter *types.InstalledPartFilter, page int64, limit int64) ([]*types.InstalledPart, int64, error) {
+	bsonFilter := r.bsonFilter(filter)
+	total, err := r.database.Count(ctx, r.collection, bsonFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	skip := int64(0)
+	if page > 1 && limit > 0 {
+		skip = (page - 1) * limit
+	}
+	parts := make([]*types.InstalledPart, 0)
+	sort := bson.D{{Key: "issued_at", Value: -1}}
+	err = r.database.Query(ctx, r.collection, bsonFilter, skip, limit, sort, &parts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return parts, total, nil
+}
+
+func (r *installedPartRepository) MarkReturned(ctx context.Context, ids []string, movementID string, returnedAt int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	objIds := make([]bson.ObjectID, len(ids))
+	for i, id := range ids {
+		objId, err := bson.ObjectIDFromHex(id)
+		if err != nil {
+			return err
+		}
+		objIds[i] = objId
+	}
+	return r.database.UpdateByFilter(ctx, r.collection,
+		bson.M{"_id": bson.M{"$in": objIds}},
+		bson.M{"$set": bson.M{"return_movement_id": movementID, "returned_at": returnedAt}},
+	)
+}
+
+func (r *installedPartRepository) ReassignEquipment(ctx context.Context, fromIDs []string, toID string, name string) error {
+	filter := bson.M{"equipment_machinery_id": bson.M{"$in": fromIDs}}
+	update := bson.M{"$set": bson.M{"equipment_machinery_id": toID, "equipment_name": name}}
+	return r.database.UpdateByFilter(ctx, r.collection, filter, update)
+}
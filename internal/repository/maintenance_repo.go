@@ -77,6 +77,9 @@ func (r *maintenanceRepository) Filter(ctx context.Context, req *types.Maintenan
 	if req.MaintenanceNumber != "" {
 		conditions = append(conditions, bson.M{"maintenance_number": req.MaintenanceNumber})
 	}
+	if req.Year != 0 {
+		conditions = append(conditions, bson.M{"year": req.Year})
+	}
 	if len(conditions) > 0 {
 		filter["$and"] = conditions
 	}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"regexp"
+	"time"
 
 	"github.com/remiehneppo/material-management/internal/database"
 	"github.com/remiehneppo/material-management/types"
@@ -14,12 +16,27 @@ type MaterialsProfileRepository interface {
 	Save(ctx context.Context, materialsProfile *types.MaterialsProfile) (string, error)
 	SaveMany(ctx context.Context, materialsProfiles []*types.MaterialsProfile) ([]string, error)
 	UpdateMany(ctx context.Context, materialProfileIds []string, materialsProfiles []*types.MaterialsProfile) error
+	// UpsertEstimates writes the estimate of every profile in one bulk write.
+	// Profiles without ID are matched by maintenance, equipment and sector and
+	// created when none is found.
+	UpsertEstimates(ctx context.Context, materialsProfiles []*types.MaterialsProfile) error
 	FindByID(ctx context.Context, id string) (*types.MaterialsProfile, error)
 	FindByIDs(ctx context.Context, ids []string) (map[string]*types.MaterialsProfile, error)
 	Filter(ctx context.Context, filter *types.MaterialsProfileFilter) ([]*types.MaterialsProfile, error)
 	Paginate(ctx context.Context, filter *types.MaterialsProfileFilter, page int64, limit int64) ([]*types.MaterialsProfile, int64, error)
+	Count(ctx context.Context, filter *types.MaterialsProfileFilter) (int64, error)
+	// ReassignEquipment points the profiles of the equipments fromIDs, archived
+	// ones included, to the equipment toID
+	ReassignEquipment(ctx context.Context, fromIDs []string, toID string) error
 	UpdateEstimateMaterials(ctx context.Context, id string, estimateMaterials types.MaterialsForEquipment) error
 	UpdateRealityMaterials(ctx context.Context, id string, realityMaterials types.MaterialsForEquipment) error
+	// ShiftIndex adds delta to the index of every profile of a maintenance
+	// sector whose index lies within [from, to]
+	ShiftIndex(ctx context.Context, maintenanceInstanceID, sector string, from, to, delta int64) error
+	UpdateIndexes(ctx context.Context, ids []string, indexes []int64) error
+	ConsumptionHistory(ctx context.Context, equipmentMachineryID string, maintenanceInstanceIDs []string) (*types.ConsumptionHistory, error)
+	SetArchived(ctx context.Context, id string, archived bool) error
+	Delete(ctx context.Context, id string) error
 }
 
 type materialsProfileRepository struct {
@@ -55,6 +72,36 @@ func (r *materialsProfileRepository) UpdateMany(ctx context.Context, materialsPr
 	return r.database.UpdateMany(ctx, r.collection, materialsProfileIds, data)
 }
 
+func (r *materialsProfileRepository) UpsertEstimates(ctx context.Context, materialsProfiles []*types.MaterialsProfile) error {
+	filters := make([]interface{}, 0, len(materialsProfiles))
+	updates := make([]interface{}, 0, len(materialsProfiles))
+	for _, mp := range materialsProfiles {
+		if mp.ID != "" {
+			objId, err := bson.ObjectIDFromHex(mp.ID)
+			if err != nil {
+				return err
+			}
+			filters = append(filters, bson.M{"_id": objId})
+			updates = append(updates, bson.M{"$set": bson.M{"estimate": mp.Estimate}})
+			continue
+		}
+		filters = append(filters, bson.M{
+			"maintenance_instance_id": mp.MaintenanceInstanceID,
+			"equipment_machinery_id":  mp.EquipmentMachineryID,
+			"sector":                  mp.Sector,
+			"archived":                bson.M{"$ne": true},
+		})
+		updates = append(updates, bson.M{
+			"$set": bson.M{"estimate": mp.Estimate},
+			"$setOnInsert": bson.M{
+				"index":   mp.Index,
+				"reality": mp.Reality,
+			},
+		})
+	}
+	return r.database.UpsertMany(ctx, r.collection, filters, updates)
+}
+
 func (r *materialsProfileRepository) FindByID(ctx context.Context, id string) (*types.MaterialsProfile, error) {
 	materialsProfile := &types.MaterialsProfile{}
 	err := r.database.FindByID(ctx, r.collection, id, materialsProfile)
@@ -90,20 +137,7 @@ func (r *materialsProfileRepository) FindByIDs(ctx context.Context, ids []string
 
 func (r *materialsProfileRepository) Filter(ctx context.Context, filter *types.MaterialsProfileFilter) ([]*types.MaterialsProfile, error) {
 	var materialsProfiles []*types.MaterialsProfile
-	bsonFilter := bson.M{}
-	conditions := []bson.M{}
-	if len(filter.MaintenanceInstanceIDs) > 0 {
-		conditions = append(conditions, bson.M{"maintenance_instance_id": bson.M{"$in": filter.MaintenanceInstanceIDs}})
-	}
-	if len(filter.EquipmentMachineryIDs) > 0 {
-		conditions = append(conditions, bson.M{"equipment_machinery_id": bson.M{"$in": filter.EquipmentMachineryIDs}})
-	}
-	if filter.Sector != "" {
-		conditions = append(conditions, bson.M{"sector": filter.Sector})
-	}
-	if len(conditions) > 0 {
-		bsonFilter["$and"] = conditions
-	}
+	bsonFilter := buildMaterialsProfileFilter(filter)
 	sort := bson.D{{Key: "index", Value: 1}}
 	err := r.database.Query(ctx, r.collection, bsonFilter, 0, 0, sort, &materialsProfiles)
 	if err != nil {
@@ -112,32 +146,81 @@ func (r *materialsProfileRepository) Filter(ctx context.Context, filter *types.M
 	return materialsProfiles, nil
 }
 
+// Paginate returns a page (starting at 1) of the profiles matching the filter,
+// sorted as asked by the filter.
 func (r *materialsProfileRepository) Paginate(ctx context.Context, filter *types.MaterialsProfileFilter, page int64, limit int64) ([]*types.MaterialsProfile, int64, error) {
-	var materialsProfiles []*types.MaterialsProfile
-	bsonFilter := bson.M{}
-	conditions := []bson.M{}
-	if len(filter.MaintenanceInstanceIDs) > 0 {
-		conditions = append(conditions, bson.M{"maintenance_instance_id": bson.M{"$in": filter.MaintenanceInstanceIDs}})
+	direction := 1
+	if filter.SortDesc {
+		direction = -1
 	}
-	if len(filter.EquipmentMachineryIDs) > 0 {
-		conditions = append(conditions, bson.M{"equipment_machinery_id": bson.M{"$in": filter.EquipmentMachineryIDs}})
+	pipeline := bson.A{
+		bson.M{"$match": buildMaterialsProfileFilter(filter)},
 	}
-	if filter.Sector != "" {
-		conditions = append(conditions, bson.M{"sector": filter.Sector})
+	var sort bson.D
+	switch filter.SortBy {
+	case types.MATERIALS_PROFILE_SORT_OVERRUN:
+		pipeline = append(pipeline, bson.M{"$addFields": bson.M{"overrun": overrunQuantityExpr()}})
+		sort = bson.D{{Key: "overrun", Value: direction}, {Key: "index", Value: 1}}
+	case types.MATERIALS_PROFILE_SORT_EQUIPMENT_NAME:
+		pipeline = append(pipeline,
+			bson.M{"$lookup": bson.M{
+				// collection of the equipment machinery repository
+				"from": "equipment_machineries",
+				"let": bson.M{"equipment_id": bson.M{"$convert": bson.M{
+					"input":   "$equipment_machinery_id",
+					"to":      "objectId",
+					"onError": nil,
+					"onNull":  nil,
+				}}},
+				"pipeline": bson.A{
+					bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$equipment_id"}}}},
+					bson.M{"$project": bson.M{"name": 1}},
+				},
+				"as": "equipment",
+			}},
+			bson.M{"$addFields": bson.M{"equipment_name": bson.M{"$arrayElemAt": bson.A{"$equipment.name", 0}}}},
+		)
+		sort = bson.D{{Key: "equipment_name", Value: direction}, {Key: "index", Value: 1}}
+	default:
+		sort = bson.D{{Key: "index", Value: direction}}
 	}
-	if len(conditions) > 0 {
-		bsonFilter["$and"] = conditions
+	sort = append(sort, bson.E{Key: "_id", Value: 1})
+
+	items := bson.A{bson.M{"$sort": sort}}
+	if page > 1 && limit > 0 {
+		items = append(items, bson.M{"$skip": (page - 1) * limit})
 	}
-	sort := bson.D{{Key: "index", Value: 1}}
-	total, err := r.database.Count(ctx, r.collection, bsonFilter)
-	if err != nil {
-		return nil, 0, err
+	if limit > 0 {
+		items = append(items, bson.M{"$limit": limit})
 	}
-	err = r.database.Query(ctx, r.collection, bsonFilter, page, limit, sort, &materialsProfiles)
-	if err != nil {
+	items = append(items, bson.M{"$project": bson.M{"overrun": 0, "equipment": 0, "equipment_name": 0}})
+	pipeline = append(pipeline, bson.M{"$facet": bson.M{
+		"total": bson.A{bson.M{"$count": "count"}},
+		"items": items,
+	}})
+
+	var results []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Items []*types.MaterialsProfile `bson:"items"`
+	}
+	if err := r.database.Aggregate(ctx, r.collection, pipeline, &results); err != nil {
 		return nil, 0, err
 	}
-	return materialsProfiles, total, nil
+	if len(results) == 0 || len(results[0].Total) == 0 {
+		return []*types.MaterialsProfile{}, 0, nil
+	}
+	return results[0].Items, results[0].Total[0].Count, nil
+}
+
+func (r *materialsProfileRepository) Count(ctx context.Context, filter *types.MaterialsProfileFilter) (int64, error) {
+	return r.database.Count(ctx, r.collection, buildMaterialsProfileFilter(filter))
+}
+
+func (r *materialsProfileRepository) ReassignEquipment(ctx context.Context, fromIDs []string, toID string) error {
+	filter := bson.M{"equipment_machinery_id": bson.M{"$in": fromIDs}}
+	return r.database.UpdateByFilter(ctx, r.collection, filter, bson.M{"$set": bson.M{"equipment_machinery_id": toID}})
 }
 
 func (r *materialsProfileRepository) UpdateEstimateMaterials(ctx context.Context, id string, estimateMaterials types.MaterialsForEquipment) error {
@@ -166,3 +249,249 @@ func (r *materialsProfileRepository) UpdateRealityMaterials(ctx context.Context,
 	}
 	return nil
 }
+
+func (r *materialsProfileRepository) ShiftIndex(ctx context.Context, maintenanceInstanceID, sector string, from, to, delta int64) error {
+	filter := bson.M{
+		"maintenance_instance_id": maintenanceInstanceID,
+		"sector":                  sector,
+		"index":                   bson.M{"$gte": from, "$lte": to},
+	}
+	return r.database.UpdateByFilter(ctx, r.collection, filter, bson.M{"$inc": bson.M{"index": delta}})
+}
+
+func (r *materialsProfileRepository) UpdateIndexes(ctx context.Context, ids []string, indexes []int64) error {
+	data := make([]interface{}, len(indexes))
+	for i, index := range indexes {
+		data[i] = bson.M{"index": index}
+	}
+	return r.database.UpdateMany(ctx, r.collection, ids, data)
+}
+
+func (r *materialsProfileRepository) SetArchived(ctx context.Context, id string, archived bool) error {
+	archivedAt := int64(0)
+	if archived {
+		archivedAt = time.Now().Unix()
+	}
+	return r.database.Update(ctx, r.collection, id, bson.M{"archived": archived, "archived_at": archivedAt})
+}
+
+func (r *materialsProfileRepository) Delete(ctx context.Context, id string) error {
+	return r.database.Delete(ctx, r.collection, id)
+}
+
+// ConsumptionHistory aggregates the reality of an equipment over the given
+// maintenances. Quantities of a material are summed per maintenance and unit,
+// so an equipment profiled in several sectors counts once per maintenance and
+// quantities in different units are never added.
+func (r *materialsProfileRepository) ConsumptionHistory(ctx context.Context, equipmentMachineryID string, maintenanceInstanceIDs []string) (*types.ConsumptionHistory, error) {
+	materialItems := func(field, materialType string) bson.M {
+		return bson.M{"$map": bson.M{
+			"input": materialsArray("$reality." + field),
+			"as":    "m",
+			"in": bson.M{
+				"material_type": materialType,
+				"name":          "$$m.k",
+				"unit":          "$$m.v.unit",
+				"quantity":      "$$m.v.quantity",
+			},
+		}}
+	}
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"equipment_machinery_id":  equipmentMachineryID,
+			"maintenance_instance_id": bson.M{"$in": maintenanceInstanceIDs},
+			"archived":                bson.M{"$ne": true},
+		}},
+		bson.M{"$facet": bson.M{
+			"maintenances": bson.A{
+				bson.M{"$group": bson.M{"_id": "$maintenance_instance_id"}},
+			},
+			"materials": bson.A{
+				bson.M{"$project": bson.M{
+					"maintenance_instance_id": 1,
+					"items": bson.M{"$concatArrays": bson.A{
+						materialItems("replacement_materials", types.MATERIAL_TYPE_REPLACEMENT),
+						materialItems("consumable_supplies", types.MATERIAL_TYPE_CONSUMABLE),
+					}},
+				}},
+				bson.M{"$unwind": "$items"},
+				bson.M{"$match": bson.M{"items.quantity": bson.M{"$gt": 0}}},
+				bson.M{"$group": bson.M{
+					"_id": bson.M{
+						"maintenance":   "$maintenance_instance_id",
+						"material_type": "$items.material_type",
+						"name":          "$items.name",
+						"unit":          "$items.unit",
+					},
+					"quantity": bson.M{"$sum": "$items.quantity"},
+				}},
+				bson.M{"$group": bson.M{
+					"_id": bson.M{
+						"material_type": "$_id.material_type",
+						"name":          "$_id.name",
+						"unit":          "$_id.unit",
+					},
+					"quantities": bson.M{"$push": "$quantity"},
+				}},
+				bson.M{"$project": bson.M{
+					"_id":           0,
+					"material_type": "$_id.material_type",
+					"name":          "$_id.name",
+					"unit":          "$_id.unit",
+					"quantities":    1,
+				}},
+				// replacement materials first, then by name
+				bson.M{"$sort": bson.D{{Key: "material_type", Value: -1}, {Key: "name", Value: 1}, {Key: "unit", Value: 1}}},
+			},
+		}},
+	}
+	var results []struct {
+		Maintenances []struct {
+			ID string `bson:"_id"`
+		} `bson:"maintenances"`
+		Materials []*types.MaterialConsumption `bson:"materials"`
+	}
+	if err := r.database.Aggregate(ctx, r.collection, pipeline, &results); err != nil {
+		return nil, err
+	}
+	history := &types.ConsumptionHistory{
+		MaintenanceInstanceIDs: make([]string, 0),
+		Materials:              make([]*types.MaterialConsumption, 0),
+	}
+	if len(results) == 0 {
+		return history, nil
+	}
+	for _, m := range results[0].Maintenances {
+		history.MaintenanceInstanceIDs = append(history.MaintenanceInstanceIDs, m.ID)
+	}
+	history.Materials = append(history.Materials, results[0].Materials...)
+	return history, nil
+}
+
+func buildMaterialsProfileFilter(filter *types.MaterialsProfileFilter) bson.M {
+	bsonFilter := bson.M{}
+	conditions := []bson.M{}
+	if len(filter.MaintenanceInstanceIDs) > 0 {
+		conditions = append(conditions, bson.M{"maintenance_instance_id": bson.M{"$in": filter.MaintenanceInstanceIDs}})
+	}
+	if len(filter.EquipmentMachineryIDs) > 0 {
+		conditions = append(conditions, bson.M{"equipment_machinery_id": bson.M{"$in": filter.EquipmentMachineryIDs}})
+	}
+	if filter.Sector != "" {
+		conditions = append(conditions, bson.M{"sector": filter.Sector})
+	}
+	if filter.IndexTo > 0 {
+		conditions = append(conditions, bson.M{"index": bson.M{"$gte": filter.IndexFrom, "$lte": filter.IndexTo}})
+	}
+	if !filter.IncludeArchived {
+		conditions = append(conditions, bson.M{"archived": bson.M{"$ne": true}})
+	}
+	if filter.MaterialName != "" {
+		materials := bson.M{"$concatArrays": bson.A{
+			materialsArray("$estimate.replacement_materials"),
+			materialsArray("$estimate.consumable_supplies"),
+			materialsArray("$reality.replacement_materials"),
+			materialsArray("$reality.consumable_supplies"),
+		}}
+		conditions = append(conditions, bson.M{"$expr": bson.M{"$gt": bson.A{
+			bson.M{"$size": bson.M{"$filter": bson.M{
+				"input": materials,
+				"as":    "m",
+				"cond": bson.M{"$regexMatch": bson.M{
+					"input":   "$$m.k",
+					"regex":   regexp.QuoteMeta(filter.MaterialName),
+					"options": "i",
+				}},
+			}}},
+			0,
+		}}})
+	}
+	if filter.CatalogID != "" {
+		catalogIDs := func(field string) bson.M {
+			return bson.M{"$map": bson.M{"input": materialsArray(field), "as": "m", "in": "$$m.v.catalog_id"}}
+		}
+		conditions = append(conditions, bson.M{"$expr": bson.M{"$in": bson.A{
+			filter.CatalogID,
+			bson.M{"$concatArrays": bson.A{
+				catalogIDs("$estimate.replacement_materials"),
+				catalogIDs("$estimate.consumable_supplies"),
+				catalogIDs("$reality.replacement_materials"),
+				catalogIDs("$reality.consumable_supplies"),
+			}},
+		}}})
+	}
+	if filter.HasOverrun {
+		conditions = append(conditions, bson.M{"$expr": bson.M{"$gt": bson.A{overrunLinesExpr(), 0}}})
+	}
+	if filter.NoEstimate {
+		conditions = append(conditions, bson.M{"$expr": bson.M{"$eq": bson.A{
+			bson.M{"$add": bson.A{
+				bson.M{"$size": materialsArray("$estimate.replacement_materials")},
+				bson.M{"$size": materialsArray("$estimate.consumable_supplies")},
+			}},
+			0,
+		}}})
+	}
+	if len(conditions) > 0 {
+		bsonFilter["$and"] = conditions
+	}
+	return bsonFilter
+}
+
+// materialsArray turns a material map of a profile into [{k: name, v: material}].
+func materialsArray(field string) bson.M {
+	return bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{field, bson.M{}}}}
+}
+
+// overrunLinesExpr counts the consumed materials of a profile that are not
+// estimated or consumed beyond the estimate in the same unit.
+func overrunLinesExpr() bson.M {
+	return overrunExpr(func(reality, estimate bson.M) interface{} { return 1 })
+}
+
+// overrunQuantityExpr sums the quantities consumed beyond the estimate, the
+// whole quantity of a material that was not estimated.
+func overrunQuantityExpr() bson.M {
+	return overrunExpr(func(reality, estimate bson.M) interface{} {
+		return bson.M{"$subtract": bson.A{reality, bson.M{"$max": bson.A{estimate, 0}}}}
+	})
+}
+
+// overrunExpr sums the value of the overrun lines of a profile, given the
+// consumed and estimated quantities of the line. Quantities in different
+// units are not compared.
+func overrunExpr(value func(reality, estimate bson.M) interface{}) bson.M {
+	reality := bson.M{"$ifNull": bson.A{"$$r.v.quantity", 0}}
+	estimate := bson.M{"$ifNull": bson.A{"$$estimate.v.quantity", 0}}
+	section := func(field string) bson.M {
+		return bson.M{"$sum": bson.M{"$map": bson.M{
+			"input": materialsArray("$reality." + field),
+			"as":    "r",
+			"in": bson.M{"$let": bson.M{
+				"vars": bson.M{"estimate": bson.M{"$arrayElemAt": bson.A{
+					bson.M{"$filter": bson.M{
+						"input": materialsArray("$estimate." + field),
+						"as":    "e",
+						"cond":  bson.M{"$eq": bson.A{"$$e.k", "$$r.k"}},
+					}},
+					0,
+				}}},
+				"in": bson.M{"$cond": bson.A{
+					bson.M{"$and": bson.A{
+						bson.M{"$gt": bson.A{reality, estimate}},
+						bson.M{"$or": bson.A{
+							bson.M{"$lte": bson.A{estimate, 0}},
+							bson.M{"$eq": bson.A{"$$estimate.v.unit", "$$r.v.unit"}},
+						}},
+					}},
+					value(reality, estimate),
+					0,
+				}},
+			}},
+		}}}
+	}
+	return bson.M{"$add": bson.A{
+		section("replacement_materials"),
+		section("consumable_supplies"),
+	}}
+}
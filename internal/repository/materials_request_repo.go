@@ -54,6 +54,9 @@ func (r *materialsRequestRepository) Filter(ctx context.Context, filter *types.M
 	if filter.EquipmentMachineryID != "" {
 		bsonFilter["materials_for_equipment."+filter.EquipmentMachineryID] = bson.M{"$exists": true}
 	}
+	if filter.MaterialsProfileID != "" {
+		bsonFilter["materials_for_equipment."+filter.MaterialsProfileID] = bson.M{"$exists": true}
+	}
 	if filter.Sector != "" {
 		bsonFilter["sector"] = filter.Sector
 	}
@@ -69,6 +72,9 @@ func (r *materialsRequestRepository) Filter(ctx context.Context, filter *types.M
 			"$lte": filter.RequestedAtEnd,
 		}
 	}
+	if filter.CatalogID != "" {
+		bsonFilter["$expr"] = catalogReferenceExpr(filter.CatalogID)
+	}
 	err := r.database.Query(ctx, r.collection, bsonFilter, 0, 0, nil, &materialsRequests)
 	if err != nil {
 		return nil, err
@@ -87,6 +93,9 @@ func (r *materialsRequestRepository) Paginate(ctx context.Context, filter *types
 	if filter.EquipmentMachineryID != "" {
 		conditions = append(conditions, bson.M{"materials_for_equipment." + filter.EquipmentMachineryID: bson.M{"$exists": true}})
 	}
+	if filter.MaterialsProfileID != "" {
+		conditions = append(conditions, bson.M{"materials_for_equipment." + filter.MaterialsProfileID: bson.M{"$exists": true}})
+	}
 	if filter.Sector != "" {
 		conditions = append(conditions, bson.M{"sector": filter.Sector})
 	}
@@ -104,6 +113,9 @@ func (r *materialsRequestRepository) Paginate(ctx context.Context, filter *types
 			},
 		})
 	}
+	if filter.CatalogID != "" {
+		conditions = append(conditions, bson.M{"$expr": catalogReferenceExpr(filter.CatalogID)})
+	}
 	if len(conditions) > 0 {
 		bsonFilter["$and"] = conditions
 	}
@@ -143,3 +155,27 @@ func (r *materialsRequestRepository) Update(ctx context.Context, id string, mate
 func (r *materialsRequestRepository) Delete(ctx context.Context, id string) error {
 	return r.database.Delete(ctx, r.collection, id)
 }
+
+// catalogReferenceExpr matches requests with a line, of any equipment,
+// referencing the catalog material.
+func catalogReferenceExpr(catalogID string) bson.M {
+	catalogIDs := func(field string) bson.M {
+		return bson.M{"$map": bson.M{
+			"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{field, bson.M{}}}},
+			"as":    "m",
+			"in":    "$$m.v.catalog_id",
+		}}
+	}
+	return bson.M{"$in": bson.A{
+		catalogID,
+		bson.M{"$reduce": bson.M{
+			"input":        bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$materials_for_equipment", bson.M{}}}},
+			"initialValue": bson.A{},
+			"in": bson.M{"$concatArrays": bson.A{
+				"$$value",
+				catalogIDs("$$this.v.replacement_materials"),
+				catalogIDs("$$this.v.consumable_supplies"),
+			}},
+		}},
+	}}
+}
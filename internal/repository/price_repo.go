@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ PriceRepository = &priceRepository{}
+
+type PriceRepository interface {
+	Save(ctx context.Context, price *types.CatalogPrice) (string, error)
+	// FindByCatalogIDs returns the price history of the catalog materials,
+	// latest effective date first
+	FindByCatalogIDs(ctx context.Context, catalogIDs []string) ([]*types.CatalogPrice, error)
+	UpdateCatalogID(ctx context.Context, fromIDs []string, toID string) error
+}
+
+type priceRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewPriceRepository(db database.Database) PriceRepository {
+	return &priceRepository{
+		database:   db,
+		collection: "catalog_prices",
+	}
+}
+
+func (r *priceRepository) Save(ctx context.Context, price *types.CatalogPrice) (string, error) {
+	return r.database.Save(ctx, r.collection, price)
+}
+
+func (r *priceRepository) FindByCatalogIDs(ctx context.Context, catalogIDs []string) ([]*types.CatalogPrice, error) {
+	prices := make([]*types.CatalogPrice, 0)
+	if len(catalogIDs) == 0 {
+		return prices, nil
+	}
+	sort := bson.D{{Key: "effective_from", Value: -1}, {Key: "created_at", Value: -1}}
+	err := r.database.Query(ctx, r.collection, bson.M{"catalog_id": bson.M{"$in": catalogIDs}}, 0, 0, sort, &prices)
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// UpdateCatalogID moves the price history of merged catalog materials to the
+// material they were merged into.
+func (r *priceRepository) UpdateCatalogID(ctx context.Context, fromIDs []string, toID string) error {
+	if len(fromIDs) == 0 {
+		return nil
+	}
+	return r.database.UpdateByFilter(ctx, r.collection, bson.M{"catalog_id": bson.M{"$in": fromIDs}}, bson.M{"$set": bson.M{"catalog_id": toID}})
+}
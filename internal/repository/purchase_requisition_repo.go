@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ PurchaseRequisitionRepository = &purchaseRequisitionRepository{}
+
+type PurchaseRequisitionRepository interface {
+	Save(ctx context.Context, requisition *types.PurchaseRequisition) (string, error)
+	FindByID(ctx context.Context, id string) (*types.PurchaseRequisition, error)
+	// Filter returns the requisitions sorted by maintenance and number
+	Filter(ctx context.Context, filter *types.PurchaseRequisitionFilter) ([]*types.PurchaseRequisition, error)
+	Update(ctx context.Context, id string, requisition *types.PurchaseRequisition) error
+}
+
+type purchaseRequisitionRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewPurchaseRequisitionRepository(db database.Database) PurchaseRequisitionRepository {
+	return &purchaseRequisitionRepository{
+		database:   db,
+		collection: "purchase_requisitions",
+	}
+}
+
+func (r *purchaseRequisitionRepository) Save(ctx context.Context, requisition *types.PurchaseRequisition) (string, error) {
+	return r.database.Save(ctx, r.collection, requisition)
+}
+
+func (r *purchaseRequisitionRepository) FindByID(ctx context.Context, id string) (*types.PurchaseRequisition, error) {
+	requisition := &types.PurchaseRequisition{}
+	err := r.database.FindByID(ctx, r.collection, id, requisition)
+	if err != nil {
+		return nil, err
+	}
+	if requisition.ID == "" {
+		return nil, nil
+	}
+	return requisition, nil
+}
+
+func (r *purchaseRequisitionRepository) Filter(ctx context.Context, filter *types.PurchaseRequisitionFilter) ([]*types.PurchaseRequisition, error) {
+	bsonFilter := bson.M{}
+	if filter.MaintenanceInstanceID != "" {
+		bsonFilter["maintenance_instance_id"] = filter.MaintenanceInstanceID
+	}
+	if filter.Sector != "" {
+		bsonFilter["sector"] = filter.Sector
+	}
+	if len(filter.Statuses) > 0 {
+		bsonFilter["status"] = bson.M{"$in": filter.Statuses}
+	}
+	requisitions := make([]*types.PurchaseRequisition, 0)
+	sort := bson.D{{Key: "maintenance_instance_id", Value: 1}, {Key: "number", Value: 1}}
+	err := r.database.Query(ctx, r.collection, bsonFilter, 0, 0, sort, &requisitions)
+	if err != nil {
+		return nil, err
+	}
+	return requisitions, nil
+}
+
+func (r *purchaseRequisitionRepository) Update(ctx context.Context, id string, requisition *types.PurchaseRequisition) error {
+	requisition.ID = ""
+	return r.database.Update(ctx, r.collection, id, requisition)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ StockMovementRepository = &stockMovementRepository{}
+
+type StockMovementRepository interface {
+	Save(ctx context.Context, movement *types.StockMovement) (string, error)
+	// Paginate returns a page (starting at 1) of the ledger, latest movement first
+	Paginate(ctx context.Context, filter *types.StockMovementFilter, page int64, limit int64) ([]*types.StockMovement, int64, error)
+	// RenameWarehouse points the movements of a warehouse to another
+	RenameWarehouse(ctx context.Context, from, to string) error
+}
+
+type stockMovementRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewStockMovementRepository(db database.Database) StockMovementRepository {
+	return &stockMovementRepository{
+		database:   db,
+		collection: "stock_movements",
+	}
+}
+
+func (r *stockMovementRepository) Save(ctx context.Context, movement *types.StockMovement) (string, error) {
+	return r.database.Save(ctx, r.collection, movement)
+}
+
+func (r *stockMovementRepository) Paginate(ctx context.Context, filter *types.StockMovementFilter, page int64, limit int64) ([]*types.StockMovement, int64, error) {
+	bsonFilter := bson.M{}
+	if filter.Kind != "" {
+		bsonFilter["kind"] = filter.Kind
+	}
+	if filter.Warehouse != "" {
+		bsonFilter["warehouse"] = filter.Warehouse
+	}
+	if filter.CatalogID != "" {
+		bsonFilter["catalog_ids"] = filter.CatalogID
+	}
+	if filter.MaterialRequestID != "" {
+		bsonFilter["material_request_id"] = filter.MaterialRequestID
+	}
+	createdAt := bson.M{}
+	if filter.CreatedFrom != 0 {
+		createdAt["$gte"] = filter.CreatedFrom
+	}
+	if filter.CreatedTo != 0 {
+		createdAt["$lte"] = filter.CreatedTo
+	}
+	if len(createdAt) > 0 {
+		bsonFilter["created_at"] = createdAt
+	}
+	total, err := r.database.Count(ctx, r.collection, bsonFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	skip := int64(0)
+	if page > 1 && limit > 0 {
+		skip = (page - 1) * limit
+	}
+	movements := make([]*types.StockMovement, 0)
+	sort := bson.D{{Key: "created_at", Value: -1}}
+	err = r.database.Query(ctx, r.collection, bsonFilter, skip, limit, sort, &movements)
+	if err != nil {
+		return nil, 0, err
+	}
+	return movements, total, nil
+}
+
+func (r *stockMovementRepository) RenameWarehouse(ctx context.Context, from, to string) error {
+	return r.database.UpdateByFilter(ctx, r.collection, bson.M{"warehouse": from}, bson.M{"$set": bson.M{"warehouse": to}})
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ StockRepository = &stockRepository{}
+
+type StockRepository interface {
+	Filter(ctx context.Context, filter *types.StockBalanceFilter) ([]*types.StockBalance, error)
+	// AddBalances adds the quantities of the balances to the balances of the
+	// same catalog material and warehouse, creating the missing ones
+	AddBalances(ctx context.Context, balances []*types.StockBalance) error
+	// TakeBalance takes the quantity of the balance out of the balance of the
+	// same catalog material and warehouse in one update, provided that the
+	// balance holds that much. It reports whether the quantity was taken.
+	TakeBalance(ctx context.Context, balance *types.StockBalance) (bool, error)
+	DeleteByCatalogIDs(ctx context.Context, catalogIDs []string) error
+	DeleteByWarehouse(ctx context.Context, warehouse string) error
+}
+
+type stockRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewStockRepository(db database.Database) StockRepository {
+	r := &stockRepository{
+		database:   db,
+		collection: "stock_balances",
+	}
+	// one balance per material and warehouse, concurrent AddBalances upserts
+	// of a new balance must not insert it twice
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := db.CreateIndex(ctx, r.collection, []string{"catalog_id", "warehouse"}, true); err != nil {
+		panic("failed to create the stock balance index: " + err.Error())
+	}
+	return r
+}
+
+func (r *stockRepository) Filter(ctx context.Context, filter *types.StockBalanceFilter) ([]*types.StockBalance, error) {
+	bsonFilter := bson.M{}
+	if len(filter.CatalogIDs) > 0 {
+		bsonFilter["catalog_id"] = bson.M{"$in": filter.CatalogIDs}
+	}
+	if filter.Warehouse != "" {
+		bsonFilter["warehouse"] = filter.Warehouse
+	}
+	balances := make([]*types.StockBalance, 0)
+	sort := bson.D{{Key: "catalog_id", Value: 1}, {Key: "warehouse", Value: 1}}
+	err := r.database.Query(ctx, r.collection, bsonFilter, 0, 0, sort, &balances)
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+func (r *stockRepository) AddBalances(ctx context.Context, balances []*types.StockBalance) error {
+	if len(balances) == 0 {
+		return nil
+	}
+	filters := make([]interface{}, len(balances))
+	updates := make([]interface{}, len(balances))
+	for i, balance := range balances {
+		filters[i] = bson.M{"catalog_id": balance.CatalogID, "warehouse": balance.Warehouse}
+		updates[i] = bson.M{
+			"$inc": bson.M{"quantity": balance.Quantity},
+			"$set": bson.M{
+				"unit":       balance.Unit,
+				"updated_by": balance.UpdatedBy,
+				"updated_at": balance.UpdatedAt,
+			},
+		}
+	}
+	return r.database.UpsertMany(ctx, r.collection, filters, updates)
+}
+
+// quantityTolerance is below the precision quantities are rounded to, so that
+// the floating point noise of summed balances does not make them fall short.
+const quantityTolerance = 1e-7
+
+func (r *stockRepository) TakeBalance(ctx context.Context, balance *types.StockBalance) (bool, error) {
+	filter := bson.M{
+		"catalog_id": balance.CatalogID,
+		"warehouse":  balance.Warehouse,
+		"quantity":   bson.M{"$gte": balance.Quantity - quantityTolerance},
+	}
+	update := bson.M{
+		"$inc": bson.M{"quantity": -balance.Quantity},
+		"$set": bson.M{
+			"updated_by": balance.UpdatedBy,
+			"updated_at": balance.UpdatedAt,
+		},
+	}
+	err := r.database.FindOneAndUpdate(ctx, r.collection, filter, update, false, &types.StockBalance{})
+	if errors.Is(err, database.ErrNoDocument) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *stockRepository) DeleteByCatalogIDs(ctx context.Context, catalogIDs []string) error {
+	if len(catalogIDs) == 0 {
+		return nil
+	}
+	return r.database.DeleteMany(ctx, r.collection, bson.M{"catalog_id": bson.M{"$in": catalogIDs}})
+}
+
+func (r *stockRepository) DeleteByWarehouse(ctx context.Context, warehouse string) error {
+	return r.database.DeleteMany(ctx, r.collection, bson.M{"warehouse": warehouse})
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ StockTransferRepository = &stockTransferRepository{}
+
+type StockTransferRepository interface {
+	Save(ctx context.Context, transfer *types.StockTransfer) (string, error)
+	FindByID(ctx context.Context, id string) (*types.StockTransfer, error)
+	// Filter returns the transfers sorted by number
+	Filter(ctx context.Context, filter *types.StockTransferFilter) ([]*types.StockTransfer, error)
+	// Paginate returns a page (starting at 1) of the transfers, latest first
+	Paginate(ctx context.Context, filter *types.StockTransferFilter, page int64, limit int64) ([]*types.StockTransfer, int64, error)
+	Update(ctx context.Context, id string, transfer *types.StockTransfer) error
+	// LastNumber returns the highest transfer number, 0 when there is none
+	LastNumber(ctx context.Context) (int, error)
+}
+
+type stockTransferRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewStockTransferRepository(db database.Database) StockTransferRepository {
+	return &stockTransferRepository{
+		database:   db,
+		collection: "stock_transfers",
+	}
+}
+
+func (r *stockTransferRepository) Save(ctx context.Context, transfer *types.StockTransfer) (string, error) {
+	return r.database.Save(ctx, r.collection, transfer)
+}
+
+func (r *stockTransferRepository) FindByID(ctx context.Context, id string) (*types.StockTransfer, error) {
+	transfer := &types.StockTransfer{}
+	err := r.database.FindByID(ctx, r.collection, id, transfer)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.ID == "" {
+		return nil, nil
+	}
+	return transfer, nil
+}
+
+func (r *stockTransferRepository) bsonFilter(filter *types.StockTransferFilter) bson.M {
+	bsonFilter := bson.M{}
+	if filter.Warehouse != "" {
+		bsonFilter["$or"] = bson.A{
+			bson.M{"from_warehouse": filter.Warehouse},
+			bson.M{"to_warehouse": filter.Warehouse},
+		}
+	}
+	if filter.Status != "" {
+		bsonFilter["status"] = filter.Status
+	}
+	if filter.CatalogID != "" {
+		bsonFilter["catalog_ids"] = filter.CatalogID
+	}
+	return bsonFilter
+}
+
+func (r *stockTransferRepository) Filter(ctx context.Context, filter *types.StockTransferFilter) ([]*types.StockTransfer, error) {
+	transfers := make([]*types.StockTransfer, 0)
+	sort := bson.D{{Key: "number", Value: 1}}
+	err := r.database.Query(ctx, r.collection, r.bsonFilter(filter), 0, 0, sort, &transfers)
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (r *stockTransferRepository) Paginate(ctx context.Context, filter *types.StockTransferFilter, page int64, limit int64) ([]*types.StockTransfer, int64, error) {
+	bsonFilter := r.bsonFilter(filter)
+	total, err := r.database.Count(ctx, r.collection, bsonFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	skip := int64(0)
+	if page > 1 && limit > 0 {
+		skip = (page - 1) * limit
+	}
+	transfers := make([]*types.StockTransfer, 0)
+	sort := bson.D{{Key: "number", Value: -1}}
+	err = r.database.Query(ctx, r.collection, bsonFilter, skip, limit, sort, &transfers)
+	if err != nil {
+		return nil, 0, err
+	}
+	return transfers, total, nil
+}
+
+func (r *stockTransferRepository) Update(ctx context.Context, id string, transfer *types.StockTransfer) error {
+	transfer.ID = ""
+	return r.database.Update(ctx, r.collection, id, transfer)
+}
+
+func (r *stockTransferRepository) LastNumber(ctx context.Context) (int, error) {
+	transfers := make([]*types.StockTransfer, 0, 1)
+	sort := bson.D{{Key: "number", Value: -1}}
+	if err := r.database.Query(ctx, r.collection, bson.M{}, 0, 1, sort, &transfers); err != nil {
+		return 0, err
+	}
+	if len(transfers) == 0 {
+		return 0, nil
+	}
+	return transfers[0].Number, nil
+}
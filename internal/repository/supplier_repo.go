@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ SupplierRepository = &supplierRepository{}
+
+type SupplierRepository interface {
+	Save(ctx context.Context, supplier *types.Supplier) (string, error)
+	FindByID(ctx context.Context, id string) (*types.Supplier, error)
+	// FindByCatalogIDs returns the suppliers supplying one of the catalog materials
+	FindByCatalogIDs(ctx context.Context, catalogIDs []string) ([]*types.Supplier, error)
+	Paginate(ctx context.Context, filter *types.SupplierFilter, page int64, limit int64) ([]*types.Supplier, int64, error)
+	Update(ctx context.Context, id string, supplier *types.Supplier) error
+	Delete(ctx context.Context, id string) error
+}
+
+type supplierRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewSupplierRepository(db database.Database) SupplierRepository {
+	return &supplierRepository{
+		database:   db,
+		collection: "suppliers",
+	}
+}
+
+func (r *supplierRepository) Save(ctx context.Context, supplier *types.Supplier) (string, error) {
+	return r.database.Save(ctx, r.collection, supplier)
+}
+
+func (r *supplierRepository) FindByID(ctx context.Context, id string) (*types.Supplier, error) {
+	supplier := &types.Supplier{}
+	err := r.database.FindByID(ctx, r.collection, id, supplier)
+	if err != nil {
+		return nil, err
+	}
+	if supplier.ID == "" {
+		return nil, nil
+	}
+	return supplier, nil
+}
+
+func (r *supplierRepository) FindByCatalogIDs(ctx context.Context, catalogIDs []string) ([]*types.Supplier, error) {
+	suppliers := make([]*types.Supplier, 0)
+	if len(catalogIDs) == 0 {
+		return suppliers, nil
+	}
+	sort := bson.D{{Key: "name", Value: 1}}
+	err := r.database.Query(ctx, r.collection, bson.M{"catalog_ids": bson.M{"$in": catalogIDs}}, 0, 0, sort, &suppliers)
+	if err != nil {
+		return nil, err
+	}
+	return suppliers, nil
+}
+
+// Paginate returns a page (starting at 1) of the suppliers sorted by name.
+func (r *supplierRepository) Paginate(ctx context.Context, filter *types.SupplierFilter, page int64, limit int64) ([]*types.Supplier, int64, error) {
+	bsonFilter := bson.M{}
+	if filter.Query != "" {
+		pattern := bson.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		bsonFilter["$or"] = bson.A{
+			bson.M{"name": pattern},
+			bson.M{"contacts.name": pattern},
+		}
+	}
+	if filter.CatalogID != "" {
+		bsonFilter["catalog_ids"] = filter.CatalogID
+	}
+	total, err := r.database.Count(ctx, r.collection, bsonFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	skip := int64(0)
+	if page > 1 && limit > 0 {
+		skip = (page - 1) * limit
+	}
+	suppliers := make([]*types.Supplier, 0)
+	sort := bson.D{{Key: "name", Value: 1}}
+	err = r.database.Query(ctx, r.collection, bsonFilter, skip, limit, sort, &suppliers)
+	if err != nil {
+		return nil, 0, err
+	}
+	return suppliers, total, nil
+}
+
+func (r *supplierRepository) Update(ctx context.Context, id string, supplier *types.Supplier) error {
+	supplier.ID = ""
+	return r.database.Update(ctx, r.collection, id, supplier)
+}
+
+func (r *supplierRepository) Delete(ctx context.Context, id string) error {
+	return r.database.Delete(ctx, r.collection, id)
+}
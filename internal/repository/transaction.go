@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+)
+
+// Transactor runs the writes of several repositories as one unit.
+type Transactor interface {
+	// WithTransaction runs fn in a transaction: the repository calls made
+	// with the context fn receives are committed together when fn returns
+	// nil and rolled back otherwise. fn may run again when the transaction
+	// is retried.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactor struct {
+	database database.Database
+}
+
+func NewTransactor(db database.Database) Transactor {
+	return &transactor{
+		database: db,
+	}
+}
+
+func (t *transactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return t.database.WithTransaction(ctx, fn)
+}
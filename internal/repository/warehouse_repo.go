@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ WarehouseRepository = &warehouseRepository{}
+
+type WarehouseRepository interface {
+	Save(ctx context.Context, warehouse *types.Warehouse) (string, error)
+	FindByID(ctx context.Context, id string) (*types.Warehouse, error)
+	// Filter returns the warehouses sorted by name
+	Filter(ctx context.Context, filter *types.WarehouseFilter) ([]*types.Warehouse, error)
+	Update(ctx context.Context, id string, warehouse *types.Warehouse) error
+	Delete(ctx context.Context, id string) error
+}
+
+type warehouseRepository struct {
+	database   database.Database
+	collection string
+}
+
+func NewWarehouseRepository(db database.Database) WarehouseRepository {
+	return &warehouseRepository{
+		database:   db,
+		collection: "warehouses",
+	}
+}
+
+func (r *warehouseRepository) Save(ctx context.Context, warehouse *types.Warehouse) (string, error) {
+	return r.database.Save(ctx, r.collection, warehouse)
+}
+
+func (r *warehouseRepository) FindByID(ctx context.Context, id string) (*types.Warehouse, error) {
+	warehouse := &types.Warehouse{}
+	err := r.database.FindByID(ctx, r.collection, id, warehouse)
+	if err != nil {
+		return nil, err
+	}
+	if warehouse.ID == "" {
+		return nil, nil
+	}
+	return warehouse, nil
+}
+
+func (r *warehouseRepository) Filter(ctx context.Context, filter *types.WarehouseFilter) ([]*types.Warehouse, error) {
+	bsonFilter := bson.M{}
+	if filter.ServedSector != "" {
+		bsonFilter["served_sectors"] = filter.ServedSector
+	}
+	warehouses := make([]*types.Warehouse, 0)
+	sort := bson.D{{Key: "name", Value: 1}}
+	err := r.database.Query(ctx, r.collection, bsonFilter, 0, 0, sort, &warehouses)
+	if err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+func (r *warehouseRepository) Update(ctx context.Context, id string, warehouse *types.Warehouse) error {
+	warehouse.ID = ""
+	return r.database.Update(ctx, r.collection, id, warehouse)
+}
+
+func (r *warehouseRepository) Delete(ctx context.Context, id string) error {
+	return r.database.Delete(ctx, r.collection, id)
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+var _ CatalogService = &catalogService{}
+
+type CatalogService interface {
+	CreateCatalogMaterial(ctx context.Context, req *types.CreateCatalogMaterialReq) (string, error)
+	UpdateCatalogMaterial(ctx context.Context, req *types.UpdateCatalogMaterialReq) error
+	GetCatalogMaterial(ctx context.Context, id string) (*types.CatalogMaterial, error)
+	SearchCatalog(ctx context.Context, req *types.CatalogSearchRequest) ([]*types.CatalogMaterial, int64, error)
+	// delete a catalog material that no profile or request line references
+	DeleteCatalogMaterial(ctx context.Context, id string) error
+	// units of measure recognized on import and conversion
+	ListUnits() []utils.Unit
+	// record a price of a catalog material per default unit from a date
+	AddCatalogPrice(ctx context.Context, req *types.AddCatalogPriceReq) (string, error)
+	// price history of a catalog material, latest effective date first
+	PriceHistory(ctx context.Context, id string) ([]*types.CatalogPrice, error)
+	// record the normalized names of the catalog materials missing or having
+	// outdated ones, returns the number of materials updated
+	BackfillNormalizedNames(ctx context.Context) (int, error)
+}
+
+type catalogService struct {
+	catalogRepo          repository.CatalogRepository
+	priceRepo            repository.PriceRepository
+	materialsProfileRepo repository.MaterialsProfileRepository
+	materialsRequestRepo repository.MaterialsRequestRepository
+}
+
+func NewCatalogService(
+	catalogRepo repository.CatalogRepository,
+	priceRepo repository.PriceRepository,
+	materialsProfileRepo repository.MaterialsProfileRepository,
+	materialsRequestRepo repository.MaterialsRequestRepository,
+) CatalogService {
+	return &catalogService{
+		catalogRepo:          catalogRepo,
+		priceRepo:            priceRepo,
+		materialsProfileRepo: materialsProfileRepo,
+		materialsRequestRepo: materialsRequestRepo,
+	}
+}
+
+func (s *catalogService) CreateCatalogMaterial(ctx context.Context, req *types.CreateCatalogMaterialReq) (string, error) {
+	material := newCatalogMaterial(req)
+	if err := s.checkCodeFree(ctx, material.Code, ""); err != nil {
+		return "", err
+	}
+	material.CreatedAt = time.Now().Unix()
+	material.UpdatedAt = material.CreatedAt
+	return s.catalogRepo.Save(ctx, material)
+}
+
+func (s *catalogService) UpdateCatalogMaterial(ctx context.Context, req *types.UpdateCatalogMaterialReq) error {
+	current, err := s.catalogRepo.FindByID(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return types.ErrCatalogMaterialNotFound
+	}
+	material := newCatalogMaterial(&req.CreateCatalogMaterialReq)
+	if err := s.checkCodeFree(ctx, material.Code, req.ID); err != nil {
+		return err
+	}
+	material.CreatedAt = current.CreatedAt
+	material.UpdatedAt = time.Now().Unix()
+	return s.catalogRepo.Update(ctx, req.ID, material)
+}
+
+func (s *catalogService) GetCatalogMaterial(ctx context.Context, id string) (*types.CatalogMaterial, error) {
+	material, err := s.catalogRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if material == nil {
+		return nil, types.ErrCatalogMaterialNotFound
+	}
+	return material, nil
+}
+
+func (s *catalogService) SearchCatalog(ctx context.Context, req *types.CatalogSearchRequest) ([]*types.CatalogMaterial, int64, error) {
+	filter := &types.CatalogMaterialFilter{
+		Query:    strings.TrimSpace(req.Query),
+		Category: strings.TrimSpace(req.Category),
+	}
+	return s.catalogRepo.Paginate(ctx, filter, req.Page, req.Limit)
+}
+
+func (s *catalogService) DeleteCatalogMaterial(ctx context.Context, id string) error {
+	material, err := s.catalogRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if material == nil {
+		return types.ErrCatalogMaterialNotFound
+	}
+	profiles, err := s.materialsProfileRepo.Count(ctx, &types.MaterialsProfileFilter{
+		CatalogID:       id,
+		IncludeArchived: true,
+	})
+	if err != nil {
+		return err
+	}
+	if profiles > 0 {
+		return types.ErrCatalogMaterialInUse
+	}
+	requests, err := s.materialsRequestRepo.Filter(ctx, &types.MaterialRequestFilter{CatalogID: id})
+	if err != nil {
+		return err
+	}
+	if len(requests) > 0 {
+		return types.ErrCatalogMaterialInUse
+	}
+	return s.catalogRepo.Delete(ctx, id)
+}
+
+func (s *catalogService) ListUnits() []utils.Unit {
+	return utils.Units()
+}
+
+func (s *catalogService) AddCatalogPrice(ctx context.Context, req *types.AddCatalogPriceReq) (string, error) {
+	if req.Price.Sign() <= 0 {
+		return "", types.ErrInvalidPrice
+	}
+	material, err := s.catalogRepo.FindByID(ctx, req.CatalogID)
+	if err != nil {
+		return "", err
+	}
+	if material == nil {
+		return "", types.ErrCatalogMaterialNotFound
+	}
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return "", types.ErrUnauthorized
+	}
+	price := &types.CatalogPrice{
+		CatalogID:     material.ID,
+		Price:         req.Price,
+		EffectiveFrom: req.EffectiveFrom,
+		Note:          strings.TrimSpace(req.Note),
+		CreatedBy:     user.Username,
+		CreatedAt:     time.Now().Unix(),
+	}
+	if price.EffectiveFrom == 0 {
+		price.EffectiveFrom = price.CreatedAt
+	}
+	return s.priceRepo.Save(ctx, price)
+}
+
+func (s *catalogService) PriceHistory(ctx context.Context, id string) ([]*types.CatalogPrice, error) {
+	material, err := s.catalogRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if material == nil {
+		return nil, types.ErrCatalogMaterialNotFound
+	}
+	prices, err := s.priceRepo.FindByCatalogIDs(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	sortPriceHistory(prices)
+	return prices, nil
+}
+
+func (s *catalogService) checkCodeFree(ctx context.Context, code, id string) error {
+	existing, err := s.catalogRepo.FindByCode(ctx, code)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != id {
+		return types.ErrDuplicateCatalogCode
+	}
+	return nil
+}
+
+// newCatalogMaterial normalizes the code to upper case, the units to their
+// registry symbol and drops blank aliases, repeated ones and those equal to
+// the canonical name.
+func newCatalogMaterial(req *types.CreateCatalogMaterialReq) *types.CatalogMaterial {
+	material := &types.CatalogMaterial{
+		Code:          strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:          strings.TrimSpace(req.Name),
+		Specification: strings.TrimSpace(req.Specification),
+		DefaultUnit:   utils.NormalizeUnit(req.DefaultUnit),
+		Category:      strings.TrimSpace(req.Category),
+	}
+	for unit, size := range req.PackSizes {
+		unit = utils.NormalizeUnit(unit)
+		if unit == "" || unit == material.DefaultUnit || size <= 0 {
+			continue
+		}
+		if material.PackSizes == nil {
+			material.PackSizes = make(map[string]float64)
+		}
+		material.PackSizes[unit] = size
+	}
+	setCatalogAliases(material, req.Aliases)
+	return material
+}
+
+func (s *catalogService) BackfillNormalizedNames(ctx context.Context) (int, error) {
+	materials, err := s.catalogRepo.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, material := range materials {
+		recorded := material.NormalizedNames
+		setCatalogAliases(material, material.Aliases)
+		if slices.Equal(recorded, material.NormalizedNames) {
+			continue
+		}
+		if err := s.catalogRepo.Update(ctx, material.ID, material); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// setCatalogAliases replaces the aliases of a material and refreshes its
+// normalized names.
+func setCatalogAliases(material *types.CatalogMaterial, aliases []string) {
+	seen := map[string]bool{strings.ToLower(material.Name): true}
+	material.Aliases = make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || seen[strings.ToLower(alias)] {
+			continue
+		}
+		seen[strings.ToLower(alias)] = true
+		material.Aliases = append(material.Aliases, alias)
+	}
+	normalized := map[string]bool{}
+	material.NormalizedNames = make([]string, 0, len(material.Aliases)+1)
+	for _, name := range append([]string{material.Name}, material.Aliases...) {
+		if n := utils.NormalizeName(name); n != "" && !normalized[n] {
+			normalized[n] = true
+			material.NormalizedNames = append(material.NormalizedNames, n)
+		}
+	}
+}
+
+// normalizeMaterials normalizes the units of the materials, makes sure the
+// catalog entries they reference exist and expresses the lines referencing
+// an entry in its default unit when their unit converts to it.
+func normalizeMaterials(ctx context.Context, catalogRepo repository.CatalogRepository, materials ...types.MaterialsForEquipment) error {
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range materials {
+		for _, section := range []map[string]types.Material{m.ReplacementMaterials, m.ConsumableSupplies} {
+			for name, material := range section {
+				material.Unit = utils.NormalizeUnit(material.Unit)
+				section[name] = material
+				if material.CatalogID != "" && !seen[material.CatalogID] {
+					seen[material.CatalogID] = true
+					ids = append(ids, material.CatalogID)
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	catalog, err := catalogRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if len(catalog) != len(ids) {
+		return types.ErrSomeCatalogMaterialNotFound
+	}
+	for _, m := range materials {
+		for _, section := range []map[string]types.Material{m.ReplacementMaterials, m.ConsumableSupplies} {
+			for name, material := range section {
+				if material.CatalogID != "" {
+					section[name] = toCatalogUnit(material, catalog[material.CatalogID])
+				}
+			}
+		}
+	}
+	return nil
+}
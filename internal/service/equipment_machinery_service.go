@@ -1,31 +1,59 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/remiehneppo/material-management/internal/repository"
 	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
 )
 
 type EquipmentMachineryService interface {
 	CreateEquipmentMachinery(ctx context.Context, req *types.CreateEquipmentMachineryReq) (string, error)
 	FilterEquipmentMachinery(ctx context.Context, req *types.EquipmentMachineryFilter) ([]*types.EquipmentMachinery, error)
+	GetEquipmentMachinery(ctx context.Context, id string) (*types.EquipmentMachinery, error)
+	// update the attributes and the parent of an equipment, or move it to
+	// another sector while no profile references it and it has no children
+	UpdateEquipmentMachinery(ctx context.Context, req *types.UpdateEquipmentMachineryReq) error
+	// delete an equipment without children no profile references
+	DeleteEquipmentMachinery(ctx context.Context, id string) error
+	// the equipments of a sector as a tree of ship systems, assemblies and
+	// components, in order
+	EquipmentTree(ctx context.Context, sector string) ([]*types.EquipmentNode, error)
+	// re-point the profiles, installed parts and children of duplicate
+	// equipments to the target and delete the duplicates, all or nothing
+	MergeEquipmentMachineries(ctx context.Context, req *types.MergeEquipmentMachineriesReq) (*types.MergeEquipmentMachineriesResult, error)
 }
 
 type equipmentMachineryService struct {
 	equipmentMachineryRepo repository.EquipmentMachineryRepo
+	materialsProfileRepo   repository.MaterialsProfileRepository
+	installedPartRepo      repository.InstalledPartRepository
+	transactor             repository.Transactor
 }
 
-func NewEquipmentMachineryService(equipmentMachineryRepo repository.EquipmentMachineryRepo) EquipmentMachineryService {
+func NewEquipmentMachineryService(
+	equipmentMachineryRepo repository.EquipmentMachineryRepo,
+	materialsProfileRepo repository.MaterialsProfileRepository,
+	installedPartRepo repository.InstalledPartRepository,
+	transactor repository.Transactor,
+) EquipmentMachineryService {
 	return &equipmentMachineryService{
 		equipmentMachineryRepo: equipmentMachineryRepo,
+		materialsProfileRepo:   materialsProfileRepo,
+		installedPartRepo:      installedPartRepo,
+		transactor:             transactor,
 	}
 }
 
 func (s *equipmentMachineryService) CreateEquipmentMachinery(ctx context.Context, req *types.CreateEquipmentMachineryReq) (string, error) {
-	equipmentMachinery := &types.EquipmentMachinery{
-		Name:   req.Name,
-		Sector: req.Sector,
+	equipmentMachinery, err := s.newEquipmentMachinery(ctx, "", req)
+	if err != nil {
+		return "", err
 	}
 
 	id, err := s.equipmentMachineryRepo.Save(
@@ -41,3 +69,337 @@ func (s *equipmentMachineryService) CreateEquipmentMachinery(ctx context.Context
 func (s *equipmentMachineryService) FilterEquipmentMachinery(ctx context.Context, req *types.EquipmentMachineryFilter) ([]*types.EquipmentMachinery, error) {
 	return s.equipmentMachineryRepo.Filter(ctx, req)
 }
+
+func (s *equipmentMachineryService) GetEquipmentMachinery(ctx context.Context, id string) (*types.EquipmentMachinery, error) {
+	equipmentMachinery, err := s.equipmentMachineryRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if equipmentMachinery == nil || equipmentMachinery.ID == "" {
+		return nil, types.ErrEquipmentMachineryNotFound
+	}
+	return equipmentMachinery, nil
+}
+
+func (s *equipmentMachineryService) UpdateEquipmentMachinery(ctx context.Context, req *types.UpdateEquipmentMachineryReq) error {
+	existing, err := s.GetEquipmentMachinery(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	update := req.CreateEquipmentMachineryReq
+	if update.Aliases == nil {
+		update.Aliases = existing.Aliases
+	}
+	equipmentMachinery, err := s.newEquipmentMachinery(ctx, existing.ID, &update)
+	if err != nil {
+		return err
+	}
+	if equipmentMachinery.Sector != existing.Sector {
+		if err := s.checkUnreferenced(ctx, existing.ID); err != nil {
+			return err
+		}
+		if err := s.checkNoChildren(ctx, existing.ID); err != nil {
+			return err
+		}
+	}
+	return s.equipmentMachineryRepo.Update(ctx, req.ID, equipmentMachinery)
+}
+
+func (s *equipmentMachineryService) DeleteEquipmentMachinery(ctx context.Context, id string) error {
+	equipmentMachinery, err := s.GetEquipmentMachinery(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkUnreferenced(ctx, equipmentMachinery.ID); err != nil {
+		return err
+	}
+	if err := s.checkNoChildren(ctx, equipmentMachinery.ID); err != nil {
+		return err
+	}
+	return s.equipmentMachineryRepo.Delete(ctx, id)
+}
+
+// newEquipmentMachinery validates the equipment id is created or updated
+// with, id being empty for a new equipment.
+func (s *equipmentMachineryService) newEquipmentMachinery(ctx context.Context, id string, req *types.CreateEquipmentMachineryReq) (*types.EquipmentMachinery, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, types.ErrEmptyEquipmentName
+	}
+	if !utils.Contains(types.SECTOR_LIST, req.Sector) {
+		return nil, types.ErrInvalidSector
+	}
+	var specs map[string]string
+	for key, value := range req.Specs {
+		key = strings.TrimSpace(key)
+		if key == "" || strings.ContainsAny(key, ".$") {
+			return nil, fmt.Errorf("%w: %q", types.ErrInvalidSpecKey, key)
+		}
+		if specs == nil {
+			specs = make(map[string]string, len(req.Specs))
+		}
+		specs[key] = strings.TrimSpace(value)
+	}
+	parentID := strings.TrimSpace(req.ParentID)
+	if parentID != "" {
+		parent, err := s.equipmentMachineryRepo.FindByID(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil || parent.ID == "" || parent.Sector != req.Sector {
+			return nil, types.ErrInvalidEquipmentParent
+		}
+		if id != "" {
+			ancestors, err := s.ancestors(ctx, parent)
+			if err != nil {
+				return nil, err
+			}
+			if ancestors[id] {
+				return nil, types.ErrInvalidEquipmentParent
+			}
+		}
+	}
+	equipmentMachinery := &types.EquipmentMachinery{
+		Name:         name,
+		Sector:       req.Sector,
+		ParentID:     parentID,
+		Order:        req.Order,
+		Model:        strings.TrimSpace(req.Model),
+		Manufacturer: strings.TrimSpace(req.Manufacturer),
+		RatedSpecs:   strings.TrimSpace(req.RatedSpecs),
+		Specs:        specs,
+	}
+	setEquipmentAliases(equipmentMachinery, req.Aliases)
+
+	others, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{Sector: req.Sector})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDistinctNames(equipmentMachinery, others, id); err != nil {
+		return nil, err
+	}
+	return equipmentMachinery, nil
+}
+
+// checkDistinctNames checks the names of an equipment against the other
+// equipments of its sector, but those of ignoredIDs. The importer resolves
+// equipments by the normalized names and aliases of the sector: siblings
+// must be told apart and an alias must name one equipment only.
+func checkDistinctNames(equipmentMachinery *types.EquipmentMachinery, others []*types.EquipmentMachinery, ignoredIDs ...string) error {
+	names := normalizedEquipmentNames(equipmentMachinery)
+	normalizedName := utils.NormalizeName(equipmentMachinery.Name)
+	for _, other := range others {
+		if slices.Contains(ignoredIDs, other.ID) {
+			continue
+		}
+		for normalized, matched := range normalizedEquipmentNames(other) {
+			if _, ok := names[normalized]; !ok {
+				continue
+			}
+			if normalized == normalizedName && normalized == utils.NormalizeName(other.Name) {
+				if other.ParentID == equipmentMachinery.ParentID {
+					return fmt.Errorf("%w: %s", types.ErrDuplicateEquipmentMachinery, other.Name)
+				}
+				continue
+			}
+			return fmt.Errorf("%w: %q of %s", types.ErrDuplicateEquipmentAlias, matched, other.Name)
+		}
+	}
+	return nil
+}
+
+// setEquipmentAliases replaces the aliases of an equipment, dropping those
+// that normalize to its name or to an earlier alias.
+func setEquipmentAliases(equipmentMachinery *types.EquipmentMachinery, aliases []string) {
+	seen := map[string]bool{utils.NormalizeName(equipmentMachinery.Name): true}
+	equipmentMachinery.Aliases = make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		normalized := utils.NormalizeName(alias)
+		if normalized == "" || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		equipmentMachinery.Aliases = append(equipmentMachinery.Aliases, alias)
+	}
+}
+
+// normalizedEquipmentNames returns the name and aliases of an equipment by their
+// normalized form.
+func normalizedEquipmentNames(equipmentMachinery *types.EquipmentMachinery) map[string]string {
+	names := make(map[string]string, len(equipmentMachinery.Aliases)+1)
+	for _, name := range append([]string{equipmentMachinery.Name}, equipmentMachinery.Aliases...) {
+		normalized := utils.NormalizeName(name)
+		if _, ok := names[normalized]; normalized != "" && !ok {
+			names[normalized] = name
+		}
+	}
+	return names
+}
+
+// ancestors returns the IDs of an equipment and of the equipments above it.
+func (s *equipmentMachineryService) ancestors(ctx context.Context, equipmentMachinery *types.EquipmentMachinery) (map[string]bool, error) {
+	ancestors := map[string]bool{equipmentMachinery.ID: true}
+	for parentID := equipmentMachinery.ParentID; parentID != "" && !ancestors[parentID]; {
+		ancestors[parentID] = true
+		parent, err := s.equipmentMachineryRepo.FindByID(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			break
+		}
+		parentID = parent.ParentID
+	}
+	return ancestors, nil
+}
+
+func (s *equipmentMachineryService) checkNoChildren(ctx context.Context, id string) error {
+	children, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{ParentID: id})
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("%w: %d children", types.ErrEquipmentHasChildren, len(children))
+	}
+	return nil
+}
+
+// checkUnreferenced checks that no profile, archived ones included,
+// references the equipment.
+func (s *equipmentMachineryService) checkUnreferenced(ctx context.Context, id string) error {
+	count, err := s.materialsProfileRepo.Count(ctx, &types.MaterialsProfileFilter{
+		EquipmentMachineryIDs: []string{id},
+		IncludeArchived:       true,
+	})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d profiles", types.ErrEquipmentMachineryInUse, count)
+	}
+	return nil
+}
+
+// MergeEquipmentMachineries writes in a transaction: a merge that fails
+// changes nothing and can be run again as is.
+func (s *equipmentMachineryService) MergeEquipmentMachineries(ctx context.Context, req *types.MergeEquipmentMachineriesReq) (*types.MergeEquipmentMachineriesResult, error) {
+	sourceIDs := make([]string, 0, len(req.SourceIDs))
+	for _, id := range trimIDs(req.SourceIDs) {
+		if id == req.TargetID {
+			return nil, types.ErrInvalidMergeSource
+		}
+		sourceIDs = append(sourceIDs, id)
+	}
+	if len(sourceIDs) == 0 {
+		return nil, types.ErrInvalidMergeSource
+	}
+	equipments, err := s.equipmentMachineryRepo.FindByIDs(ctx, append([]string{req.TargetID}, sourceIDs...))
+	if err != nil {
+		return nil, err
+	}
+	if len(equipments) != len(sourceIDs)+1 {
+		return nil, types.ErrSomeEquipmentMachineryNotFound
+	}
+	target := equipments[req.TargetID]
+	for _, id := range sourceIDs {
+		if equipments[id].Sector != target.Sector {
+			return nil, fmt.Errorf("%w: %s", types.ErrEquipmentSectorMismatch, equipments[id].Name)
+		}
+	}
+
+	// the children of the sources move under the target, which cannot be one of them
+	ancestors, err := s.ancestors(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range sourceIDs {
+		if ancestors[id] {
+			return nil, fmt.Errorf("%w: %s", types.ErrInvalidEquipmentParent, equipments[id].Name)
+		}
+	}
+
+	updatedProfiles, err := s.materialsProfileRepo.Count(ctx, &types.MaterialsProfileFilter{
+		EquipmentMachineryIDs: sourceIDs,
+		IncludeArchived:       true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the names of the duplicates remain known as aliases of the target
+	aliases := slices.Clone(target.Aliases)
+	for _, id := range sourceIDs {
+		aliases = append(aliases, equipments[id].Name)
+		aliases = append(aliases, equipments[id].Aliases...)
+	}
+	setEquipmentAliases(target, aliases)
+	others, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{Sector: target.Sector})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDistinctNames(target, others, append([]string{req.TargetID}, sourceIDs...)...); err != nil {
+		return nil, err
+	}
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.materialsProfileRepo.ReassignEquipment(ctx, sourceIDs, req.TargetID); err != nil {
+			return err
+		}
+		if err := s.installedPartRepo.ReassignEquipment(ctx, sourceIDs, req.TargetID, target.Name); err != nil {
+			return err
+		}
+		if err := s.equipmentMachineryRepo.Reparent(ctx, sourceIDs, req.TargetID); err != nil {
+			return err
+		}
+		if err := s.equipmentMachineryRepo.Update(ctx, req.TargetID, target); err != nil {
+			return err
+		}
+		return s.equipmentMachineryRepo.DeleteMany(ctx, sourceIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.MergeEquipmentMachineriesResult{
+		MergedEquipments: len(sourceIDs),
+		UpdatedProfiles:  updatedProfiles,
+	}, nil
+}
+
+func (s *equipmentMachineryService) EquipmentTree(ctx context.Context, sector string) ([]*types.EquipmentNode, error) {
+	if sector != "" && !utils.Contains(types.SECTOR_LIST, sector) {
+		return nil, types.ErrInvalidSector
+	}
+	equipments, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{Sector: sector})
+	if err != nil {
+		return nil, err
+	}
+	return buildEquipmentTree(equipments), nil
+}
+
+// buildEquipmentTree nests equipments under their parent, siblings sorted by
+// order then name. Equipments whose parent is not among them are roots.
+func buildEquipmentTree(equipments []*types.EquipmentMachinery) []*types.EquipmentNode {
+	nodes := make(map[string]*types.EquipmentNode, len(equipments))
+	for _, equipment := range equipments {
+		nodes[equipment.ID] = &types.EquipmentNode{EquipmentMachinery: equipment, Children: []*types.EquipmentNode{}}
+	}
+	roots := make([]*types.EquipmentNode, 0)
+	for _, equipment := range equipments {
+		node := nodes[equipment.ID]
+		if parent, ok := nodes[equipment.ParentID]; ok && parent != node {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	sortEquipmentNodes(roots)
+	return roots
+}
+
+func sortEquipmentNodes(nodes []*types.EquipmentNode) {
+	slices.SortFunc(nodes, func(a, b *types.EquipmentNode) int {
+		return cmp.Or(cmp.Compare(a.Order, b.Order), cmp.Compare(a.Name, b.Name))
+	})
+	for _, node := range nodes {
+		sortEquipmentNodes(node.Children)
+	}
+}
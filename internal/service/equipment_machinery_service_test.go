@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+func TestMergeEquipmentMachineries(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	equipmentMachineryRepo := repository.NewEquipmentMachineryRepo(db)
+	materialsProfileRepo := repository.NewMaterialsProfileRepository(db)
+	installedPartRepo := repository.NewInstalledPartRepository(db)
+	s := NewEquipmentMachineryService(equipmentMachineryRepo, materialsProfileRepo, installedPartRepo, repository.NewTransactor(db))
+
+	targetID, err := equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Máy chính", Sector: types.SECTOR_MECHANICAL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicateID, err := equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "May chinh", Sector: types.SECTOR_MECHANICAL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hullID, err := equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Máy chính", Sector: types.SECTOR_HULL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, archived := range []bool{false, true} {
+		if _, err := materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+			MaintenanceInstanceID: "maintenance-1",
+			EquipmentMachineryID:  duplicateID,
+			Sector:                types.SECTOR_MECHANICAL,
+			Archived:              archived,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := installedPartRepo.SaveMany(ctx, []*types.InstalledPart{
+		{Serial: "SN-1", EquipmentMachineryID: duplicateID, EquipmentName: "May chinh"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteEquipmentMachinery(ctx, duplicateID); !errors.Is(err, types.ErrEquipmentMachineryInUse) {
+		t.Fatalf("delete a referenced equipment: got %v, want ErrEquipmentMachineryInUse", err)
+	}
+	err = s.UpdateEquipmentMachinery(ctx, &types.UpdateEquipmentMachineryReq{
+		ID:                          duplicateID,
+		CreateEquipmentMachineryReq: types.CreateEquipmentMachineryReq{Name: "MÁY CHÍNH", Sector: types.SECTOR_MECHANICAL},
+	})
+	if !errors.Is(err, types.ErrDuplicateEquipmentMachinery) {
+		t.Fatalf("rename to the name of another equipment: got %v, want ErrDuplicateEquipmentMachinery", err)
+	}
+	err = s.UpdateEquipmentMachinery(ctx, &types.UpdateEquipmentMachineryReq{
+		ID:                          duplicateID,
+		CreateEquipmentMachineryReq: types.CreateEquipmentMachineryReq{Name: "Máy phụ", Sector: types.SECTOR_ELECTRICAL},
+	})
+	if !errors.Is(err, types.ErrEquipmentMachineryInUse) {
+		t.Fatalf("move a referenced equipment to another sector: got %v, want ErrEquipmentMachineryInUse", err)
+	}
+	_, err = s.MergeEquipmentMachineries(ctx, &types.MergeEquipmentMachineriesReq{TargetID: targetID, SourceIDs: []string{hullID}})
+	if !errors.Is(err, types.ErrEquipmentSectorMismatch) {
+		t.Fatalf("merge across sectors: got %v, want ErrEquipmentSectorMismatch", err)
+	}
+
+	// a merge failing on its last write changes nothing and is run again
+	failure := errors.New("connection lost")
+	db.failWrites("equipment_machineries", failure)
+	_, err = s.MergeEquipmentMachineries(ctx, &types.MergeEquipmentMachineriesReq{TargetID: targetID, SourceIDs: []string{duplicateID}})
+	if !errors.Is(err, failure) {
+		t.Fatalf("merge with failing writes: got %v, want %v", err, failure)
+	}
+	db.failWrites("equipment_machineries", nil)
+	if count, err := materialsProfileRepo.Count(ctx, &types.MaterialsProfileFilter{EquipmentMachineryIDs: []string{duplicateID}, IncludeArchived: true}); err != nil || count != 2 {
+		t.Fatalf("profiles of the duplicate after a failed merge = %d (%v), want 2", count, err)
+	}
+	parts, err := installedPartRepo.Filter(ctx, &types.InstalledPartFilter{Serials: []string{"SN-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts[0].EquipmentMachineryID != duplicateID {
+		t.Fatalf("installed part after a failed merge = %+v, want it on the duplicate", parts[0])
+	}
+
+	result, err := s.MergeEquipmentMachineries(ctx, &types.MergeEquipmentMachineriesReq{TargetID: targetID, SourceIDs: []string{duplicateID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MergedEquipments != 1 || result.UpdatedProfiles != 2 {
+		t.Fatalf("result = %+v, want 1 equipment and 2 profiles", result)
+	}
+	profiles, err := materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{EquipmentMachineryIDs: []string{targetID}, IncludeArchived: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("got %d profiles of the target, want 2", len(profiles))
+	}
+	parts, err = installedPartRepo.Filter(ctx, &types.InstalledPartFilter{Serials: []string{"SN-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts[0].EquipmentMachineryID != targetID || parts[0].EquipmentName != "Máy chính" {
+		t.Fatalf("installed part = %+v, want it on the target", parts[0])
+	}
+	if _, err := s.GetEquipmentMachinery(ctx, duplicateID); !errors.Is(err, types.ErrEquipmentMachineryNotFound) {
+		t.Fatalf("merged equipment: got %v, want ErrEquipmentMachineryNotFound", err)
+	}
+
+	if err := s.UpdateEquipmentMachinery(ctx, &types.UpdateEquipmentMachineryReq{
+		ID:                          hullID,
+		CreateEquipmentMachineryReq: types.CreateEquipmentMachineryReq{Name: "Máy chính tàu", Sector: types.SECTOR_HULL},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteEquipmentMachinery(ctx, hullID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEquipmentHierarchy(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	equipmentMachineryRepo := repository.NewEquipmentMachineryRepo(db)
+	materialsProfileRepo := repository.NewMaterialsProfileRepository(db)
+	s := NewEquipmentMachineryService(equipmentMachineryRepo, materialsProfileRepo, repository.NewInstalledPartRepository(db), repository.NewTransactor(db))
+	create := func(req types.CreateEquipmentMachineryReq) string {
+		t.Helper()
+		req.Sector = types.SECTOR_MECHANICAL
+		id, err := s.CreateEquipmentMachinery(ctx, &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	systemID := create(types.CreateEquipmentMachineryReq{Name: "Hệ động lực"})
+	engineID := create(types.CreateEquipmentMachineryReq{
+		Name:         "Máy chính",
+		ParentID:     systemID,
+		Order:        1,
+		Model:        "MTU 16V4000",
+		Manufacturer: "MTU",
+		RatedSpecs:   "2000 kW, 1800 vòng/phút",
+		Specs:        map[string]string{"Số xi lanh": "16"},
+	})
+	pumpID := create(types.CreateEquipmentMachineryReq{Name: "Bơm nước ngọt", ParentID: engineID})
+	gearboxID := create(types.CreateEquipmentMachineryReq{Name: "Hộp số", ParentID: systemID, Order: 2})
+	// siblings are told apart by name, components of other assemblies are not
+	create(types.CreateEquipmentMachineryReq{Name: "Bơm nước ngọt", ParentID: gearboxID})
+	_, err := s.CreateEquipmentMachinery(ctx, &types.CreateEquipmentMachineryReq{Name: "bơm nước ngọt", Sector: types.SECTOR_MECHANICAL, ParentID: engineID})
+	if !errors.Is(err, types.ErrDuplicateEquipmentMachinery) {
+		t.Fatalf("namesake sibling: got %v, want ErrDuplicateEquipmentMachinery", err)
+	}
+	_, err = s.CreateEquipmentMachinery(ctx, &types.CreateEquipmentMachineryReq{Name: "Tời", Sector: types.SECTOR_HULL, ParentID: systemID})
+	if !errors.Is(err, types.ErrInvalidEquipmentParent) {
+		t.Fatalf("parent of another sector: got %v, want ErrInvalidEquipmentParent", err)
+	}
+	err = s.UpdateEquipmentMachinery(ctx, &types.UpdateEquipmentMachineryReq{
+		ID:                          systemID,
+		CreateEquipmentMachineryReq: types.CreateEquipmentMachineryReq{Name: "Hệ động lực", Sector: types.SECTOR_MECHANICAL, ParentID: pumpID},
+	})
+	if !errors.Is(err, types.ErrInvalidEquipmentParent) {
+		t.Fatalf("system under its own component: got %v, want ErrInvalidEquipmentParent", err)
+	}
+	if err := s.DeleteEquipmentMachinery(ctx, engineID); !errors.Is(err, types.ErrEquipmentHasChildren) {
+		t.Fatalf("delete an assembly with components: got %v, want ErrEquipmentHasChildren", err)
+	}
+
+	tree, err := s.EquipmentTree(ctx, types.SECTOR_MECHANICAL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 1 || len(tree[0].Children) != 2 || tree[0].Children[0].ID != engineID || tree[0].Children[0].Children[0].ID != pumpID {
+		t.Fatalf("tree = %+v, want the engine then the gearbox under the system", tree)
+	}
+	found, err := s.FilterEquipmentMachinery(ctx, &types.EquipmentMachineryFilter{Manufacturer: "mtu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != engineID || found[0].Specs["Số xi lanh"] != "16" {
+		t.Fatalf("equipments of MTU = %+v, want the engine", found)
+	}
+	roots, err := s.FilterEquipmentMachinery(ctx, &types.EquipmentMachineryFilter{RootsOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || roots[0].ID != systemID {
+		t.Fatalf("roots = %+v, want the system", roots)
+	}
+
+	// picking the system profiles its assemblies and components below it
+	profiles := newTestMaterialsProfileService(db)
+	maintenanceID, err := repository.NewMaintenanceRepository(db).Save(ctx, &types.Maintenance{Project: "Tàu 01", ProjectCode: "T01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := profiles.CreateMaterialsProfile(ctx, &types.CreateMaterialProfileReq{
+		MaintenanceInstanceID: maintenanceID,
+		EquipmentMachineryID:  systemID,
+		IndexPath:             "2",
+		Sector:                types.SECTOR_MECHANICAL,
+		IncludeChildren:       true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	created, err := materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{MaintenanceInstanceIDs: []string{maintenanceID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexes := make(map[string]string)
+	for _, profile := range created {
+		indexes[utils.IndexPathToString(profile.Index)] = profile.EquipmentMachineryID
+	}
+	if len(indexes) != 5 || indexes["2"] != systemID || indexes["2.1"] != engineID || indexes["2.1.1"] != pumpID || indexes["2.2"] != gearboxID {
+		t.Fatalf("profiles = %v, want the system at 2, the engine at 2.1 with its pump at 2.1.1 and the gearbox at 2.2", indexes)
+	}
+}
+
+func TestEquipmentAliases(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	equipmentMachineryRepo := repository.NewEquipmentMachineryRepo(db)
+	s := NewEquipmentMachineryService(equipmentMachineryRepo, repository.NewMaterialsProfileRepository(db), repository.NewInstalledPartRepository(db), repository.NewTransactor(db))
+
+	firstID, err := s.CreateEquipmentMachinery(ctx, &types.CreateEquipmentMachineryReq{
+		Name:    "Máy phát điện số 1",
+		Sector:  types.SECTOR_MECHANICAL,
+		Aliases: []string{"MF1", " mf1 ", "may phat dien so 1", ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.GetEquipmentMachinery(ctx, firstID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Aliases) != 1 || first.Aliases[0] != "MF1" {
+		t.Errorf("aliases = %q, want [MF1]", first.Aliases)
+	}
+
+	for _, req := range []*types.CreateEquipmentMachineryReq{
+		{Name: "Máy phát điện số 2", Sector: types.SECTOR_MECHANICAL, Aliases: []string{"mf 1", "Mf1"}},
+		{Name: "MF1", Sector: types.SECTOR_MECHANICAL},
+		{Name: "Máy phát điện số 2", Sector: types.SECTOR_MECHANICAL, Aliases: []string{"Máy phát điện số 1"}},
+	} {
+		if _, err := s.CreateEquipmentMachinery(ctx, req); !errors.Is(err, types.ErrDuplicateEquipmentAlias) {
+			t.Errorf("create %s with aliases %q: got %v, want ErrDuplicateEquipmentAlias", req.Name, req.Aliases, err)
+		}
+	}
+	// aliases are unique per sector only
+	if _, err := s.CreateEquipmentMachinery(ctx, &types.CreateEquipmentMachineryReq{Name: "MF1", Sector: types.SECTOR_ELECTRICAL}); err != nil {
+		t.Errorf("create MF1 in another sector: %v", err)
+	}
+
+	secondID, err := s.CreateEquipmentMachinery(ctx, &types.CreateEquipmentMachineryReq{
+		Name:    "Máy phát điện số 2",
+		Sector:  types.SECTOR_MECHANICAL,
+		Aliases: []string{"MF2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MergeEquipmentMachineries(ctx, &types.MergeEquipmentMachineriesReq{TargetID: firstID, SourceIDs: []string{secondID}}); err != nil {
+		t.Fatal(err)
+	}
+	first, err = s.GetEquipmentMachinery(ctx, firstID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"MF1", "Máy phát điện số 2", "MF2"}; !slices.Equal(first.Aliases, want) {
+		t.Errorf("aliases after merge = %q, want %q", first.Aliases, want)
+	}
+
+	// an update leaving the aliases out keeps them, an empty list clears them
+	update := &types.UpdateEquipmentMachineryReq{
+		ID:                          firstID,
+		CreateEquipmentMachineryReq: types.CreateEquipmentMachineryReq{Name: "Máy phát điện chính", Sector: types.SECTOR_MECHANICAL},
+	}
+	if err := s.UpdateEquipmentMachinery(ctx, update); err != nil {
+		t.Fatal(err)
+	}
+	if first, err = s.GetEquipmentMachinery(ctx, firstID); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"MF1", "Máy phát điện số 2", "MF2"}; !slices.Equal(first.Aliases, want) {
+		t.Errorf("aliases after an update without aliases = %q, want %q", first.Aliases, want)
+	}
+	update.Aliases = []string{}
+	if err := s.UpdateEquipmentMachinery(ctx, update); err != nil {
+		t.Fatal(err)
+	}
+	if first, err = s.GetEquipmentMachinery(ctx, firstID); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Aliases) != 0 {
+		t.Errorf("aliases after clearing = %q, want none", first.Aliases)
+	}
+
+	// the name of a duplicate becomes an alias of the target, which must not
+	// name another equipment of the sector
+	ids := make(map[string]string)
+	for _, equipment := range []struct{ key, name, parent string }{
+		{"system 1", "Hệ thống nước ngọt", ""},
+		{"system 2", "Hệ thống nước biển", ""},
+		{"pump", "Bơm nước", "system 1"},
+		{"target", "Bơm cấp nước", "system 1"},
+		{"other pump", "Bơm nước", "system 2"},
+	} {
+		id, err := s.CreateEquipmentMachinery(ctx, &types.CreateEquipmentMachineryReq{Name: equipment.name, Sector: types.SECTOR_MECHANICAL, ParentID: ids[equipment.parent]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[equipment.key] = id
+	}
+	_, err = s.MergeEquipmentMachineries(ctx, &types.MergeEquipmentMachineriesReq{TargetID: ids["target"], SourceIDs: []string{ids["pump"]}})
+	if !errors.Is(err, types.ErrDuplicateEquipmentAlias) {
+		t.Fatalf("merge taking the name of another equipment as alias: got %v, want ErrDuplicateEquipmentAlias", err)
+	}
+	if _, err := s.GetEquipmentMachinery(ctx, ids["pump"]); err != nil {
+		t.Fatalf("duplicate after a refused merge: %v", err)
+	}
+}
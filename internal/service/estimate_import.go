@@ -0,0 +1,465 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+// ImportReporter receives the progress of an estimate import.
+type ImportReporter interface {
+	// AddRows announces sheet rows that are about to be processed
+	AddRows(rows int)
+	// Advance marks sheet rows as processed
+	Advance(rows int)
+	Warn(warning string)
+	// Committing announces that the import starts writing, it is cancelled
+	// instead when an error is returned
+	Committing() error
+}
+
+// noopImportReporter discards the progress of imports run inside a request.
+type noopImportReporter struct{}
+
+func (noopImportReporter) AddRows(int)       {}
+func (noopImportReporter) Advance(int)       {}
+func (noopImportReporter) Warn(string)       {}
+func (noopImportReporter) Committing() error { return nil }
+
+// plannedProfile is a materials profile touched by an import. Equipment and
+// profile IDs stay empty until the plan is committed when they are new.
+type plannedProfile struct {
+	equipment *types.EquipmentMachinery
+	profile   *types.MaterialsProfile
+}
+
+// importLookupBatchSize bounds the number of names or IDs sent in one $in
+// query while resolving a sheet.
+const importLookupBatchSize = 500
+
+// estimateImportPlanner resolves parsed sheets against the database using
+// reads only, so that a whole workbook is validated before anything is
+// written. Equipment and profiles are shared between sheets of the same
+// sector so that a workbook never creates the same record twice. Lookups are
+// batched per sheet and the writes per workbook.
+type estimateImportPlanner struct {
+	materialsProfileRepo   repository.MaterialsProfileRepository
+	equipmentMachineryRepo repository.EquipmentMachineryRepo
+	catalogRepo            repository.CatalogRepository
+	transactor             repository.Transactor
+	maintenance            *types.Maintenance
+	reporter               ImportReporter
+
+	// equipments and profiles are keyed by sector and normalized name
+	equipments map[string]*types.EquipmentMachinery
+	profiles   map[string]*plannedProfile
+	// ambiguous are the candidates of the names matching several equipments
+	ambiguous map[string][]types.EquipmentCandidate
+	// existingProfiles are the stored profiles of resolved equipments by
+	// equipment ID
+	existingProfiles map[string]*types.MaterialsProfile
+	// sectorEquipments are all the stored equipments of a sector, loaded to
+	// resolve names and aliases and to propose the closest one for a name
+	// that is not found
+	sectorEquipments map[string][]*types.EquipmentMachinery
+	order            []*plannedProfile
+	results          []*types.EstimateImportResult
+}
+
+func newEstimateImportPlanner(
+	materialsProfileRepo repository.MaterialsProfileRepository,
+	equipmentMachineryRepo repository.EquipmentMachineryRepo,
+	catalogRepo repository.CatalogRepository,
+	transactor repository.Transactor,
+	maintenance *types.Maintenance,
+	reporter ImportReporter,
+) *estimateImportPlanner {
+	if reporter == nil {
+		reporter = noopImportReporter{}
+	}
+	return &estimateImportPlanner{
+		materialsProfileRepo:   materialsProfileRepo,
+		equipmentMachineryRepo: equipmentMachineryRepo,
+		catalogRepo:            catalogRepo,
+		transactor:             transactor,
+		maintenance:            maintenance,
+		reporter:               reporter,
+		equipments:             make(map[string]*types.EquipmentMachinery),
+		profiles:               make(map[string]*plannedProfile),
+		ambiguous:              make(map[string][]types.EquipmentCandidate),
+		existingProfiles:       make(map[string]*types.MaterialsProfile),
+		sectorEquipments:       make(map[string][]*types.EquipmentMachinery),
+	}
+}
+
+func equipmentKey(sector, name string) string {
+	return sector + "\x00" + utils.NormalizeName(name)
+}
+
+// plan resolves the equipment and materials profile of every parsed equipment
+// block of a sheet and merges the estimate into the profile in memory.
+func (p *estimateImportPlanner) plan(ctx context.Context, sheetName, sector, importProfileName string, equipments []*estimateEquipment) (*types.EstimateImportResult, error) {
+	result := &types.EstimateImportResult{
+		SheetName:     sheetName,
+		Sector:        sector,
+		ImportProfile: importProfileName,
+	}
+	if err := p.resolveEquipments(ctx, sector, equipments); err != nil {
+		return nil, err
+	}
+	if err := p.resolveProfiles(ctx, sector); err != nil {
+		return nil, err
+	}
+	links, err := p.linkCatalog(ctx, sheetName, equipments)
+	if err != nil {
+		return nil, err
+	}
+	result.CatalogLinks = links
+
+	for _, parsed := range equipments {
+		if ctx.Err() != nil {
+			return nil, types.ErrImportCancelled
+		}
+		key := equipmentKey(sector, parsed.Name)
+		if candidates, ok := p.ambiguous[key]; ok {
+			// the materials are not attached to a guessed equipment
+			if !slices.ContainsFunc(result.AmbiguousEquipments, func(ambiguous *types.AmbiguousEquipment) bool {
+				return equipmentKey(sector, ambiguous.Name) == key
+			}) {
+				result.AmbiguousEquipments = append(result.AmbiguousEquipments, &types.AmbiguousEquipment{
+					Name:       parsed.Name,
+					Candidates: candidates,
+				})
+				p.reporter.Warn(fmt.Sprintf("sheet %s: equipment %q matches %d equipments of sector %s, its materials are not imported", sheetName, parsed.Name, len(candidates), sector))
+			}
+			p.reporter.Advance(parsed.Rows)
+			continue
+		}
+		equipment, ok := p.equipments[key]
+		if !ok {
+			// Equipment not found, it will be created on commit
+			equipment = &types.EquipmentMachinery{
+				Name:   parsed.Name,
+				Sector: sector,
+			}
+			p.equipments[key] = equipment
+			result.CreatedEquipments++
+			warning := fmt.Sprintf("sheet %s: equipment %q not found in sector %s, it will be created", sheetName, parsed.Name, sector)
+			closest, err := p.closestEquipment(ctx, sector, parsed.Name)
+			if err != nil {
+				return nil, err
+			}
+			if closest != nil {
+				warning += fmt.Sprintf(", did you mean %q (%.0f%%)?", closest.Name, closest.Score*100)
+			}
+			p.reporter.Warn(warning)
+		}
+
+		planned, ok := p.profiles[key]
+		if !ok {
+			profile := p.existingProfiles[equipment.ID]
+			if equipment.ID == "" || profile == nil {
+				index, err := utils.StringToIndexPath(parsed.IndexPath)
+				if err != nil {
+					index = 0
+					p.reporter.Warn(fmt.Sprintf("sheet %s: invalid index %q of %q, the profile is created without index", sheetName, parsed.IndexPath, parsed.Name))
+				}
+				profile = &types.MaterialsProfile{
+					MaintenanceInstanceID: p.maintenance.ID,
+					Sector:                sector,
+					Index:                 index,
+					Estimate: types.MaterialsForEquipment{
+						ReplacementMaterials: make(map[string]types.Material),
+						ConsumableSupplies:   make(map[string]types.Material),
+					},
+					Reality: types.MaterialsForEquipment{
+						ReplacementMaterials: make(map[string]types.Material),
+						ConsumableSupplies:   make(map[string]types.Material),
+					},
+				}
+				result.CreatedProfiles++
+			}
+			planned = &plannedProfile{
+				equipment: equipment,
+				profile:   profile,
+			}
+			p.profiles[key] = planned
+			p.order = append(p.order, planned)
+		}
+
+		if planned.profile.Estimate.ConsumableSupplies == nil {
+			planned.profile.Estimate.ConsumableSupplies = make(map[string]types.Material)
+		}
+		if planned.profile.Estimate.ReplacementMaterials == nil {
+			planned.profile.Estimate.ReplacementMaterials = make(map[string]types.Material)
+		}
+		for name, material := range parsed.Estimate.ConsumableSupplies {
+			planned.profile.Estimate.ConsumableSupplies[name] = material
+		}
+		for name, material := range parsed.Estimate.ReplacementMaterials {
+			planned.profile.Estimate.ReplacementMaterials[name] = material
+		}
+		result.Equipments++
+		result.ConsumableSupplies += len(parsed.Estimate.ConsumableSupplies)
+		result.ReplacementMaterials += len(parsed.Estimate.ReplacementMaterials)
+		p.reporter.Advance(parsed.Rows)
+	}
+	p.results = append(p.results, result)
+	return result, nil
+}
+
+// resolveEquipments resolves the equipments of a sheet not resolved by an
+// earlier sheet to the equipment of the sector whose name or an alias has the
+// same normalized form. Names matching several equipments are recorded as
+// ambiguous rather than resolved to one of them.
+func (p *estimateImportPlanner) resolveEquipments(ctx context.Context, sector string, equipments []*estimateEquipment) error {
+	stored, err := p.loadSectorEquipments(ctx, sector)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*types.EquipmentMachinery, len(stored))
+	for _, eq := range stored {
+		byID[eq.ID] = eq
+	}
+	matches := make(map[string][]types.EquipmentCandidate)
+	for _, eq := range stored {
+		for normalized, matched := range normalizedEquipmentNames(eq) {
+			candidate := types.EquipmentCandidate{
+				ID:      eq.ID,
+				Name:    eq.Name,
+				Matched: matched,
+			}
+			if parent := byID[eq.ParentID]; parent != nil {
+				candidate.ParentName = parent.Name
+			}
+			key := sector + "\x00" + normalized
+			matches[key] = append(matches[key], candidate)
+		}
+	}
+
+	for _, parsed := range equipments {
+		key := equipmentKey(sector, parsed.Name)
+		if _, ok := p.equipments[key]; ok {
+			continue
+		}
+		if _, ok := p.ambiguous[key]; ok {
+			continue
+		}
+		switch candidates := matches[key]; len(candidates) {
+		case 0:
+		case 1:
+			p.equipments[key] = byID[candidates[0].ID]
+		default:
+			p.ambiguous[key] = candidates
+		}
+	}
+	return nil
+}
+
+// loadSectorEquipments returns all the stored equipments of a sector, loaded
+// once per import.
+func (p *estimateImportPlanner) loadSectorEquipments(ctx context.Context, sector string) ([]*types.EquipmentMachinery, error) {
+	if equipments, ok := p.sectorEquipments[sector]; ok {
+		return equipments, nil
+	}
+	equipments, err := p.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{Sector: sector})
+	if err != nil {
+		return nil, err
+	}
+	p.sectorEquipments[sector] = equipments
+	return equipments, nil
+}
+
+// closestEquipment proposes the stored equipment of the sector whose name or
+// an alias is the most similar to name, nil when none is similar enough.
+func (p *estimateImportPlanner) closestEquipment(ctx context.Context, sector, name string) (*types.NameMatch, error) {
+	candidates, err := p.loadSectorEquipments(ctx, sector)
+	if err != nil {
+		return nil, err
+	}
+	matches := rankNameCandidates(name, equipmentCandidates(candidates), equipmentHintMinScore, 1)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	return matches[0], nil
+}
+
+// linkCatalog references the catalog from the materials of a sheet whose
+// name normalizes to the name or an alias of exactly one catalog material,
+// converting their quantity to its default unit. It returns the number of
+// linked materials.
+func (p *estimateImportPlanner) linkCatalog(ctx context.Context, sheetName string, equipments []*estimateEquipment) (int, error) {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, parsed := range equipments {
+		for _, section := range []map[string]types.Material{parsed.Estimate.ReplacementMaterials, parsed.Estimate.ConsumableSupplies} {
+			for name, material := range section {
+				normalized := utils.NormalizeName(name)
+				if material.CatalogID != "" || normalized == "" || seen[normalized] {
+					continue
+				}
+				seen[normalized] = true
+				names = append(names, normalized)
+			}
+		}
+	}
+
+	matches := make(map[string][]string)
+	catalog := make(map[string]*types.CatalogMaterial)
+	for start := 0; start < len(names); start += importLookupBatchSize {
+		end := min(start+importLookupBatchSize, len(names))
+		materials, err := p.catalogRepo.FindByNormalizedNames(ctx, names[start:end])
+		if err != nil {
+			return 0, err
+		}
+		for _, material := range materials {
+			catalog[material.ID] = material
+			for _, normalized := range material.NormalizedNames {
+				if seen[normalized] && !slices.Contains(matches[normalized], material.ID) {
+					matches[normalized] = append(matches[normalized], material.ID)
+				}
+			}
+		}
+	}
+
+	links := 0
+	warned := make(map[string]bool)
+	for _, parsed := range equipments {
+		for _, section := range []map[string]types.Material{parsed.Estimate.ReplacementMaterials, parsed.Estimate.ConsumableSupplies} {
+			for name, material := range section {
+				normalized := utils.NormalizeName(name)
+				if material.CatalogID != "" {
+					continue
+				}
+				switch ids := matches[normalized]; len(ids) {
+				case 0:
+				case 1:
+					material = toCatalogUnit(material, catalog[ids[0]])
+					material.CatalogID = ids[0]
+					section[name] = material
+					links++
+				default:
+					if !warned[normalized] {
+						warned[normalized] = true
+						p.reporter.Warn(fmt.Sprintf("sheet %s: material %q matches %d catalog materials, it is not linked", sheetName, name, len(ids)))
+					}
+				}
+			}
+		}
+	}
+	return links, nil
+}
+
+// resolveProfiles loads the stored profiles of the resolved equipments of a
+// sector that have no planned profile yet, a batch of equipments at a time.
+func (p *estimateImportPlanner) resolveProfiles(ctx context.Context, sector string) error {
+	ids := make([]string, 0)
+	for key, equipment := range p.equipments {
+		if equipment.ID == "" || equipment.Sector != sector {
+			continue
+		}
+		if _, ok := p.profiles[key]; ok {
+			continue
+		}
+		if _, ok := p.existingProfiles[equipment.ID]; ok {
+			continue
+		}
+		ids = append(ids, equipment.ID)
+	}
+
+	for start := 0; start < len(ids); start += importLookupBatchSize {
+		end := min(start+importLookupBatchSize, len(ids))
+		materialsProfiles, err := p.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+			MaintenanceInstanceIDs: []string{p.maintenance.ID},
+			EquipmentMachineryIDs:  ids[start:end],
+			Sector:                 sector,
+		})
+		if err != nil {
+			return err
+		}
+		for _, profile := range materialsProfiles {
+			if _, ok := p.existingProfiles[profile.EquipmentMachineryID]; !ok {
+				p.existingProfiles[profile.EquipmentMachineryID] = profile
+			}
+		}
+	}
+	return nil
+}
+
+// commit writes every planned equipment in one insert and every planned
+// profile in one bulk upsert, in one transaction so that a failed import
+// leaves nothing behind.
+func (p *estimateImportPlanner) commit(ctx context.Context) ([]*types.EstimateImportResult, error) {
+	if err := p.reporter.Committing(); err != nil {
+		return nil, err
+	}
+	newEquipments := make([]*types.EquipmentMachinery, 0)
+	for _, planned := range p.order {
+		if planned.equipment.ID == "" {
+			newEquipments = append(newEquipments, planned.equipment)
+		}
+	}
+	newProfiles := make([]*types.MaterialsProfile, 0)
+	profiles := make([]*types.MaterialsProfile, 0, len(p.order))
+	for _, planned := range p.order {
+		if planned.profile.ID == "" {
+			newProfiles = append(newProfiles, planned.profile)
+		}
+		profiles = append(profiles, planned.profile)
+	}
+
+	err := p.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		if len(newEquipments) > 0 {
+			ids, err := p.equipmentMachineryRepo.SaveMany(ctx, newEquipments)
+			if err != nil {
+				return err
+			}
+			for i, id := range ids {
+				newEquipments[i].ID = id
+			}
+		}
+		for _, planned := range p.order {
+			if planned.profile.ID == "" {
+				planned.profile.EquipmentMachineryID = planned.equipment.ID
+			}
+		}
+		if len(profiles) > 0 {
+			return p.materialsProfileRepo.UpsertEstimates(ctx, profiles)
+		}
+		return nil
+	})
+	if err != nil {
+		// the rolled back equipments are planned as new again
+		for _, equipment := range newEquipments {
+			equipment.ID = ""
+		}
+		for _, profile := range newProfiles {
+			profile.EquipmentMachineryID = ""
+		}
+		return nil, err
+	}
+	return p.results, nil
+}
+
+// sectorFromSheetName infers the sector of a workbook tab from its name, which
+// is either the sector name or its short code as in types.ShortSectorList.
+func sectorFromSheetName(sheetName string) (string, bool) {
+	name := strings.TrimSpace(sheetName)
+	for _, sector := range types.SECTOR_LIST {
+		if strings.EqualFold(name, sector) || strings.EqualFold(name, types.ShortSectorList[sector]) {
+			return sector, true
+		}
+	}
+	lowerName := strings.ToLower(name)
+	for _, sector := range types.SECTOR_LIST {
+		if strings.Contains(lowerName, strings.ToLower(sector)) {
+			return sector, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,722 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const testSector = "Cơ khí"
+
+func newTestMaterialsProfileService(db *memoryDatabase) *materialsProfileService {
+	return &materialsProfileService{
+		materialsProfileRepo:   repository.NewMaterialsProfileRepository(db),
+		materialsRequestRepo:   repository.NewMaterialsRequestRepository(db),
+		maintenanceRepo:        repository.NewMaintenanceRepository(db),
+		equipmentMachineryRepo: repository.NewEquipmentMachineryRepo(db),
+		importProfileRepo:      repository.NewImportProfileRepository(db),
+		estimateUploadRepo:     repository.NewEstimateUploadRepository(db),
+		catalogRepo:            repository.NewCatalogRepository(db),
+		priceRepo:              repository.NewPriceRepository(db),
+		transactor:             repository.NewTransactor(db),
+	}
+}
+
+// writeEstimateSheet writes a sheet in the default layout where every
+// equipment block takes 10 rows: the equipment, 3 replacement materials and 4
+// consumable supplies under their section labels.
+func writeEstimateSheet(tb testing.TB, names []string) string {
+	tb.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	stream, err := f.NewStreamWriter("Sheet1")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	rows := [][]interface{}{{"STT", "Tên thiết bị, vật tư", "ĐVT", "Số lượng"}}
+	for i, name := range names {
+		rows = append(rows,
+			[]interface{}{fmt.Sprintf("%d.%d", i/60+1, i%60+1), name},
+			[]interface{}{"", types.LABEL_REPLACEMENT},
+		)
+		for j := 1; j <= 3; j++ {
+			rows = append(rows, []interface{}{"-", fmt.Sprintf("Phụ tùng %d", j), "Cái", j})
+		}
+		rows = append(rows, []interface{}{"", types.LABEL_CONSUMABLE})
+		for j := 1; j <= 4; j++ {
+			rows = append(rows, []interface{}{"-", fmt.Sprintf("Vật tư %d", j), "Kg", float64(j) / 2})
+		}
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := stream.SetRow(cell, row); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := stream.Flush(); err != nil {
+		tb.Fatal(err)
+	}
+	path := filepath.Join(tb.TempDir(), "estimate.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func saveCatalogMaterial(tb testing.TB, s *materialsProfileService, code, name string, aliases ...string) string {
+	tb.Helper()
+	material := &types.CatalogMaterial{Code: code, Name: name}
+	setCatalogAliases(material, aliases)
+	id, err := s.catalogRepo.Save(context.Background(), material)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return id
+}
+
+func equipmentNames(count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("Thiết bị %d", i+1)
+	}
+	return names
+}
+
+type recordingReporter struct {
+	total     int
+	processed int
+	warnings  []string
+}
+
+func (r *recordingReporter) AddRows(rows int)    { r.total += rows }
+func (r *recordingReporter) Advance(rows int)    { r.processed += rows }
+func (r *recordingReporter) Warn(warning string) { r.warnings = append(r.warnings, warning) }
+func (r *recordingReporter) Committing() error   { return nil }
+
+func TestImportEstimateSheet(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	s := newTestMaterialsProfileService(db)
+	maintenance := &types.Maintenance{ID: "maintenance-1"}
+
+	pumpID, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Bơm nước", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valveID, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "VAN XẢ", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a name containing another one must not be taken for it
+	if _, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Bơm nước biển", Sector: testSector}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Quạt gió số 1", Sector: testSector}); err != nil {
+		t.Fatal(err)
+	}
+	// "Phụ tùng 2" is linked to the catalog, "Vật tư 1" is ambiguous
+	spareID := saveCatalogMaterial(t, s, "PT-02", "Phu tung 2")
+	saveCatalogMaterial(t, s, "VT-01A", "Vật tư 1 loại A", "vat tu 1")
+	saveCatalogMaterial(t, s, "VT-01B", "Vật tư 1 loại B", "VẬT TƯ 1")
+	pumpProfileID, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+		MaintenanceInstanceID: maintenance.ID,
+		EquipmentMachineryID:  pumpID,
+		Sector:                testSector,
+		Index:                 1,
+		Estimate: types.MaterialsForEquipment{
+			ReplacementMaterials: map[string]types.Material{"Gioăng": {Name: "Gioăng", Unit: "cái", Quantity: 4}},
+		},
+		Reality: types.MaterialsForEquipment{
+			ConsumableSupplies: map[string]types.Material{"Vật tư 1": {Name: "Vật tư 1", Unit: "kg", Quantity: 1}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// archived profiles are left alone, the valve gets a new one
+	if _, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+		MaintenanceInstanceID: maintenance.ID,
+		EquipmentMachineryID:  valveID,
+		Sector:                testSector,
+		Archived:              true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	upload := &types.EstimateUpload{
+		FilePath:  writeEstimateSheet(t, []string{"Bơm nước", "Van xả", "Quạt gió"}),
+		SheetName: "Sheet1",
+		Sector:    testSector,
+	}
+	reporter := &recordingReporter{}
+	calls := db.Calls()
+	result, err := s.importEstimateSheet(ctx, maintenance, upload, reporter)
+	if err != nil {
+		t.Fatalf("importEstimateSheet error: %v", err)
+	}
+	// import profiles, sector equipments, profiles, catalog, new equipments,
+	// profile upsert
+	if got := db.Calls() - calls; got != 6 {
+		t.Errorf("importEstimateSheet made %d database calls, want 6", got)
+	}
+
+	want := types.EstimateImportResult{
+		SheetName:            "Sheet1",
+		Sector:               testSector,
+		ImportProfile:        types.DEFAULT_IMPORT_PROFILE.Name,
+		Equipments:           3,
+		ReplacementMaterials: 9,
+		ConsumableSupplies:   12,
+		CreatedEquipments:    1,
+		CreatedProfiles:      2,
+		CatalogLinks:         3,
+	}
+	if !reflect.DeepEqual(*result, want) {
+		t.Errorf("importEstimateSheet result = %+v, want %+v", *result, want)
+	}
+	if reporter.total != 30 || reporter.processed != 30 {
+		t.Errorf("reported %d of %d rows, want 30 of 30", reporter.processed, reporter.total)
+	}
+	if len(reporter.warnings) != 2 ||
+		!strings.Contains(reporter.warnings[0], "Vật tư 1") ||
+		!strings.Contains(reporter.warnings[1], `did you mean "Quạt gió số 1"`) {
+		t.Errorf("warnings = %q, want one about the ambiguous material and one about the created equipment", reporter.warnings)
+	}
+
+	pumpProfile, err := s.materialsProfileRepo.FindByID(ctx, pumpProfileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pumpProfile.Estimate.ReplacementMaterials) != 4 || len(pumpProfile.Estimate.ConsumableSupplies) != 4 {
+		t.Errorf("pump estimate = %+v, want the sheet merged into the stored estimate", pumpProfile.Estimate)
+	}
+	if spare := pumpProfile.Estimate.ReplacementMaterials["Phụ tùng 2"]; spare.Quantity != 2 || spare.Unit != "cái" || spare.CatalogID != spareID {
+		t.Errorf("pump estimate of Phụ tùng 2 = %v, want 2 cái linked to %s", spare, spareID)
+	}
+	if supply := pumpProfile.Estimate.ConsumableSupplies["Vật tư 1"]; supply.CatalogID != "" {
+		t.Errorf("ambiguous Vật tư 1 was linked to %s", supply.CatalogID)
+	}
+	if len(pumpProfile.Reality.ConsumableSupplies) != 1 || pumpProfile.Index != 1 {
+		t.Errorf("pump profile reality and index changed: %+v", pumpProfile)
+	}
+
+	profiles, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{maintenance.ID},
+		IncludeArchived:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 4 {
+		t.Fatalf("found %d profiles, want 4", len(profiles))
+	}
+	for _, profile := range profiles {
+		if profile.Archived && len(profile.Estimate.ReplacementMaterials) > 0 {
+			t.Errorf("archived profile was updated: %+v", profile)
+		}
+		if profile.EquipmentMachineryID == "" {
+			t.Errorf("profile without equipment: %+v", profile)
+		}
+	}
+}
+
+func TestParseEstimateRowsWithoutQuantity(t *testing.T) {
+	profile := types.DEFAULT_IMPORT_PROFILE
+	layout, err := newEstimateSheetLayout(&profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := newEstimateSheetParser(layout)
+	rows := [][]string{
+		{"STT", "Tên thiết bị, vật tư", "ĐVT", "Số lượng"},
+		{"1", "Bơm nước"},
+		{"", types.LABEL_REPLACEMENT},
+		{"-", "Phụ tùng 1", "Cái", ""},
+		{"", types.LABEL_CONSUMABLE},
+		{"-", "Vật tư 1", "Kg"},
+		{"-", "Vật tư 2", "Kg", "1.5"},
+	}
+	for i, row := range rows {
+		if err := parser.parseRow(i+1, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	estimate := parser.equipments[0].Estimate
+	if spare, ok := estimate.ReplacementMaterials["Phụ tùng 1"]; !ok || spare.Quantity != 0 {
+		t.Errorf("Phụ tùng 1 = %+v, %v, want it kept with quantity 0", spare, ok)
+	}
+	if supply, ok := estimate.ConsumableSupplies["Vật tư 1"]; !ok || supply.Quantity != 0 || supply.Unit != "kg" {
+		t.Errorf("Vật tư 1 = %+v, %v, want it kept with quantity 0", supply, ok)
+	}
+	if len(parser.warnings) != 2 || !strings.Contains(parser.warnings[0], "row 4") {
+		t.Errorf("warnings = %q, want one per row without quantity", parser.warnings)
+	}
+}
+
+func TestExportEstimateSheetOfUnknownMaintenance(t *testing.T) {
+	s := newTestMaterialsProfileService(newMemoryDatabase())
+	_, err := s.ExportEstimateSheet(context.Background(), &types.ExportEstimateSheetReq{
+		MaintenanceInstanceID: bson.NewObjectID().Hex(),
+		Sector:                testSector,
+	})
+	if !errors.Is(err, types.ErrMaintenanceNotFound) {
+		t.Errorf("export the estimate of an unknown maintenance: got %v, want ErrMaintenanceNotFound", err)
+	}
+}
+
+func TestExportEstimateSheetLeavesUnestimatedRemainingEmpty(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	s := newTestMaterialsProfileService(db)
+	maintenanceID, err := s.maintenanceRepo.Save(ctx, &types.Maintenance{ProjectCode: "T01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equipmentID, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Bơm nước", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+		MaintenanceInstanceID: maintenanceID,
+		EquipmentMachineryID:  equipmentID,
+		Index:                 1,
+		Sector:                testSector,
+		Estimate: types.MaterialsForEquipment{
+			ReplacementMaterials: map[string]types.Material{"Bu lông": {Name: "Bu lông", Unit: "cái", Quantity: 10}},
+		},
+		Reality: types.MaterialsForEquipment{
+			ReplacementMaterials: map[string]types.Material{
+				"Bu lông": {Name: "Bu lông", Unit: "cái", Quantity: 4},
+				"Đai ốc":  {Name: "Đai ốc", Unit: "cái", Quantity: 3},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := s.ExportEstimateSheet(ctx, &types.ExportEstimateSheetReq{MaintenanceInstanceID: maintenanceID, Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	f, err := excelize.OpenFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(testSector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remaining := make(map[string]string)
+	for _, row := range rows {
+		if len(row) > 1 && row[0] == "-" {
+			remaining[row[1]] = ""
+			if len(row) > 5 {
+				remaining[row[1]] = row[5]
+			}
+		}
+	}
+	if want := map[string]string{"Bu lông": "6", "Đai ốc": ""}; !reflect.DeepEqual(remaining, want) {
+		t.Errorf("remaining cells = %q, want %q", remaining, want)
+	}
+}
+
+func TestImportEstimateSheetIsAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	s := newTestMaterialsProfileService(db)
+	maintenance := &types.Maintenance{ID: "maintenance-1"}
+	if _, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Bơm nước", Sector: testSector}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the new equipments are saved before the profiles fail
+	failure := errors.New("write failed")
+	db.failWrites("materials_profiles", failure)
+	upload := &types.EstimateUpload{
+		FilePath:  writeEstimateSheet(t, []string{"Bơm nước", "Van xả", "Quạt gió"}),
+		SheetName: "Sheet1",
+		Sector:    testSector,
+	}
+	if _, err := s.importEstimateSheet(ctx, maintenance, upload, nil); !errors.Is(err, failure) {
+		t.Fatalf("importEstimateSheet error = %v, want the write failure", err)
+	}
+	equipments, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(equipments) != 1 {
+		t.Errorf("found %d equipments after a failed import, want only the stored one", len(equipments))
+	}
+	profiles, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{MaintenanceInstanceIDs: []string{maintenance.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 0 {
+		t.Errorf("found %d profiles after a failed import, want none", len(profiles))
+	}
+
+	// the same import goes through once writes succeed
+	db.failWrites("materials_profiles", nil)
+	result, err := s.importEstimateSheet(ctx, maintenance, upload, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.CreatedEquipments != 2 || result.CreatedProfiles != 3 {
+		t.Errorf("result = %+v, want 2 equipments and 3 profiles created", result)
+	}
+}
+
+func TestImportEstimateSheetResolvesEquipmentsExactly(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	s := newTestMaterialsProfileService(db)
+	maintenance := &types.Maintenance{ID: "maintenance-1"}
+
+	save := func(equipment *types.EquipmentMachinery) string {
+		t.Helper()
+		equipment.Sector = testSector
+		id, err := s.equipmentMachineryRepo.Save(ctx, equipment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	save(&types.EquipmentMachinery{Name: "Bơm nước biển làm mát"})
+	save(&types.EquipmentMachinery{Name: "Máy phát điện số 1", Aliases: []string{"MF1"}})
+	firstSystemID := save(&types.EquipmentMachinery{Name: "Hệ thống nhiên liệu"})
+	secondSystemID := save(&types.EquipmentMachinery{Name: "Hệ thống dầu bôi trơn"})
+	save(&types.EquipmentMachinery{Name: "Van an toàn", ParentID: firstSystemID})
+	save(&types.EquipmentMachinery{Name: "Van an toàn", ParentID: secondSystemID})
+
+	upload := &types.EstimateUpload{
+		FilePath:  writeEstimateSheet(t, []string{"Bơm", "mf1 ", "VAN AN TOÀN"}),
+		SheetName: "Sheet1",
+		Sector:    testSector,
+	}
+	reporter := &recordingReporter{}
+	result, err := s.importEstimateSheet(ctx, maintenance, upload, reporter)
+	if err != nil {
+		t.Fatalf("importEstimateSheet error: %v", err)
+	}
+	if result.Equipments != 2 || result.CreatedEquipments != 1 || result.CreatedProfiles != 2 {
+		t.Errorf("result = %+v, want Bơm created and MF1 resolved by its alias", result)
+	}
+	if reporter.processed != 30 {
+		t.Errorf("processed %d rows, want 30", reporter.processed)
+	}
+	if len(result.AmbiguousEquipments) != 1 {
+		t.Fatalf("ambiguous equipments = %+v, want VAN AN TOÀN", result.AmbiguousEquipments)
+	}
+	ambiguous := result.AmbiguousEquipments[0]
+	if ambiguous.Name != "VAN AN TOÀN" || len(ambiguous.Candidates) != 2 {
+		t.Errorf("ambiguous equipment = %+v, want the two safety valves", ambiguous)
+	}
+	for _, candidate := range ambiguous.Candidates {
+		if candidate.ParentName == "" || candidate.Matched != "Van an toàn" {
+			t.Errorf("candidate = %+v, want its parent and matched name", candidate)
+		}
+	}
+	if !slices.ContainsFunc(reporter.warnings, func(warning string) bool {
+		return strings.Contains(warning, "VAN AN TOÀN") && strings.Contains(warning, "not imported")
+	}) {
+		t.Errorf("warnings = %q, want one about the ambiguous valve", reporter.warnings)
+	}
+
+	profiles, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{MaintenanceInstanceIDs: []string{maintenance.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equipments, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]string, len(equipments))
+	for _, equipment := range equipments {
+		names[equipment.ID] = equipment.Name
+	}
+	got := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		got = append(got, names[profile.EquipmentMachineryID])
+	}
+	slices.Sort(got)
+	if want := []string{"Bơm", "Máy phát điện số 1"}; !slices.Equal(got, want) {
+		t.Errorf("profiles of %q, want %q", got, want)
+	}
+	if len(equipments) != 7 {
+		t.Errorf("found %d equipments, want only Bơm created", len(equipments))
+	}
+}
+
+func TestImportEstimateSheetQueriesDoNotGrowWithRows(t *testing.T) {
+	ctx := context.Background()
+	callsFor := func(equipments int) int {
+		db := newMemoryDatabase()
+		s := newTestMaterialsProfileService(db)
+		upload := &types.EstimateUpload{
+			FilePath:  writeEstimateSheet(t, equipmentNames(equipments)),
+			SheetName: "Sheet1",
+			Sector:    testSector,
+		}
+		if _, err := s.importEstimateSheet(ctx, &types.Maintenance{ID: "maintenance-1"}, upload, nil); err != nil {
+			t.Fatalf("importEstimateSheet error: %v", err)
+		}
+		return db.Calls()
+	}
+	small, large := callsFor(10), callsFor(400)
+	if small != large {
+		t.Errorf("database calls grew from %d to %d with the sheet size", small, large)
+	}
+}
+
+// importEstimateSheetPerBlock is the importer the batched one replaced, kept
+// as the baseline of the import benchmarks: every equipment block looks its
+// equipment and profile up and saves them one at a time.
+func importEstimateSheetPerBlock(ctx context.Context, s *materialsProfileService, maintenance *types.Maintenance, upload *types.EstimateUpload) error {
+	f, err := excelize.OpenFile(upload.FilePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rows, err := f.GetRows(upload.SheetName)
+	if err != nil {
+		return err
+	}
+	layout, err := newEstimateSheetLayout(&types.DEFAULT_IMPORT_PROFILE)
+	if err != nil {
+		return err
+	}
+	parser := newEstimateSheetParser(layout)
+	for i, row := range rows {
+		if err := parser.parseRow(i+1, row); err != nil {
+			return err
+		}
+	}
+
+	for _, parsed := range parser.equipments {
+		equipments, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{
+			Name:   parsed.Name,
+			Sector: upload.Sector,
+		})
+		if err != nil {
+			return err
+		}
+		var profiles []*types.MaterialsProfile
+		equipment := &types.EquipmentMachinery{Name: parsed.Name, Sector: upload.Sector}
+		if len(equipments) > 0 {
+			equipment = equipments[0]
+			profiles, err = s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+				MaintenanceInstanceIDs: []string{maintenance.ID},
+				EquipmentMachineryIDs:  []string{equipment.ID},
+				Sector:                 upload.Sector,
+			})
+			if err != nil {
+				return err
+			}
+		} else if equipment.ID, err = s.equipmentMachineryRepo.Save(ctx, equipment); err != nil {
+			return err
+		}
+		if len(profiles) > 0 {
+			if err := s.materialsProfileRepo.UpdateEstimateMaterials(ctx, profiles[0].ID, parsed.Estimate); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+			MaintenanceInstanceID: maintenance.ID,
+			EquipmentMachineryID:  equipment.ID,
+			Sector:                upload.Sector,
+			Estimate:              parsed.Estimate,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestImportEstimateSheetQueriesLessThanPerBlockBaseline(t *testing.T) {
+	ctx := context.Background()
+	upload := &types.EstimateUpload{
+		FilePath:  writeEstimateSheet(t, equipmentNames(100)),
+		SheetName: "Sheet1",
+		Sector:    testSector,
+	}
+	maintenance := &types.Maintenance{ID: "maintenance-1"}
+
+	baselineDB := newMemoryDatabase()
+	if err := importEstimateSheetPerBlock(ctx, newTestMaterialsProfileService(baselineDB), maintenance, upload); err != nil {
+		t.Fatalf("importEstimateSheetPerBlock error: %v", err)
+	}
+	db := newMemoryDatabase()
+	if _, err := newTestMaterialsProfileService(db).importEstimateSheet(ctx, maintenance, upload, nil); err != nil {
+		t.Fatalf("importEstimateSheet error: %v", err)
+	}
+	// both write the same equipments and profiles
+	for _, collection := range []string{"equipment_machineries", "materials_profiles"} {
+		if got, want := len(db.collections[collection]), len(baselineDB.collections[collection]); got != want {
+			t.Errorf("%s: %d documents, want %d as the baseline", collection, got, want)
+		}
+	}
+	if baseline := baselineDB.Calls(); baseline != 300 || db.Calls() >= baseline/10 {
+		t.Errorf("%d database calls against %d for the baseline, want less than a tenth", db.Calls(), baseline)
+	}
+}
+
+// benchmarkImportEstimateSheet imports 500 new equipments, 5,000 rows, with a
+// simulated round trip of 200µs per database call.
+func benchmarkImportEstimateSheet(b *testing.B, importSheet func(ctx context.Context, s *materialsProfileService, maintenance *types.Maintenance, upload *types.EstimateUpload) error) {
+	ctx := context.Background()
+	path := writeEstimateSheet(b, equipmentNames(500))
+	upload := &types.EstimateUpload{
+		FilePath:  path,
+		SheetName: "Sheet1",
+		Sector:    testSector,
+	}
+	calls := 0
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db := newMemoryDatabase()
+		db.latency = 200 * time.Microsecond
+		s := newTestMaterialsProfileService(db)
+		b.StartTimer()
+
+		if err := importSheet(ctx, s, &types.Maintenance{ID: "maintenance-1"}, upload); err != nil {
+			b.Fatal(err)
+		}
+		calls += db.Calls()
+	}
+	b.ReportMetric(float64(calls)/float64(b.N), "db-calls/op")
+}
+
+// BenchmarkImportEstimateSheet5000Rows measures the batched importer, to be
+// compared with BenchmarkImportEstimateSheet5000RowsPerBlock.
+func BenchmarkImportEstimateSheet5000Rows(b *testing.B) {
+	benchmarkImportEstimateSheet(b, func(ctx context.Context, s *materialsProfileService, maintenance *types.Maintenance, upload *types.EstimateUpload) error {
+		_, err := s.importEstimateSheet(ctx, maintenance, upload, nil)
+		return err
+	})
+}
+
+// BenchmarkImportEstimateSheet5000RowsPerBlock measures the per-block baseline.
+func BenchmarkImportEstimateSheet5000RowsPerBlock(b *testing.B) {
+	benchmarkImportEstimateSheet(b, importEstimateSheetPerBlock)
+}
+
+// estimateFileHeader reads a workbook back as the file of a multipart upload.
+func estimateFileHeader(tb testing.TB, path string) *multipart.FileHeader {
+	tb.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("sheet", filepath.Base(path))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := part.Write(data); err != nil {
+		tb.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	form, err := multipart.NewReader(body, writer.Boundary()).ReadForm(int64(len(data)) + 1024)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return form.File["sheet"][0]
+}
+
+func TestUploadEstimateSheetDetectsDuplicates(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	s := newTestMaterialsProfileService(db)
+	s.uploadService = NewUploadService(t.TempDir())
+	maintenanceID, err := s.maintenanceRepo.Save(ctx, &types.Maintenance{ProjectCode: "T01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same blocks on a second sheet of the workbook
+	path := writeEstimateSheet(t, equipmentNames(2))
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.NewSheet("Sheet2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetSheetRow("Sheet2", "A1", &[]interface{}{"STT", "Tên thiết bị, vật tư", "ĐVT", "Số lượng"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetSheetRow("Sheet2", "A2", &[]interface{}{"1", "Thiết bị 3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	sheet := estimateFileHeader(t, path)
+
+	upload := func(sheetName, sector string, force bool) *types.EstimateImportResult {
+		t.Helper()
+		result, err := s.UploadEstimateSheet(ctx, &types.UploadEstimateSheetRequest{
+			MaintenanceInstanceID: maintenanceID,
+			Sheet:                 sheet,
+			SheetName:             sheetName,
+			Sector:                sector,
+			Force:                 force,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	first := upload("Sheet1", testSector, false)
+	if first.Duplicate || first.CreatedProfiles != 2 {
+		t.Fatalf("first upload = %+v, want 2 profiles created", first)
+	}
+
+	again := upload("Sheet1", testSector, false)
+	if !again.Duplicate || again.UploadID != first.UploadID || again.CreatedProfiles != first.CreatedProfiles {
+		t.Errorf("same upload again = %+v, want the result of upload %s", again, first.UploadID)
+	}
+	if uploads, err := s.estimateUploadRepo.FindByMaintenance(ctx, maintenanceID); err != nil || len(uploads) != 1 {
+		t.Errorf("uploads after a duplicate = %d (%v), want 1", len(uploads), err)
+	}
+
+	if result := upload("Sheet2", testSector, false); result.Duplicate || result.Equipments != 1 {
+		t.Errorf("other sheet of the file = %+v, want it imported", result)
+	}
+	if result := upload("Sheet1", types.SECTOR_HULL, false); result.Duplicate || result.CreatedProfiles != 2 {
+		t.Errorf("same sheet in another sector = %+v, want it imported", result)
+	}
+
+	forced := upload("Sheet1", testSector, true)
+	if forced.Duplicate || forced.UploadID == first.UploadID {
+		t.Errorf("forced upload = %+v, want a new import", forced)
+	}
+	recorded, err := s.estimateUploadRepo.FindByID(ctx, forced.UploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.DuplicateOf != first.UploadID {
+		t.Errorf("forced upload is a duplicate of %q, want %q", recorded.DuplicateOf, first.UploadID)
+	}
+}
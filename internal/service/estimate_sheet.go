@@ -0,0 +1,226 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+var estimateIndexRegex = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// estimateSheetLayout is an import profile resolved to zero-based column
+// positions and compiled section labels.
+type estimateSheetLayout struct {
+	headerRow         int
+	indexColumn       int
+	titleColumn       int
+	unitColumn        int
+	quantityColumn    int
+	replacementLabels []*regexp.Regexp
+	consumableLabels  []*regexp.Regexp
+	materialMarker    string
+}
+
+// estimateEquipment is one equipment block parsed from an estimate sheet.
+type estimateEquipment struct {
+	IndexPath string
+	Name      string
+	Estimate  types.MaterialsForEquipment
+	// Rows is the number of sheet rows of the block, used to report progress
+	Rows int
+}
+
+func newEstimateSheetLayout(profile *types.ImportProfile) (*estimateSheetLayout, error) {
+	if profile.HeaderRow < 0 {
+		return nil, fmt.Errorf("%w: header row must not be negative", types.ErrInvalidImportProfile)
+	}
+	layout := &estimateSheetLayout{
+		headerRow:      profile.HeaderRow,
+		materialMarker: strings.TrimSpace(profile.MaterialMarker),
+	}
+	if layout.materialMarker == "" {
+		return nil, fmt.Errorf("%w: material marker is required", types.ErrInvalidImportProfile)
+	}
+
+	columns := []struct {
+		name   string
+		target *int
+	}{
+		{profile.IndexColumn, &layout.indexColumn},
+		{profile.TitleColumn, &layout.titleColumn},
+		{profile.UnitColumn, &layout.unitColumn},
+		{profile.QuantityColumn, &layout.quantityColumn},
+	}
+	for _, column := range columns {
+		number, err := excelize.ColumnNameToNumber(strings.TrimSpace(column.name))
+		if err != nil {
+			return nil, fmt.Errorf("%w: column %q", types.ErrInvalidImportProfile, column.name)
+		}
+		*column.target = number - 1
+	}
+
+	var err error
+	layout.replacementLabels, err = compileSectionLabels(profile.ReplacementLabels)
+	if err != nil {
+		return nil, err
+	}
+	layout.consumableLabels, err = compileSectionLabels(profile.ConsumableLabels)
+	if err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+func compileSectionLabels(labels []string) ([]*regexp.Regexp, error) {
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("%w: section labels are required", types.ErrInvalidImportProfile)
+	}
+	compiled := make([]*regexp.Regexp, 0, len(labels))
+	for _, label := range labels {
+		re, err := regexp.Compile("(?i)" + strings.TrimSpace(label))
+		if err != nil {
+			return nil, fmt.Errorf("%w: label %q: %v", types.ErrInvalidImportProfile, label, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func matchAnyLabel(labels []*regexp.Regexp, text string) bool {
+	for _, label := range labels {
+		if label.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectImportProfile returns the profile whose header keywords are all found
+// in its header row, preferring the most specific one. The default layout is
+// returned when nothing matches.
+func detectImportProfile(rows [][]string, profiles []*types.ImportProfile) *types.ImportProfile {
+	defaultProfile := types.DEFAULT_IMPORT_PROFILE
+	best := &defaultProfile
+	bestScore := 0
+	for _, profile := range profiles {
+		if len(profile.HeaderKeywords) == 0 || profile.HeaderRow < 1 || profile.HeaderRow > len(rows) {
+			continue
+		}
+		header := strings.ToLower(strings.Join(rows[profile.HeaderRow-1], " "))
+		matched := 0
+		for _, keyword := range profile.HeaderKeywords {
+			if strings.Contains(header, strings.ToLower(strings.TrimSpace(keyword))) {
+				matched++
+			}
+		}
+		if matched == len(profile.HeaderKeywords) && matched > bestScore {
+			best = profile
+			bestScore = matched
+		}
+	}
+	return best
+}
+
+func cellAt(row []string, column int) string {
+	if column < 0 || column >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[column])
+}
+
+// estimateSheetParser walks the data rows of an estimate sheet one at a time
+// and groups the material lines under the equipment row they belong to.
+// Equipment appearing several times is merged into the first occurrence.
+type estimateSheetParser struct {
+	layout              *estimateSheetLayout
+	equipments          []*estimateEquipment
+	equipmentsByName    map[string]*estimateEquipment
+	currentEquipment    *estimateEquipment
+	currentMaterialType string
+	// warnings are about rows imported with an assumption
+	warnings []string
+}
+
+func newEstimateSheetParser(layout *estimateSheetLayout) *estimateSheetParser {
+	return &estimateSheetParser{
+		layout:           layout,
+		equipments:       make([]*estimateEquipment, 0),
+		equipmentsByName: make(map[string]*estimateEquipment),
+	}
+}
+
+// parseRow reads the row at the 1-based rowNumber, rows up to the header row
+// are ignored.
+func (p *estimateSheetParser) parseRow(rowNumber int, row []string) error {
+	if rowNumber <= p.layout.headerRow {
+		return nil
+	}
+	layout := p.layout
+	indexCell := cellAt(row, layout.indexColumn)
+	titleCell := cellAt(row, layout.titleColumn)
+
+	if estimateIndexRegex.MatchString(indexCell) {
+		equipment, ok := p.equipmentsByName[titleCell]
+		if !ok {
+			equipment = &estimateEquipment{
+				IndexPath: indexCell,
+				Name:      titleCell,
+				Estimate: types.MaterialsForEquipment{
+					ReplacementMaterials: make(map[string]types.Material),
+					ConsumableSupplies:   make(map[string]types.Material),
+				},
+			}
+			p.equipmentsByName[titleCell] = equipment
+			p.equipments = append(p.equipments, equipment)
+		}
+		p.currentEquipment = equipment
+		p.currentMaterialType = ""
+	}
+	if p.currentEquipment != nil {
+		p.currentEquipment.Rows++
+	}
+	if matchAnyLabel(layout.replacementLabels, titleCell) {
+		p.currentMaterialType = types.LABEL_REPLACEMENT
+	}
+	if matchAnyLabel(layout.consumableLabels, titleCell) {
+		p.currentMaterialType = types.LABEL_CONSUMABLE
+	}
+	if p.currentEquipment == nil || p.currentMaterialType == "" || indexCell != layout.materialMarker {
+		return nil
+	}
+
+	unitCell := cellAt(row, layout.unitColumn)
+	quantityCell := cellAt(row, layout.quantityColumn)
+	if len(row) <= layout.unitColumn {
+		return nil
+	}
+	materialQuantity := 0.0
+	if quantityCell == "" {
+		// lines only consumed in an exported estimate/reality sheet have no
+		// estimate, they are kept so that the material stays listed
+		p.warnings = append(p.warnings, fmt.Sprintf("row %d: %q has no quantity, it is imported with quantity 0", rowNumber, titleCell))
+	} else {
+		var err error
+		materialQuantity, err = strconv.ParseFloat(quantityCell, 64)
+		if err != nil {
+			return fmt.Errorf("row %d: invalid quantity %q: %w", rowNumber, quantityCell, err)
+		}
+	}
+	material := types.Material{
+		Name:     titleCell,
+		Unit:     utils.NormalizeUnit(unitCell),
+		Quantity: materialQuantity,
+	}
+	switch p.currentMaterialType {
+	case types.LABEL_CONSUMABLE:
+		p.currentEquipment.Estimate.ConsumableSupplies[titleCell] = material
+	case types.LABEL_REPLACEMENT:
+		p.currentEquipment.Estimate.ReplacementMaterials[titleCell] = material
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	importJobKeyPrefix = "import_job:"
+	// the control key of a job is set once, either by a cancellation or by
+	// the job when it starts writing, so that a job is never cancelled while
+	// writing
+	importJobControlKeyPrefix = "import_job_control:"
+	importJobCancelled        = "cancelled"
+	importJobCommitting       = "committing"
+	// the heartbeat key of a job expires when the instance running it stops
+	importJobHeartbeatKeyPrefix = "import_job_heartbeat:"
+	importJobHeartbeatInterval  = 10 * time.Second
+	importJobHeartbeatTTL       = 3 * importJobHeartbeatInterval
+	// importJobTTL is how long the state of a job is kept after its last update
+	importJobTTL = 24 * time.Hour
+	// importJobFlushInterval throttles the progress written to redis, the
+	// cancellation flag is checked at the same pace
+	importJobFlushInterval = time.Second
+)
+
+var _ ImportJobService = &importJobService{}
+
+// ImportJobFunc runs an import, reporting its progress. The returned value is
+// the result of the job when no error is returned.
+type ImportJobFunc func(ctx context.Context, reporter ImportReporter) (any, error)
+
+type ImportJobService interface {
+	// register a job and run it in the background, the job is returned right away
+	Start(ctx context.Context, job *types.ImportJob, run ImportJobFunc) (*types.ImportJob, error)
+	// get the state of a job, a running job whose instance stopped is
+	// reported as failed
+	GetJob(ctx context.Context, id string) (*types.ImportJob, error)
+	// request the cancellation of a running job, it stops before writing
+	// anything and cannot be cancelled once it started writing
+	CancelJob(ctx context.Context, id string) error
+}
+
+// importJobService keeps the state of jobs in redis so that any instance can
+// report or cancel a job running on another one.
+type importJobService struct {
+	redisClient redis.Cmdable
+	logger      *logger.Logger
+	// cancels holds the cancel functions of the jobs running on this instance
+	cancels sync.Map
+}
+
+func NewImportJobService(redisClient redis.Cmdable, logger *logger.Logger) ImportJobService {
+	return &importJobService{
+		redisClient: redisClient,
+		logger:      logger,
+	}
+}
+
+func (s *importJobService) Start(ctx context.Context, job *types.ImportJob, run ImportJobFunc) (*types.ImportJob, error) {
+	now := time.Now().Unix()
+	job.ID = bson.NewObjectID().Hex()
+	job.Status = types.IMPORT_JOB_STATUS_RUNNING
+	job.Warnings = make([]string, 0)
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	if err := s.save(ctx, job); err != nil {
+		return nil, err
+	}
+	if err := s.beat(ctx, job.ID); err != nil {
+		return nil, err
+	}
+
+	// the job outlives the request, it must not inherit its context
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancels.Store(job.ID, cancel)
+	progress := &importJobProgress{
+		service: s,
+		ctx:     runCtx,
+		cancel:  cancel,
+		job:     *job,
+	}
+	go s.run(progress, run)
+
+	return job, nil
+}
+
+func (s *importJobService) run(progress *importJobProgress, run ImportJobFunc) {
+	defer progress.cancel()
+	defer s.cancels.Delete(progress.job.ID)
+	stopHeartbeat := make(chan struct{})
+	defer close(stopHeartbeat)
+	go s.heartbeat(progress.job.ID, stopHeartbeat)
+
+	result, err := func() (result any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("import panicked: %v", r)
+			}
+		}()
+		return run(progress.ctx, progress)
+	}()
+
+	job := &progress.job
+	switch {
+	case errors.Is(err, types.ErrImportCancelled):
+		job.Status = types.IMPORT_JOB_STATUS_CANCELLED
+	case err != nil:
+		job.Status = types.IMPORT_JOB_STATUS_FAILED
+		job.Error = err.Error()
+	default:
+		job.Status = types.IMPORT_JOB_STATUS_SUCCEEDED
+		job.ProcessedRows = job.TotalRows
+		job.Result = result
+	}
+	job.FinishedAt = time.Now().Unix()
+
+	ctx := context.Background()
+	if err := s.save(ctx, job); err != nil {
+		s.logger.Error("ImportJob: Failed to save the final state of job ", job.ID, " error ", err)
+	}
+	if err := s.redisClient.Del(ctx, importJobControlKeyPrefix+job.ID, importJobHeartbeatKeyPrefix+job.ID).Err(); err != nil {
+		s.logger.Error("ImportJob: Failed to clear the control keys of job ", job.ID, " error ", err)
+	}
+}
+
+// heartbeat keeps the heartbeat key of a running job alive until stopped.
+func (s *importJobService) heartbeat(id string, stop <-chan struct{}) {
+	ticker := time.NewTicker(importJobHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.beat(context.Background(), id); err != nil {
+				s.logger.Error("ImportJob: Failed to refresh the heartbeat of job ", id, " error ", err)
+			}
+		}
+	}
+}
+
+func (s *importJobService) beat(ctx context.Context, id string) error {
+	return s.redisClient.Set(ctx, importJobHeartbeatKeyPrefix+id, 1, importJobHeartbeatTTL).Err()
+}
+
+func (s *importJobService) GetJob(ctx context.Context, id string) (*types.ImportJob, error) {
+	data, err := s.redisClient.Get(ctx, importJobKeyPrefix+id).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, types.ErrImportJobNotFound
+		}
+		return nil, err
+	}
+	job := &types.ImportJob{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+	if job.Status != types.IMPORT_JOB_STATUS_RUNNING {
+		return job, nil
+	}
+	alive, err := s.redisClient.Exists(ctx, importJobHeartbeatKeyPrefix+id).Result()
+	if err != nil {
+		return nil, err
+	}
+	if alive == 0 {
+		// the instance running the job stopped without finishing it
+		job.Status = types.IMPORT_JOB_STATUS_FAILED
+		job.Error = types.ErrImportJobLost.Error()
+		job.FinishedAt = time.Now().Unix()
+		if err := s.save(ctx, job); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+func (s *importJobService) CancelJob(ctx context.Context, id string) error {
+	job, err := s.GetJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status != types.IMPORT_JOB_STATUS_RUNNING {
+		return types.ErrImportJobFinished
+	}
+	set, err := s.redisClient.SetNX(ctx, importJobControlKeyPrefix+id, importJobCancelled, importJobTTL).Result()
+	if err != nil {
+		return err
+	}
+	if !set {
+		control, err := s.redisClient.Get(ctx, importJobControlKeyPrefix+id).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if control == importJobCommitting {
+			return types.ErrImportJobCommitting
+		}
+	}
+	if cancel, ok := s.cancels.Load(id); ok {
+		cancel.(context.CancelFunc)()
+	}
+	return nil
+}
+
+func (s *importJobService) save(ctx context.Context, job *types.ImportJob) error {
+	job.UpdatedAt = time.Now().Unix()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.Set(ctx, importJobKeyPrefix+job.ID, data, importJobTTL).Err()
+}
+
+// importJobProgress reports the progress of a running job. It is only used by
+// the goroutine running the job.
+type importJobProgress struct {
+	service   *importJobService
+	ctx       context.Context
+	cancel    context.CancelFunc
+	job       types.ImportJob
+	lastFlush time.Time
+}
+
+func (p *importJobProgress) AddRows(rows int) {
+	p.job.TotalRows += rows
+	p.flush()
+}
+
+func (p *importJobProgress) Advance(rows int) {
+	p.job.ProcessedRows += rows
+	p.flush()
+}
+
+func (p *importJobProgress) Warn(warning string) {
+	p.job.Warnings = append(p.job.Warnings, warning)
+	p.flush()
+}
+
+// Committing claims the control key of the job before it starts writing,
+// failing when a cancellation was requested first.
+func (p *importJobProgress) Committing() error {
+	ctx := context.Background()
+	set, err := p.service.redisClient.SetNX(ctx, importJobControlKeyPrefix+p.job.ID, importJobCommitting, importJobTTL).Result()
+	if err != nil {
+		return err
+	}
+	if !set {
+		return types.ErrImportCancelled
+	}
+	p.job.Committing = true
+	p.lastFlush = time.Now()
+	if err := p.service.save(ctx, &p.job); err != nil {
+		p.service.logger.Error("ImportJob: Failed to save the progress of job ", p.job.ID, " error ", err)
+	}
+	return nil
+}
+
+// flush writes the progress to redis at most once per flush interval and
+// cancels the job when another instance requested it.
+func (p *importJobProgress) flush() {
+	if time.Since(p.lastFlush) < importJobFlushInterval {
+		return
+	}
+	p.lastFlush = time.Now()
+
+	ctx := context.Background()
+	if err := p.service.save(ctx, &p.job); err != nil {
+		p.service.logger.Error("ImportJob: Failed to save the progress of job ", p.job.ID, " error ", err)
+	}
+	control, err := p.service.redisClient.Get(ctx, importJobControlKeyPrefix+p.job.ID).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		p.service.logger.Error("ImportJob: Failed to check the cancellation of job ", p.job.ID, " error ", err)
+		return
+	}
+	if control == importJobCancelled {
+		p.cancel()
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/remiehneppo/material-management/internal/logger"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestImportJobService(redis *memoryRedis) *importJobService {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return NewImportJobService(redis, &logger.Logger{Logger: log}).(*importJobService)
+}
+
+// waitForJob polls a job until it finishes.
+func waitForJob(t *testing.T, s ImportJobService, id string) *types.ImportJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := s.GetJob(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != types.IMPORT_JOB_STATUS_RUNNING {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestImportJobSucceeds(t *testing.T) {
+	ctx := context.Background()
+	redis := newMemoryRedis()
+	s := newTestImportJobService(redis)
+
+	job, err := s.Start(ctx, &types.ImportJob{Kind: "sheet"}, func(ctx context.Context, reporter ImportReporter) (any, error) {
+		reporter.AddRows(3)
+		reporter.Warn("row 2: no quantity")
+		reporter.Advance(2)
+		if err := reporter.Committing(); err != nil {
+			return nil, err
+		}
+		return "imported", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != types.IMPORT_JOB_STATUS_RUNNING {
+		t.Errorf("started job status = %s, want running", job.Status)
+	}
+	job = waitForJob(t, s, job.ID)
+	if job.Status != types.IMPORT_JOB_STATUS_SUCCEEDED || job.Result != "imported" || job.ProcessedRows != 3 || len(job.Warnings) != 1 {
+		t.Errorf("job = %+v, want it succeeded with its result, rows and warning", job)
+	}
+	if err := s.CancelJob(ctx, job.ID); !errors.Is(err, types.ErrImportJobFinished) {
+		t.Errorf("cancel a finished job: got %v, want ErrImportJobFinished", err)
+	}
+	if err := s.CancelJob(ctx, "unknown"); !errors.Is(err, types.ErrImportJobNotFound) {
+		t.Errorf("cancel an unknown job: got %v, want ErrImportJobNotFound", err)
+	}
+	if n := redis.Exists(ctx, importJobControlKeyPrefix+job.ID, importJobHeartbeatKeyPrefix+job.ID).Val(); n != 0 {
+		t.Errorf("%d control keys left after the job finished", n)
+	}
+}
+
+func TestImportJobCancellation(t *testing.T) {
+	ctx := context.Background()
+	s := newTestImportJobService(newMemoryRedis())
+
+	// a job cancelled before writing does not write
+	proceed := make(chan struct{})
+	committed := false
+	job, err := s.Start(ctx, &types.ImportJob{}, func(ctx context.Context, reporter ImportReporter) (any, error) {
+		<-proceed
+		if err := reporter.Committing(); err != nil {
+			return nil, err
+		}
+		committed = true
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelJob(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	close(proceed)
+	job = waitForJob(t, s, job.ID)
+	if job.Status != types.IMPORT_JOB_STATUS_CANCELLED || committed {
+		t.Errorf("job = %+v, committed %v, want it cancelled before writing", job, committed)
+	}
+
+	// a job that started writing cannot be cancelled and completes
+	writing := make(chan struct{})
+	release := make(chan struct{})
+	job, err = s.Start(ctx, &types.ImportJob{}, func(ctx context.Context, reporter ImportReporter) (any, error) {
+		if err := reporter.Committing(); err != nil {
+			return nil, err
+		}
+		close(writing)
+		<-release
+		return "imported", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-writing
+	if err := s.CancelJob(ctx, job.ID); !errors.Is(err, types.ErrImportJobCommitting) {
+		t.Errorf("cancel a job while writing: got %v, want ErrImportJobCommitting", err)
+	}
+	close(release)
+	if job = waitForJob(t, s, job.ID); job.Status != types.IMPORT_JOB_STATUS_SUCCEEDED {
+		t.Errorf("job cancelled while writing = %+v, want it succeeded", job)
+	}
+}
+
+func TestImportJobOfStoppedInstanceFails(t *testing.T) {
+	ctx := context.Background()
+	redis := newMemoryRedis()
+	s := newTestImportJobService(redis)
+
+	release := make(chan struct{})
+	defer close(release)
+	job, err := s.Start(ctx, &types.ImportJob{}, func(ctx context.Context, reporter ImportReporter) (any, error) {
+		<-release
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, err = s.GetJob(ctx, job.ID); err != nil || job.Status != types.IMPORT_JOB_STATUS_RUNNING {
+		t.Fatalf("job = %+v, %v, want it running while its heartbeat is alive", job, err)
+	}
+	// the instance stops refreshing the heartbeat
+	redis.expire(importJobHeartbeatKeyPrefix + job.ID)
+	job, err = s.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != types.IMPORT_JOB_STATUS_FAILED || job.Error != types.ErrImportJobLost.Error() || job.FinishedAt == 0 {
+		t.Errorf("job = %+v, want it failed as lost", job)
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+)
+
+var _ ImportProfileService = &importProfileService{}
+
+type ImportProfileService interface {
+	CreateImportProfile(ctx context.Context, req *types.CreateImportProfileReq) (string, error)
+	UpdateImportProfile(ctx context.Context, req *types.UpdateImportProfileReq) error
+	GetImportProfile(ctx context.Context, id string) (*types.ImportProfile, error)
+	ListImportProfiles(ctx context.Context) ([]*types.ImportProfile, error)
+	DeleteImportProfile(ctx context.Context, id string) error
+}
+
+type importProfileService struct {
+	importProfileRepo repository.ImportProfileRepository
+}
+
+func NewImportProfileService(importProfileRepo repository.ImportProfileRepository) ImportProfileService {
+	return &importProfileService{
+		importProfileRepo: importProfileRepo,
+	}
+}
+
+func (s *importProfileService) CreateImportProfile(ctx context.Context, req *types.CreateImportProfileReq) (string, error) {
+	importProfile := newImportProfile(req)
+	if _, err := newEstimateSheetLayout(importProfile); err != nil {
+		return "", err
+	}
+	existing, err := s.importProfileRepo.FindByName(ctx, importProfile.Name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return "", types.ErrDuplicateImportProfile
+	}
+	return s.importProfileRepo.Save(ctx, importProfile)
+}
+
+func (s *importProfileService) UpdateImportProfile(ctx context.Context, req *types.UpdateImportProfileReq) error {
+	current, err := s.importProfileRepo.FindByID(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return types.ErrImportProfileNotFound
+	}
+	importProfile := newImportProfile(&req.CreateImportProfileReq)
+	if _, err := newEstimateSheetLayout(importProfile); err != nil {
+		return err
+	}
+	existing, err := s.importProfileRepo.FindByName(ctx, importProfile.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != req.ID {
+		return types.ErrDuplicateImportProfile
+	}
+	return s.importProfileRepo.Update(ctx, req.ID, importProfile)
+}
+
+func (s *importProfileService) GetImportProfile(ctx context.Context, id string) (*types.ImportProfile, error) {
+	importProfile, err := s.importProfileRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if importProfile == nil {
+		return nil, types.ErrImportProfileNotFound
+	}
+	return importProfile, nil
+}
+
+func (s *importProfileService) ListImportProfiles(ctx context.Context) ([]*types.ImportProfile, error) {
+	return s.importProfileRepo.FindAll(ctx)
+}
+
+func (s *importProfileService) DeleteImportProfile(ctx context.Context, id string) error {
+	importProfile, err := s.importProfileRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if importProfile == nil {
+		return types.ErrImportProfileNotFound
+	}
+	return s.importProfileRepo.Delete(ctx, id)
+}
+
+func newImportProfile(req *types.CreateImportProfileReq) *types.ImportProfile {
+	return &types.ImportProfile{
+		Name:              strings.TrimSpace(req.Name),
+		Description:       req.Description,
+		HeaderRow:         req.HeaderRow,
+		IndexColumn:       strings.ToUpper(strings.TrimSpace(req.IndexColumn)),
+		TitleColumn:       strings.ToUpper(strings.TrimSpace(req.TitleColumn)),
+		UnitColumn:        strings.ToUpper(strings.TrimSpace(req.UnitColumn)),
+		QuantityColumn:    strings.ToUpper(strings.TrimSpace(req.QuantityColumn)),
+		ReplacementLabels: req.ReplacementLabels,
+		ConsumableLabels:  req.ConsumableLabels,
+		MaterialMarker:    strings.TrimSpace(req.MaterialMarker),
+		HeaderKeywords:    req.HeaderKeywords,
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+)
+
+func newTestImportProfileReq(name string) types.CreateImportProfileReq {
+	return types.CreateImportProfileReq{
+		Name:              name,
+		IndexColumn:       "a",
+		TitleColumn:       "B",
+		UnitColumn:        "C",
+		QuantityColumn:    "D",
+		ReplacementLabels: []string{types.LABEL_REPLACEMENT},
+		ConsumableLabels:  []string{types.LABEL_CONSUMABLE},
+		MaterialMarker:    "-",
+	}
+}
+
+func TestImportProfileService(t *testing.T) {
+	ctx := context.Background()
+	s := NewImportProfileService(repository.NewImportProfileRepository(newMemoryDatabase()))
+
+	// a sheet without header starts with the data
+	req := newTestImportProfileReq(" Dự toán xưởng ")
+	id, err := s.CreateImportProfile(ctx, &req)
+	if err != nil {
+		t.Fatalf("create a profile without header row: %v", err)
+	}
+	profile, err := s.GetImportProfile(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "Dự toán xưởng" || profile.HeaderRow != 0 || profile.IndexColumn != "A" {
+		t.Errorf("profile = %+v, want the name trimmed, no header row and upper case columns", profile)
+	}
+
+	if _, err := s.CreateImportProfile(ctx, &req); !errors.Is(err, types.ErrDuplicateImportProfile) {
+		t.Errorf("create a profile twice: got %v, want ErrDuplicateImportProfile", err)
+	}
+	invalid := newTestImportProfileReq("Âm")
+	invalid.HeaderRow = -1
+	if _, err := s.CreateImportProfile(ctx, &invalid); !errors.Is(err, types.ErrInvalidImportProfile) {
+		t.Errorf("create a profile with a negative header row: got %v, want ErrInvalidImportProfile", err)
+	}
+	invalid = newTestImportProfileReq("Cột sai")
+	invalid.QuantityColumn = "1"
+	if _, err := s.CreateImportProfile(ctx, &invalid); !errors.Is(err, types.ErrInvalidImportProfile) {
+		t.Errorf("create a profile with an invalid column: got %v, want ErrInvalidImportProfile", err)
+	}
+
+	otherReq := newTestImportProfileReq("Dự toán nhà máy")
+	otherID, err := s.CreateImportProfile(ctx, &otherReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := &types.UpdateImportProfileReq{ID: otherID, CreateImportProfileReq: newTestImportProfileReq("Dự toán xưởng")}
+	if err := s.UpdateImportProfile(ctx, update); !errors.Is(err, types.ErrDuplicateImportProfile) {
+		t.Errorf("rename to the name of another profile: got %v, want ErrDuplicateImportProfile", err)
+	}
+	update.Name = "Dự toán nhà máy"
+	update.HeaderRow = 3
+	if err := s.UpdateImportProfile(ctx, update); err != nil {
+		t.Fatalf("update a profile: %v", err)
+	}
+	if profile, err := s.GetImportProfile(ctx, otherID); err != nil || profile.HeaderRow != 3 {
+		t.Errorf("updated profile = %+v, %v, want header row 3", profile, err)
+	}
+
+	profiles, err := s.ListImportProfiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 {
+		t.Errorf("listed %d profiles, want 2", len(profiles))
+	}
+	if err := s.DeleteImportProfile(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetImportProfile(ctx, id); !errors.Is(err, types.ErrImportProfileNotFound) {
+		t.Errorf("get a deleted profile: got %v, want ErrImportProfileNotFound", err)
+	}
+	missing := &types.UpdateImportProfileReq{ID: id, CreateImportProfileReq: newTestImportProfileReq("Mới")}
+	if err := s.UpdateImportProfile(ctx, missing); !errors.Is(err, types.ErrImportProfileNotFound) {
+		t.Errorf("update a deleted profile: got %v, want ErrImportProfileNotFound", err)
+	}
+	if err := s.DeleteImportProfile(ctx, id); !errors.Is(err, types.ErrImportProfileNotFound) {
+		t.Errorf("delete a deleted profile: got %v, want ErrImportProfileNotFound", err)
+	}
+}
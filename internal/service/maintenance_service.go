@@ -13,6 +13,8 @@ type MaintenanceService interface {
 	GetMaintenanceByIDs(ctx context.Context, ids []string) (map[string]*types.Maintenance, error)
 	GetMaintenances(ctx context.Context, req *types.MaintenanceFilter) ([]*types.Maintenance, error)
 	CreateMaintenance(ctx context.Context, maintenance *types.CreateMaintenanceRequest) (string, error)
+	// set the dates the ship arrives at and leaves the yard
+	UpdateSchedule(ctx context.Context, req *types.UpdateMaintenanceScheduleReq) error
 }
 
 type maintenanceService struct {
@@ -54,6 +56,9 @@ func (s *maintenanceService) GetMaintenances(ctx context.Context, req *types.Mai
 }
 
 func (s *maintenanceService) CreateMaintenance(ctx context.Context, maintenance *types.CreateMaintenanceRequest) (string, error) {
+	if err := checkSchedule(maintenance.StartDate, maintenance.EndDate); err != nil {
+		return "", err
+	}
 	maintenances, err := s.maintenanceRepo.Filter(ctx, &types.MaintenanceFilter{
 		ProjectCode:       maintenance.ProjectCode,
 		MaintenanceTier:   maintenance.MaintenanceTier,
@@ -71,6 +76,31 @@ func (s *maintenanceService) CreateMaintenance(ctx context.Context, maintenance
 		MaintenanceTier:   maintenance.MaintenanceTier,
 		MaintenanceNumber: maintenance.MaintenanceNumber,
 		Year:              time.Now().Year(),
+		StartDate:         maintenance.StartDate,
+		EndDate:           maintenance.EndDate,
 	},
 	)
 }
+
+func (s *maintenanceService) UpdateSchedule(ctx context.Context, req *types.UpdateMaintenanceScheduleReq) error {
+	if err := checkSchedule(req.StartDate, req.EndDate); err != nil {
+		return err
+	}
+	maintenance, err := s.maintenanceRepo.FindByID(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if maintenance == nil || maintenance.ID == "" {
+		return types.ErrMaintenanceNotFound
+	}
+	maintenance.StartDate = req.StartDate
+	maintenance.EndDate = req.EndDate
+	return s.maintenanceRepo.Update(ctx, req.ID, maintenance)
+}
+
+func checkSchedule(startDate, endDate int64) error {
+	if startDate < 0 || endDate < 0 || (startDate != 0 && endDate != 0 && endDate < startDate) {
+		return types.ErrInvalidSchedule
+	}
+	return nil
+}
@@ -0,0 +1,497 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+const (
+	// suggestionMinScore is the similarity from which a record is proposed
+	// for a name
+	suggestionMinScore     = 0.5
+	defaultSuggestionLimit = 5
+	// equipmentHintMinScore is the similarity from which the importer names
+	// the closest equipment of a name it is about to create
+	equipmentHintMinScore    = 0.6
+	defaultDuplicateMinScore = 0.85
+)
+
+var _ MatchingService = &matchingService{}
+
+type MatchingService interface {
+	// propose catalog materials for free-text material names
+	SuggestMaterials(ctx context.Context, req *types.SuggestMatchesReq) ([]*types.NameSuggestions, error)
+	// propose stored equipments for equipment names
+	SuggestEquipments(ctx context.Context, req *types.SuggestMatchesReq) ([]*types.NameSuggestions, error)
+	// group catalog materials or equipments whose names likely denote the same thing
+	FindDuplicates(ctx context.Context, req *types.DuplicatesRequest) ([]*types.DuplicateGroup, error)
+	// fold duplicate catalog materials into one, re-pointing the lines referencing them
+	MergeCatalogMaterials(ctx context.Context, req *types.MergeCatalogMaterialsReq) (*types.MergeCatalogMaterialsResult, error)
+}
+
+type matchingService struct {
+	catalogRepo            repository.CatalogRepository
+	priceRepo              repository.PriceRepository
+	supplierRepo           repository.SupplierRepository
+	stockRepo              repository.StockRepository
+	stockMovementRepo      repository.StockMovementRepository
+	stockTransferRepo      repository.StockTransferRepository
+	equipmentMachineryRepo repository.EquipmentMachineryRepo
+	materialsProfileRepo   repository.MaterialsProfileRepository
+	materialsRequestRepo   repository.MaterialsRequestRepository
+}
+
+func NewMatchingService(
+	catalogRepo repository.CatalogRepository,
+	priceRepo repository.PriceRepository,
+	supplierRepo repository.SupplierRepository,
+	stockRepo repository.StockRepository,
+	stockMovementRepo repository.StockMovementRepository,
+	stockTransferRepo repository.StockTransferRepository,
+	equipmentMachineryRepo repository.EquipmentMachineryRepo,
+	materialsProfileRepo repository.MaterialsProfileRepository,
+	materialsRequestRepo repository.MaterialsRequestRepository,
+) MatchingService {
+	return &matchingService{
+		catalogRepo:            catalogRepo,
+		priceRepo:              priceRepo,
+		supplierRepo:           supplierRepo,
+		stockRepo:              stockRepo,
+		stockMovementRepo:      stockMovementRepo,
+		stockTransferRepo:      stockTransferRepo,
+		equipmentMachineryRepo: equipmentMachineryRepo,
+		materialsProfileRepo:   materialsProfileRepo,
+		materialsRequestRepo:   materialsRequestRepo,
+	}
+}
+
+func (s *matchingService) SuggestMaterials(ctx context.Context, req *types.SuggestMatchesReq) ([]*types.NameSuggestions, error) {
+	materials, err := s.catalogRepo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return suggestNames(req, catalogCandidates(materials)), nil
+}
+
+func (s *matchingService) SuggestEquipments(ctx context.Context, req *types.SuggestMatchesReq) ([]*types.NameSuggestions, error) {
+	equipments, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{Sector: req.Sector})
+	if err != nil {
+		return nil, err
+	}
+	return suggestNames(req, equipmentCandidates(equipments)), nil
+}
+
+func (s *matchingService) FindDuplicates(ctx context.Context, req *types.DuplicatesRequest) ([]*types.DuplicateGroup, error) {
+	minScore := req.MinScore
+	if minScore <= 0 {
+		minScore = defaultDuplicateMinScore
+	}
+	var candidates []*nameCandidate
+	switch req.Kind {
+	case types.MATCH_KIND_MATERIAL:
+		materials, err := s.catalogRepo.FindAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		candidates = catalogCandidates(materials)
+	case types.MATCH_KIND_EQUIPMENT:
+		equipments, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{Sector: req.Sector})
+		if err != nil {
+			return nil, err
+		}
+		candidates = equipmentCandidates(equipments)
+	default:
+		return nil, types.ErrInvalidMatchKind
+	}
+	return groupDuplicates(candidates, minScore), nil
+}
+
+func (s *matchingService) MergeCatalogMaterials(ctx context.Context, req *types.MergeCatalogMaterialsReq) (*types.MergeCatalogMaterialsResult, error) {
+	sourceIDs := make(map[string]bool, len(req.SourceIDs))
+	for _, id := range req.SourceIDs {
+		if id == req.TargetID {
+			return nil, types.ErrInvalidMergeSource
+		}
+		sourceIDs[id] = true
+	}
+	ids := append([]string{req.TargetID}, req.SourceIDs...)
+	materials, err := s.catalogRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(materials) != len(sourceIDs)+1 {
+		return nil, types.ErrSomeCatalogMaterialNotFound
+	}
+	target := materials[req.TargetID]
+	// checked before any write so that a refused merge changes nothing
+	for id := range sourceIDs {
+		transfers, err := s.stockTransferRepo.Filter(ctx, &types.StockTransferFilter{
+			Status:    types.TRANSFER_STATUS_IN_TRANSIT,
+			CatalogID: id,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(transfers) > 0 {
+			return nil, fmt.Errorf("%w: %s", types.ErrCatalogMaterialInTransit, materials[id].Name)
+		}
+	}
+	movements, err := mergedStockMovements(ctx, s.stockRepo, materials, sourceIDs, target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.MergeCatalogMaterialsResult{MergedMaterials: len(sourceIDs)}
+	profiles := make(map[string]*types.MaterialsProfile)
+	requests := make(map[string]*types.MaterialRequest)
+	for id := range sourceIDs {
+		found, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+			CatalogID:       id,
+			IncludeArchived: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, profile := range found {
+			profiles[profile.ID] = profile
+		}
+		foundRequests, err := s.materialsRequestRepo.Filter(ctx, &types.MaterialRequestFilter{CatalogID: id})
+		if err != nil {
+			return nil, err
+		}
+		for _, request := range foundRequests {
+			requests[request.ID] = request
+		}
+	}
+
+	profileIDs := make([]string, 0, len(profiles))
+	updatedProfiles := make([]*types.MaterialsProfile, 0, len(profiles))
+	for id, profile := range profiles {
+		relinkCatalog(profile.Estimate, sourceIDs, target.ID)
+		relinkCatalog(profile.Reality, sourceIDs, target.ID)
+		profileIDs = append(profileIDs, id)
+		updatedProfiles = append(updatedProfiles, profile)
+	}
+	if len(profileIDs) > 0 {
+		if err := s.materialsProfileRepo.UpdateMany(ctx, profileIDs, updatedProfiles); err != nil {
+			return nil, err
+		}
+	}
+	result.UpdatedProfiles = len(profileIDs)
+	for id, request := range requests {
+		for _, materials := range request.MaterialsForEquipment {
+			relinkCatalog(materials, sourceIDs, target.ID)
+		}
+		if err := s.materialsRequestRepo.Update(ctx, id, request); err != nil {
+			return nil, err
+		}
+		result.UpdatedRequests++
+	}
+
+	// the names of the duplicates remain known as aliases of the target
+	aliases := slices.Clone(target.Aliases)
+	for id := range sourceIDs {
+		aliases = append(aliases, materials[id].Name)
+		aliases = append(aliases, materials[id].Aliases...)
+	}
+	setCatalogAliases(target, aliases)
+	if err := s.catalogRepo.Update(ctx, req.TargetID, target); err != nil {
+		return nil, err
+	}
+	// prices per the same unit remain in the price history of the target
+	samePricedIDs := make([]string, 0, len(sourceIDs))
+	for id := range sourceIDs {
+		if utils.NormalizeUnit(materials[id].DefaultUnit) == utils.NormalizeUnit(target.DefaultUnit) {
+			samePricedIDs = append(samePricedIDs, id)
+		}
+	}
+	if err := s.priceRepo.UpdateCatalogID(ctx, samePricedIDs, req.TargetID); err != nil {
+		return nil, err
+	}
+	// the update cleared target.ID
+	updatedSuppliers, err := s.relinkSuppliers(ctx, sourceIDs, req.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	result.UpdatedSuppliers = updatedSuppliers
+	for _, movement := range movements {
+		if err := applyStockMovement(ctx, s.stockRepo, s.stockMovementRepo, movement, materials); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.stockRepo.DeleteByCatalogIDs(ctx, req.SourceIDs); err != nil {
+		return nil, err
+	}
+	for id := range sourceIDs {
+		if err := s.catalogRepo.Delete(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// relinkSuppliers makes the suppliers of the merged materials suppliers of
+// the target.
+func (s *matchingService) relinkSuppliers(ctx context.Context, sourceIDs map[string]bool, targetID string) (int, error) {
+	ids := make([]string, 0, len(sourceIDs))
+	for id := range sourceIDs {
+		ids = append(ids, id)
+	}
+	suppliers, err := s.supplierRepo.FindByCatalogIDs(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	for _, supplier := range suppliers {
+		supplier.CatalogIDs = relinkIDs(supplier.CatalogIDs, sourceIDs, targetID)
+		supplier.PreferredFor = relinkIDs(supplier.PreferredFor, sourceIDs, targetID)
+		id := supplier.ID
+		if err := s.supplierRepo.Update(ctx, id, supplier); err != nil {
+			return 0, err
+		}
+	}
+	return len(suppliers), nil
+}
+
+func relinkIDs(ids []string, sourceIDs map[string]bool, targetID string) []string {
+	relinked := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if sourceIDs[id] {
+			id = targetID
+		}
+		relinked = append(relinked, id)
+	}
+	return utils.RemoveDuplicates(relinked)
+}
+
+// mergedStockMovements moves the stock of the merged materials to the target
+// in every warehouse, converted to the default unit of the target, with one
+// adjustment per warehouse.
+func mergedStockMovements(ctx context.Context, stockRepo repository.StockRepository, materials map[string]*types.CatalogMaterial, sourceIDs map[string]bool, target *types.CatalogMaterial) ([]*types.StockMovement, error) {
+	ids := make([]string, 0, len(sourceIDs))
+	for id := range sourceIDs {
+		ids = append(ids, id)
+	}
+	found, err := stockRepo.Filter(ctx, &types.StockBalanceFilter{CatalogIDs: ids})
+	if err != nil {
+		return nil, err
+	}
+	user, _ := ctx.Value("user").(*types.User)
+	note := "Hợp nhất vật tư vào " + target.Name
+	byWarehouse := make(map[string]*types.StockMovement)
+	movements := make([]*types.StockMovement, 0)
+	for _, balance := range found {
+		if balance.Quantity == 0 {
+			continue
+		}
+		unit := balance.Unit
+		if unit == "" {
+			unit = materials[balance.CatalogID].DefaultUnit
+		}
+		quantity, ok := utils.ConvertQuantity(balance.Quantity, unit, target.DefaultUnit, catalogPackSizes(target))
+		if !ok {
+			return nil, fmt.Errorf("%w: stock of %s in %s", types.ErrIncompatibleUnit, materials[balance.CatalogID].Name, unit)
+		}
+		movement, ok := byWarehouse[balance.Warehouse]
+		if !ok {
+			movement = newStockMovement(types.STOCK_MOVEMENT_ADJUSTMENT, balance.Warehouse, note, user, nil)
+			byWarehouse[balance.Warehouse] = movement
+			movements = append(movements, movement)
+		}
+		movement.Lines = append(movement.Lines,
+			types.StockMovementLine{
+				CatalogID: balance.CatalogID,
+				Name:      materials[balance.CatalogID].Name,
+				Unit:      unit,
+				Quantity:  -balance.Quantity,
+				Delta:     -balance.Quantity,
+			},
+			types.StockMovementLine{
+				CatalogID: target.ID,
+				Name:      target.Name,
+				Unit:      target.DefaultUnit,
+				Quantity:  quantity,
+				Delta:     quantity,
+			},
+		)
+		movement.CatalogIDs = utils.RemoveDuplicates(append(movement.CatalogIDs, balance.CatalogID, target.ID))
+	}
+	return movements, nil
+}
+
+// relinkCatalog points the materials referencing one of sourceIDs to targetID.
+func relinkCatalog(materials types.MaterialsForEquipment, sourceIDs map[string]bool, targetID string) {
+	for _, section := range []map[string]types.Material{materials.ReplacementMaterials, materials.ConsumableSupplies} {
+		for name, material := range section {
+			if sourceIDs[material.CatalogID] {
+				material.CatalogID = targetID
+				section[name] = material
+			}
+		}
+	}
+}
+
+// nameCandidate is a record that can be proposed for a name, with the names
+// it is known by and their normalized form.
+type nameCandidate struct {
+	match      types.NameMatch
+	names      []string
+	normalized []string
+}
+
+func newNameCandidate(match types.NameMatch, names ...string) *nameCandidate {
+	candidate := &nameCandidate{match: match}
+	for _, name := range names {
+		if normalized := utils.NormalizeName(name); normalized != "" {
+			candidate.names = append(candidate.names, name)
+			candidate.normalized = append(candidate.normalized, normalized)
+		}
+	}
+	return candidate
+}
+
+func catalogCandidates(materials []*types.CatalogMaterial) []*nameCandidate {
+	candidates := make([]*nameCandidate, 0, len(materials))
+	for _, material := range materials {
+		match := types.NameMatch{ID: material.ID, Name: material.Name, Code: material.Code}
+		candidates = append(candidates, newNameCandidate(match, append([]string{material.Name}, material.Aliases...)...))
+	}
+	return candidates
+}
+
+func equipmentCandidates(equipments []*types.EquipmentMachinery) []*nameCandidate {
+	candidates := make([]*nameCandidate, 0, len(equipments))
+	for _, equipment := range equipments {
+		match := types.NameMatch{ID: equipment.ID, Name: equipment.Name, Sector: equipment.Sector}
+		candidates = append(candidates, newNameCandidate(match, append([]string{equipment.Name}, equipment.Aliases...)...))
+	}
+	return candidates
+}
+
+// score returns the best similarity between one of the names of the
+// candidate and the normalized name, with the name that scored it.
+func (c *nameCandidate) score(normalized string) (float64, string) {
+	best, matched := 0.0, ""
+	for i, name := range c.normalized {
+		if score := utils.NormalizedNameSimilarity(normalized, name); score > best {
+			best, matched = score, c.names[i]
+		}
+	}
+	return best, matched
+}
+
+// rankNameCandidates returns at most limit candidates scoring at least
+// minScore for name, the best first.
+func rankNameCandidates(name string, candidates []*nameCandidate, minScore float64, limit int) []*types.NameMatch {
+	normalized := utils.NormalizeName(name)
+	matches := make([]*types.NameMatch, 0)
+	for _, candidate := range candidates {
+		score, matched := candidate.score(normalized)
+		if score < minScore {
+			continue
+		}
+		match := candidate.match
+		match.Matched = matched
+		match.Score = roundScore(score)
+		matches = append(matches, &match)
+	}
+	slices.SortStableFunc(matches, func(a, b *types.NameMatch) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+func suggestNames(req *types.SuggestMatchesReq, candidates []*nameCandidate) []*types.NameSuggestions {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSuggestionLimit
+	}
+	suggestions := make([]*types.NameSuggestions, 0, len(req.Names))
+	for _, name := range req.Names {
+		suggestions = append(suggestions, &types.NameSuggestions{
+			Name:    name,
+			Matches: rankNameCandidates(name, candidates, suggestionMinScore, limit),
+		})
+	}
+	return suggestions
+}
+
+// groupDuplicates links every two candidates of the same sector scoring at
+// least minScore and returns the connected groups, the most similar first.
+func groupDuplicates(candidates []*nameCandidate, minScore float64) []*types.DuplicateGroup {
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	best := make(map[int]float64)
+	for i := range candidates {
+		for j := i + 1; j < len(candidates); j++ {
+			if candidates[i].match.Sector != candidates[j].match.Sector {
+				continue
+			}
+			score := 0.0
+			for _, name := range candidates[j].normalized {
+				s, _ := candidates[i].score(name)
+				score = max(score, s)
+			}
+			if score < minScore {
+				continue
+			}
+			rootI, rootJ := find(i), find(j)
+			if rootI != rootJ {
+				parent[rootJ] = rootI
+			}
+			best[rootI] = max(best[rootI], score)
+		}
+	}
+
+	members := make(map[int][]*types.NameMatch)
+	for i, candidate := range candidates {
+		root := find(i)
+		match := candidate.match
+		members[root] = append(members[root], &match)
+	}
+	groups := make([]*types.DuplicateGroup, 0)
+	for root, items := range members {
+		if len(items) < 2 {
+			continue
+		}
+		score := 0.0
+		for r, s := range best {
+			if find(r) == root {
+				score = max(score, s)
+			}
+		}
+		slices.SortFunc(items, func(a, b *types.NameMatch) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+		groups = append(groups, &types.DuplicateGroup{Items: items, Score: roundScore(score)})
+	}
+	slices.SortFunc(groups, func(a, b *types.DuplicateGroup) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Items[0].Name, b.Items[0].Name)
+	})
+	return groups
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
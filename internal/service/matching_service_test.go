@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func newTestMatchingService(db *memoryDatabase) *matchingService {
+	return NewMatchingService(
+		repository.NewCatalogRepository(db),
+		repository.NewPriceRepository(db),
+		repository.NewSupplierRepository(db),
+		repository.NewStockRepository(db),
+		repository.NewStockMovementRepository(db),
+		repository.NewStockTransferRepository(db),
+		repository.NewEquipmentMachineryRepo(db),
+		repository.NewMaterialsProfileRepository(db),
+		repository.NewMaterialsRequestRepository(db),
+	).(*matchingService)
+}
+
+func TestMergeCatalogMaterials(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &types.User{Username: "thukho"})
+	db := newMemoryDatabase()
+	s := newTestMatchingService(db)
+
+	saveMaterial := func(code, name string, aliases ...string) string {
+		t.Helper()
+		material := &types.CatalogMaterial{Code: code, Name: name, DefaultUnit: "cái"}
+		setCatalogAliases(material, aliases)
+		id, err := s.catalogRepo.Save(ctx, material)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	targetID := saveMaterial("GC-50", "Gioăng cao su DN50")
+	sourceID := saveMaterial("GC-50B", "gioang cao su DN 50", "Ron DN50")
+	inTransitID := saveMaterial("GC-50C", "Gioăng DN50 cũ")
+
+	profileID, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+		Sector: testSector,
+		Estimate: types.MaterialsForEquipment{
+			ReplacementMaterials: map[string]types.Material{
+				"gioang cao su DN 50": {Name: "gioang cao su DN 50", Unit: "cái", Quantity: 2, CatalogID: sourceID},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestID, err := s.materialsRequestRepo.Save(ctx, &types.MaterialRequest{
+		Sector: testSector,
+		MaterialsForEquipment: map[string]types.MaterialsForEquipment{
+			profileID: {ConsumableSupplies: map[string]types.Material{
+				"Ron DN50": {Name: "Ron DN50", Unit: "cái", Quantity: 1, CatalogID: sourceID},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	supplierID, err := s.supplierRepo.Save(ctx, &types.Supplier{Name: "Công ty A", CatalogIDs: []string{sourceID, targetID}, PreferredFor: []string{sourceID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.priceRepo.Save(ctx, &types.CatalogPrice{CatalogID: sourceID, Price: utils.NewDecimal(15000)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.stockRepo.AddBalances(ctx, []*types.StockBalance{
+		{CatalogID: sourceID, Warehouse: "Kho 1", Quantity: 4, Unit: "cái"},
+		{CatalogID: targetID, Warehouse: "Kho 1", Quantity: 6, Unit: "cái"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.stockTransferRepo.Save(ctx, &types.StockTransfer{Status: types.TRANSFER_STATUS_IN_TRANSIT, CatalogIDs: []string{inTransitID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	refused := []struct {
+		name      string
+		sourceIDs []string
+		want      error
+	}{
+		{"target among the sources", []string{sourceID, targetID}, types.ErrInvalidMergeSource},
+		{"unknown source", []string{bson.NewObjectID().Hex()}, types.ErrSomeCatalogMaterialNotFound},
+		{"source in transit", []string{sourceID, inTransitID}, types.ErrCatalogMaterialInTransit},
+	}
+	for _, test := range refused {
+		_, err := s.MergeCatalogMaterials(ctx, &types.MergeCatalogMaterialsReq{TargetID: targetID, SourceIDs: test.sourceIDs})
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+		if source, err := s.catalogRepo.FindByID(ctx, sourceID); err != nil || source == nil {
+			t.Errorf("%s: source deleted by a refused merge", test.name)
+		}
+	}
+
+	result, err := s.MergeCatalogMaterials(ctx, &types.MergeCatalogMaterialsReq{TargetID: targetID, SourceIDs: []string{sourceID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.MergeCatalogMaterialsResult{MergedMaterials: 1, UpdatedProfiles: 1, UpdatedRequests: 1, UpdatedSuppliers: 1}
+	if *result != want {
+		t.Errorf("result = %+v, want %+v", *result, want)
+	}
+
+	if source, err := s.catalogRepo.FindByID(ctx, sourceID); err != nil || source != nil {
+		t.Errorf("source = %+v, %v, want it deleted", source, err)
+	}
+	target, err := s.catalogRepo.FindByID(ctx, targetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(target.Aliases, []string{"gioang cao su DN 50", "Ron DN50"}) {
+		t.Errorf("target aliases = %q, want the name and aliases of the source", target.Aliases)
+	}
+	if found, err := s.catalogRepo.FindByNormalizedNames(ctx, []string{utils.NormalizeName("Ron DN50")}); err != nil || len(found) != 1 || found[0].ID != targetID {
+		t.Errorf("found %+v by a merged alias, want the target", found)
+	}
+
+	profile, err := s.materialsProfileRepo.FindByID(ctx, profileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := profile.Estimate.ReplacementMaterials["gioang cao su DN 50"].CatalogID; got != targetID {
+		t.Errorf("profile line references %s, want the target", got)
+	}
+	request, err := s.materialsRequestRepo.FindByID(ctx, requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := request.MaterialsForEquipment[profileID].ConsumableSupplies["Ron DN50"].CatalogID; got != targetID {
+		t.Errorf("request line references %s, want the target", got)
+	}
+	supplier, err := s.supplierRepo.FindByID(ctx, supplierID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(supplier.CatalogIDs, []string{targetID}) || !slices.Equal(supplier.PreferredFor, []string{targetID}) {
+		t.Errorf("supplier = %+v, want it supplying the target only", supplier)
+	}
+	prices, err := s.priceRepo.FindByCatalogIDs(ctx, []string{targetID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 1 {
+		t.Errorf("target has %d prices, want the price of the source", len(prices))
+	}
+	balances, err := s.stockRepo.Filter(ctx, &types.StockBalanceFilter{CatalogIDs: []string{sourceID, targetID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 1 || balances[0].CatalogID != targetID || balances[0].Quantity != 10 {
+		t.Errorf("balances = %+v, want the stock of both on the target", balances)
+	}
+}
+
+func TestCreateMaterialsRequestSuggestsCatalogMaterials(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &types.User{Username: "kythuat"})
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	materialsProfileRepo := repository.NewMaterialsProfileRepository(db)
+	maintenanceRepo := repository.NewMaintenanceRepository(db)
+	s := NewMaterialsRequestService(
+		repository.NewMaterialsRequestRepository(db),
+		materialsProfileRepo,
+		maintenanceRepo,
+		repository.NewEquipmentMachineryRepo(db),
+		catalogRepo,
+		repository.NewPriceRepository(db),
+		"",
+	)
+
+	gasketID := saveCatalog(t, catalogRepo, "GC-50", "Gioăng cao su DN50")
+	boltID := saveCatalog(t, catalogRepo, "BL-12", "Bu lông M12")
+	maintenanceID, err := maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	profileID, err := materialsProfileRepo.Save(ctx, &types.MaterialsProfile{MaintenanceInstanceID: maintenanceID, Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.CreateMaterialsRequest(ctx, &types.CreateMaterialRequestReq{
+		MaintenanceInstanceID: maintenanceID,
+		Sector:                testSector,
+		MaterialsForEquipment: map[string]types.MaterialsForEquipment{
+			profileID: {
+				ReplacementMaterials: map[string]types.Material{
+					"gioang cao su  DN 50": {Name: "gioang cao su  DN 50", Unit: "cái", Quantity: 2},
+					"Bu lông M12":          {Name: "Bu lông M12", Unit: "cái", Quantity: 8, CatalogID: boltID},
+				},
+				ConsumableSupplies: map[string]types.Material{
+					"Dây điện": {Name: "Dây điện", Unit: "m", Quantity: 5},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ID == "" {
+		t.Error("created request has no ID")
+	}
+	if len(result.CatalogSuggestions) != 1 {
+		t.Fatalf("suggestions = %+v, want only the unlinked gasket", result.CatalogSuggestions)
+	}
+	suggestion := result.CatalogSuggestions[0]
+	if suggestion.Name != "gioang cao su  DN 50" || len(suggestion.Matches) == 0 || suggestion.Matches[0].ID != gasketID || suggestion.Matches[0].Score < 0.9 {
+		t.Errorf("suggestion = %+v, want the gasket first with a high score", suggestion)
+	}
+}
+
+func TestBackfillNormalizedNames(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	s := NewCatalogService(catalogRepo, repository.NewPriceRepository(db), repository.NewMaterialsProfileRepository(db), repository.NewMaterialsRequestRepository(db))
+
+	// recorded before the catalog normalized names
+	legacyID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{Code: "GC-50", Name: "Gioăng cao su DN50", Aliases: []string{"Ron DN50"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveCatalog(t, catalogRepo, "BL-12", "Bu lông M12")
+
+	updated, err := s.BackfillNormalizedNames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 1 {
+		t.Errorf("updated %d materials, want only the legacy one", updated)
+	}
+	found, err := catalogRepo.FindByNormalizedNames(ctx, []string{utils.NormalizeName("ron dn 50")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != legacyID {
+		t.Errorf("found %+v by alias, want the legacy material", found)
+	}
+	if updated, err := s.BackfillNormalizedNames(ctx); err != nil || updated != 0 {
+		t.Errorf("second backfill updated %d, %v, want nothing", updated, err)
+	}
+}
+
+func saveCatalog(tb testing.TB, catalogRepo repository.CatalogRepository, code, name string, aliases ...string) string {
+	tb.Helper()
+	material := &types.CatalogMaterial{Code: code, Name: name, DefaultUnit: "cái"}
+	setCatalogAliases(material, aliases)
+	id, err := catalogRepo.Save(context.Background(), material)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return id
+}
@@ -3,9 +3,12 @@ package service
 import (
 	"context"
 	"fmt"
+	"maps"
+	"math"
+	"mime/multipart"
+	"os"
 	"path"
-	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,34 +20,78 @@ import (
 
 var _ MaterialsProfileService = &materialsProfileService{}
 
+// estimateUploadDownloadURL is the route serving the original file of an upload
+const estimateUploadDownloadURL = "/api/v1/materials-profiles/uploads/%s/download"
+
 type MaterialsProfileService interface {
 	GetMaterialsProfile(ctx context.Context, id string) (*types.MaterialsProfileResponse, error)
 	GetMaterialsProfiles(ctx context.Context, req *types.MaterialsProfileFilterRequest) ([]*types.MaterialsProfileResponse, error)
 	UpdateMaterialsEstimateProfile(ctx context.Context, request *types.UpdateMaterialsEstimateProfileRequest) error
-	UploadEstimateSheet(ctx context.Context, request *types.UploadEstimateSheetRequest) error
-	PaginatedMaterialsProfiles(ctx context.Context, request *types.PaginatedRequest) ([]*types.MaterialsProfileResponse, int64, error)
+	UploadEstimateSheet(ctx context.Context, request *types.UploadEstimateSheetRequest) (*types.EstimateImportResult, error)
+	UploadEstimateWorkbook(ctx context.Context, request *types.UploadEstimateWorkbookRequest) (*types.EstimateWorkbookImportResult, error)
+	// store the uploaded file and import it in a background job
+	StartEstimateSheetImport(ctx context.Context, request *types.UploadEstimateSheetRequest) (*types.ImportJob, error)
+	StartEstimateWorkbookImport(ctx context.Context, request *types.UploadEstimateWorkbookRequest) (*types.ImportJob, error)
+	PaginatedMaterialsProfiles(ctx context.Context, request *types.MaterialsProfileSearchRequest) ([]*types.MaterialsProfileResponse, int64, error)
 	CreateMaterialsProfile(ctx context.Context, request *types.CreateMaterialProfileReq) (string, error)
+	// create a xlsx file in the layout accepted by UploadEstimateSheet
+	ExportEstimateSheet(ctx context.Context, request *types.ExportEstimateSheetReq) (*os.File, error)
+	MaterialsProfileTree(ctx context.Context, request *types.MaterialsProfileTreeReq) (*types.MaterialsProfileTree, error)
+	// create a profile at an index path, shifting the siblings from that path onward when it is taken
+	InsertMaterialsProfile(ctx context.Context, request *types.CreateMaterialProfileReq) (string, error)
+	MoveMaterialsProfile(ctx context.Context, request *types.MoveMaterialsProfileReq) error
+	ValidateIndexPaths(ctx context.Context, request *types.ValidateIndexPathsReq) ([]*types.DuplicateIndexPath, error)
+	// seed the estimates of a maintenance from a previous one, reality is left empty
+	CopyEstimates(ctx context.Context, request *types.CopyEstimatesReq) (*types.CopyEstimatesResult, error)
+	DeleteMaterialsProfile(ctx context.Context, request *types.RemoveMaterialsProfileReq) error
+	ArchiveMaterialsProfile(ctx context.Context, request *types.RemoveMaterialsProfileReq) error
+	RestoreMaterialsProfile(ctx context.Context, id string) error
+	// list the uploaded estimate files of a maintenance, newest first
+	EstimateUploadHistory(ctx context.Context, request *types.EstimateUploadHistoryReq) ([]*types.EstimateUploadResponse, error)
+	// open the original file of an estimate upload
+	OpenEstimateUpload(ctx context.Context, id string) (*types.EstimateUpload, *os.File, error)
 	//UpdateMaterialsRealityProfile(ctx context.Context, request *types.UpdateMaterialsRealityProfileRequest) error
 }
 
 type materialsProfileService struct {
 	materialsProfileRepo   repository.MaterialsProfileRepository
+	materialsRequestRepo   repository.MaterialsRequestRepository
 	maintenanceRepo        repository.MaintenanceRepository
 	equipmentMachineryRepo repository.EquipmentMachineryRepo
+	importProfileRepo      repository.ImportProfileRepository
+	estimateUploadRepo     repository.EstimateUploadRepository
+	catalogRepo            repository.CatalogRepository
+	priceRepo              repository.PriceRepository
+	transactor             repository.Transactor
 	uploadService          UploadService
+	importJobService       ImportJobService
 }
 
 func NewMaterialsProfileService(
 	materialsProfileRepo repository.MaterialsProfileRepository,
+	materialsRequestRepo repository.MaterialsRequestRepository,
 	maintenanceRepo repository.MaintenanceRepository,
 	equipmentMachineryRepo repository.EquipmentMachineryRepo,
+	importProfileRepo repository.ImportProfileRepository,
+	estimateUploadRepo repository.EstimateUploadRepository,
+	catalogRepo repository.CatalogRepository,
+	priceRepo repository.PriceRepository,
+	transactor repository.Transactor,
 	uploadService UploadService,
+	importJobService ImportJobService,
 ) MaterialsProfileService {
 	return &materialsProfileService{
 		materialsProfileRepo:   materialsProfileRepo,
+		materialsRequestRepo:   materialsRequestRepo,
 		maintenanceRepo:        maintenanceRepo,
 		equipmentMachineryRepo: equipmentMachineryRepo,
+		importProfileRepo:      importProfileRepo,
+		estimateUploadRepo:     estimateUploadRepo,
+		catalogRepo:            catalogRepo,
+		priceRepo:              priceRepo,
+		transactor:             transactor,
 		uploadService:          uploadService,
+		importJobService:       importJobService,
 	}
 }
 
@@ -78,7 +125,8 @@ func (s *materialsProfileService) GetMaterialsProfile(ctx context.Context, id st
 func (s *materialsProfileService) GetMaterialsProfiles(ctx context.Context, request *types.MaterialsProfileFilterRequest) ([]*types.MaterialsProfileResponse, error) {
 
 	filter := &types.MaterialsProfileFilter{
-		Sector: request.Sector,
+		Sector:          request.Sector,
+		IncludeArchived: request.IncludeArchived,
 	}
 	if len(request.MaintenanceIDs) > 0 {
 		filter.MaintenanceInstanceIDs = request.MaintenanceIDs
@@ -87,6 +135,13 @@ func (s *materialsProfileService) GetMaterialsProfiles(ctx context.Context, requ
 	if len(request.EquipmentMachineryIDs) > 0 {
 		filter.EquipmentMachineryIDs = request.EquipmentMachineryIDs
 	}
+	if request.IndexPath != "" {
+		root, err := utils.StringToIndexPath(request.IndexPath)
+		if err != nil {
+			return nil, err
+		}
+		filter.IndexFrom, filter.IndexTo = utils.IndexPathRange(root)
+	}
 	materialsProfiles, err := s.materialsProfileRepo.Filter(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -127,6 +182,14 @@ func (s *materialsProfileService) GetMaterialsProfiles(ctx context.Context, requ
 }
 
 func (s *materialsProfileService) CreateMaterialsProfile(ctx context.Context, request *types.CreateMaterialProfileReq) (string, error) {
+	return s.createMaterialsProfile(ctx, request, false)
+}
+
+func (s *materialsProfileService) InsertMaterialsProfile(ctx context.Context, request *types.CreateMaterialProfileReq) (string, error) {
+	return s.createMaterialsProfile(ctx, request, true)
+}
+
+func (s *materialsProfileService) createMaterialsProfile(ctx context.Context, request *types.CreateMaterialProfileReq, shiftSiblings bool) (string, error) {
 	maintenance, err := s.maintenanceRepo.FindByID(ctx, request.MaintenanceInstanceID)
 	if err != nil {
 		return "", err
@@ -136,16 +199,29 @@ func (s *materialsProfileService) CreateMaterialsProfile(ctx context.Context, re
 		return "", err
 	}
 
-	if maintenance == nil {
+	if maintenance.ID == "" {
 		return "", types.ErrMaintenanceNotFound
 	}
-	if equipmentMachinery == nil {
+	if equipmentMachinery.ID == "" {
 		return "", types.ErrSomeEquipmentMachineryNotFound
 	}
 	index, err := utils.StringToIndexPath(request.IndexPath)
 	if err != nil {
 		return "", err
 	}
+	var children []*types.EquipmentNode
+	if request.IncludeChildren {
+		if children, err = s.equipmentChildren(ctx, equipmentMachinery); err != nil {
+			return "", err
+		}
+	}
+	descendants, err := descendantProfiles(request.MaintenanceInstanceID, request.Sector, index, children)
+	if err != nil {
+		return "", err
+	}
+	if err := normalizeMaterials(ctx, s.catalogRepo, request.Estimate); err != nil {
+		return "", err
+	}
 	if request.Estimate.ConsumableSupplies == nil {
 		request.Estimate.ConsumableSupplies = map[string]types.Material{}
 	}
@@ -163,31 +239,448 @@ func (s *materialsProfileService) CreateMaterialsProfile(ctx context.Context, re
 			ReplacementMaterials: map[string]types.Material{},
 		},
 	}
-	materialProfileID, err := s.materialsProfileRepo.Save(ctx, materialProfile)
+	var materialProfileID string
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if shiftSiblings {
+			_, err = s.makeRoomAt(ctx, request.MaintenanceInstanceID, request.Sector, index)
+		} else if len(children) > 0 {
+			err = s.checkSubtreeFree(ctx, request.MaintenanceInstanceID, request.Sector, index)
+		} else {
+			err = s.checkIndexPathFree(ctx, request.MaintenanceInstanceID, request.Sector, index)
+		}
+		if err != nil {
+			return err
+		}
+		materialProfileID, err = s.materialsProfileRepo.Save(ctx, materialProfile)
+		if err != nil {
+			return err
+		}
+		if len(descendants) > 0 {
+			if _, err := s.materialsProfileRepo.SaveMany(ctx, descendants); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return "", err
 	}
 	return materialProfileID, nil
 }
 
+// equipmentChildren returns the children of an equipment in the hierarchy
+// of its sector, in order.
+func (s *materialsProfileService) equipmentChildren(ctx context.Context, equipmentMachinery *types.EquipmentMachinery) ([]*types.EquipmentNode, error) {
+	equipments, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{Sector: equipmentMachinery.Sector})
+	if err != nil {
+		return nil, err
+	}
+	var find func(nodes []*types.EquipmentNode) []*types.EquipmentNode
+	find = func(nodes []*types.EquipmentNode) []*types.EquipmentNode {
+		for _, node := range nodes {
+			if node.ID == equipmentMachinery.ID {
+				return node.Children
+			}
+			if children := find(node.Children); children != nil {
+				return children
+			}
+		}
+		return nil
+	}
+	return find(buildEquipmentTree(equipments)), nil
+}
+
+// descendantProfiles numbers empty profiles for the equipments below the
+// profile at index, e.g. 2.3.1 and 2.3.2 for the children of 2.3.
+func descendantProfiles(maintenanceInstanceID, sector string, index int64, children []*types.EquipmentNode) ([]*types.MaterialsProfile, error) {
+	profiles := make([]*types.MaterialsProfile, 0)
+	if len(children) == 0 {
+		return profiles, nil
+	}
+	depth := utils.IndexPathDepth(index)
+	if depth >= 10 || len(children) > 63 {
+		return nil, types.ErrIndexPathOverflow
+	}
+	step := utils.IndexPathStep(depth + 1)
+	for i, child := range children {
+		childIndex := index + int64(i+1)*step
+		profiles = append(profiles, &types.MaterialsProfile{
+			MaintenanceInstanceID: maintenanceInstanceID,
+			EquipmentMachineryID:  child.ID,
+			Index:                 childIndex,
+			Sector:                sector,
+			Estimate: types.MaterialsForEquipment{
+				ConsumableSupplies:   map[string]types.Material{},
+				ReplacementMaterials: map[string]types.Material{},
+			},
+			Reality: types.MaterialsForEquipment{
+				ConsumableSupplies:   map[string]types.Material{},
+				ReplacementMaterials: map[string]types.Material{},
+			},
+		})
+		below, err := descendantProfiles(maintenanceInstanceID, sector, childIndex, child.Children)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, below...)
+	}
+	return profiles, nil
+}
+
 func (s *materialsProfileService) UpdateMaterialsEstimateProfile(ctx context.Context, request *types.UpdateMaterialsEstimateProfileRequest) error {
 	// TODO: Implement UpdateMaterialsEstimateProfile
 	return types.ErrNotImplemented
 }
 
-func (s *materialsProfileService) UploadEstimateSheet(ctx context.Context, request *types.UploadEstimateSheetRequest) error {
+func (s *materialsProfileService) UploadEstimateSheet(ctx context.Context, request *types.UploadEstimateSheetRequest) (*types.EstimateImportResult, error) {
+	staged, err := s.stageEstimateSheet(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return s.runEstimateSheetImport(ctx, staged, noopImportReporter{})
+}
+
+func (s *materialsProfileService) StartEstimateSheetImport(ctx context.Context, request *types.UploadEstimateSheetRequest) (*types.ImportJob, error) {
+	staged, err := s.stageEstimateSheet(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return s.importJobService.Start(ctx, staged.importJob(), func(ctx context.Context, reporter ImportReporter) (any, error) {
+		return s.runEstimateSheetImport(ctx, staged, reporter)
+	})
+}
+
+// stagedEstimateUpload is an upload whose request was validated and whose
+// file was stored, ready to be imported inside the request or in a job.
+type stagedEstimateUpload struct {
+	maintenance *types.Maintenance
+	upload      *types.EstimateUpload
+	// previous is the earlier upload of the same file whose result is
+	// returned instead of importing again
+	previous *types.EstimateUpload
+}
+
+func (u *stagedEstimateUpload) importJob() *types.ImportJob {
+	return &types.ImportJob{
+		Kind:                  u.upload.Kind,
+		MaintenanceInstanceID: u.upload.MaintenanceInstanceID,
+		FileName:              u.upload.FileName,
+		CreatedBy:             u.upload.UploadedBy,
+	}
+}
+
+func (s *materialsProfileService) stageEstimateSheet(ctx context.Context, request *types.UploadEstimateSheetRequest) (*stagedEstimateUpload, error) {
 	if !utils.Contains(types.SECTOR_LIST, request.Sector) {
-		return types.ErrInvalidSector
+		return nil, types.ErrInvalidSector
 	}
 
 	maintenance, err := s.maintenanceRepo.FindByID(ctx, request.MaintenanceInstanceID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if maintenance.ID == "" {
+		return nil, types.ErrMaintenanceNotFound
+	}
+
+	upload, err := newEstimateUpload(ctx, maintenance, request.Sheet, types.ESTIMATE_UPLOAD_KIND_SHEET)
+	if err != nil {
+		return nil, err
+	}
+	upload.SheetName = request.SheetName
+	upload.Sector = request.Sector
+	upload.ImportProfileID = request.ImportProfileID
+	return s.stageEstimateUpload(ctx, maintenance, upload, request.Sheet, request.Sector, request.Force)
+}
+
+// stageEstimateUpload stores the uploaded file unless the same file was
+// imported before with the same options and the import is not forced.
+func (s *materialsProfileService) stageEstimateUpload(ctx context.Context, maintenance *types.Maintenance, upload *types.EstimateUpload, sheet *multipart.FileHeader, name string, force bool) (*stagedEstimateUpload, error) {
+	staged := &stagedEstimateUpload{
+		maintenance: maintenance,
+		upload:      upload,
+	}
+	previous, err := s.findPreviousUpload(ctx, upload)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		if !force {
+			staged.previous = previous
+			return staged, nil
+		}
+		upload.DuplicateOf = previous.ID
+	}
+
+	upload.FilePath, err = s.saveEstimateUpload(ctx, maintenance, sheet, name, upload.ContentHash)
+	if err != nil {
+		return nil, err
 	}
-	if maintenance == nil {
-		return types.ErrMaintenanceNotFound
+	return staged, nil
+}
+
+func (s *materialsProfileService) runEstimateSheetImport(ctx context.Context, staged *stagedEstimateUpload, reporter ImportReporter) (*types.EstimateImportResult, error) {
+	if previous := staged.previous; previous != nil {
+		result := *previous.Result
+		result.UploadID = previous.ID
+		result.Duplicate = true
+		return &result, nil
 	}
 
+	upload := staged.upload
+	result, err := s.importEstimateSheet(ctx, staged.maintenance, upload, reporter)
+	upload.Result = result
+	uploadID, err := s.recordEstimateUpload(context.WithoutCancel(ctx), upload, err)
+	if err != nil {
+		return nil, err
+	}
+	result.UploadID = uploadID
+	return result, nil
+}
+
+func (s *materialsProfileService) importEstimateSheet(ctx context.Context, maintenance *types.Maintenance, upload *types.EstimateUpload, reporter ImportReporter) (*types.EstimateImportResult, error) {
+	f, err := excelize.OpenFile(upload.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	planner := newEstimateImportPlanner(s.materialsProfileRepo, s.equipmentMachineryRepo, s.catalogRepo, s.transactor, maintenance, reporter)
+	if err := s.planEstimateSheet(ctx, planner, f, upload.SheetName, upload.Sector, upload.ImportProfileID); err != nil {
+		return nil, err
+	}
+	// once writing started the import is completed even when cancelled
+	results, err := planner.commit(context.WithoutCancel(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+func (s *materialsProfileService) UploadEstimateWorkbook(ctx context.Context, request *types.UploadEstimateWorkbookRequest) (*types.EstimateWorkbookImportResult, error) {
+	staged, err := s.stageEstimateWorkbook(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return s.runEstimateWorkbookImport(ctx, staged, noopImportReporter{})
+}
+
+func (s *materialsProfileService) StartEstimateWorkbookImport(ctx context.Context, request *types.UploadEstimateWorkbookRequest) (*types.ImportJob, error) {
+	staged, err := s.stageEstimateWorkbook(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return s.importJobService.Start(ctx, staged.importJob(), func(ctx context.Context, reporter ImportReporter) (any, error) {
+		return s.runEstimateWorkbookImport(ctx, staged, reporter)
+	})
+}
+
+func (s *materialsProfileService) stageEstimateWorkbook(ctx context.Context, request *types.UploadEstimateWorkbookRequest) (*stagedEstimateUpload, error) {
+	for _, sector := range request.SheetSectors {
+		if !utils.Contains(types.SECTOR_LIST, sector) {
+			return nil, types.ErrInvalidSector
+		}
+	}
+
+	maintenance, err := s.maintenanceRepo.FindByID(ctx, request.MaintenanceInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if maintenance.ID == "" {
+		return nil, types.ErrMaintenanceNotFound
+	}
+
+	upload, err := newEstimateUpload(ctx, maintenance, request.Sheet, types.ESTIMATE_UPLOAD_KIND_WORKBOOK)
+	if err != nil {
+		return nil, err
+	}
+	upload.SheetSectors = request.SheetSectors
+	upload.ImportProfileID = request.ImportProfileID
+	return s.stageEstimateUpload(ctx, maintenance, upload, request.Sheet, types.ESTIMATE_UPLOAD_KIND_WORKBOOK, request.Force)
+}
+
+func (s *materialsProfileService) runEstimateWorkbookImport(ctx context.Context, staged *stagedEstimateUpload, reporter ImportReporter) (*types.EstimateWorkbookImportResult, error) {
+	if previous := staged.previous; previous != nil {
+		result := *previous.WorkbookResult
+		result.UploadID = previous.ID
+		result.Duplicate = true
+		return &result, nil
+	}
+
+	upload := staged.upload
+	result, err := s.importEstimateWorkbook(ctx, staged.maintenance, upload, reporter)
+	upload.WorkbookResult = result
+	uploadID, err := s.recordEstimateUpload(context.WithoutCancel(ctx), upload, err)
+	if err != nil {
+		return nil, err
+	}
+	result.UploadID = uploadID
+	return result, nil
+}
+
+func (s *materialsProfileService) importEstimateWorkbook(ctx context.Context, maintenance *types.Maintenance, upload *types.EstimateUpload, reporter ImportReporter) (*types.EstimateWorkbookImportResult, error) {
+	f, err := excelize.OpenFile(upload.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheetNames := f.GetSheetList()
+	existingSheets := make(map[string]struct{}, len(sheetNames))
+	for _, sheetName := range sheetNames {
+		existingSheets[sheetName] = struct{}{}
+	}
+	response := &types.EstimateWorkbookImportResult{
+		SkippedSheets: make([]string, 0),
+	}
+	sheetSectors := make(map[string]string)
+	if len(upload.SheetSectors) > 0 {
+		for sheetName, sector := range upload.SheetSectors {
+			if _, ok := existingSheets[sheetName]; !ok {
+				return nil, fmt.Errorf("%w: %s", types.ErrSheetNotFound, sheetName)
+			}
+			sheetSectors[sheetName] = sector
+		}
+		for _, sheetName := range sheetNames {
+			if _, ok := sheetSectors[sheetName]; !ok {
+				response.SkippedSheets = append(response.SkippedSheets, sheetName)
+			}
+		}
+	} else {
+		for _, sheetName := range sheetNames {
+			sector, ok := sectorFromSheetName(sheetName)
+			if !ok {
+				response.SkippedSheets = append(response.SkippedSheets, sheetName)
+				continue
+			}
+			sheetSectors[sheetName] = sector
+		}
+	}
+	if len(sheetSectors) == 0 {
+		return nil, types.ErrNoSectorSheets
+	}
+	for _, sheetName := range response.SkippedSheets {
+		reporter.Warn(fmt.Sprintf("sheet %s: skipped, it is not mapped to a sector", sheetName))
+	}
+
+	// Every sheet is parsed and resolved before the first write so that a
+	// broken tab leaves the database untouched.
+	planner := newEstimateImportPlanner(s.materialsProfileRepo, s.equipmentMachineryRepo, s.catalogRepo, s.transactor, maintenance, reporter)
+	for _, sheetName := range sheetNames {
+		sector, ok := sheetSectors[sheetName]
+		if !ok {
+			continue
+		}
+		if err := s.planEstimateSheet(ctx, planner, f, sheetName, sector, upload.ImportProfileID); err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", sheetName, err)
+		}
+	}
+	response.Sheets, err = planner.commit(context.WithoutCancel(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *materialsProfileService) EstimateUploadHistory(ctx context.Context, request *types.EstimateUploadHistoryReq) ([]*types.EstimateUploadResponse, error) {
+	uploads, err := s.estimateUploadRepo.FindByMaintenance(ctx, request.MaintenanceInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]*types.EstimateUploadResponse, 0, len(uploads))
+	for _, upload := range uploads {
+		history = append(history, &types.EstimateUploadResponse{
+			EstimateUpload: upload,
+			DownloadURL:    fmt.Sprintf(estimateUploadDownloadURL, upload.ID),
+		})
+	}
+	return history, nil
+}
+
+func (s *materialsProfileService) OpenEstimateUpload(ctx context.Context, id string) (*types.EstimateUpload, *os.File, error) {
+	upload, err := s.estimateUploadRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if upload == nil || upload.FilePath == "" {
+		return nil, nil, types.ErrEstimateUploadNotFound
+	}
+	file, err := os.Open(upload.FilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, types.ErrEstimateUploadNotFound
+		}
+		return nil, nil, err
+	}
+	return upload, file, nil
+}
+
+// newEstimateUpload hashes an uploaded file and describes it, the file itself
+// is only stored once it is known to be imported.
+func newEstimateUpload(ctx context.Context, maintenance *types.Maintenance, sheet *multipart.FileHeader, kind string) (*types.EstimateUpload, error) {
+	file, err := sheet.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	contentHash, err := utils.ContentHash(file)
+	if err != nil {
+		return nil, err
+	}
+	upload := &types.EstimateUpload{
+		MaintenanceInstanceID: maintenance.ID,
+		Kind:                  kind,
+		FileName:              sheet.Filename,
+		ContentHash:           contentHash,
+		Size:                  sheet.Size,
+	}
+	if user, ok := ctx.Value("user").(*types.User); ok {
+		upload.UploadedBy = user.Username
+	}
+	return upload, nil
+}
+
+// findPreviousUpload returns the latest successful upload of the same file
+// imported with the same options, or nil.
+func (s *materialsProfileService) findPreviousUpload(ctx context.Context, upload *types.EstimateUpload) (*types.EstimateUpload, error) {
+	previous, err := s.estimateUploadRepo.FindByContentHash(ctx, upload.MaintenanceInstanceID, upload.ContentHash)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range previous {
+		if candidate.Kind != upload.Kind ||
+			candidate.SheetName != upload.SheetName ||
+			candidate.Sector != upload.Sector ||
+			candidate.ImportProfileID != upload.ImportProfileID ||
+			!maps.Equal(candidate.SheetSectors, upload.SheetSectors) {
+			continue
+		}
+		if candidate.Result == nil && candidate.WorkbookResult == nil {
+			continue
+		}
+		return candidate, nil
+	}
+	return nil, nil
+}
+
+// recordEstimateUpload adds an upload to the history. Failed imports are kept
+// as well so the history shows every file, the import error is returned.
+func (s *materialsProfileService) recordEstimateUpload(ctx context.Context, upload *types.EstimateUpload, importErr error) (string, error) {
+	upload.UploadedAt = time.Now().Unix()
+	if importErr != nil {
+		upload.Result = nil
+		upload.WorkbookResult = nil
+		upload.Error = importErr.Error()
+		_, _ = s.estimateUploadRepo.Save(ctx, upload)
+		return "", importErr
+	}
+	return s.estimateUploadRepo.Save(ctx, upload)
+}
+
+// saveEstimateUpload stores the uploaded workbook under
+// <tier>_<code>_<number>/<year>/<name>_<date>_<hash prefix>, the hash keeps
+// different files uploaded on the same day apart.
+func (s *materialsProfileService) saveEstimateUpload(ctx context.Context, maintenance *types.Maintenance, sheet *multipart.FileHeader, name, contentHash string) (string, error) {
 	saveDir := path.Join(
 		maintenance.MaintenanceTier+"_"+
 			maintenance.ProjectCode+"_"+
@@ -196,126 +689,134 @@ func (s *materialsProfileService) UploadEstimateSheet(ctx context.Context, reque
 	)
 	saveDir = strings.ReplaceAll(saveDir, " ", "_")
 
-	// file name is materials_estimate_ + full date time
-	fileName := fmt.Sprintf("%s_%s", request.Sector, time.Now().Format("2006-01-02"))
+	fileName := fmt.Sprintf("%s_%s_%s", name, time.Now().Format("2006-01-02"), contentHash[:12])
 
-	sheetPath, err := s.uploadService.UploadFile(ctx, request.Sheet, saveDir, fileName)
-	if err != nil {
-		return err
-	}
+	return s.uploadService.UploadFile(ctx, sheet, saveDir, fileName)
+}
 
-	f, err := excelize.OpenFile(sheetPath)
+// planEstimateSheet streams the rows of a sheet into the planner. When no
+// import profile is requested, the rows up to the deepest header row of the
+// stored profiles are held back to detect the layout.
+func (s *materialsProfileService) planEstimateSheet(ctx context.Context, planner *estimateImportPlanner, f *excelize.File, sheetName, sector, importProfileID string) error {
+	importProfile, candidates, err := s.resolveImportProfile(ctx, importProfileID)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	rows, err := f.GetRows(request.SheetName)
+	headerRows := 0
+	if importProfile == nil {
+		for _, candidate := range candidates {
+			headerRows = max(headerRows, candidate.HeaderRow)
+		}
+	}
+
+	rows, err := f.Rows(sheetName)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	var materialsProfilesMap = make(map[string]*types.MaterialsProfile)
-	var equipmentNameToID = make(map[string]string)
-
-	indexRegex := regexp.MustCompile(`^\d+(\.\d+)*$`)
-	currentEquipmentMachineryName := ""
-	currentMaterialType := ""
-	lastIndexStr := ""
-	for _, row := range rows[1:] {
-		indexCell := strings.TrimSpace(row[0])
-		titleCell := strings.TrimSpace(row[1])
-		// check indexCell match regex like "1.1", "2.3.4", etc
-		indexStr := indexRegex.FindString(indexCell)
-		if indexStr != "" {
-			lastIndexStr = indexStr
-			currentEquipmentMachineryName = titleCell
-			eqs, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{
-				Name:   currentEquipmentMachineryName,
-				Sector: request.Sector,
-			})
-			if err != nil {
+	var parser *estimateSheetParser
+	header := make([][]string, 0, headerRows)
+	rowNumber, dataRows := 0, 0
+	feed := func(row []string) error {
+		rowNumber++
+		if rowNumber > parser.layout.headerRow {
+			dataRows++
+			planner.reporter.AddRows(1)
+		}
+		return parser.parseRow(rowNumber, row)
+	}
+	start := func() error {
+		if importProfile == nil {
+			importProfile = detectImportProfile(header, candidates)
+		}
+		layout, err := newEstimateSheetLayout(importProfile)
+		if err != nil {
+			return err
+		}
+		parser = newEstimateSheetParser(layout)
+		for _, row := range header {
+			if err := feed(row); err != nil {
 				return err
 			}
-			if len(eqs) == 0 {
-				// Equipment not found, create new one
-				eqID, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{
-					Name:   currentEquipmentMachineryName,
-					Sector: request.Sector,
-				})
-				if err != nil {
-					return err
-				}
-				equipmentNameToID[currentEquipmentMachineryName] = eqID
-
-			} else {
-				equipmentNameToID[currentEquipmentMachineryName] = eqs[0].ID
-			}
-			s.ensureMaterialsProfile(ctx, currentEquipmentMachineryName, materialsProfilesMap, maintenance.ID, equipmentNameToID[currentEquipmentMachineryName], request.Sector, lastIndexStr)
-			currentMaterialType = ""
 		}
-		if strings.Contains(strings.ToLower(titleCell), types.LABEL_REPLACEMENT) {
-			currentMaterialType = types.LABEL_REPLACEMENT
-			// s.ensureMaterialsProfile(ctx, currentEquipmentMachineryName, materialsProfilesMap, maintenance[0].ID, equipmentNameToID[currentEquipmentMachineryName], request.Sector, lastIndexStr)
+		return nil
+	}
+	for rows.Next() {
+		if ctx.Err() != nil {
+			return types.ErrImportCancelled
 		}
-		if strings.Contains(strings.ToLower(titleCell), types.LABEL_CONSUMABLE) {
-			currentMaterialType = types.LABEL_CONSUMABLE
-			// s.ensureMaterialsProfile(ctx, currentEquipmentMachineryName, materialsProfilesMap, maintenance[0].ID, equipmentNameToID[currentEquipmentMachineryName], request.Sector, lastIndexStr)
+		row, err := rows.Columns()
+		if err != nil {
+			return err
 		}
-		if currentMaterialType == types.LABEL_CONSUMABLE && indexCell == "-" {
-			materialQuantity := 0.0
-			if len(row) < 3 {
-				continue // Skip rows that do not have enough columns
-			}
-			materialUnit := strings.ToLower(strings.TrimSpace(row[2]))
-			if len(row) >= 4 {
-				materialQuantity, err = strconv.ParseFloat(strings.TrimSpace(row[3]), 64)
-				if err != nil {
-					return err
-				}
-			}
-			materialsProfilesMap[currentEquipmentMachineryName].Estimate.ConsumableSupplies[titleCell] = types.Material{
-				Name:     titleCell,
-				Unit:     materialUnit,
-				Quantity: materialQuantity,
+		if parser != nil {
+			if err := feed(row); err != nil {
+				return err
 			}
-
+			continue
 		}
-		if currentMaterialType == types.LABEL_REPLACEMENT && indexCell == "-" {
-			if len(row) < 4 {
-				continue // Skip rows that do not have enough columns
-			}
-			materialUnit := strings.ToLower(strings.TrimSpace(row[2]))
-			materialQuantity, err := strconv.ParseFloat(strings.TrimSpace(row[3]), 64)
-			if err != nil {
+		header = append(header, row)
+		if len(header) >= headerRows {
+			if err := start(); err != nil {
 				return err
 			}
-			materialsProfilesMap[currentEquipmentMachineryName].Estimate.ReplacementMaterials[titleCell] = types.Material{
-				Name:     titleCell,
-				Unit:     materialUnit,
-				Quantity: materialQuantity,
-			}
 		}
-
 	}
-
-	materialsProfileList := make([]*types.MaterialsProfile, 0, len(materialsProfilesMap))
-	materialsProfileIds := make([]string, 0, len(materialsProfilesMap))
-	for _, materialsProfile := range materialsProfilesMap {
-		materialsProfileList = append(materialsProfileList, materialsProfile)
-		materialsProfileIds = append(materialsProfileIds, materialsProfile.ID)
+	if err := rows.Error(); err != nil {
+		return err
+	}
+	if parser == nil {
+		// the sheet ends before the header row of some profiles
+		if err := start(); err != nil {
+			return err
+		}
 	}
 
-	err = s.materialsProfileRepo.UpdateMany(ctx, materialsProfileIds, materialsProfileList)
-	if err != nil {
+	for _, warning := range parser.warnings {
+		planner.reporter.Warn(fmt.Sprintf("sheet %s: %s", sheetName, warning))
+	}
+	if _, err := planner.plan(ctx, sheetName, sector, importProfile.Name, parser.equipments); err != nil {
 		return err
 	}
-
+	// rows outside of equipment blocks are processed as well
+	for _, equipment := range parser.equipments {
+		dataRows -= equipment.Rows
+	}
+	planner.reporter.Advance(dataRows)
 	return nil
+}
 
+// resolveImportProfile loads the requested import profile. When no profile is
+// requested it returns the stored profiles instead, to be detected from the
+// sheet header.
+func (s *materialsProfileService) resolveImportProfile(ctx context.Context, importProfileID string) (*types.ImportProfile, []*types.ImportProfile, error) {
+	if importProfileID != "" {
+		importProfile, err := s.importProfileRepo.FindByID(ctx, importProfileID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if importProfile == nil {
+			return nil, nil, types.ErrImportProfileNotFound
+		}
+		return importProfile, nil, nil
+	}
+	importProfiles, err := s.importProfileRepo.FindAll(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, importProfiles, nil
 }
 
-func (s *materialsProfileService) PaginatedMaterialsProfiles(ctx context.Context, request *types.PaginatedRequest) ([]*types.MaterialsProfileResponse, int64, error) {
-	filter := &types.MaterialsProfileFilter{}
+func (s *materialsProfileService) PaginatedMaterialsProfiles(ctx context.Context, request *types.MaterialsProfileSearchRequest) ([]*types.MaterialsProfileResponse, int64, error) {
+	filter, err := s.searchFilter(ctx, request)
+	if err != nil {
+		return nil, 0, err
+	}
+	if filter == nil {
+		// the maintenance or equipment criteria match nothing
+		return []*types.MaterialsProfileResponse{}, 0, nil
+	}
 	materialsProfiles, total, err := s.materialsProfileRepo.Paginate(ctx, filter, request.Page, request.Limit)
 	if err != nil {
 		return nil, 0, err
@@ -341,7 +842,7 @@ func (s *materialsProfileService) PaginatedMaterialsProfiles(ctx context.Context
 	}
 
 	// Map materials profiles to response format
-	var responses []*types.MaterialsProfileResponse
+	responses := make([]*types.MaterialsProfileResponse, 0, len(materialsProfiles))
 
 	for _, profile := range materialsProfiles {
 		maintenance, ok := maintenances[profile.MaintenanceInstanceID]
@@ -371,36 +872,777 @@ func (s *materialsProfileService) PaginatedMaterialsProfiles(ctx context.Context
 	return responses, total, nil
 }
 
-func (s *materialsProfileService) ensureMaterialsProfile(ctx context.Context, equipmentName string, materialsProfilesMap map[string]*types.MaterialsProfile, maintenanceID, equipmentID, sector, indexPathStr string) {
-	if _, exists := materialsProfilesMap[equipmentName]; !exists {
-		materialsProfilesFromDb, _ := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
-			MaintenanceInstanceIDs: []string{maintenanceID},
-			EquipmentMachineryIDs:  []string{equipmentID},
+// searchFilter resolves the maintenance and equipment criteria of a search
+// to IDs. It returns nil when they cannot match any profile.
+func (s *materialsProfileService) searchFilter(ctx context.Context, request *types.MaterialsProfileSearchRequest) (*types.MaterialsProfileFilter, error) {
+	if request.Sector != "" && !utils.Contains(types.SECTOR_LIST, request.Sector) {
+		return nil, types.ErrInvalidSector
+	}
+	if request.SortBy != "" && !utils.Contains(types.MATERIALS_PROFILE_SORT_LIST, request.SortBy) {
+		return nil, types.ErrInvalidSortField
+	}
+	filter := &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: request.MaintenanceIDs,
+		Sector:                 request.Sector,
+		IncludeArchived:        request.IncludeArchived,
+		MaterialName:           strings.TrimSpace(request.MaterialName),
+		HasOverrun:             request.HasOverrun,
+		NoEstimate:             request.NoEstimate,
+		SortBy:                 request.SortBy,
+		SortDesc:               request.SortDesc,
+	}
+	if request.IndexPath != "" {
+		root, err := utils.StringToIndexPath(request.IndexPath)
+		if err != nil {
+			return nil, err
+		}
+		filter.IndexFrom, filter.IndexTo = utils.IndexPathRange(root)
+	}
+
+	if request.ProjectCode != "" || request.MaintenanceTier != "" || request.Year != 0 {
+		maintenances, err := s.maintenanceRepo.Filter(ctx, &types.MaintenanceFilter{
+			ProjectCode:     request.ProjectCode,
+			MaintenanceTier: request.MaintenanceTier,
+			Year:            request.Year,
+		})
+		if err != nil {
+			return nil, err
+		}
+		maintenanceIds := make([]string, 0, len(maintenances))
+		for _, maintenance := range maintenances {
+			if len(request.MaintenanceIDs) == 0 || utils.Contains(request.MaintenanceIDs, maintenance.ID) {
+				maintenanceIds = append(maintenanceIds, maintenance.ID)
+			}
+		}
+		if len(maintenanceIds) == 0 {
+			return nil, nil
+		}
+		filter.MaintenanceInstanceIDs = maintenanceIds
+	}
+
+	if name := strings.TrimSpace(request.EquipmentName); name != "" {
+		equipmentMachineries, err := s.equipmentMachineryRepo.Filter(ctx, &types.EquipmentMachineryFilter{
+			Name:   name,
+			Sector: request.Sector,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(equipmentMachineries) == 0 {
+			return nil, nil
+		}
+		for _, em := range equipmentMachineries {
+			filter.EquipmentMachineryIDs = append(filter.EquipmentMachineryIDs, em.ID)
+		}
+	}
+	return filter, nil
+}
+
+func (s *materialsProfileService) ExportEstimateSheet(ctx context.Context, request *types.ExportEstimateSheetReq) (*os.File, error) {
+	if !utils.Contains(types.SECTOR_LIST, request.Sector) {
+		return nil, types.ErrInvalidSector
+	}
+	maintenance, err := s.maintenanceRepo.FindByID(ctx, request.MaintenanceInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if maintenance.ID == "" {
+		return nil, types.ErrMaintenanceNotFound
+	}
+	materialsProfiles, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{request.MaintenanceInstanceID},
+		Sector:                 request.Sector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	emIds := make([]string, 0, len(materialsProfiles))
+	for _, mp := range materialsProfiles {
+		emIds = append(emIds, mp.EquipmentMachineryID)
+	}
+	equipmentMachineries, err := s.equipmentMachineryRepo.FindByIDs(ctx, utils.RemoveDuplicates(emIds))
+	if err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	// The sheet is named after the sector so the file can be re-uploaded as a workbook
+	sheetName := request.Sector
+	if err := f.SetSheetName(f.GetSheetName(0), sheetName); err != nil {
+		return nil, err
+	}
+
+	var book *priceBook
+	pricedAt := time.Now().Unix()
+	if request.WithCost {
+		materials := make([]types.MaterialsForEquipment, 0, 2*len(materialsProfiles))
+		for _, mp := range materialsProfiles {
+			materials = append(materials, mp.Estimate, mp.Reality)
+		}
+		book, err = loadPriceBook(ctx, s.catalogRepo, s.priceRepo, materials...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sheet := newSheetWriter(f, sheetName)
+	header := []interface{}{"STT", "Tên thiết bị, vật tư", "ĐVT", "Dự toán", "Thực tế", "Còn lại"}
+	if book != nil {
+		// cost columns come after the columns read back on re-import
+		header = append(header, "Đơn giá", "Thành tiền dự toán", "Thành tiền thực tế")
+	}
+	sheet.writeRow(header...)
+	for _, mp := range materialsProfiles {
+		indexPath := utils.IndexPathToString(mp.Index)
+		if indexPath == "" {
+			indexPath = "0"
+		}
+		equipmentName := ""
+		if em, ok := equipmentMachineries[mp.EquipmentMachineryID]; ok {
+			equipmentName = em.Name
+		}
+		sheet.writeRow(indexPath, equipmentName)
+		sections := []struct {
+			label        string
+			materialType string
+			estimate     map[string]types.Material
+			reality      map[string]types.Material
+		}{
+			{types.LABEL_REPLACEMENT, types.MATERIAL_TYPE_REPLACEMENT, mp.Estimate.ReplacementMaterials, mp.Reality.ReplacementMaterials},
+			{types.LABEL_CONSUMABLE, types.MATERIAL_TYPE_CONSUMABLE, mp.Estimate.ConsumableSupplies, mp.Reality.ConsumableSupplies},
+		}
+		for _, section := range sections {
+			if len(section.estimate) == 0 && len(section.reality) == 0 {
+				continue
+			}
+			sheet.writeRow("", strings.ToUpper(section.label))
+			for _, name := range sortedMaterialNames(section.estimate, section.reality) {
+				estimate, hasEstimate := section.estimate[name]
+				reality, hasReality := section.reality[name]
+				unit := estimate.Unit
+				if !hasEstimate {
+					unit = reality.Unit
+				}
+				// Lines consumed without estimate keep an empty estimate cell
+				// so that they are skipped on re-import
+				var estimateCell interface{} = ""
+				if hasEstimate {
+					estimateCell = estimate.Quantity
+				}
+				var cells []interface{}
+				realityQuantity, ok := reality.Quantity, true
+				if hasEstimate && reality.Unit != "" {
+					realityQuantity, ok = utils.ConvertQuantity(reality.Quantity, reality.Unit, estimate.Unit, nil)
+				}
+				if ok {
+					// nothing remains of a line consumed without estimate
+					var remainingCell interface{} = ""
+					if hasEstimate {
+						remainingCell = estimate.Quantity - realityQuantity
+					}
+					cells = []interface{}{"-", name, unit, estimateCell, realityQuantity, remainingCell}
+				} else {
+					// a reality in another unit is shown as is and not compared
+					cells = []interface{}{"-", name, unit, estimateCell, fmt.Sprintf("%g %s", reality.Quantity, reality.Unit), ""}
+				}
+				if book != nil {
+					cells = append(cells, "", "", "")
+					if hasEstimate {
+						if line := book.line(section.materialType, estimate, pricedAt); line.Priced {
+							cells[6], cells[7] = line.UnitPrice.Float64(), line.Cost.Float64()
+						}
+					}
+					if hasReality {
+						if line := book.line(section.materialType, reality, pricedAt); line.Priced {
+							cells[8] = line.Cost.Float64()
+							if !hasEstimate {
+								cells[6] = line.UnitPrice.Float64()
+							}
+						}
+					}
+				}
+				sheet.writeRow(cells...)
+			}
+		}
+	}
+	if sheet.err != nil {
+		return nil, sheet.err
+	}
+
+	tempDir := os.TempDir()
+	saveDir := path.Join(tempDir, "materials_profile")
+	// create dir if not exist
+	if err := os.MkdirAll(saveDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	fileName := path.Join(
+		saveDir,
+		strings.ReplaceAll(
+			fmt.Sprintf(
+				"%s_%s_%s_%s.xlsx",
+				types.ShortSectorList[request.Sector],
+				maintenance.MaintenanceTier,
+				maintenance.ProjectCode,
+				maintenance.MaintenanceNumber,
+			),
+			" ", "_",
+		),
+	)
+	if err := f.SaveAs(fileName); err != nil {
+		return nil, err
+	}
+	return os.Open(fileName)
+}
+
+// MoveMaterialsProfile moves the profile at an index path together with its
+// subtree, e.g. "2.3" -> "4" turns "2.3.1" into "4.1". Moves within the same
+// depth are a single bulk update of the encoded index.
+func (s *materialsProfileService) MoveMaterialsProfile(ctx context.Context, request *types.MoveMaterialsProfileReq) error {
+	from, err := utils.StringToIndexPath(request.From)
+	if err != nil {
+		return err
+	}
+	to, err := utils.StringToIndexPath(request.To)
+	if err != nil {
+		return err
+	}
+	fromStart, fromEnd := utils.IndexPathRange(from)
+	if to >= fromStart && to <= fromEnd {
+		return types.ErrInvalidIndexMove
+	}
+	// archived profiles keep their index path, so they move with the others
+	subtree, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{request.MaintenanceInstanceID},
+		Sector:                 request.Sector,
+		IndexFrom:              fromStart,
+		IndexTo:                fromEnd,
+		IncludeArchived:        true,
+	})
+	if err != nil {
+		return err
+	}
+	if len(subtree) == 0 {
+		return types.ErrIndexPathNotFound
+	}
+	// validate the depth of the whole subtree before writing anything
+	for _, mp := range subtree {
+		if _, err := utils.IndexPathRebase(mp.Index, from, to); err != nil {
+			return err
+		}
+	}
+
+	return s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		var shift int64
+		if request.ShiftSiblings {
+			shifted, err := s.makeRoomAt(ctx, request.MaintenanceInstanceID, request.Sector, to)
+			if err != nil {
+				return err
+			}
+			// the moved subtree may be among the shifted siblings
+			if shiftStart, shiftEnd := siblingsFrom(to); shifted && from >= shiftStart && from <= shiftEnd {
+				shift = utils.IndexPathStep(utils.IndexPathDepth(to))
+			}
+		} else if err := s.checkSubtreeFree(ctx, request.MaintenanceInstanceID, request.Sector, to); err != nil {
+			return err
+		}
+
+		if utils.IndexPathDepth(from) == utils.IndexPathDepth(to) {
+			return s.materialsProfileRepo.ShiftIndex(ctx, request.MaintenanceInstanceID, request.Sector, fromStart+shift, fromEnd+shift, to-from-shift)
+		}
+		ids := make([]string, len(subtree))
+		indexes := make([]int64, len(subtree))
+		for i, mp := range subtree {
+			ids[i] = mp.ID
+			indexes[i], err = utils.IndexPathRebase(mp.Index+shift, from+shift, to)
+			if err != nil {
+				return err
+			}
+		}
+		return s.materialsProfileRepo.UpdateIndexes(ctx, ids, indexes)
+	})
+}
+
+// ValidateIndexPaths lists the index paths shared by several profiles of a
+// maintenance sector, archived ones included as they keep their index path.
+func (s *materialsProfileService) ValidateIndexPaths(ctx context.Context, request *types.ValidateIndexPathsReq) ([]*types.DuplicateIndexPath, error) {
+	materialsProfiles, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{request.MaintenanceInstanceID},
+		Sector:                 request.Sector,
+		IncludeArchived:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	duplicates := make([]*types.DuplicateIndexPath, 0)
+	// profiles are sorted by index, so duplicates are adjacent
+	for i := 0; i < len(materialsProfiles); {
+		j := i + 1
+		for j < len(materialsProfiles) && materialsProfiles[j].Index == materialsProfiles[i].Index {
+			j++
+		}
+		if j-i > 1 && materialsProfiles[i].Index != 0 {
+			duplicate := &types.DuplicateIndexPath{
+				IndexPath:           utils.IndexPathToString(materialsProfiles[i].Index),
+				MaterialsProfileIDs: make([]string, 0, j-i),
+			}
+			for _, mp := range materialsProfiles[i:j] {
+				duplicate.MaterialsProfileIDs = append(duplicate.MaterialsProfileIDs, mp.ID)
+			}
+			duplicates = append(duplicates, duplicate)
+		}
+		i = j
+	}
+	return duplicates, nil
+}
+
+// CopyEstimates copies the profiles (index, equipment and estimate) of the
+// chosen sectors of a maintenance into another one. Profiles whose equipment
+// already has a profile in the target sector, or whose index path is taken
+// there, are reported, not duplicated.
+func (s *materialsProfileService) CopyEstimates(ctx context.Context, request *types.CopyEstimatesReq) (*types.CopyEstimatesResult, error) {
+	if request.SourceMaintenanceInstanceID == request.TargetMaintenanceInstanceID {
+		return nil, types.ErrSameMaintenance
+	}
+	if request.ScaleFactor < 0 {
+		return nil, types.ErrInvalidScaleFactor
+	}
+	scale := request.ScaleFactor
+	if scale == 0 {
+		scale = 1
+	}
+	for _, sector := range request.Sectors {
+		if !utils.Contains(types.SECTOR_LIST, sector) {
+			return nil, types.ErrInvalidSector
+		}
+	}
+	ids := []string{request.SourceMaintenanceInstanceID, request.TargetMaintenanceInstanceID}
+	maintenances, err := s.maintenanceRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if maintenances[id] == nil {
+			return nil, types.ErrMaintenanceNotFound
+		}
+	}
+
+	sectors := request.Sectors
+	if len(sectors) == 0 {
+		sectors = []string{""}
+	}
+	sourceProfiles := make([]*types.MaterialsProfile, 0)
+	targetProfiles := make([]*types.MaterialsProfile, 0)
+	for _, sector := range sectors {
+		source, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+			MaintenanceInstanceIDs: []string{request.SourceMaintenanceInstanceID},
 			Sector:                 sector,
 		})
-		if len(materialsProfilesFromDb) > 0 {
-			materialsProfilesMap[equipmentName] = materialsProfilesFromDb[0]
+		if err != nil {
+			return nil, err
+		}
+		sourceProfiles = append(sourceProfiles, source...)
+		// archived profiles keep their index path, see countIndexRange
+		existing, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+			MaintenanceInstanceIDs: []string{request.TargetMaintenanceInstanceID},
+			Sector:                 sector,
+			IncludeArchived:        true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		targetProfiles = append(targetProfiles, existing...)
+	}
+	existingProfiles := make(map[string]string, len(targetProfiles))
+	takenIndexes := make(map[sectorIndex]string, len(targetProfiles))
+	for _, mp := range targetProfiles {
+		takenIndexes[sectorIndex{mp.Sector, mp.Index}] = mp.ID
+		if !mp.Archived {
+			existingProfiles[mp.Sector+"\x00"+mp.EquipmentMachineryID] = mp.ID
+		}
+	}
+
+	result := &types.CopyEstimatesResult{
+		Skipped: make([]*types.SkippedMaterialsProfile, 0),
+	}
+	skipped := make([]*types.MaterialsProfile, 0)
+	copied := make(map[string]struct{})
+	copies := make([]*types.MaterialsProfile, 0, len(sourceProfiles))
+	for _, mp := range sourceProfiles {
+		key := mp.Sector + "\x00" + mp.EquipmentMachineryID
+		if id, ok := existingProfiles[key]; ok {
+			skipped = append(skipped, mp)
+			result.Skipped = append(result.Skipped, skippedProfile(mp, id, false))
+			continue
+		}
+		// the same equipment twice in the source is copied once
+		if _, ok := copied[key]; ok {
+			continue
+		}
+		if id, ok := takenIndexes[sectorIndex{mp.Sector, mp.Index}]; ok {
+			skipped = append(skipped, mp)
+			result.Skipped = append(result.Skipped, skippedProfile(mp, id, true))
+			continue
+		}
+		copied[key] = struct{}{}
+		takenIndexes[sectorIndex{mp.Sector, mp.Index}] = ""
+		copies = append(copies, &types.MaterialsProfile{
+			MaintenanceInstanceID: request.TargetMaintenanceInstanceID,
+			EquipmentMachineryID:  mp.EquipmentMachineryID,
+			Index:                 mp.Index,
+			Sector:                mp.Sector,
+			Estimate: types.MaterialsForEquipment{
+				ReplacementMaterials: scaleMaterials(mp.Estimate.ReplacementMaterials, scale),
+				ConsumableSupplies:   scaleMaterials(mp.Estimate.ConsumableSupplies, scale),
+			},
+			Reality: types.MaterialsForEquipment{
+				ReplacementMaterials: map[string]types.Material{},
+				ConsumableSupplies:   map[string]types.Material{},
+			},
+		})
+	}
+
+	if len(skipped) > 0 {
+		emIds := make([]string, 0, len(skipped))
+		for _, mp := range skipped {
+			emIds = append(emIds, mp.EquipmentMachineryID)
+		}
+		equipmentMachineries, err := s.equipmentMachineryRepo.FindByIDs(ctx, utils.RemoveDuplicates(emIds))
+		if err != nil {
+			return nil, err
+		}
+		for i, mp := range skipped {
+			if em, ok := equipmentMachineries[mp.EquipmentMachineryID]; ok {
+				result.Skipped[i].EquipmentMachinery = em.Name
+			}
+		}
+	}
+	if len(copies) > 0 {
+		if _, err := s.materialsProfileRepo.SaveMany(ctx, copies); err != nil {
+			return nil, err
+		}
+	}
+	result.Copied = len(copies)
+	return result, nil
+}
+
+// sectorIndex is an index path within a sector.
+type sectorIndex struct {
+	sector string
+	index  int64
+}
+
+func skippedProfile(mp *types.MaterialsProfile, existingID string, indexPathTaken bool) *types.SkippedMaterialsProfile {
+	return &types.SkippedMaterialsProfile{
+		Sector:                     mp.Sector,
+		IndexPath:                  utils.IndexPathToString(mp.Index),
+		SourceMaterialsProfileID:   mp.ID,
+		ExistingMaterialsProfileID: existingID,
+		IndexPathTaken:             indexPathTaken,
+	}
+}
+
+// scaleMaterials copies materials multiplying their quantity, rounded to two
+// decimals.
+func scaleMaterials(materials map[string]types.Material, scale float64) map[string]types.Material {
+	scaled := make(map[string]types.Material, len(materials))
+	for name, material := range materials {
+		material.Quantity = math.Round(material.Quantity*scale*100) / 100
+		scaled[name] = material
+	}
+	return scaled
+}
+
+// DeleteMaterialsProfile removes a profile created by mistake. It is refused
+// while material requests reference the profile unless their lines are
+// reassigned to another profile.
+func (s *materialsProfileService) DeleteMaterialsProfile(ctx context.Context, request *types.RemoveMaterialsProfileReq) error {
+	if err := s.detachMaterialsProfile(ctx, request); err != nil {
+		return err
+	}
+	return s.materialsProfileRepo.Delete(ctx, request.MaterialsProfileID)
+}
+
+// ArchiveMaterialsProfile hides a profile from filters, with the same checks
+// as DeleteMaterialsProfile.
+func (s *materialsProfileService) ArchiveMaterialsProfile(ctx context.Context, request *types.RemoveMaterialsProfileReq) error {
+	if err := s.detachMaterialsProfile(ctx, request); err != nil {
+		return err
+	}
+	return s.materialsProfileRepo.SetArchived(ctx, request.MaterialsProfileID, true)
+}
+
+func (s *materialsProfileService) RestoreMaterialsProfile(ctx context.Context, id string) error {
+	materialsProfile, err := s.materialsProfileRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if materialsProfile.ID == "" {
+		return types.ErrSomeMaterialsProfileNotFound
+	}
+	return s.materialsProfileRepo.SetArchived(ctx, id, false)
+}
+
+// detachMaterialsProfile makes sure no material request references a profile
+// about to be removed, moving the request lines and the reality they produced
+// to the reassign target when there is one.
+func (s *materialsProfileService) detachMaterialsProfile(ctx context.Context, request *types.RemoveMaterialsProfileReq) error {
+	materialsProfile, err := s.materialsProfileRepo.FindByID(ctx, request.MaterialsProfileID)
+	if err != nil {
+		return err
+	}
+	if materialsProfile.ID == "" {
+		return types.ErrSomeMaterialsProfileNotFound
+	}
+	materialsRequests, err := s.materialsRequestRepo.Filter(ctx, &types.MaterialRequestFilter{
+		MaterialsProfileID: materialsProfile.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if len(materialsRequests) == 0 {
+		return nil
+	}
+	if request.ReassignToID == "" {
+		return fmt.Errorf("%w: %d material requests", types.ErrMaterialsProfileInUse, len(materialsRequests))
+	}
+
+	if request.ReassignToID == materialsProfile.ID {
+		return types.ErrInvalidReassignTarget
+	}
+	target, err := s.materialsProfileRepo.FindByID(ctx, request.ReassignToID)
+	if err != nil {
+		return err
+	}
+	if target.ID == "" {
+		return types.ErrSomeMaterialsProfileNotFound
+	}
+	if target.Archived {
+		return types.ErrMaterialsProfileArchived
+	}
+	if target.MaintenanceInstanceID != materialsProfile.MaintenanceInstanceID {
+		return types.ErrMaterialsProfileMaintenanceMismatch
+	}
+	if target.Sector != materialsProfile.Sector {
+		return types.ErrMaterialsProfileSectorMismatch
+	}
+
+	for _, materialsRequest := range materialsRequests {
+		lines := materialsRequest.MaterialsForEquipment[materialsProfile.ID]
+		targetLines := materialsRequest.MaterialsForEquipment[target.ID]
+		materialsRequest.MaterialsForEquipment[target.ID] = types.MaterialsForEquipment{
+			ConsumableSupplies:   mergeMaterials(targetLines.ConsumableSupplies, lines.ConsumableSupplies),
+			ReplacementMaterials: mergeMaterials(targetLines.ReplacementMaterials, lines.ReplacementMaterials),
+		}
+		delete(materialsRequest.MaterialsForEquipment, materialsProfile.ID)
+		if err := s.materialsRequestRepo.Update(ctx, materialsRequest.ID, materialsRequest); err != nil {
+			return err
+		}
+	}
+	// numbered requests already added their lines to the reality of the profile
+	target.Reality = types.MaterialsForEquipment{
+		ConsumableSupplies:   mergeMaterials(target.Reality.ConsumableSupplies, materialsProfile.Reality.ConsumableSupplies),
+		ReplacementMaterials: mergeMaterials(target.Reality.ReplacementMaterials, materialsProfile.Reality.ReplacementMaterials),
+	}
+	if err := s.materialsProfileRepo.UpdateRealityMaterials(ctx, target.ID, target.Reality); err != nil {
+		return err
+	}
+	return s.materialsProfileRepo.UpdateRealityMaterials(ctx, materialsProfile.ID, types.MaterialsForEquipment{
+		ConsumableSupplies:   map[string]types.Material{},
+		ReplacementMaterials: map[string]types.Material{},
+	})
+}
+
+// mergeMaterials returns the materials of both maps, adding up the quantities
+// of materials present in both.
+func mergeMaterials(dst, src map[string]types.Material) map[string]types.Material {
+	merged := make(map[string]types.Material, len(dst)+len(src))
+	for name, material := range dst {
+		merged[name] = material
+	}
+	for name, material := range src {
+		addMaterial(merged, name, material)
+	}
+	return merged
+}
+
+// makeRoomAt shifts the profiles at an index path and at its following
+// siblings one position further, together with their subtrees, so that the
+// index path becomes free. Nothing moves when it is already free; shifted
+// tells whether anything did.
+func (s *materialsProfileService) makeRoomAt(ctx context.Context, maintenanceInstanceID, sector string, index int64) (shifted bool, err error) {
+	start, end := utils.IndexPathRange(index)
+	count, err := s.countIndexRange(ctx, maintenanceInstanceID, sector, start, end)
+	if err != nil || count == 0 {
+		return false, err
+	}
+	depth := utils.IndexPathDepth(index)
+	shiftStart, shiftEnd := siblingsFrom(index)
+	// the last possible sibling has nowhere to go
+	lastSibling := shiftEnd &^ (utils.IndexPathStep(depth) - 1)
+	count, err = s.countIndexRange(ctx, maintenanceInstanceID, sector, lastSibling, shiftEnd)
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, types.ErrIndexPathOverflow
+	}
+	err = s.materialsProfileRepo.ShiftIndex(ctx, maintenanceInstanceID, sector, shiftStart, shiftEnd, utils.IndexPathStep(depth))
+	return err == nil, err
+}
+
+func (s *materialsProfileService) checkIndexPathFree(ctx context.Context, maintenanceInstanceID, sector string, index int64) error {
+	count, err := s.countIndexRange(ctx, maintenanceInstanceID, sector, index, index)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return types.ErrIndexPathOccupied
+	}
+	return nil
+}
+
+func (s *materialsProfileService) checkSubtreeFree(ctx context.Context, maintenanceInstanceID, sector string, index int64) error {
+	start, end := utils.IndexPathRange(index)
+	count, err := s.countIndexRange(ctx, maintenanceInstanceID, sector, start, end)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return types.ErrIndexPathOccupied
+	}
+	return nil
+}
+
+// countIndexRange counts the profiles in a range of index paths, archived
+// ones included as they keep their index path and are shifted with the others.
+func (s *materialsProfileService) countIndexRange(ctx context.Context, maintenanceInstanceID, sector string, from, to int64) (int64, error) {
+	return s.materialsProfileRepo.Count(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{maintenanceInstanceID},
+		Sector:                 sector,
+		IndexFrom:              from,
+		IndexTo:                to,
+		IncludeArchived:        true,
+	})
+}
+
+// siblingsFrom returns the encoded range covering an index path, its
+// following siblings and all of their subtrees.
+func siblingsFrom(index int64) (int64, int64) {
+	_, end := utils.IndexPathRange(utils.IndexPathParent(index))
+	return index, end
+}
+
+// MaterialsProfileTree nests the materials profiles of a sector by index path
+// and rolls estimate and reality totals up to every level. Levels without a
+// profile of their own, such as a system grouping its subsystems, are
+// synthesized.
+func (s *materialsProfileService) MaterialsProfileTree(ctx context.Context, request *types.MaterialsProfileTreeReq) (*types.MaterialsProfileTree, error) {
+	if !utils.Contains(types.SECTOR_LIST, request.Sector) {
+		return nil, types.ErrInvalidSector
+	}
+	filter := &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{request.MaintenanceInstanceID},
+		Sector:                 request.Sector,
+	}
+	var root int64
+	if request.IndexPath != "" {
+		var err error
+		root, err = utils.StringToIndexPath(request.IndexPath)
+		if err != nil {
+			return nil, err
+		}
+		filter.IndexFrom, filter.IndexTo = utils.IndexPathRange(root)
+	}
+	materialsProfiles, err := s.materialsProfileRepo.Filter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	emIds := make([]string, 0, len(materialsProfiles))
+	for _, mp := range materialsProfiles {
+		emIds = append(emIds, mp.EquipmentMachineryID)
+	}
+	equipmentMachineries, err := s.equipmentMachineryRepo.FindByIDs(ctx, utils.RemoveDuplicates(emIds))
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &types.MaterialsProfileTree{
+		MaintenanceInstanceID: request.MaintenanceInstanceID,
+		Sector:                request.Sector,
+		IndexPath:             utils.IndexPathToString(root),
+		Nodes:                 make([]*types.MaterialsProfileTreeNode, 0),
+	}
+	nodes := make(map[int64]*types.MaterialsProfileTreeNode)
+	var node func(index int64) *types.MaterialsProfileTreeNode
+	// newNode adds a node under its parent, creating the missing ancestors up
+	// to the root of the tree
+	newNode := func(index int64) *types.MaterialsProfileTreeNode {
+		n := &types.MaterialsProfileTreeNode{
+			IndexPath: utils.IndexPathToString(index),
+			Children:  make([]*types.MaterialsProfileTreeNode, 0),
+		}
+		if index == 0 || index == root || utils.IndexPathDepth(index) == 1 {
+			tree.Nodes = append(tree.Nodes, n)
 		} else {
-			index, err := utils.StringToIndexPath(indexPathStr)
-			if err != nil {
-				index = 0
+			parent := node(utils.IndexPathParent(index))
+			parent.Children = append(parent.Children, n)
+		}
+		return n
+	}
+	node = func(index int64) *types.MaterialsProfileTreeNode {
+		n, ok := nodes[index]
+		if !ok {
+			n = newNode(index)
+			nodes[index] = n
+		}
+		return n
+	}
+
+	// profiles are sorted by index, so parents are always visited first
+	for _, mp := range materialsProfiles {
+		var n *types.MaterialsProfileTreeNode
+		if mp.Index == 0 || nodes[mp.Index] != nil && nodes[mp.Index].MaterialsProfileID != "" {
+			// profiles without index path are listed at the top level and
+			// profiles sharing an index path are kept side by side
+			n = newNode(mp.Index)
+		} else {
+			n = node(mp.Index)
+		}
+		n.MaterialsProfileID = mp.ID
+		n.EquipmentMachineryID = mp.EquipmentMachineryID
+		if em, ok := equipmentMachineries[mp.EquipmentMachineryID]; ok {
+			n.EquipmentMachinery = em.Name
+		}
+
+		// roll the lines up to the node, its ancestors and the tree
+		summaries := []*types.VarianceSummary{&tree.Summary, &n.Summary}
+		if mp.Index != root {
+			for parent := utils.IndexPathParent(mp.Index); parent != 0; parent = utils.IndexPathParent(parent) {
+				summaries = append(summaries, &nodes[parent].Summary)
+				if parent == root {
+					break
+				}
 			}
-			materialsProfilesMap[equipmentName] = &types.MaterialsProfile{
-				MaintenanceInstanceID: maintenanceID,
-				EquipmentMachineryID:  equipmentID,
-				Sector:                sector,
-				Index:                 index,
-				Estimate: types.MaterialsForEquipment{
-					ReplacementMaterials: make(map[string]types.Material),
-					ConsumableSupplies:   make(map[string]types.Material),
-				},
-				Reality: types.MaterialsForEquipment{
-					ReplacementMaterials: make(map[string]types.Material),
-					ConsumableSupplies:   make(map[string]types.Material),
-				},
+		}
+		for _, line := range buildVarianceLines(mp) {
+			for _, summary := range summaries {
+				addVarianceLine(summary, line)
 			}
+		}
+	}
+	return tree, nil
+}
 
-			materialsProfilesMap[equipmentName].ID, _ = s.materialsProfileRepo.Save(ctx, materialsProfilesMap[equipmentName])
+// sortedMaterialNames returns the union of the material names, sorted.
+func sortedMaterialNames(materials ...map[string]types.Material) []string {
+	unique := make(map[string]struct{})
+	for _, m := range materials {
+		for name := range m {
+			unique[name] = struct{}{}
 		}
 	}
+	names := utils.MapKeys(unique)
+	sort.Strings(names)
+	return names
 }
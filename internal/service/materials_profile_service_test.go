@@ -0,0 +1,586 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCreateMaterialsProfileOverArchivedIndex(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	s := newTestMaterialsProfileService(db)
+
+	maintenanceID, err := s.maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equipmentID, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Bơm nước", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := utils.StringToIndexPath("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+		MaintenanceInstanceID: maintenanceID,
+		EquipmentMachineryID:  equipmentID,
+		Sector:                testSector,
+		Index:                 index,
+		Archived:              true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &types.CreateMaterialProfileReq{
+		MaintenanceInstanceID: maintenanceID,
+		EquipmentMachineryID:  equipmentID,
+		Sector:                testSector,
+		IndexPath:             "1",
+	}
+	if _, err := s.CreateMaterialsProfile(ctx, req); !errors.Is(err, types.ErrIndexPathOccupied) {
+		t.Errorf("create a profile at the index path of an archived one: got %v, want ErrIndexPathOccupied", err)
+	}
+	req.IndexPath = "1.1"
+	if _, err := s.CreateMaterialsProfile(ctx, req); err != nil {
+		t.Errorf("create a profile below an archived one: %v", err)
+	}
+}
+
+func TestMaterialsProfileTreeCountsLines(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	s := newTestMaterialsProfileService(db)
+
+	equipmentID, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Hệ thống nhiên liệu", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := func(indexPath string, estimate, reality map[string]types.Material) {
+		t.Helper()
+		index, err := utils.StringToIndexPath(indexPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+			MaintenanceInstanceID: "maintenance-1",
+			EquipmentMachineryID:  equipmentID,
+			Sector:                testSector,
+			Index:                 index,
+			Estimate:              types.MaterialsForEquipment{ConsumableSupplies: estimate},
+			Reality:               types.MaterialsForEquipment{ConsumableSupplies: reality},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("1",
+		map[string]types.Material{"Giẻ lau": {Name: "Giẻ lau", Unit: "kg", Quantity: 2}},
+		map[string]types.Material{"Giẻ lau": {Name: "Giẻ lau", Unit: "kg", Quantity: 3}},
+	)
+	save("1.1",
+		nil,
+		map[string]types.Material{"Dầu": {Name: "Dầu", Unit: "lít", Quantity: 40}},
+	)
+	save("1.2",
+		map[string]types.Material{"Sơn": {Name: "Sơn", Unit: "thùng", Quantity: 1}},
+		map[string]types.Material{"Sơn": {Name: "Sơn", Unit: "lít", Quantity: 18}},
+	)
+
+	tree, err := s.MaterialsProfileTree(ctx, &types.MaterialsProfileTreeReq{MaintenanceInstanceID: "maintenance-1", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.VarianceSummary{Lines: 3, OverrunLines: 2, UnestimatedLines: 1, MismatchLines: 1}
+	if tree.Summary != want {
+		t.Errorf("tree summary = %+v, want %+v", tree.Summary, want)
+	}
+	if len(tree.Nodes) != 1 || len(tree.Nodes[0].Children) != 2 {
+		t.Fatalf("tree = %+v, want 1 with 2 children", tree.Nodes)
+	}
+	if got := tree.Nodes[0].Summary; got != want {
+		t.Errorf("system summary = %+v, want the lines of its subtree %+v", got, want)
+	}
+	want = types.VarianceSummary{Lines: 1, MismatchLines: 1}
+	if got := tree.Nodes[0].Children[1].Summary; got != want {
+		t.Errorf("1.2 summary = %+v, want %+v", got, want)
+	}
+}
+
+// saveProfilesAt saves a profile at every index path, archived when the path
+// ends with "*", and returns their IDs by index path.
+func saveProfilesAt(t *testing.T, s *materialsProfileService, maintenanceID string, indexPaths ...string) map[string]string {
+	t.Helper()
+	ids := make(map[string]string, len(indexPaths))
+	for _, indexPath := range indexPaths {
+		archived := strings.HasSuffix(indexPath, "*")
+		indexPath = strings.TrimSuffix(indexPath, "*")
+		index, err := utils.StringToIndexPath(indexPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := s.materialsProfileRepo.Save(context.Background(), &types.MaterialsProfile{
+			MaintenanceInstanceID: maintenanceID,
+			EquipmentMachineryID:  "equipment-" + indexPath,
+			Sector:                testSector,
+			Index:                 index,
+			Archived:              archived,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[indexPath] = id
+	}
+	return ids
+}
+
+// profileIndexPaths returns the index paths of the profiles by ID, archived
+// ones included.
+func profileIndexPaths(t *testing.T, s *materialsProfileService, maintenanceID string) map[string]string {
+	t.Helper()
+	profiles, err := s.materialsProfileRepo.Filter(context.Background(), &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{maintenanceID},
+		Sector:                 testSector,
+		IncludeArchived:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexPaths := make(map[string]string, len(profiles))
+	for _, mp := range profiles {
+		indexPaths[mp.ID] = utils.IndexPathToString(mp.Index)
+	}
+	return indexPaths
+}
+
+func TestMoveMaterialsProfile(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		profile []string
+		req     types.MoveMaterialsProfileReq
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name:    "same depth",
+			profile: []string{"1", "2", "2.1", "2.1.1*"},
+			req:     types.MoveMaterialsProfileReq{From: "2", To: "4"},
+			want:    map[string]string{"1": "1", "2": "4", "2.1": "4.1", "2.1.1": "4.1.1"},
+		},
+		{
+			name:    "other depth with an archived descendant",
+			profile: []string{"1", "2", "2.1*", "2.2"},
+			req:     types.MoveMaterialsProfileReq{From: "2", To: "1.3"},
+			want:    map[string]string{"1": "1", "2": "1.3", "2.1": "1.3.1", "2.2": "1.3.2"},
+		},
+		{
+			name:    "occupied by an archived profile",
+			profile: []string{"1", "2*"},
+			req:     types.MoveMaterialsProfileReq{From: "1", To: "2"},
+			wantErr: types.ErrIndexPathOccupied,
+		},
+		{
+			name:    "shift siblings",
+			profile: []string{"1", "2", "2.1", "3"},
+			req:     types.MoveMaterialsProfileReq{From: "3", To: "2", ShiftSiblings: true},
+			want:    map[string]string{"1": "1", "2": "3", "2.1": "3.1", "3": "2"},
+		},
+		{
+			name:    "shift siblings over the moved profile",
+			profile: []string{"1", "2", "2.1", "3"},
+			req:     types.MoveMaterialsProfileReq{From: "2", To: "1", ShiftSiblings: true},
+			want:    map[string]string{"1": "2", "2": "1", "2.1": "1.1", "3": "4"},
+		},
+		{
+			name:    "shift siblings at a free index path",
+			profile: []string{"1", "3", "4"},
+			req:     types.MoveMaterialsProfileReq{From: "4", To: "2", ShiftSiblings: true},
+			want:    map[string]string{"1": "1", "3": "3", "4": "2"},
+		},
+		{
+			name:    "into its own subtree",
+			profile: []string{"1", "1.1"},
+			req:     types.MoveMaterialsProfileReq{From: "1", To: "1.2"},
+			wantErr: types.ErrInvalidIndexMove,
+		},
+		{
+			name:    "nothing at the index path",
+			profile: []string{"1"},
+			req:     types.MoveMaterialsProfileReq{From: "2", To: "3"},
+			wantErr: types.ErrIndexPathNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestMaterialsProfileService(newMemoryDatabase())
+			ids := saveProfilesAt(t, s, "maintenance-1", tt.profile...)
+			before := profileIndexPaths(t, s, "maintenance-1")
+
+			req := tt.req
+			req.MaintenanceInstanceID = "maintenance-1"
+			req.Sector = testSector
+			err := s.MoveMaterialsProfile(ctx, &req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("move: got %v, want %v", err, tt.wantErr)
+			}
+			got := profileIndexPaths(t, s, "maintenance-1")
+			if tt.wantErr != nil {
+				if !reflect.DeepEqual(got, before) {
+					t.Errorf("index paths = %v after a refused move, want %v", got, before)
+				}
+				return
+			}
+			for indexPath, want := range tt.want {
+				if got[ids[indexPath]] != want {
+					t.Errorf("profile at %s moved to %s, want %s", indexPath, got[ids[indexPath]], want)
+				}
+			}
+		})
+	}
+}
+
+func TestInsertMaterialsProfileShiftsSiblings(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMaterialsProfileService(newMemoryDatabase())
+	maintenanceID, err := s.maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equipmentID, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Bơm nước", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := saveProfilesAt(t, s, maintenanceID, "1", "2", "2.1*", "3", "3.1")
+
+	id, err := s.InsertMaterialsProfile(ctx, &types.CreateMaterialProfileReq{
+		MaintenanceInstanceID: maintenanceID,
+		EquipmentMachineryID:  equipmentID,
+		Sector:                testSector,
+		IndexPath:             "2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := profileIndexPaths(t, s, maintenanceID)
+	if got[id] != "2" {
+		t.Errorf("inserted profile at %s, want 2", got[id])
+	}
+	want := map[string]string{"1": "1", "2": "3", "2.1": "3.1", "3": "4", "3.1": "4.1"}
+	for indexPath, want := range want {
+		if got[ids[indexPath]] != want {
+			t.Errorf("profile at %s shifted to %s, want %s", indexPath, got[ids[indexPath]], want)
+		}
+	}
+
+	// nothing shifts when the index path is free
+	id, err = s.InsertMaterialsProfile(ctx, &types.CreateMaterialProfileReq{
+		MaintenanceInstanceID: maintenanceID,
+		EquipmentMachineryID:  equipmentID,
+		Sector:                testSector,
+		IndexPath:             "6",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = profileIndexPaths(t, s, maintenanceID)
+	if got[id] != "6" || got[ids["3"]] != "4" {
+		t.Errorf("insert at a free index path: got %s and 3 at %s, want 6 and 4", got[id], got[ids["3"]])
+	}
+}
+
+func TestValidateIndexPaths(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMaterialsProfileService(newMemoryDatabase())
+	saveProfilesAt(t, s, "maintenance-1", "1", "2")
+	duplicates := saveProfilesAt(t, s, "maintenance-1", "2*", "2.1")
+	saveProfilesAt(t, s, "maintenance-1", "2.1")
+
+	got, err := s.ValidateIndexPaths(ctx, &types.ValidateIndexPathsReq{MaintenanceInstanceID: "maintenance-1", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("duplicates = %+v, want 2 and 2.1", got)
+	}
+	if got[0].IndexPath != "2" || len(got[0].MaterialsProfileIDs) != 2 || !utils.Contains(got[0].MaterialsProfileIDs, duplicates["2"]) {
+		t.Errorf("duplicate = %+v, want 2 shared with the archived profile", got[0])
+	}
+	if got[1].IndexPath != "2.1" || len(got[1].MaterialsProfileIDs) != 2 {
+		t.Errorf("duplicate = %+v, want 2.1 shared by 2 profiles", got[1])
+	}
+}
+
+func TestCopyEstimates(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMaterialsProfileService(newMemoryDatabase())
+	sourceID, err := s.maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetID, err := s.maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equipments := make(map[string]string)
+	for _, name := range []string{"Bơm nước", "Động cơ", "Van", "Tời", "Cẩu"} {
+		id, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: name, Sector: testSector})
+		if err != nil {
+			t.Fatal(err)
+		}
+		equipments[name] = id
+	}
+	save := func(maintenanceID, indexPath, equipment string, quantity float64, archived bool) string {
+		t.Helper()
+		index, err := utils.StringToIndexPath(indexPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+			MaintenanceInstanceID: maintenanceID,
+			EquipmentMachineryID:  equipments[equipment],
+			Sector:                testSector,
+			Index:                 index,
+			Archived:              archived,
+			Estimate: types.MaterialsForEquipment{ConsumableSupplies: map[string]types.Material{
+				"Giẻ lau": {Name: "Giẻ lau", Unit: "kg", Quantity: quantity},
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	save(sourceID, "1", "Bơm nước", 3, false)
+	save(sourceID, "2", "Động cơ", 1, false)
+	save(sourceID, "2.1", "Bơm nước", 1, false)
+	save(sourceID, "3", "Van", 1, false)
+	save(sourceID, "4", "Tời", 1, false)
+	existingEngine := save(targetID, "5", "Động cơ", 1, false)
+	archivedAt3 := save(targetID, "3", "Cẩu", 1, true)
+
+	result, err := s.CopyEstimates(ctx, &types.CopyEstimatesReq{
+		SourceMaintenanceInstanceID: sourceID,
+		TargetMaintenanceInstanceID: targetID,
+		ScaleFactor:                 1.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Copied != 2 {
+		t.Errorf("copied = %d, want the pump once and the winch", result.Copied)
+	}
+	skipped := make(map[string]types.SkippedMaterialsProfile)
+	for _, item := range result.Skipped {
+		skipped[item.IndexPath] = *item
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("skipped = %+v, want 2 and 3", skipped)
+	}
+	if item := skipped["2"]; item.ExistingMaterialsProfileID != existingEngine || item.IndexPathTaken || item.EquipmentMachinery != "Động cơ" {
+		t.Errorf("skipped 2 = %+v, want the existing profile of the engine", item)
+	}
+	if item := skipped["3"]; item.ExistingMaterialsProfileID != archivedAt3 || !item.IndexPathTaken {
+		t.Errorf("skipped 3 = %+v, want the archived profile holding the index path", item)
+	}
+
+	copies, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{targetID},
+		EquipmentMachineryIDs:  []string{equipments["Bơm nước"], equipments["Tời"]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quantities := make(map[string]float64)
+	for _, mp := range copies {
+		quantities[utils.IndexPathToString(mp.Index)] = mp.Estimate.ConsumableSupplies["Giẻ lau"].Quantity
+		if len(mp.Reality.ConsumableSupplies) != 0 {
+			t.Errorf("copy at %s has a reality %+v, want none", utils.IndexPathToString(mp.Index), mp.Reality)
+		}
+	}
+	want := map[string]float64{"1": 4.5, "4": 1.5}
+	if !reflect.DeepEqual(quantities, want) {
+		t.Errorf("copied quantities = %v, want the scaled estimates %v", quantities, want)
+	}
+
+	// copying again finds every equipment already there
+	result, err = s.CopyEstimates(ctx, &types.CopyEstimatesReq{
+		SourceMaintenanceInstanceID: sourceID,
+		TargetMaintenanceInstanceID: targetID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Copied != 0 {
+		t.Errorf("copied = %d on a second copy, want 0", result.Copied)
+	}
+
+	_, err = s.CopyEstimates(ctx, &types.CopyEstimatesReq{
+		SourceMaintenanceInstanceID: sourceID,
+		TargetMaintenanceInstanceID: "64b000000000000000000000",
+	})
+	if !errors.Is(err, types.ErrMaintenanceNotFound) {
+		t.Errorf("copy to a missing maintenance: got %v, want ErrMaintenanceNotFound", err)
+	}
+}
+
+func TestSearchFilter(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMaterialsProfileService(newMemoryDatabase())
+	t01, err := s.maintenanceRepo.Save(ctx, &types.Maintenance{ProjectCode: "T01", MaintenanceTier: types.MAINTENANCE_TIER_MEDIUM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t02, err := s.maintenanceRepo.Save(ctx, &types.Maintenance{ProjectCode: "T02", MaintenanceTier: types.MAINTENANCE_TIER_MEDIUM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pumpID, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Bơm nước", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := s.searchFilter(ctx, &types.MaterialsProfileSearchRequest{
+		MaintenanceIDs:  []string{t02},
+		Sector:          testSector,
+		MaintenanceTier: types.MAINTENANCE_TIER_MEDIUM,
+		EquipmentName:   "bơm",
+		MaterialName:    " Giẻ lau ",
+		IndexPath:       "2",
+		HasOverrun:      true,
+		SortBy:          types.MATERIALS_PROFILE_SORT_OVERRUN,
+		SortDesc:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexFrom, indexTo := utils.IndexPathRange(2 << 54)
+	want := &types.MaterialsProfileFilter{
+		// the maintenances of the tier among those asked for
+		MaintenanceInstanceIDs: []string{t02},
+		EquipmentMachineryIDs:  []string{pumpID},
+		Sector:                 testSector,
+		IndexFrom:              indexFrom,
+		IndexTo:                indexTo,
+		MaterialName:           "Giẻ lau",
+		HasOverrun:             true,
+		SortBy:                 types.MATERIALS_PROFILE_SORT_OVERRUN,
+		SortDesc:               true,
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("filter = %+v, want %+v", filter, want)
+	}
+
+	filter, err = s.searchFilter(ctx, &types.MaterialsProfileSearchRequest{
+		MaintenanceIDs:  []string{t01},
+		MaintenanceTier: types.MAINTENANCE_TIER_DOCK,
+	})
+	if err != nil || filter != nil {
+		t.Errorf("search of a tier the maintenance is not in: got %+v, %v, want nothing to match", filter, err)
+	}
+	if _, err := s.searchFilter(ctx, &types.MaterialsProfileSearchRequest{SortBy: "price"}); !errors.Is(err, types.ErrInvalidSortField) {
+		t.Errorf("sort by price: got %v, want ErrInvalidSortField", err)
+	}
+}
+
+func TestPaginateMaterialsProfilesByOverrun(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	s := newTestMaterialsProfileService(db)
+	save := func(indexPath string, estimate, reality map[string]types.Material) {
+		t.Helper()
+		index, err := utils.StringToIndexPath(indexPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+			MaintenanceInstanceID: "maintenance-1",
+			EquipmentMachineryID:  "equipment-" + indexPath,
+			Sector:                testSector,
+			Index:                 index,
+			Estimate:              types.MaterialsForEquipment{ConsumableSupplies: estimate},
+			Reality:               types.MaterialsForEquipment{ConsumableSupplies: reality},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pieces := func(quantity float64) types.Material { return types.Material{Unit: "cái", Quantity: quantity} }
+	save("1",
+		map[string]types.Material{"Giẻ lau": {Unit: "kg", Quantity: 2}},
+		map[string]types.Material{"Giẻ lau": {Unit: "kg", Quantity: 3}},
+	)
+	save("2", nil, map[string]types.Material{"Dầu": {Unit: "lít", Quantity: 40}})
+	save("3",
+		map[string]types.Material{"Sơn": {Unit: "thùng", Quantity: 1}},
+		map[string]types.Material{"Sơn": {Unit: "lít", Quantity: 18}},
+	)
+	save("4",
+		map[string]types.Material{"Bu lông": pieces(10), "Đai ốc": pieces(10), "Long đen": pieces(5)},
+		map[string]types.Material{"Bu lông": pieces(12), "Đai ốc": pieces(11), "Long đen": pieces(6)},
+	)
+	save("5",
+		map[string]types.Material{"Giẻ lau": {Unit: "kg", Quantity: 2}},
+		map[string]types.Material{"Giẻ lau": {Unit: "kg", Quantity: 1}},
+	)
+
+	// the filter keeps profiles with an overrun line in the same unit or
+	// without estimate
+	overrun, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{HasOverrun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, mp := range overrun {
+		got = append(got, utils.IndexPathToString(mp.Index))
+	}
+	if want := []string{"1", "2", "4"}; !slices.Equal(got, want) {
+		t.Errorf("profiles with an overrun = %v, want %v", got, want)
+	}
+
+	db.aggregateResults("materials_profiles", bson.M{"total": bson.A{}, "items": bson.A{}})
+	if _, _, err := s.PaginatedMaterialsProfiles(ctx, &types.MaterialsProfileSearchRequest{
+		SortBy:   types.MATERIALS_PROFILE_SORT_OVERRUN,
+		SortDesc: true,
+		Page:     3,
+		Limit:    20,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var addFields bson.M
+	var items bson.A
+	for _, stage := range db.lastPipeline("materials_profiles") {
+		if fields, ok := stage.(bson.M)["$addFields"]; ok {
+			addFields = fields.(bson.M)
+		}
+		if facet, ok := stage.(bson.M)["$facet"]; ok {
+			items = facet.(bson.M)["items"].(bson.A)
+		}
+	}
+	// the sort key is the quantity consumed beyond the estimates, not the
+	// number of overrun lines
+	keys := make(map[string]float64)
+	for _, doc := range db.collections["materials_profiles"] {
+		index, _ := toInt(doc["index"])
+		keys[utils.IndexPathToString(index)] = toFloat(evalExpr(doc, nil, addFields["overrun"]))
+	}
+	wantKeys := map[string]float64{"1": 1, "2": 40, "3": 0, "4": 4, "5": 0}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("overrun sort keys = %v, want %v", keys, wantKeys)
+	}
+	wantItems := bson.A{
+		bson.M{"$sort": bson.D{{Key: "overrun", Value: -1}, {Key: "index", Value: 1}, {Key: "_id", Value: 1}}},
+		bson.M{"$skip": int64(40)},
+		bson.M{"$limit": int64(20)},
+	}
+	if len(items) < 3 || !reflect.DeepEqual(items[:3], wantItems) {
+		t.Errorf("items = %v, want %v first", items, wantItems)
+	}
+}
@@ -3,8 +3,10 @@ package service
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"path"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,7 +17,9 @@ import (
 )
 
 type MaterialsRequestService interface {
-	CreateMaterialsRequest(ctx context.Context, request *types.CreateMaterialRequestReq) (string, error)
+	// create a material request and propose catalog materials for its lines
+	// that reference none
+	CreateMaterialsRequest(ctx context.Context, request *types.CreateMaterialRequestReq) (*types.CreateMaterialRequestResult, error)
 	GetMaterialsRequest(ctx context.Context, id string) (*types.MaterialRequestResponse, error)
 	FilterMaterialsRequests(ctx context.Context, req *types.MaterialRequestFilter, page, limit int64) ([]*types.MaterialRequestResponse, int64, error)
 	UpdateMaterialsRequest(ctx context.Context, request *types.MaterialRequestUpdate) error
@@ -30,6 +34,8 @@ type materialsRequestService struct {
 	materialsProfileRepo   repository.MaterialsProfileRepository
 	maintenanceRepo        repository.MaintenanceRepository
 	equipmentMachineryRepo repository.EquipmentMachineryRepo
+	catalogRepo            repository.CatalogRepository
+	priceRepo              repository.PriceRepository
 	templateRequestPath    string
 }
 
@@ -38,6 +44,8 @@ func NewMaterialsRequestService(
 	materialsProfileRepo repository.MaterialsProfileRepository,
 	maintenanceRepo repository.MaintenanceRepository,
 	equipmentMachineryRepo repository.EquipmentMachineryRepo,
+	catalogRepo repository.CatalogRepository,
+	priceRepo repository.PriceRepository,
 	templateRequestPath string,
 ) MaterialsRequestService {
 	return &materialsRequestService{
@@ -45,19 +53,21 @@ func NewMaterialsRequestService(
 		materialsProfileRepo:   materialsProfileRepo,
 		maintenanceRepo:        maintenanceRepo,
 		equipmentMachineryRepo: equipmentMachineryRepo,
+		catalogRepo:            catalogRepo,
+		priceRepo:              priceRepo,
 		templateRequestPath:    templateRequestPath,
 	}
 }
 
-func (s *materialsRequestService) CreateMaterialsRequest(ctx context.Context, request *types.CreateMaterialRequestReq) (string, error) {
+func (s *materialsRequestService) CreateMaterialsRequest(ctx context.Context, request *types.CreateMaterialRequestReq) (*types.CreateMaterialRequestResult, error) {
 	// Validate sector
 	if !utils.Contains(types.SECTOR_LIST, request.Sector) {
-		return "", types.ErrInvalidSector
+		return nil, types.ErrInvalidSector
 	}
 
 	maintenance, err := s.maintenanceRepo.FindByID(ctx, request.MaintenanceInstanceID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	materialProfileIds := make([]string, 0)
 	for materialProfileId := range request.MaterialsForEquipment {
@@ -65,22 +75,28 @@ func (s *materialsRequestService) CreateMaterialsRequest(ctx context.Context, re
 	}
 	materialsProfiles, err := s.materialsProfileRepo.FindByIDs(ctx, materialProfileIds)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if len(materialsProfiles) != len(materialProfileIds) {
-		return "", types.ErrSomeMaterialsProfileNotFound
+		return nil, types.ErrSomeMaterialsProfileNotFound
 	}
 	for _, profile := range materialsProfiles {
+		if profile.Archived {
+			return nil, types.ErrMaterialsProfileArchived
+		}
 		if profile.Sector != request.Sector {
-			return "", types.ErrMaterialsProfileSectorMismatch
+			return nil, types.ErrMaterialsProfileSectorMismatch
 		}
 		if profile.MaintenanceInstanceID != request.MaintenanceInstanceID {
-			return "", types.ErrMaterialsProfileMaintenanceMismatch
+			return nil, types.ErrMaterialsProfileMaintenanceMismatch
 		}
 	}
+	if err := normalizeMaterials(ctx, s.catalogRepo, slices.Collect(maps.Values(request.MaterialsForEquipment))...); err != nil {
+		return nil, err
+	}
 	user, ok := ctx.Value("user").(*types.User)
 	if !ok {
-		return "", types.ErrUnauthorized
+		return nil, types.ErrUnauthorized
 	}
 	materialsRequest := &types.MaterialRequest{
 		MaintenanceInstanceID: maintenance.ID,
@@ -90,7 +106,50 @@ func (s *materialsRequestService) CreateMaterialsRequest(ctx context.Context, re
 		RequestedBy:           user.Username,
 		RequestedAt:           time.Now().Unix(),
 	}
-	return s.materialsRequestRepo.Save(ctx, materialsRequest)
+	id, err := s.materialsRequestRepo.Save(ctx, materialsRequest)
+	if err != nil {
+		return nil, err
+	}
+	suggestions, err := s.catalogSuggestions(ctx, request.MaterialsForEquipment)
+	if err != nil {
+		return nil, err
+	}
+	return &types.CreateMaterialRequestResult{
+		ID:                 id,
+		CatalogSuggestions: suggestions,
+	}, nil
+}
+
+// catalogSuggestions proposes catalog materials for the names of the lines
+// referencing no catalog material, leaving out the names nothing resembles.
+func (s *materialsRequestService) catalogSuggestions(ctx context.Context, materialsForEquipment map[string]types.MaterialsForEquipment) ([]*types.NameSuggestions, error) {
+	names := make([]string, 0)
+	for _, materials := range materialsForEquipment {
+		for _, section := range []map[string]types.Material{materials.ReplacementMaterials, materials.ConsumableSupplies} {
+			for name, material := range section {
+				if material.CatalogID == "" {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	suggestions := make([]*types.NameSuggestions, 0)
+	if len(names) == 0 {
+		return suggestions, nil
+	}
+	slices.Sort(names)
+	catalog, err := s.catalogRepo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	candidates := catalogCandidates(catalog)
+	for _, name := range slices.Compact(names) {
+		matches := rankNameCandidates(name, candidates, suggestionMinScore, defaultSuggestionLimit)
+		if len(matches) > 0 {
+			suggestions = append(suggestions, &types.NameSuggestions{Name: name, Matches: matches})
+		}
+	}
+	return suggestions, nil
 }
 
 func (s *materialsRequestService) GetMaterialsRequest(ctx context.Context, id string) (*types.MaterialRequestResponse, error) {
@@ -141,6 +200,7 @@ func (s *materialsRequestService) GetMaterialsRequest(ctx context.Context, id st
 		Sector:                materialsRequest.Sector,
 		Description:           materialsRequest.Description,
 		MaterialsForEquipment: materialsForEquipment,
+		Issued:                materialsRequest.Issued,
 		RequestedBy:           materialsRequest.RequestedBy,
 		RequestedAt:           materialsRequest.RequestedAt,
 	}
@@ -209,6 +269,7 @@ func (s *materialsRequestService) FilterMaterialsRequests(ctx context.Context, r
 			Description:           materialsRequest.Description,
 			NumOfRequest:          materialsRequest.NumOfRequest,
 			MaterialsForEquipment: materialsForEquipment,
+			Issued:                materialsRequest.Issued,
 			RequestedBy:           materialsRequest.RequestedBy,
 			RequestedAt:           materialsRequest.RequestedAt,
 		}
@@ -244,6 +305,9 @@ func (s *materialsRequestService) UpdateMaterialsRequest(ctx context.Context, re
 			return types.ErrSomeMaterialsProfileNotFound
 		}
 		for _, profile := range materialProfiles {
+			if profile.Archived {
+				return types.ErrMaterialsProfileArchived
+			}
 			if request.Sector != "" && profile.Sector != request.Sector {
 				return types.ErrMaterialsProfileSectorMismatch
 			}
@@ -251,6 +315,9 @@ func (s *materialsRequestService) UpdateMaterialsRequest(ctx context.Context, re
 				return types.ErrMaterialsProfileMaintenanceMismatch
 			}
 		}
+		if err := normalizeMaterials(ctx, s.catalogRepo, slices.Collect(maps.Values(request.MaterialsForEquipment))...); err != nil {
+			return err
+		}
 		materialsRequest.MaterialsForEquipment = request.MaterialsForEquipment
 	}
 
@@ -266,6 +333,10 @@ func (s *materialsRequestService) DeleteMaterialsRequest(ctx context.Context, id
 	if materialsRequest == nil {
 		return types.ErrMaterialRequestNotFound
 	}
+	// the stock ledger refers to the requests materials were issued against
+	if len(materialsRequest.Issued) > 0 {
+		return types.ErrMaterialRequestIssued
+	}
 
 	return s.materialsRequestRepo.Delete(ctx, id)
 }
@@ -316,22 +387,12 @@ func (s *materialsRequestService) UpdateNumberOfRequest(ctx context.Context, req
 
 			// Update consumable supplies
 			for _, consumableSupplies := range materials.ConsumableSupplies {
-				if existing, ok := profile.Reality.ConsumableSupplies[consumableSupplies.Name]; ok {
-					existing.Quantity += consumableSupplies.Quantity
-					profile.Reality.ConsumableSupplies[consumableSupplies.Name] = existing
-				} else {
-					profile.Reality.ConsumableSupplies[consumableSupplies.Name] = consumableSupplies
-				}
+				addConsumed(profile.Reality.ConsumableSupplies, profile.Estimate.ConsumableSupplies, consumableSupplies.Name, consumableSupplies)
 			}
 
 			// Update replacement materials
 			for _, replacementMaterial := range materials.ReplacementMaterials {
-				if existing, ok := profile.Reality.ReplacementMaterials[replacementMaterial.Name]; ok {
-					existing.Quantity += replacementMaterial.Quantity
-					profile.Reality.ReplacementMaterials[replacementMaterial.Name] = existing
-				} else {
-					profile.Reality.ReplacementMaterials[replacementMaterial.Name] = replacementMaterial
-				}
+				addConsumed(profile.Reality.ReplacementMaterials, profile.Estimate.ReplacementMaterials, replacementMaterial.Name, replacementMaterial)
 			}
 
 			err = s.materialsProfileRepo.UpdateRealityMaterials(ctx, profile.ID, profile.Reality)
@@ -390,6 +451,34 @@ func (s *materialsRequestService) ExportMaterialsRequest(ctx context.Context, re
 	currentEquipmentIndex := 1
 	currentTableIndex := 1
 
+	// the cost column is appended to the rows of the template, its title on
+	// the header row and the total on the last row
+	var book *priceBook
+	var totalCostCell *document.Cell
+	var cost exportedCost
+	consumableCosts := make(map[string]types.CostSummary)
+	addCostCell := func(row document.Row, text string) {
+		if book != nil {
+			row.AddCell().AddParagraph().AddRun().AddText(text)
+		}
+	}
+	if req.WithCost {
+		book, err = loadPriceBook(ctx, s.catalogRepo, s.priceRepo, slices.Collect(maps.Values(materialRequest.MaterialsForEquipment))...)
+		if err != nil {
+			return nil, err
+		}
+		rows := materialTable.Rows()
+		for i, row := range rows {
+			cell := row.AddCell()
+			switch {
+			case i == 0:
+				cell.AddParagraph().AddRun().AddText("Thành tiền")
+			case i == len(rows)-1:
+				totalCostCell = &cell
+			}
+		}
+	}
+
 	for mpID, mpMaterials := range materialRequest.MaterialsForEquipment {
 		newRow := materialTable.InsertRowBefore(materialTable.Rows()[len(materialTable.Rows())-1])
 		indexRun := newRow.AddCell().AddParagraph().AddRun()
@@ -403,13 +492,14 @@ func (s *materialsRequestService) ExportMaterialsRequest(ctx context.Context, re
 		newRow.AddCell().AddParagraph().AddRun().AddText("")
 		newRow.AddCell().AddParagraph().AddRun().AddText("")
 		newRow.AddCell().AddParagraph().AddRun().AddText("")
+		addCostCell(newRow, "")
 
 		for _, consumable := range mpMaterials.ConsumableSupplies {
-			if existing, ok := consumableMaterialsMap[consumable.Name]; ok {
-				existing.Quantity += consumable.Quantity
-				consumableMaterialsMap[consumable.Name] = existing
-			} else {
-				consumableMaterialsMap[consumable.Name] = consumable
+			key := addMaterial(consumableMaterialsMap, consumable.Name, consumable)
+			if book != nil {
+				summary := consumableCosts[key]
+				addCostLine(&summary, book.line(types.MATERIAL_TYPE_CONSUMABLE, consumable, materialRequest.RequestedAt))
+				consumableCosts[key] = summary
 			}
 		}
 
@@ -422,6 +512,11 @@ func (s *materialsRequestService) ExportMaterialsRequest(ctx context.Context, re
 			newRow.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%.2f", replacement.Quantity))
 			newRow.AddCell().AddParagraph().AddRun().AddText("")
 			newRow.AddCell().AddParagraph().AddRun().AddText("")
+			if book != nil {
+				var summary types.CostSummary
+				addCostLine(&summary, book.line(types.MATERIAL_TYPE_REPLACEMENT, replacement, materialRequest.RequestedAt))
+				addCostCell(newRow, cost.row(summary))
+			}
 			currentTableIndex += 1
 		}
 
@@ -440,18 +535,25 @@ func (s *materialsRequestService) ExportMaterialsRequest(ctx context.Context, re
 	newRow.AddCell().AddParagraph().AddRun().AddText("")
 	newRow.AddCell().AddParagraph().AddRun().AddText("")
 	newRow.AddCell().AddParagraph().AddRun().AddText("")
+	addCostCell(newRow, "")
 
-	for consumableName, consumable := range consumableMaterialsMap {
+	for key, consumable := range consumableMaterialsMap {
 		newRow := materialTable.InsertRowBefore(materialTable.Rows()[len(materialTable.Rows())-1])
 		newRow.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%d", currentTableIndex))
-		newRow.AddCell().AddParagraph().AddRun().AddText(consumableName)
+		newRow.AddCell().AddParagraph().AddRun().AddText(consumable.Name)
 		newRow.AddCell().AddParagraph().AddRun().AddText(consumable.Unit)
 		newRow.AddCell().AddParagraph().AddRun().AddText("")
 		newRow.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%.2f", consumable.Quantity))
 		newRow.AddCell().AddParagraph().AddRun().AddText("")
 		newRow.AddCell().AddParagraph().AddRun().AddText("")
+		if book != nil {
+			addCostCell(newRow, cost.row(consumableCosts[key]))
+		}
 		currentTableIndex += 1
 	}
+	if totalCostCell != nil {
+		totalCostCell.AddParagraph().AddRun().AddText(cost.totalText())
+	}
 
 	tempDir := os.TempDir()
 	saveDir := path.Join(tempDir, "material_request")
@@ -472,6 +574,31 @@ func (s *materialsRequestService) ExportMaterialsRequest(ctx context.Context, re
 	return os.Open(fileName)
 }
 
+// exportedCost adds up the cost cells of an exported request. A row with an
+// unpriced line shows no cost and is left out of the total, which is then
+// marked partial.
+type exportedCost struct {
+	total        utils.Decimal
+	unpricedRows int
+}
+
+// row returns the cost cell of a row and adds it to the total.
+func (c *exportedCost) row(summary types.CostSummary) string {
+	if summary.UnpricedLines > 0 {
+		c.unpricedRows++
+		return ""
+	}
+	c.total = c.total.Add(summary.Total)
+	return summary.Total.Format()
+}
+
+func (c *exportedCost) totalText() string {
+	if c.unpricedRows == 0 {
+		return c.total.Format()
+	}
+	return fmt.Sprintf("%s (chưa tính %d dòng chưa có giá)", c.total.Format(), c.unpricedRows)
+}
+
 func (s *materialsRequestService) replacePlaceholderInDoc(doc *document.Document, maintenance *types.Maintenance, materialRequest *types.MaterialRequest) {
 	replacements := map[string]string{
 		"{project}":     maintenance.Project,
@@ -0,0 +1,812 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/remiehneppo/material-management/internal/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ database.Database = &memoryDatabase{}
+
+// memoryDatabase is an in-memory database.Database for service tests. It
+// understands the subset of filters used by the repositories: equality, $and,
+// $in (with regular expressions), $ne, $exists, $regex, $gte and $lte on top
+// level fields, matching any element of array fields, and the $expr filters
+// finding catalog references and overrun lines. Updates may use $set, $setOnInsert, $inc and
+// $max. Queries are sorted on numbers and strings. Aggregations are not run:
+// they return the documents given to aggregateResults and record their
+// pipeline. Every call counts as one round trip and waits for latency.
+// Transactions restore the collections when they fail but are not isolated
+// from concurrent calls.
+type memoryDatabase struct {
+	mu          sync.Mutex
+	collections map[string][]bson.M
+	latency     time.Duration
+	calls       int
+	// failures are the errors returned by the writes to a collection
+	failures map[string]error
+	// aggregations are the documents returned by the aggregations on a
+	// collection, pipelines the pipelines they were called with
+	aggregations map[string][]bson.M
+	pipelines    map[string][]interface{}
+	// uniqueIndexes are the keys of the unique indexes of a collection, they
+	// are not enforced
+	uniqueIndexes map[string][][]string
+}
+
+func newMemoryDatabase() *memoryDatabase {
+	return &memoryDatabase{
+		collections:   make(map[string][]bson.M),
+		failures:      make(map[string]error),
+		aggregations:  make(map[string][]bson.M),
+		pipelines:     make(map[string][]interface{}),
+		uniqueIndexes: make(map[string][][]string),
+	}
+}
+
+// failWrites makes the writes to the collection fail with err.
+func (m *memoryDatabase) failWrites(collection string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[collection] = err
+}
+
+// aggregateResults makes the aggregations on the collection return docs.
+func (m *memoryDatabase) aggregateResults(collection string, docs ...bson.M) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aggregations[collection] = docs
+}
+
+// lastPipeline returns the pipeline of the last aggregation on the collection.
+func (m *memoryDatabase) lastPipeline(collection string) bson.A {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pipelines := m.pipelines[collection]
+	if len(pipelines) == 0 {
+		return nil
+	}
+	pipeline, _ := pipelines[len(pipelines)-1].(bson.A)
+	return pipeline
+}
+
+func (m *memoryDatabase) roundTrip() {
+	m.calls++
+	if m.latency > 0 {
+		time.Sleep(m.latency)
+	}
+}
+
+func (m *memoryDatabase) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+func (m *memoryDatabase) Connect(ctx context.Context) error    { return nil }
+func (m *memoryDatabase) Disconnect(ctx context.Context) error { return nil }
+
+func (m *memoryDatabase) Save(ctx context.Context, collection string, data interface{}) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	if err := m.failures[collection]; err != nil {
+		return "", err
+	}
+	return m.insert(collection, data)
+}
+
+func (m *memoryDatabase) SaveMany(ctx context.Context, collection string, data []interface{}) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	if err := m.failures[collection]; err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(data))
+	for _, item := range data {
+		id, err := m.insert(collection, item)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (m *memoryDatabase) FindByID(ctx context.Context, collection string, id string, data interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	for _, doc := range m.collections[collection] {
+		if doc["_id"] == id {
+			raw, err := bson.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			return bson.Unmarshal(raw, data)
+		}
+	}
+	return nil
+}
+
+func (m *memoryDatabase) FindAll(ctx context.Context, collection string, sort interface{}, data interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	return decodeDocuments(m.collections[collection], data)
+}
+
+func (m *memoryDatabase) Update(ctx context.Context, collection string, id string, data interface{}) error {
+	return m.UpdateMany(ctx, collection, []string{id}, []interface{}{data})
+}
+
+func (m *memoryDatabase) UpdateMany(ctx context.Context, collection string, ids []string, data []interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	if err := m.failures[collection]; err != nil {
+		return err
+	}
+	for i, id := range ids {
+		fields, err := toDocument(data[i])
+		if err != nil {
+			return err
+		}
+		for _, doc := range m.collections[collection] {
+			if doc["_id"] == id {
+				for key, value := range fields {
+					doc[key] = value
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memoryDatabase) UpdateByFilter(ctx context.Context, collection string, filter interface{}, update interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	if err := m.failures[collection]; err != nil {
+		return err
+	}
+	for _, doc := range m.collections[collection] {
+		if matchDocument(doc, filter) {
+			if err := applyUpdate(doc, update, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memoryDatabase) UpsertMany(ctx context.Context, collection string, filters []interface{}, updates []interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	if err := m.failures[collection]; err != nil {
+		return err
+	}
+	for i, filter := range filters {
+		if _, err := m.updateOne(collection, filter, updates[i], true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryDatabase) FindOneAndUpdate(ctx context.Context, collection string, filter interface{}, update interface{}, upsert bool, data interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	if err := m.failures[collection]; err != nil {
+		return err
+	}
+	doc, err := m.updateOne(collection, filter, update, upsert)
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		return database.ErrNoDocument
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, data)
+}
+
+// updateOne applies the update to the first document matching the filter,
+// inserting one when none matches and upsert is set. It returns the updated
+// document, nil when nothing was updated.
+func (m *memoryDatabase) updateOne(collection string, filter interface{}, update interface{}, upsert bool) (bson.M, error) {
+	for _, doc := range m.collections[collection] {
+		if matchDocument(doc, filter) {
+			return doc, applyUpdate(doc, update, false)
+		}
+	}
+	if !upsert {
+		return nil, nil
+	}
+	target := bson.M{"_id": bson.NewObjectID().Hex()}
+	for key, value := range filter.(bson.M) {
+		if !strings.HasPrefix(key, "$") && !isOperator(value) {
+			target[key] = normalizeValue(value)
+		}
+	}
+	if err := applyUpdate(target, update, true); err != nil {
+		return nil, err
+	}
+	m.collections[collection] = append(m.collections[collection], target)
+	return target, nil
+}
+
+func (m *memoryDatabase) Delete(ctx context.Context, collection string, id string) error {
+	return m.DeleteMany(ctx, collection, bson.M{"_id": id})
+}
+
+func (m *memoryDatabase) DeleteMany(ctx context.Context, collection string, filter interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	if err := m.failures[collection]; err != nil {
+		return err
+	}
+	kept := make([]bson.M, 0, len(m.collections[collection]))
+	for _, doc := range m.collections[collection] {
+		if !matchDocument(doc, filter) {
+			kept = append(kept, doc)
+		}
+	}
+	m.collections[collection] = kept
+	return nil
+}
+
+func (m *memoryDatabase) Query(ctx context.Context, collection string, filter interface{}, skip int64, limit int64, sort interface{}, data interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	found := make([]bson.M, 0)
+	for _, doc := range m.collections[collection] {
+		if matchDocument(doc, filter) {
+			found = append(found, doc)
+		}
+	}
+	if keys, ok := sort.(bson.D); ok {
+		slices.SortStableFunc(found, func(a, b bson.M) int {
+			for _, key := range keys {
+				if c := compareValues(a[key.Key], b[key.Key]); c != 0 {
+					return c * int(toFloat(key.Value))
+				}
+			}
+			return 0
+		})
+	}
+	found = found[min(int(skip), len(found)):]
+	if limit > 0 && int(limit) < len(found) {
+		found = found[:limit]
+	}
+	return decodeDocuments(found, data)
+}
+
+func (m *memoryDatabase) Aggregate(ctx context.Context, collection string, pipeline interface{}, data interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	m.pipelines[collection] = append(m.pipelines[collection], pipeline)
+	docs, ok := m.aggregations[collection]
+	if !ok {
+		return errors.New("memory database: aggregate is not supported")
+	}
+	return decodeDocuments(docs, data)
+}
+
+func (m *memoryDatabase) Count(ctx context.Context, collection string, filter interface{}) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roundTrip()
+	count := int64(0)
+	for _, doc := range m.collections[collection] {
+		if matchDocument(doc, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryDatabase) CreateIndex(ctx context.Context, collection string, keys []string, unique bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if unique {
+		m.uniqueIndexes[collection] = append(m.uniqueIndexes[collection], keys)
+	}
+	return nil
+}
+
+func (m *memoryDatabase) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.mu.Lock()
+	snapshot := make(map[string][]bson.M, len(m.collections))
+	for collection, docs := range m.collections {
+		copied := make([]bson.M, len(docs))
+		for i, doc := range docs {
+			var err error
+			if copied[i], err = toDocument(doc); err != nil {
+				m.mu.Unlock()
+				return err
+			}
+		}
+		snapshot[collection] = copied
+	}
+	m.mu.Unlock()
+
+	if err := fn(ctx); err != nil {
+		m.mu.Lock()
+		m.collections = snapshot
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (m *memoryDatabase) insert(collection string, data interface{}) (string, error) {
+	doc, err := toDocument(data)
+	if err != nil {
+		return "", err
+	}
+	id := bson.NewObjectID().Hex()
+	doc["_id"] = id
+	m.collections[collection] = append(m.collections[collection], doc)
+	return id, nil
+}
+
+// toDocument turns a struct into the document the mongo driver would store.
+func toDocument(data interface{}) (bson.M, error) {
+	raw, err := bson.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.M{}
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func decodeDocuments(docs []bson.M, data interface{}) error {
+	raw, err := bson.Marshal(bson.M{"documents": docs})
+	if err != nil {
+		return err
+	}
+	return bson.Raw(raw).Lookup("documents").Unmarshal(data)
+}
+
+func applyUpdate(doc bson.M, update interface{}, inserting bool) error {
+	for operator, fields := range update.(bson.M) {
+		switch operator {
+		case "$set":
+		case "$setOnInsert":
+			if !inserting {
+				continue
+			}
+		case "$inc":
+			for key, delta := range fields.(bson.M) {
+				current, ok := toInt(doc[key])
+				if doc[key] == nil {
+					current, ok = 0, true
+				}
+				if d, isInt := toInt(delta); ok && isInt {
+					doc[key] = current + d
+				} else {
+					doc[key] = toFloat(doc[key]) + toFloat(delta)
+				}
+			}
+			continue
+		case "$max":
+			for key, value := range fields.(bson.M) {
+				if current, ok := doc[key]; !ok || compareValues(value, current) > 0 {
+					doc[key] = value
+				}
+			}
+			continue
+		default:
+			return fmt.Errorf("memory database: update operator %s is not supported", operator)
+		}
+		for key, value := range fields.(bson.M) {
+			doc[key] = value
+		}
+	}
+	return nil
+}
+
+func matchDocument(doc bson.M, filter interface{}) bool {
+	if filter == nil {
+		return true
+	}
+	conditions, ok := filter.(bson.M)
+	if !ok {
+		panic(fmt.Sprintf("memory database: unsupported filter %T", filter))
+	}
+	for key, condition := range conditions {
+		if key == "$and" {
+			for _, sub := range reflectSlice(condition) {
+				if !matchDocument(doc, sub) {
+					return false
+				}
+			}
+			continue
+		}
+		if key == "$expr" {
+			if evalExpr(doc, nil, condition) != true {
+				return false
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			panic("memory database: unsupported filter operator " + key)
+		}
+		if !matchValue(doc, key, condition) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchValue(doc bson.M, key string, condition interface{}) bool {
+	value, exists := doc[key]
+	// like mongo, a condition on an array field holds when an element matches
+	if values, ok := value.(bson.A); ok && !isOperatorOn(condition, "$exists", "$ne") {
+		for _, element := range values {
+			if matchValue(bson.M{key: element}, key, condition) {
+				return true
+			}
+		}
+		return false
+	}
+	operators, ok := condition.(bson.M)
+	if !ok || !isOperator(condition) {
+		return exists && equalValues(value, condition)
+	}
+	for operator, operand := range operators {
+		switch operator {
+		case "$in":
+			found := false
+			for _, candidate := range reflectSlice(operand) {
+				if regex, ok := candidate.(bson.Regex); ok {
+					found = exists && matchRegex(value, regex.Pattern, regex.Options)
+				} else if candidate == nil {
+					// like mongo, null matches a missing field
+					found = !exists || value == nil
+				} else {
+					found = exists && equalValues(value, candidate)
+				}
+				if found {
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		case "$ne":
+			if exists && equalValues(value, operand) {
+				return false
+			}
+		case "$exists":
+			if exists != operand.(bool) {
+				return false
+			}
+		case "$regex":
+			options, _ := operators["$options"].(string)
+			if !exists || !matchRegex(value, operand.(string), options) {
+				return false
+			}
+		case "$options":
+		case "$gte":
+			if !exists || compareValues(value, operand) < 0 {
+				return false
+			}
+		case "$lte":
+			if !exists || compareValues(value, operand) > 0 {
+				return false
+			}
+		default:
+			panic("memory database: unsupported filter operator " + operator)
+		}
+	}
+	return true
+}
+
+// evalExpr evaluates the aggregation expressions of the $expr filters used to
+// find catalog references and overrun lines: field paths, variables, $in,
+// $ifNull, $map, $reduce, $filter, $let, $cond, $objectToArray,
+// $concatArrays, $arrayElemAt, comparisons, $and, $or and arithmetic.
+func evalExpr(doc bson.M, vars map[string]interface{}, expr interface{}) interface{} {
+	switch e := expr.(type) {
+	case string:
+		if strings.HasPrefix(e, "$$") {
+			path := strings.Split(e[2:], ".")
+			return lookupPath(vars[path[0]], path[1:])
+		}
+		if strings.HasPrefix(e, "$") {
+			return lookupPath(doc, strings.Split(e[1:], "."))
+		}
+		return e
+	case bson.A:
+		values := make(bson.A, len(e))
+		for i, item := range e {
+			values[i] = evalExpr(doc, vars, item)
+		}
+		return values
+	case bson.M:
+		if !isOperator(e) {
+			return e
+		}
+		for operator, operand := range e {
+			switch operator {
+			case "$in":
+				args := reflectSlice(operand)
+				value := evalExpr(doc, vars, args[0])
+				for _, item := range reflectSlice(evalExpr(doc, vars, args[1])) {
+					if equalValues(value, item) {
+						return true
+					}
+				}
+				return false
+			case "$ifNull":
+				args := reflectSlice(operand)
+				if value := evalExpr(doc, vars, args[0]); value != nil {
+					return value
+				}
+				return evalExpr(doc, vars, args[1])
+			case "$objectToArray":
+				pairs := bson.A{}
+				for key, value := range documentFields(evalExpr(doc, vars, operand)) {
+					pairs = append(pairs, bson.M{"k": key, "v": value})
+				}
+				return pairs
+			case "$concatArrays":
+				values := bson.A{}
+				for _, arg := range reflectSlice(operand) {
+					values = append(values, reflectSlice(evalExpr(doc, vars, arg))...)
+				}
+				return values
+			case "$map":
+				spec := operand.(bson.M)
+				values := bson.A{}
+				for _, item := range reflectSlice(evalExpr(doc, vars, spec["input"])) {
+					values = append(values, evalExpr(doc, withVar(vars, spec["as"].(string), item), spec["in"]))
+				}
+				return values
+			case "$reduce":
+				spec := operand.(bson.M)
+				value := evalExpr(doc, vars, spec["initialValue"])
+				for _, item := range reflectSlice(evalExpr(doc, vars, spec["input"])) {
+					value = evalExpr(doc, withVar(withVar(vars, "value", value), "this", item), spec["in"])
+				}
+				return value
+			case "$filter":
+				spec := operand.(bson.M)
+				values := bson.A{}
+				for _, item := range reflectSlice(evalExpr(doc, vars, spec["input"])) {
+					if evalExpr(doc, withVar(vars, spec["as"].(string), item), spec["cond"]) == true {
+						values = append(values, item)
+					}
+				}
+				return values
+			case "$let":
+				spec := operand.(bson.M)
+				scoped := vars
+				for name, value := range spec["vars"].(bson.M) {
+					scoped = withVar(scoped, name, evalExpr(doc, vars, value))
+				}
+				return evalExpr(doc, scoped, spec["in"])
+			case "$cond":
+				args := reflectSlice(operand)
+				if evalExpr(doc, vars, args[0]) == true {
+					return evalExpr(doc, vars, args[1])
+				}
+				return evalExpr(doc, vars, args[2])
+			case "$arrayElemAt":
+				args := reflectSlice(operand)
+				values := reflectSlice(evalExpr(doc, vars, args[0]))
+				if i := int(toFloat(evalExpr(doc, vars, args[1]))); i < len(values) {
+					return values[i]
+				}
+				return nil
+			case "$and", "$or":
+				for _, arg := range reflectSlice(operand) {
+					if (evalExpr(doc, vars, arg) == true) == (operator == "$or") {
+						return operator == "$or"
+					}
+				}
+				return operator == "$and"
+			case "$eq":
+				args := reflectSlice(operand)
+				return equalValues(evalExpr(doc, vars, args[0]), evalExpr(doc, vars, args[1]))
+			case "$gt", "$lte":
+				args := reflectSlice(operand)
+				greater := compareValues(evalExpr(doc, vars, args[0]), evalExpr(doc, vars, args[1])) > 0
+				return greater == (operator == "$gt")
+			case "$sum", "$add":
+				// $sum adds the elements of an array, $add its arguments
+				args := evalExpr(doc, vars, operand)
+				if operator == "$add" {
+					args = evalExpr(doc, vars, bson.A(reflectSlice(operand)))
+				}
+				sum := 0.0
+				for _, value := range reflectSlice(args) {
+					sum += toFloat(value)
+				}
+				return sum
+			case "$subtract":
+				args := reflectSlice(evalExpr(doc, vars, bson.A(reflectSlice(operand))))
+				return toFloat(args[0]) - toFloat(args[1])
+			case "$max":
+				args := reflectSlice(evalExpr(doc, vars, bson.A(reflectSlice(operand))))
+				max := args[0]
+				for _, value := range args[1:] {
+					if compareValues(value, max) > 0 {
+						max = value
+					}
+				}
+				return max
+			}
+			panic("memory database: unsupported expression operator " + operator)
+		}
+	}
+	return expr
+}
+
+func withVar(vars map[string]interface{}, name string, value interface{}) map[string]interface{} {
+	scoped := make(map[string]interface{}, len(vars)+1)
+	for key, v := range vars {
+		scoped[key] = v
+	}
+	scoped[name] = value
+	return scoped
+}
+
+// lookupPath returns the value at the dotted path of a document, nil when
+// missing.
+func lookupPath(value interface{}, path []string) interface{} {
+	for _, key := range path {
+		fields := documentFields(value)
+		if fields == nil {
+			return nil
+		}
+		value = fields[key]
+	}
+	return value
+}
+
+func documentFields(value interface{}) bson.M {
+	switch v := value.(type) {
+	case bson.M:
+		return v
+	case bson.D:
+		fields := make(bson.M, len(v))
+		for _, e := range v {
+			fields[e.Key] = e.Value
+		}
+		return fields
+	}
+	return nil
+}
+
+// isOperatorOn reports whether the condition uses one of the operators.
+func isOperatorOn(condition interface{}, operators ...string) bool {
+	fields, ok := condition.(bson.M)
+	if !ok {
+		return false
+	}
+	for _, operator := range operators {
+		if _, ok := fields[operator]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isOperator(condition interface{}) bool {
+	operators, ok := condition.(bson.M)
+	if !ok {
+		return false
+	}
+	for key := range operators {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return len(operators) > 0
+}
+
+func matchRegex(value interface{}, pattern, options string) bool {
+	text, ok := value.(string)
+	if !ok {
+		return false
+	}
+	if strings.Contains(options, "i") {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.MustCompile(pattern).MatchString(text)
+}
+
+func equalValues(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeValue(a), normalizeValue(b))
+}
+
+// normalizeValue compares ids as hex strings and numbers as float64.
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.ObjectID:
+		return v.Hex()
+	case int, int32, int64, float32, float64:
+		return toFloat(v)
+	}
+	return value
+}
+
+// compareValues orders numbers and strings, and missing values first.
+func compareValues(a, b interface{}) int {
+	if as, ok := a.(string); ok {
+		bs, _ := b.(string)
+		return cmp.Compare(as, bs)
+	}
+	// encoded index paths need the exact integer comparison mongodb does
+	if ai, ok := toInt(a); ok {
+		if bi, ok := toInt(b); ok {
+			return cmp.Compare(ai, bi)
+		}
+	}
+	return cmp.Compare(toFloat(a), toFloat(b))
+}
+
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func reflectSlice(value interface{}) []interface{} {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice {
+		panic(fmt.Sprintf("memory database: expected a list, got %T", value))
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items
+}
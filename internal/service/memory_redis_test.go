@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// memoryRedis is an in-memory redis.Cmdable for service tests. It implements
+// the string commands used by the services, calling any other one panics.
+type memoryRedis struct {
+	redis.Cmdable
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+func newMemoryRedis() *memoryRedis {
+	return &memoryRedis{
+		values:  make(map[string]string),
+		expires: make(map[string]time.Time),
+	}
+}
+
+// lookup returns the value of a key, dropping it when expired.
+func (r *memoryRedis) lookup(key string) (string, bool) {
+	if expires, ok := r.expires[key]; ok && time.Now().After(expires) {
+		delete(r.values, key)
+		delete(r.expires, key)
+	}
+	value, ok := r.values[key]
+	return value, ok
+}
+
+func (r *memoryRedis) store(key string, value interface{}, expiration time.Duration) {
+	switch v := value.(type) {
+	case []byte:
+		r.values[key] = string(v)
+	default:
+		r.values[key] = fmt.Sprint(v)
+	}
+	delete(r.expires, key)
+	if expiration > 0 {
+		r.expires[key] = time.Now().Add(expiration)
+	}
+}
+
+// expire drops a key as if its time to live elapsed.
+func (r *memoryRedis) expire(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.values, key)
+	delete(r.expires, key)
+}
+
+func (r *memoryRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.lookup(key)
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(value, nil)
+}
+
+func (r *memoryRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store(key, value, expiration)
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (r *memoryRedis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.lookup(key); ok {
+		return redis.NewBoolResult(false, nil)
+	}
+	r.store(key, value, expiration)
+	return redis.NewBoolResult(true, nil)
+}
+
+func (r *memoryRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deleted := int64(0)
+	for _, key := range keys {
+		if _, ok := r.lookup(key); ok {
+			delete(r.values, key)
+			delete(r.expires, key)
+			deleted++
+		}
+	}
+	return redis.NewIntResult(deleted, nil)
+}
+
+func (r *memoryRedis) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := int64(0)
+	for _, key := range keys {
+		if _, ok := r.lookup(key); ok {
+			found++
+		}
+	}
+	return redis.NewIntResult(found, nil)
+}
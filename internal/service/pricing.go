@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+// priceBook prices material lines from their own unit price or from the
+// price of their catalog material effective at a date. It holds the whole
+// price history of the catalog materials it was loaded for, so that lines of
+// different dates are priced without further queries.
+type priceBook struct {
+	catalog map[string]*types.CatalogMaterial
+	// prices of every catalog material, latest effective date first
+	prices map[string][]*types.CatalogPrice
+}
+
+// loadPriceBook loads the catalog materials referenced by the materials and
+// their price history in two queries.
+func loadPriceBook(ctx context.Context, catalogRepo repository.CatalogRepository, priceRepo repository.PriceRepository, materials ...types.MaterialsForEquipment) (*priceBook, error) {
+	book := &priceBook{
+		catalog: make(map[string]*types.CatalogMaterial),
+		prices:  make(map[string][]*types.CatalogPrice),
+	}
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range materials {
+		for _, section := range []map[string]types.Material{m.ReplacementMaterials, m.ConsumableSupplies} {
+			for _, material := range section {
+				if material.CatalogID != "" && !seen[material.CatalogID] {
+					seen[material.CatalogID] = true
+					ids = append(ids, material.CatalogID)
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return book, nil
+	}
+	catalog, err := catalogRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	book.catalog = catalog
+	prices, err := priceRepo.FindByCatalogIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, price := range prices {
+		book.prices[price.CatalogID] = append(book.prices[price.CatalogID], price)
+	}
+	for _, history := range book.prices {
+		sortPriceHistory(history)
+	}
+	return book, nil
+}
+
+// sortPriceHistory orders prices by effective date, latest first, the price
+// recorded last winning on the same date.
+func sortPriceHistory(prices []*types.CatalogPrice) {
+	sort.SliceStable(prices, func(i, j int) bool {
+		if prices[i].EffectiveFrom != prices[j].EffectiveFrom {
+			return prices[i].EffectiveFrom > prices[j].EffectiveFrom
+		}
+		return prices[i].CreatedAt > prices[j].CreatedAt
+	})
+}
+
+// catalogPrice returns the price of a catalog material effective at a date.
+func (b *priceBook) catalogPrice(catalogID string, at int64) (utils.Decimal, bool) {
+	for _, price := range b.prices[catalogID] {
+		if price.EffectiveFrom <= at {
+			return price.Price, true
+		}
+	}
+	return utils.Decimal{}, false
+}
+
+// line prices a material at a date. The unit price is expressed in the unit
+// of the material, converted from the default unit of the catalog material.
+func (b *priceBook) line(materialType string, material types.Material, at int64) *types.CostLine {
+	line := &types.CostLine{
+		MaterialType: materialType,
+		Name:         material.Name,
+		Unit:         material.Unit,
+		Quantity:     material.Quantity,
+	}
+	if !material.UnitPrice.IsZero() {
+		line.UnitPrice = material.UnitPrice
+		line.Cost = material.UnitPrice.MulQuantity(material.Quantity)
+		line.Priced = true
+		return line
+	}
+	catalog := b.catalog[material.CatalogID]
+	if catalog == nil {
+		return line
+	}
+	price, ok := b.catalogPrice(catalog.ID, at)
+	if !ok {
+		return line
+	}
+	unit := material.Unit
+	if unit == "" {
+		unit = catalog.DefaultUnit
+	}
+	packSizes := catalogPackSizes(catalog)
+	factor, ok := utils.ConvertQuantity(1, unit, catalog.DefaultUnit, packSizes)
+	if !ok {
+		return line
+	}
+	quantity, _ := utils.ConvertQuantity(material.Quantity, unit, catalog.DefaultUnit, packSizes)
+	line.UnitPrice = price.MulQuantity(factor)
+	line.Cost = price.MulQuantity(quantity)
+	line.Priced = true
+	return line
+}
+
+// lines prices the materials of an equipment, replacement materials first and
+// then by name.
+func (b *priceBook) lines(materials types.MaterialsForEquipment, at int64) []*types.CostLine {
+	lines := make([]*types.CostLine, 0)
+	sections := []struct {
+		materialType string
+		materials    map[string]types.Material
+	}{
+		{types.MATERIAL_TYPE_REPLACEMENT, materials.ReplacementMaterials},
+		{types.MATERIAL_TYPE_CONSUMABLE, materials.ConsumableSupplies},
+	}
+	for _, section := range sections {
+		for _, name := range sortedMaterialNames(section.materials) {
+			line := b.line(section.materialType, section.materials[name], at)
+			line.Name = name
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func addCostLine(summary *types.CostSummary, line *types.CostLine) {
+	if !line.Priced {
+		summary.UnpricedLines++
+		return
+	}
+	summary.Total = summary.Total.Add(line.Cost)
+	summary.PricedLines++
+}
+
+func addCostSummary(summary *types.CostSummary, other types.CostSummary) {
+	summary.Total = summary.Total.Add(other.Total)
+	summary.PricedLines += other.PricedLines
+	summary.UnpricedLines += other.UnpricedLines
+}
+
+func summarizeCostLines(lines []*types.CostLine) types.CostSummary {
+	var summary types.CostSummary
+	for _, line := range lines {
+		addCostLine(&summary, line)
+	}
+	return summary
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+func TestPriceBook(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	priceRepo := repository.NewPriceRepository(db)
+
+	boltID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{
+		Code:        "BL-01",
+		Name:        "Bu lông M12",
+		DefaultUnit: "cái",
+		PackSizes:   map[string]float64{"hộp": 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, price := range []*types.CatalogPrice{
+		{CatalogID: boltID, Price: utils.NewDecimal(1000), EffectiveFrom: 100, CreatedAt: 1},
+		{CatalogID: boltID, Price: utils.NewDecimal(1200), EffectiveFrom: 200, CreatedAt: 2},
+		// recorded later for the same date, it replaces the previous one
+		{CatalogID: boltID, Price: utils.NewDecimal(1100), EffectiveFrom: 100, CreatedAt: 3},
+	} {
+		if _, err := priceRepo.Save(ctx, price); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ownPrice, _ := utils.ParseDecimal("2500.5")
+	materials := types.MaterialsForEquipment{
+		ReplacementMaterials: map[string]types.Material{
+			"Bu lông":  {Name: "Bu lông", Unit: "hộp", Quantity: 2, CatalogID: boltID},
+			"Bu lông2": {Name: "Bu lông", Unit: "kg", Quantity: 1, CatalogID: boltID},
+		},
+		ConsumableSupplies: map[string]types.Material{
+			"Sơn": {Name: "Sơn", Unit: "lít", Quantity: 3, UnitPrice: ownPrice},
+			"Giẻ": {Name: "Giẻ", Unit: "kg", Quantity: 1},
+		},
+	}
+	book, err := loadPriceBook(ctx, catalogRepo, priceRepo, materials)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		at        int64
+		priced    bool
+		unitPrice string
+		cost      string
+	}{
+		{name: "Bu lông", at: 50, priced: false},
+		{name: "Bu lông", at: 150, priced: true, unitPrice: "11000", cost: "22000"},
+		{name: "Bu lông", at: 250, priced: true, unitPrice: "12000", cost: "24000"},
+		// kilograms do not convert to pieces
+		{name: "Bu lông2", at: 250, priced: false},
+	}
+	for _, tt := range tests {
+		line := book.line(types.MATERIAL_TYPE_REPLACEMENT, materials.ReplacementMaterials[tt.name], tt.at)
+		if line.Priced != tt.priced {
+			t.Errorf("%s at %d priced = %v, want %v", tt.name, tt.at, line.Priced, tt.priced)
+			continue
+		}
+		if tt.priced && (line.UnitPrice.String() != tt.unitPrice || line.Cost.String() != tt.cost) {
+			t.Errorf("%s at %d = %s × %v = %s, want %s and %s", tt.name, tt.at, line.UnitPrice, line.Quantity, line.Cost, tt.unitPrice, tt.cost)
+		}
+	}
+
+	summary := summarizeCostLines(book.lines(materials, 250))
+	if summary.Total.String() != "31501.5" || summary.PricedLines != 2 || summary.UnpricedLines != 2 {
+		t.Errorf("summary = %+v, want 31501.5 over 2 priced and 2 unpriced lines", summary)
+	}
+}
+
+func TestExportedCostLeavesOutUnpricedRows(t *testing.T) {
+	var cost exportedCost
+	priced := types.CostSummary{Total: utils.NewDecimal(1000), PricedLines: 1}
+	// a consumable merged from a priced and an unpriced line
+	partial := types.CostSummary{Total: utils.NewDecimal(500), PricedLines: 1, UnpricedLines: 1}
+
+	if cell := cost.row(priced); cell != priced.Total.Format() {
+		t.Errorf("priced row shows %q, want its cost", cell)
+	}
+	if cell := cost.row(partial); cell != "" {
+		t.Errorf("partly priced row shows %q, want no cost", cell)
+	}
+	if want := priced.Total.Format() + " (chưa tính 1 dòng chưa có giá)"; cost.totalText() != want {
+		t.Errorf("total = %q, want %q", cost.totalText(), want)
+	}
+	var full exportedCost
+	full.row(priced)
+	full.row(priced)
+	if want := utils.NewDecimal(2000).Format(); full.totalText() != want {
+		t.Errorf("total of priced rows = %q, want %q", full.totalText(), want)
+	}
+}
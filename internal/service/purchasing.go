@@ -0,0 +1,259 @@
+package service
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+// materialNeed is the estimate of a material not consumed yet, summed over
+// materials profiles.
+type materialNeed struct {
+	catalogID string
+	code      string
+	name      string
+	unit      string
+	quantity  float64
+}
+
+// needKey identifies a material across profiles and requisitions: the
+// catalog material and unit of linked lines, the normalized name and unit of
+// the others.
+func needKey(catalogID, name, unit string) string {
+	if catalogID != "" {
+		return catalogID + "\x00" + unit
+	}
+	return utils.NormalizeName(name) + "\x00" + utils.NormalizeUnit(unit)
+}
+
+// roundQuantity drops the floating point noise of subtracted quantities.
+func roundQuantity(quantity float64) float64 {
+	return math.Round(quantity*1e6) / 1e6
+}
+
+// remainingNeeds sums the estimate minus the reality of every material of
+// the profiles, sorted by name. Materials linked to the catalog are summed in
+// the default unit of their catalog material.
+func remainingNeeds(profiles []*types.MaterialsProfile, catalog map[string]*types.CatalogMaterial) []*materialNeed {
+	needs := make(map[string]*materialNeed)
+	for _, profile := range profiles {
+		sections := []struct {
+			estimate map[string]types.Material
+			reality  map[string]types.Material
+		}{
+			{profile.Estimate.ReplacementMaterials, profile.Reality.ReplacementMaterials},
+			{profile.Estimate.ConsumableSupplies, profile.Reality.ConsumableSupplies},
+		}
+		for _, section := range sections {
+			for name, material := range section.estimate {
+				catalogMaterial := catalog[material.CatalogID]
+				remaining := material.Quantity
+				if consumed, ok := section.reality[name]; ok {
+					if quantity, ok := utils.ConvertQuantity(consumed.Quantity, consumed.Unit, material.Unit, catalogPackSizes(catalogMaterial)); ok {
+						remaining -= quantity
+					}
+				}
+				if remaining = roundQuantity(remaining); remaining <= 0 {
+					continue
+				}
+				material.Quantity = remaining
+				material = toCatalogUnit(material, catalogMaterial)
+				key := needKey(material.CatalogID, name, material.Unit)
+				need, ok := needs[key]
+				if !ok {
+					need = &materialNeed{
+						catalogID: material.CatalogID,
+						name:      material.Name,
+						unit:      material.Unit,
+					}
+					if catalogMaterial != nil {
+						need.code = catalogMaterial.Code
+						need.name = catalogMaterial.Name
+					}
+					if need.name == "" {
+						need.name = name
+					}
+					needs[key] = need
+				}
+				need.quantity = roundQuantity(need.quantity + material.Quantity)
+			}
+		}
+	}
+	sorted := make([]*materialNeed, 0, len(needs))
+	for _, need := range needs {
+		sorted = append(sorted, need)
+	}
+	slices.SortFunc(sorted, func(a, b *materialNeed) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), cmp.Compare(a.unit, b.unit))
+	})
+	return sorted
+}
+
+// stockOnHand sums the balances of every warehouse per catalog material, in
+// its default unit.
+func stockOnHand(balances []*types.StockBalance, catalog map[string]*types.CatalogMaterial) map[string]float64 {
+	stock := make(map[string]float64)
+	for _, balance := range balances {
+		quantity := balance.Quantity
+		if material := catalog[balance.CatalogID]; material != nil && balance.Unit != "" {
+			converted, ok := utils.ConvertQuantity(balance.Quantity, balance.Unit, material.DefaultUnit, catalogPackSizes(material))
+			if !ok {
+				continue
+			}
+			quantity = converted
+		}
+		stock[balance.CatalogID] += quantity
+	}
+	return stock
+}
+
+// inTransitStock sums the quantities of the transfers in transit to one of
+// the warehouses, to any warehouse when none is given, per catalog material
+// in its default unit.
+func inTransitStock(transfers []*types.StockTransfer, warehouses []string) map[string]float64 {
+	stock := make(map[string]float64)
+	for _, transfer := range transfers {
+		if transfer.Status != types.TRANSFER_STATUS_IN_TRANSIT {
+			continue
+		}
+		if len(warehouses) > 0 && !slices.Contains(warehouses, transfer.ToWarehouse) {
+			continue
+		}
+		for _, line := range transfer.Lines {
+			stock[line.CatalogID] += line.Delta
+		}
+	}
+	return stock
+}
+
+// requisitionedQuantities sums the quantities of requisitions per material.
+func requisitionedQuantities(requisitions []*types.PurchaseRequisition) map[string]float64 {
+	quantities := make(map[string]float64)
+	for _, requisition := range requisitions {
+		for _, group := range requisition.Groups {
+			for _, line := range group.Lines {
+				quantities[needKey(line.CatalogID, line.Name, line.Unit)] += line.Quantity
+			}
+		}
+	}
+	return quantities
+}
+
+// committedStock sums the stock requisitions counted on per catalog material.
+func committedStock(requisitions []*types.PurchaseRequisition) map[string]float64 {
+	committed := make(map[string]float64)
+	for _, requisition := range requisitions {
+		for _, group := range requisition.Groups {
+			for _, line := range group.Lines {
+				if line.CatalogID != "" {
+					committed[line.CatalogID] += line.Stock
+				}
+			}
+		}
+	}
+	return committed
+}
+
+// sharesWarehouses reports whether requisitions made for the two warehouse
+// lists count on the same stock. An empty list stands for every warehouse.
+func sharesWarehouses(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(a, func(warehouse string) bool {
+		return slices.Contains(b, warehouse)
+	})
+}
+
+// preferredSupplier returns the supplier a catalog material is purchased
+// from: one preferred for it, then the one with the shortest lead time, the
+// first by name on ties. It is nil when no supplier supplies the material.
+func preferredSupplier(catalogID string, suppliers []*types.Supplier) *types.Supplier {
+	var best *types.Supplier
+	for _, supplier := range suppliers {
+		if !slices.Contains(supplier.CatalogIDs, catalogID) {
+			continue
+		}
+		if best == nil || compareSuppliers(catalogID, supplier, best) < 0 {
+			best = supplier
+		}
+	}
+	return best
+}
+
+func compareSuppliers(catalogID string, a, b *types.Supplier) int {
+	aPreferred, bPreferred := slices.Contains(a.PreferredFor, catalogID), slices.Contains(b.PreferredFor, catalogID)
+	if aPreferred != bPreferred {
+		if aPreferred {
+			return -1
+		}
+		return 1
+	}
+	return cmp.Or(cmp.Compare(a.LeadTimeDays, b.LeadTimeDays), cmp.Compare(a.Name, b.Name))
+}
+
+// requisitionGroups subtracts the stock on hand and the quantities on order
+// from the needs and groups the materials left to purchase by supplier,
+// sorted by supplier name with the materials without supplier last. Stock is
+// only subtracted from needs in the default unit of the catalog material.
+func requisitionGroups(needs []*materialNeed, stock, onOrder map[string]float64, catalog map[string]*types.CatalogMaterial, suppliers []*types.Supplier) []types.RequisitionGroup {
+	groups := make(map[string]*types.RequisitionGroup)
+	for _, need := range needs {
+		line := types.RequisitionLine{
+			CatalogID: need.catalogID,
+			Code:      need.code,
+			Name:      need.name,
+			Unit:      need.unit,
+			Remaining: need.quantity,
+			OnOrder:   roundQuantity(onOrder[needKey(need.catalogID, need.name, need.unit)]),
+		}
+		// the stock is allocated to the needs in turn so that needs of the
+		// same material in several sections do not count it twice
+		uncovered := roundQuantity(line.Remaining - line.OnOrder)
+		if material := catalog[need.catalogID]; material != nil && need.unit == material.DefaultUnit && uncovered > 0 {
+			line.Stock = roundQuantity(min(max(stock[need.catalogID], 0), uncovered))
+			stock[need.catalogID] -= line.Stock
+		}
+		line.Quantity = roundQuantity(uncovered - line.Stock)
+		if line.Quantity <= 0 {
+			continue
+		}
+		var group *types.RequisitionGroup
+		supplier := preferredSupplier(need.catalogID, suppliers)
+		if supplier == nil {
+			group = groups[""]
+			if group == nil {
+				group = &types.RequisitionGroup{}
+				groups[""] = group
+			}
+		} else {
+			group = groups[supplier.ID]
+			if group == nil {
+				group = &types.RequisitionGroup{
+					SupplierID:   supplier.ID,
+					SupplierName: supplier.Name,
+					LeadTimeDays: supplier.LeadTimeDays,
+				}
+				groups[supplier.ID] = group
+			}
+		}
+		group.Lines = append(group.Lines, line)
+	}
+	sorted := make([]types.RequisitionGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+	slices.SortFunc(sorted, func(a, b types.RequisitionGroup) int {
+		if (a.SupplierID == "") != (b.SupplierID == "") {
+			if a.SupplierID == "" {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Or(cmp.Compare(a.SupplierName, b.SupplierName), cmp.Compare(a.SupplierID, b.SupplierID))
+	})
+	return sorted
+}
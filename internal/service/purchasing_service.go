@@ -0,0 +1,404 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"baliance.com/gooxml/color"
+	"baliance.com/gooxml/document"
+	"baliance.com/gooxml/measurement"
+	"baliance.com/gooxml/schema/soo/wml"
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+var _ PurchasingService = &purchasingService{}
+
+type PurchasingService interface {
+	// generate a draft purchase requisition from the remaining estimate of a
+	// maintenance minus the stock on hand and the open requisitions
+	CreatePurchaseRequisition(ctx context.Context, req *types.CreatePurchaseRequisitionReq) (*types.PurchaseRequisition, error)
+	GetPurchaseRequisition(ctx context.Context, id string) (*types.PurchaseRequisition, error)
+	FilterPurchaseRequisitions(ctx context.Context, req *types.PurchaseRequisitionFilterReq) ([]*types.PurchaseRequisition, error)
+	// move a requisition along draft, ordered and received, or cancel it
+	UpdateRequisitionStatus(ctx context.Context, req *types.UpdateRequisitionStatusReq) error
+	// printable requisition with one table per supplier
+	ExportPurchaseRequisition(ctx context.Context, id string) (*os.File, error)
+	// the materials upcoming maintenances will be short of, with the dates
+	// they are needed by and must be ordered by
+	ForecastShortages(ctx context.Context, req *types.ShortageForecastReq) (*types.ShortageForecast, error)
+	ExportShortageForecast(ctx context.Context, req *types.ShortageForecastReq) (*os.File, error)
+}
+
+type purchasingService struct {
+	purchaseRequisitionRepo repository.PurchaseRequisitionRepository
+	supplierRepo            repository.SupplierRepository
+	stockRepo               repository.StockRepository
+	stockTransferRepo       repository.StockTransferRepository
+	warehouseRepo           repository.WarehouseRepository
+	catalogRepo             repository.CatalogRepository
+	materialsProfileRepo    repository.MaterialsProfileRepository
+	maintenanceRepo         repository.MaintenanceRepository
+	counterRepo             repository.CounterRepository
+	transactor              repository.Transactor
+}
+
+func NewPurchasingService(
+	purchaseRequisitionRepo repository.PurchaseRequisitionRepository,
+	supplierRepo repository.SupplierRepository,
+	stockRepo repository.StockRepository,
+	stockTransferRepo repository.StockTransferRepository,
+	warehouseRepo repository.WarehouseRepository,
+	catalogRepo repository.CatalogRepository,
+	materialsProfileRepo repository.MaterialsProfileRepository,
+	maintenanceRepo repository.MaintenanceRepository,
+	counterRepo repository.CounterRepository,
+	transactor repository.Transactor,
+) PurchasingService {
+	return &purchasingService{
+		purchaseRequisitionRepo: purchaseRequisitionRepo,
+		supplierRepo:            supplierRepo,
+		stockRepo:               stockRepo,
+		stockTransferRepo:       stockTransferRepo,
+		warehouseRepo:           warehouseRepo,
+		catalogRepo:             catalogRepo,
+		materialsProfileRepo:    materialsProfileRepo,
+		maintenanceRepo:         maintenanceRepo,
+		counterRepo:             counterRepo,
+		transactor:              transactor,
+	}
+}
+
+func (s *purchasingService) CreatePurchaseRequisition(ctx context.Context, req *types.CreatePurchaseRequisitionReq) (*types.PurchaseRequisition, error) {
+	if req.Sector != "" && !utils.Contains(types.SECTOR_LIST, req.Sector) {
+		return nil, types.ErrInvalidSector
+	}
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return nil, types.ErrUnauthorized
+	}
+	maintenance, err := s.maintenanceRepo.FindByID(ctx, req.MaintenanceInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if maintenance.ID == "" {
+		return nil, types.ErrMaintenanceNotFound
+	}
+	warehouses := trimIDs(req.Warehouses)
+	for _, id := range warehouses {
+		warehouse, err := s.warehouseRepo.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if warehouse == nil {
+			return nil, types.ErrWarehouseNotFound
+		}
+	}
+	var requisition *types.PurchaseRequisition
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		// every creation bumps the same counter, so of two concurrent ones the
+		// later conflicts and is retried over the stock the other allocated
+		if _, err := s.counterRepo.Next(ctx, types.PURCHASE_REQUISITION_ALLOCATION_COUNTER, 0); err != nil {
+			return err
+		}
+		var err error
+		requisition, err = s.allocatePurchaseRequisition(ctx, req, maintenance, warehouses, user)
+		if err != nil {
+			return err
+		}
+		requisition.ID, err = s.purchaseRequisitionRepo.Save(ctx, requisition)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return requisition, nil
+}
+
+// allocatePurchaseRequisition builds a draft requisition for the remaining
+// estimate of a maintenance not covered by the available stock and the open
+// requisitions.
+func (s *purchasingService) allocatePurchaseRequisition(ctx context.Context, req *types.CreatePurchaseRequisitionReq, maintenance *types.Maintenance, warehouses []string, user *types.User) (*types.PurchaseRequisition, error) {
+	profiles, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{req.MaintenanceInstanceID},
+		Sector:                 req.Sector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	catalogIDs := make([]string, 0)
+	for _, profile := range profiles {
+		for _, section := range []map[string]types.Material{profile.Estimate.ReplacementMaterials, profile.Estimate.ConsumableSupplies} {
+			for _, material := range section {
+				if material.CatalogID != "" {
+					catalogIDs = append(catalogIDs, material.CatalogID)
+				}
+			}
+		}
+	}
+	catalogIDs = utils.RemoveDuplicates(catalogIDs)
+	catalog := make(map[string]*types.CatalogMaterial)
+	if len(catalogIDs) > 0 {
+		if catalog, err = s.catalogRepo.FindByIDs(ctx, catalogIDs); err != nil {
+			return nil, err
+		}
+	}
+	balances, err := s.stockRepo.Filter(ctx, &types.StockBalanceFilter{CatalogIDs: catalogIDs})
+	if err != nil {
+		return nil, err
+	}
+	if len(warehouses) > 0 {
+		balances = slices.DeleteFunc(balances, func(balance *types.StockBalance) bool {
+			return !slices.Contains(warehouses, balance.Warehouse)
+		})
+	}
+	transfers, err := s.stockTransferRepo.Filter(ctx, &types.StockTransferFilter{Status: types.TRANSFER_STATUS_IN_TRANSIT})
+	if err != nil {
+		return nil, err
+	}
+	suppliers, err := s.supplierRepo.FindByCatalogIDs(ctx, catalogIDs)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.purchaseRequisitionRepo.Filter(ctx, &types.PurchaseRequisitionFilter{
+		MaintenanceInstanceID: req.MaintenanceInstanceID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the open requisitions of the whole maintenance cover every sector, those
+	// of another sector cover none of the materials of this one
+	last := 0
+	open := make([]*types.PurchaseRequisition, 0)
+	for _, requisition := range existing {
+		last = max(last, requisition.Number)
+		if !slices.Contains(types.OPEN_REQUISITION_STATUSES, requisition.Status) {
+			continue
+		}
+		if req.Sector == "" || requisition.Sector == "" || requisition.Sector == req.Sector {
+			open = append(open, requisition)
+		}
+	}
+	// the stock the open requisitions of other sectors and maintenances
+	// counted on is not available to this one
+	others, err := s.purchaseRequisitionRepo.Filter(ctx, &types.PurchaseRequisitionFilter{
+		Statuses: types.OPEN_REQUISITION_STATUSES,
+	})
+	if err != nil {
+		return nil, err
+	}
+	others = slices.DeleteFunc(others, func(requisition *types.PurchaseRequisition) bool {
+		return slices.ContainsFunc(open, func(covering *types.PurchaseRequisition) bool {
+			return covering.ID == requisition.ID
+		}) || !sharesWarehouses(requisition.Warehouses, warehouses)
+	})
+
+	needs := remainingNeeds(profiles, catalog)
+	stock := stockOnHand(balances, catalog)
+	for catalogID, quantity := range inTransitStock(transfers, warehouses) {
+		stock[catalogID] += quantity
+	}
+	for catalogID, quantity := range committedStock(others) {
+		stock[catalogID] -= quantity
+	}
+	groups := requisitionGroups(needs, stock, requisitionedQuantities(open), catalog, suppliers)
+	if len(groups) == 0 {
+		return nil, types.ErrNothingToPurchase
+	}
+	number, err := s.counterRepo.Next(ctx, types.PURCHASE_REQUISITION_COUNTER+maintenance.ID, last)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	requisition := &types.PurchaseRequisition{
+		MaintenanceInstanceID: maintenance.ID,
+		Number:                number,
+		Sector:                req.Sector,
+		Warehouses:            warehouses,
+		Status:                types.REQUISITION_STATUS_DRAFT,
+		Groups:                groups,
+		Note:                  strings.TrimSpace(req.Note),
+		CreatedBy:             user.Username,
+		CreatedAt:             now,
+		UpdatedBy:             user.Username,
+		UpdatedAt:             now,
+	}
+	return requisition, nil
+}
+
+func (s *purchasingService) GetPurchaseRequisition(ctx context.Context, id string) (*types.PurchaseRequisition, error) {
+	requisition, err := s.purchaseRequisitionRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if requisition == nil {
+		return nil, types.ErrPurchaseRequisitionNotFound
+	}
+	return requisition, nil
+}
+
+func (s *purchasingService) FilterPurchaseRequisitions(ctx context.Context, req *types.PurchaseRequisitionFilterReq) ([]*types.PurchaseRequisition, error) {
+	for _, status := range req.Statuses {
+		if !isRequisitionStatus(status) {
+			return nil, types.ErrInvalidRequisitionStatus
+		}
+	}
+	return s.purchaseRequisitionRepo.Filter(ctx, &types.PurchaseRequisitionFilter{
+		MaintenanceInstanceID: req.MaintenanceInstanceID,
+		Sector:                req.Sector,
+		Statuses:              req.Statuses,
+	})
+}
+
+func (s *purchasingService) UpdateRequisitionStatus(ctx context.Context, req *types.UpdateRequisitionStatusReq) error {
+	if !isRequisitionStatus(req.Status) {
+		return types.ErrInvalidRequisitionStatus
+	}
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return types.ErrUnauthorized
+	}
+	requisition, err := s.GetPurchaseRequisition(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(types.REQUISITION_STATUS_TRANSITIONS[requisition.Status], req.Status) {
+		return fmt.Errorf("%w: %s to %s", types.ErrInvalidStatusTransition, requisition.Status, req.Status)
+	}
+	requisition.Status = req.Status
+	requisition.UpdatedBy = user.Username
+	requisition.UpdatedAt = time.Now().Unix()
+	return s.purchaseRequisitionRepo.Update(ctx, req.ID, requisition)
+}
+
+func isRequisitionStatus(status string) bool {
+	switch status {
+	case types.REQUISITION_STATUS_DRAFT, types.REQUISITION_STATUS_ORDERED,
+		types.REQUISITION_STATUS_RECEIVED, types.REQUISITION_STATUS_CANCELLED:
+		return true
+	}
+	return false
+}
+
+var requisitionStatusLabels = map[string]string{
+	types.REQUISITION_STATUS_DRAFT:     "Dự thảo",
+	types.REQUISITION_STATUS_ORDERED:   "Đã đặt hàng",
+	types.REQUISITION_STATUS_RECEIVED:  "Đã nhận hàng",
+	types.REQUISITION_STATUS_CANCELLED: "Đã hủy",
+}
+
+func (s *purchasingService) ExportPurchaseRequisition(ctx context.Context, id string) (*os.File, error) {
+	requisition, err := s.GetPurchaseRequisition(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	maintenance, err := s.maintenanceRepo.FindByID(ctx, requisition.MaintenanceInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if maintenance.ID == "" {
+		return nil, types.ErrMaintenanceNotFound
+	}
+
+	doc := document.New()
+	addLine := func(text string, bold bool) document.Paragraph {
+		paragraph := doc.AddParagraph()
+		run := paragraph.AddRun()
+		run.Properties().SetBold(bold)
+		run.AddText(text)
+		return paragraph
+	}
+	addLine("ĐỀ NGHỊ MUA VẬT TƯ", true).Properties().SetAlignment(wml.ST_JcCenter)
+	addLine(fmt.Sprintf("Số: %s%d", types.PURCHASE_REQUISITION_PREFIX, requisition.Number), false).Properties().SetAlignment(wml.ST_JcCenter)
+	addLine(fmt.Sprintf("Dự án: %s (%s)", maintenance.Project, maintenance.ProjectCode), false)
+	addLine(fmt.Sprintf("Cấp sửa chữa: %s, lần %s, năm %d", maintenance.MaintenanceTier, maintenance.MaintenanceNumber, maintenance.Year), false)
+	if requisition.Sector != "" {
+		addLine(fmt.Sprintf("Ngành: %s", requisition.Sector), false)
+	}
+	addLine(fmt.Sprintf("Ngày lập: %s - Người lập: %s - Trạng thái: %s",
+		time.Unix(requisition.CreatedAt, 0).Local().Format("02/01/2006"),
+		requisition.CreatedBy,
+		requisitionStatusLabels[requisition.Status],
+	), false)
+	if requisition.Note != "" {
+		addLine(fmt.Sprintf("Ghi chú: %s", requisition.Note), false)
+	}
+
+	for i, group := range requisition.Groups {
+		addLine("", false)
+		if group.SupplierID == "" {
+			addLine(fmt.Sprintf("%s. Chưa có nhà cung cấp", utils.IntToRoman(i+1)), true)
+		} else {
+			addLine(fmt.Sprintf("%s. Nhà cung cấp: %s - Thời gian giao hàng: %d ngày", utils.IntToRoman(i+1), group.SupplierName, group.LeadTimeDays), true)
+			supplier, err := s.supplierRepo.FindByID(ctx, group.SupplierID)
+			if err != nil {
+				return nil, err
+			}
+			// the supplier may have been deleted since the requisition was made
+			if supplier != nil {
+				if supplier.Address != "" {
+					addLine(fmt.Sprintf("Địa chỉ: %s", supplier.Address), false)
+				}
+				for _, contact := range supplier.Contacts {
+					addLine(fmt.Sprintf("Liên hệ: %s", strings.Join(nonEmpty(contact.Name, contact.Role, contact.Phone, contact.Email), " - ")), false)
+				}
+			}
+		}
+
+		table := doc.AddTable()
+		table.Properties().SetWidthPercent(100)
+		table.Properties().Borders().SetAll(wml.ST_BorderSingle, color.Auto, 1*measurement.Point)
+		header := table.AddRow()
+		for _, title := range []string{"STT", "Mã vật tư", "Tên vật tư", "ĐVT", "Dự toán còn lại", "Tồn kho", "Đang đặt mua", "Số lượng đề nghị"} {
+			run := header.AddCell().AddParagraph().AddRun()
+			run.Properties().SetBold(true)
+			run.AddText(title)
+		}
+		for j, line := range group.Lines {
+			row := table.AddRow()
+			row.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%d", j+1))
+			row.AddCell().AddParagraph().AddRun().AddText(line.Code)
+			row.AddCell().AddParagraph().AddRun().AddText(line.Name)
+			row.AddCell().AddParagraph().AddRun().AddText(line.Unit)
+			row.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%.2f", line.Remaining))
+			row.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%.2f", line.Stock))
+			row.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%.2f", line.OnOrder))
+			row.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%.2f", line.Quantity))
+		}
+	}
+
+	tempDir := os.TempDir()
+	saveDir := path.Join(tempDir, "purchase_requisition")
+	if err := os.MkdirAll(saveDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	fileName := path.Join(
+		saveDir,
+		fmt.Sprintf(
+			"%s%d-%s.docx",
+			types.PURCHASE_REQUISITION_PREFIX,
+			requisition.Number,
+			time.Now().Local().Format("2006-01-02"),
+		),
+	)
+	if err := doc.SaveToFile(fileName); err != nil {
+		return nil, err
+	}
+	return os.Open(fileName)
+}
+
+func nonEmpty(values ...string) []string {
+	kept := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCreatePurchaseRequisition(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &types.User{Username: "thukho"})
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	supplierRepo := repository.NewSupplierRepository(db)
+	stockRepo := repository.NewStockRepository(db)
+	materialsProfileRepo := repository.NewMaterialsProfileRepository(db)
+	maintenanceRepo := repository.NewMaintenanceRepository(db)
+	s := NewPurchasingService(
+		repository.NewPurchaseRequisitionRepository(db),
+		supplierRepo,
+		stockRepo,
+		repository.NewStockTransferRepository(db),
+		repository.NewWarehouseRepository(db),
+		catalogRepo,
+		materialsProfileRepo,
+		maintenanceRepo,
+		repository.NewCounterRepository(db),
+		repository.NewTransactor(db),
+	)
+
+	maintenanceID, err := maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 01", ProjectCode: "T01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	boltID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{
+		Code:        "BL-01",
+		Name:        "Bu lông M12",
+		DefaultUnit: "cái",
+		PackSizes:   map[string]float64{"hộp": 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paintID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{Code: "SO-01", Name: "Sơn chống gỉ", DefaultUnit: "lít"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the bolts come from the preferred supplier despite its longer lead time
+	quickID, err := supplierRepo.Save(ctx, &types.Supplier{Name: "Công ty A", CatalogIDs: []string{boltID, paintID}, LeadTimeDays: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	preferredID, err := supplierRepo.Save(ctx, &types.Supplier{Name: "Công ty B", CatalogIDs: []string{boltID}, PreferredFor: []string{boltID}, LeadTimeDays: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stockRepo.AddBalances(ctx, []*types.StockBalance{{CatalogID: boltID, Warehouse: "Kho 1", Quantity: 15, Unit: "cái"}}); err != nil {
+		t.Fatal(err)
+	}
+	profiles := []*types.MaterialsProfile{
+		{
+			MaintenanceInstanceID: maintenanceID,
+			Sector:                types.SECTOR_MECHANICAL,
+			Estimate: types.MaterialsForEquipment{
+				ReplacementMaterials: map[string]types.Material{
+					"Bu lông": {Name: "Bu lông", Unit: "hộp", Quantity: 3, CatalogID: boltID},
+				},
+				ConsumableSupplies: map[string]types.Material{
+					"Sơn":     {Name: "Sơn", Unit: "lít", Quantity: 10, CatalogID: paintID},
+					"Giẻ lau": {Name: "Giẻ lau", Unit: "kg", Quantity: 4},
+				},
+			},
+			Reality: types.MaterialsForEquipment{
+				ReplacementMaterials: map[string]types.Material{
+					"Bu lông": {Name: "Bu lông", Unit: "cái", Quantity: 5, CatalogID: boltID},
+				},
+				ConsumableSupplies: map[string]types.Material{
+					"Sơn": {Name: "Sơn", Unit: "lít", Quantity: 2, CatalogID: paintID},
+				},
+			},
+		},
+		{
+			MaintenanceInstanceID: maintenanceID,
+			Sector:                types.SECTOR_MECHANICAL,
+			Estimate: types.MaterialsForEquipment{
+				ReplacementMaterials: map[string]types.Material{
+					"Bu lông M12": {Name: "Bu lông M12", Unit: "cái", Quantity: 10, CatalogID: boltID},
+				},
+			},
+		},
+	}
+	for _, profile := range profiles {
+		if _, err := materialsProfileRepo.Save(ctx, profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requisition, err := s.CreatePurchaseRequisition(ctx, &types.CreatePurchaseRequisitionReq{MaintenanceInstanceID: maintenanceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requisition.Number != 1 || requisition.Status != types.REQUISITION_STATUS_DRAFT {
+		t.Errorf("requisition number %d status %s, want 1 and draft", requisition.Number, requisition.Status)
+	}
+	want := []struct {
+		supplierID string
+		name       string
+		remaining  float64
+		stock      float64
+		quantity   float64
+	}{
+		{quickID, "Sơn chống gỉ", 8, 0, 8},
+		{preferredID, "Bu lông M12", 35, 15, 20},
+		{"", "Giẻ lau", 4, 0, 4},
+	}
+	if len(requisition.Groups) != len(want) {
+		t.Fatalf("groups = %+v, want %d groups", requisition.Groups, len(want))
+	}
+	for i, w := range want {
+		group := requisition.Groups[i]
+		if group.SupplierID != w.supplierID || len(group.Lines) != 1 {
+			t.Errorf("group %d = %+v, want one line from %q", i, group, w.supplierID)
+			continue
+		}
+		line := group.Lines[0]
+		if line.Name != w.name || line.Remaining != w.remaining || line.Stock != w.stock || line.Quantity != w.quantity {
+			t.Errorf("group %d line = %+v, want %s remaining %v stock %v quantity %v", i, line, w.name, w.remaining, w.stock, w.quantity)
+		}
+	}
+
+	// the open requisition already covers the whole shortage
+	if _, err := s.CreatePurchaseRequisition(ctx, &types.CreatePurchaseRequisitionReq{MaintenanceInstanceID: maintenanceID}); !errors.Is(err, types.ErrNothingToPurchase) {
+		t.Errorf("second requisition error = %v, want %v", err, types.ErrNothingToPurchase)
+	}
+	if err := s.UpdateRequisitionStatus(ctx, &types.UpdateRequisitionStatusReq{ID: requisition.ID, Status: types.REQUISITION_STATUS_CANCELLED}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateRequisitionStatus(ctx, &types.UpdateRequisitionStatusReq{ID: requisition.ID, Status: types.REQUISITION_STATUS_ORDERED}); !errors.Is(err, types.ErrInvalidStatusTransition) {
+		t.Errorf("reopening a cancelled requisition error = %v, want %v", err, types.ErrInvalidStatusTransition)
+	}
+	again, err := s.CreatePurchaseRequisition(ctx, &types.CreatePurchaseRequisitionReq{MaintenanceInstanceID: maintenanceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Number != 2 || len(again.Groups) != len(want) {
+		t.Errorf("requisition after cancelling = number %d with %d groups, want 2 with %d", again.Number, len(again.Groups), len(want))
+	}
+
+	// a requisition that cannot be saved takes no number
+	if err := s.UpdateRequisitionStatus(ctx, &types.UpdateRequisitionStatusReq{ID: again.ID, Status: types.REQUISITION_STATUS_CANCELLED}); err != nil {
+		t.Fatal(err)
+	}
+	saveErr := errors.New("write failed")
+	db.failWrites("purchase_requisitions", saveErr)
+	if _, err := s.CreatePurchaseRequisition(ctx, &types.CreatePurchaseRequisitionReq{MaintenanceInstanceID: maintenanceID}); !errors.Is(err, saveErr) {
+		t.Errorf("requisition failing to save error = %v, want %v", err, saveErr)
+	}
+	db.failWrites("purchase_requisitions", nil)
+	third, err := s.CreatePurchaseRequisition(ctx, &types.CreatePurchaseRequisitionReq{MaintenanceInstanceID: maintenanceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Number != 3 {
+		t.Errorf("requisition after a failed one = number %d, want 3", third.Number)
+	}
+}
+
+func TestPurchaseRequisitionsShareStock(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &types.User{Username: "thukho"})
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	stockRepo := repository.NewStockRepository(db)
+	purchaseRequisitionRepo := repository.NewPurchaseRequisitionRepository(db)
+	materialsProfileRepo := repository.NewMaterialsProfileRepository(db)
+	maintenanceRepo := repository.NewMaintenanceRepository(db)
+	s := NewPurchasingService(
+		purchaseRequisitionRepo,
+		repository.NewSupplierRepository(db),
+		stockRepo,
+		repository.NewStockTransferRepository(db),
+		repository.NewWarehouseRepository(db),
+		catalogRepo,
+		materialsProfileRepo,
+		maintenanceRepo,
+		repository.NewCounterRepository(db),
+		repository.NewTransactor(db),
+	)
+
+	if _, err := s.CreatePurchaseRequisition(ctx, &types.CreatePurchaseRequisitionReq{MaintenanceInstanceID: bson.NewObjectID().Hex()}); !errors.Is(err, types.ErrMaintenanceNotFound) {
+		t.Errorf("requisition of an unknown maintenance error = %v, want %v", err, types.ErrMaintenanceNotFound)
+	}
+
+	maintenanceID, err := maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 01", ProjectCode: "T01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	boltID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{Code: "BL-01", Name: "Bu lông M12", DefaultUnit: "cái"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stockRepo.AddBalances(ctx, []*types.StockBalance{{CatalogID: boltID, Warehouse: "Kho 1", Quantity: 15, Unit: "cái"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, sector := range []string{types.SECTOR_MECHANICAL, types.SECTOR_HULL} {
+		_, err := materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+			MaintenanceInstanceID: maintenanceID,
+			Sector:                sector,
+			Estimate: types.MaterialsForEquipment{
+				ReplacementMaterials: map[string]types.Material{
+					"Bu lông M12": {Name: "Bu lông M12", Unit: "cái", Quantity: 20, CatalogID: boltID},
+				},
+				ConsumableSupplies: map[string]types.Material{
+					"Bu lông M12": {Name: "Bu lông M12", Unit: "cái", Quantity: 5, CatalogID: boltID},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// numbering continues after the requisitions made before it was counted
+	if _, err := purchaseRequisitionRepo.Save(ctx, &types.PurchaseRequisition{
+		MaintenanceInstanceID: maintenanceID,
+		Number:                4,
+		Status:                types.REQUISITION_STATUS_RECEIVED,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the two sections of the first sector need the stock once, the second
+	// sector finds it taken
+	want := []struct {
+		sector   string
+		number   int
+		stock    float64
+		quantity float64
+	}{
+		{types.SECTOR_MECHANICAL, 5, 15, 10},
+		{types.SECTOR_HULL, 6, 0, 25},
+	}
+	for _, w := range want {
+		requisition, err := s.CreatePurchaseRequisition(ctx, &types.CreatePurchaseRequisitionReq{MaintenanceInstanceID: maintenanceID, Sector: w.sector})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requisition.Number != w.number || len(requisition.Groups) != 1 {
+			t.Fatalf("%s requisition = number %d with groups %+v, want number %d with one group", w.sector, requisition.Number, requisition.Groups, w.number)
+		}
+		stock, quantity := 0.0, 0.0
+		for _, line := range requisition.Groups[0].Lines {
+			stock += line.Stock
+			quantity += line.Quantity
+		}
+		if stock != w.stock || quantity != w.quantity {
+			t.Errorf("%s requisition stock %v quantity %v, want %v and %v", w.sector, stock, quantity, w.stock, w.quantity)
+		}
+	}
+}
+
+func TestForecastShortages(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &types.User{Username: "kehoach"})
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	supplierRepo := repository.NewSupplierRepository(db)
+	stockRepo := repository.NewStockRepository(db)
+	purchaseRequisitionRepo := repository.NewPurchaseRequisitionRepository(db)
+	materialsProfileRepo := repository.NewMaterialsProfileRepository(db)
+	maintenanceRepo := repository.NewMaintenanceRepository(db)
+	s := NewPurchasingService(
+		purchaseRequisitionRepo,
+		supplierRepo,
+		stockRepo,
+		repository.NewStockTransferRepository(db),
+		repository.NewWarehouseRepository(db),
+		catalogRepo,
+		materialsProfileRepo,
+		maintenanceRepo,
+		repository.NewCounterRepository(db),
+		repository.NewTransactor(db),
+	)
+
+	start := time.Date(2026, 3, 20, 0, 0, 0, 0, time.Local).Unix()
+	laterID, err := maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 02", ProjectCode: "T02", StartDate: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlierID, err := maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 01", ProjectCode: "T01", StartDate: start - 10*24*3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paintID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{Code: "SO-01", Name: "Sơn chống gỉ", DefaultUnit: "lít"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := supplierRepo.Save(ctx, &types.Supplier{Name: "Công ty A", CatalogIDs: []string{paintID}, LeadTimeDays: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stockRepo.AddBalances(ctx, []*types.StockBalance{{CatalogID: paintID, Warehouse: "Kho 1", Quantity: 12, Unit: "lít"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{laterID, earlierID} {
+		if _, err := materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+			MaintenanceInstanceID: id,
+			Sector:                types.SECTOR_HULL,
+			Estimate: types.MaterialsForEquipment{
+				ConsumableSupplies: map[string]types.Material{
+					"Sơn":     {Name: "Sơn", Unit: "lít", Quantity: 10, CatalogID: paintID},
+					"Giẻ lau": {Name: "Giẻ lau", Unit: "kg", Quantity: 4},
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := purchaseRequisitionRepo.Save(ctx, &types.PurchaseRequisition{
+		MaintenanceInstanceID: laterID,
+		Number:                1,
+		Status:                types.REQUISITION_STATUS_ORDERED,
+		Groups: []types.RequisitionGroup{{Lines: []types.RequisitionLine{
+			{CatalogID: paintID, Name: "Sơn chống gỉ", Unit: "lít", Quantity: 3},
+		}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	forecast, err := s.ForecastShortages(ctx, &types.ShortageForecastReq{MaintenanceInstanceIDs: []string{laterID, earlierID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecast.Maintenances) != 2 || forecast.Maintenances[0].ID != earlierID {
+		t.Fatalf("maintenances = %+v, want the earlier one first", forecast.Maintenances)
+	}
+	// the earlier ship takes 10 of the 12 liters on hand, the later one the
+	// rest and its 3 on order
+	got := make(map[string]types.ShortageLine)
+	for _, line := range forecast.Lines {
+		got[line.ProjectCode+" "+line.Name] = line
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d shortages, want 3: %+v", len(got), forecast.Lines)
+	}
+	if _, ok := got["T01 Sơn chống gỉ"]; ok {
+		t.Fatal("the paint of the earlier ship is covered by the stock")
+	}
+	paint := got["T02 Sơn chống gỉ"]
+	if paint.Stock != 2 || paint.OnOrder != 3 || paint.Shortage != 5 || paint.SupplierName != "Công ty A" {
+		t.Fatalf("paint shortage = %+v, want 2 in stock, 3 on order and 5 short", paint)
+	}
+	if paint.NeededBy != start || paint.OrderBy != start-10*24*3600 {
+		t.Fatalf("paint dates = %d and %d, want %d and 10 days before", paint.NeededBy, paint.OrderBy, start)
+	}
+	if rags := got["T01 Giẻ lau"]; rags.Shortage != 4 || rags.NeededBy != start-10*24*3600 {
+		t.Fatalf("rags shortage = %+v, want 4", rags)
+	}
+
+	_, err = s.ForecastShortages(ctx, &types.ShortageForecastReq{MaintenanceInstanceIDs: []string{laterID, "000000000000000000000000"}})
+	if !errors.Is(err, types.ErrMaintenanceNotFound) {
+		t.Fatalf("unknown maintenance: got %v, want ErrMaintenanceNotFound", err)
+	}
+	file, err := s.ExportShortageForecast(ctx, &types.ShortageForecastReq{MaintenanceInstanceIDs: []string{laterID, earlierID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	os.Remove(file.Name())
+}
@@ -0,0 +1,694 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+var _ ReportService = &reportService{}
+
+type ReportService interface {
+	VarianceReport(ctx context.Context, req *types.VarianceReportReq) (*types.VarianceReport, error)
+	// create a xlsx file of the variance report to download
+	ExportVarianceReport(ctx context.Context, req *types.VarianceReportReq) (*os.File, error)
+	// cost of estimate, reality and material requests of a maintenance per
+	// equipment and sector
+	CostReport(ctx context.Context, req *types.CostReportReq) (*types.CostReport, error)
+	// create a xlsx file of the cost report to download
+	ExportCostReport(ctx context.Context, req *types.CostReportReq) (*os.File, error)
+	// cost of the estimate and reality lines of a materials profile at a date, now when zero
+	ProfileCost(ctx context.Context, id string, at int64) (*types.ProfileCost, error)
+	// cost of the lines of a material request at its request date
+	RequestCost(ctx context.Context, id string) (*types.RequestCost, error)
+}
+
+type reportService struct {
+	materialsProfileRepo   repository.MaterialsProfileRepository
+	materialsRequestRepo   repository.MaterialsRequestRepository
+	maintenanceRepo        repository.MaintenanceRepository
+	equipmentMachineryRepo repository.EquipmentMachineryRepo
+	catalogRepo            repository.CatalogRepository
+	priceRepo              repository.PriceRepository
+}
+
+func NewReportService(
+	materialsProfileRepo repository.MaterialsProfileRepository,
+	materialsRequestRepo repository.MaterialsRequestRepository,
+	maintenanceRepo repository.MaintenanceRepository,
+	equipmentMachineryRepo repository.EquipmentMachineryRepo,
+	catalogRepo repository.CatalogRepository,
+	priceRepo repository.PriceRepository,
+) ReportService {
+	return &reportService{
+		materialsProfileRepo:   materialsProfileRepo,
+		materialsRequestRepo:   materialsRequestRepo,
+		maintenanceRepo:        maintenanceRepo,
+		equipmentMachineryRepo: equipmentMachineryRepo,
+		catalogRepo:            catalogRepo,
+		priceRepo:              priceRepo,
+	}
+}
+
+// VarianceReport compares estimate and reality of every materials profile of a
+// maintenance. Summaries always cover every line, the filters only decide
+// which lines are listed.
+func (s *reportService) VarianceReport(ctx context.Context, req *types.VarianceReportReq) (*types.VarianceReport, error) {
+	if req.Sector != "" && !utils.Contains(types.SECTOR_LIST, req.Sector) {
+		return nil, types.ErrInvalidSector
+	}
+	maintenance, err := s.maintenanceRepo.FindByID(ctx, req.MaintenanceInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if maintenance.ID == "" {
+		return nil, types.ErrMaintenanceNotFound
+	}
+	materialsProfiles, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{req.MaintenanceInstanceID},
+		Sector:                 req.Sector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	emIds := make([]string, 0, len(materialsProfiles))
+	for _, mp := range materialsProfiles {
+		emIds = append(emIds, mp.EquipmentMachineryID)
+	}
+	equipmentMachineries, err := s.equipmentMachineryRepo.FindByIDs(ctx, utils.RemoveDuplicates(emIds))
+	if err != nil {
+		return nil, err
+	}
+	var book *priceBook
+	if req.WithCost {
+		book, err = s.loadProfilesPriceBook(ctx, materialsProfiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pricedAt := time.Now().Unix()
+
+	report := &types.VarianceReport{
+		MaintenanceInstanceID: maintenance.ID,
+		Project:               maintenance.Project,
+		ProjectCode:           maintenance.ProjectCode,
+		MaintenanceTier:       maintenance.MaintenanceTier,
+		MaintenanceNumber:     maintenance.MaintenanceNumber,
+		Year:                  maintenance.Year,
+		Sectors:               make([]*types.SectorVariance, 0),
+	}
+	sectors := make(map[string]*types.SectorVariance)
+	for _, mp := range materialsProfiles {
+		sector, ok := sectors[mp.Sector]
+		if !ok {
+			sector = &types.SectorVariance{
+				Sector:     mp.Sector,
+				Equipments: make([]*types.EquipmentVariance, 0),
+			}
+			sectors[mp.Sector] = sector
+			report.Sectors = append(report.Sectors, sector)
+		}
+
+		equipment := &types.EquipmentVariance{
+			MaterialsProfileID: mp.ID,
+			IndexPath:          utils.IndexPathToString(mp.Index),
+			Lines:              make([]*types.VarianceLine, 0),
+		}
+		if em, ok := equipmentMachineries[mp.EquipmentMachineryID]; ok {
+			equipment.EquipmentMachinery = em.Name
+		}
+		lines := buildVarianceLines(mp)
+		if book != nil {
+			priceVarianceLines(lines, mp, book, pricedAt)
+		}
+		for _, line := range lines {
+			addVarianceLine(&equipment.Summary, line)
+			addVarianceLine(&sector.Summary, line)
+			addVarianceLine(&report.Summary, line)
+			if keepVarianceLine(line, req) {
+				equipment.Lines = append(equipment.Lines, line)
+			}
+		}
+		if len(equipment.Lines) > 0 {
+			sector.Equipments = append(sector.Equipments, equipment)
+		}
+	}
+	return report, nil
+}
+
+func (s *reportService) ExportVarianceReport(ctx context.Context, req *types.VarianceReportReq) (*os.File, error) {
+	report, err := s.VarianceReport(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	summarySheet := "Tổng hợp"
+	detailSheet := "Chi tiết"
+	if err := f.SetSheetName(f.GetSheetName(0), summarySheet); err != nil {
+		return nil, err
+	}
+	if _, err := f.NewSheet(detailSheet); err != nil {
+		return nil, err
+	}
+	summary := newSheetWriter(f, summarySheet)
+	detail := newSheetWriter(f, detailSheet)
+
+	summary.writeRow("Dự án", report.Project)
+	summary.writeRow("Cấp sửa chữa", fmt.Sprintf("%s %s/%d", report.MaintenanceTier, report.MaintenanceNumber, report.Year))
+	summary.writeRow()
+	summaryHeader := []interface{}{"Ngành", "STT", "Thiết bị", "Số dòng", "Dòng vượt", "Ngoài dự toán", "Khác đơn vị"}
+	detailHeader := []interface{}{"Ngành", "STT", "Thiết bị", "Loại", "Vật tư", "ĐVT", "Dự toán", "Thực tế", "ĐVT thực tế", "Chênh lệch", "%", "Ngoài dự toán"}
+	if req.WithCost {
+		summaryHeader = append(summaryHeader, "Chi phí dự toán", "Chi phí thực tế")
+		detailHeader = append(detailHeader, "Chi phí dự toán", "Chi phí thực tế")
+	}
+	summary.writeRow(summaryHeader...)
+	detail.writeRow(detailHeader...)
+	summaryCells := func(s types.VarianceSummary) []interface{} {
+		cells := varianceSummaryCells(s)
+		if req.WithCost {
+			cells = append(cells, s.EstimateCost.Float64(), s.RealityCost.Float64())
+		}
+		return cells
+	}
+
+	for _, sector := range report.Sectors {
+		for _, equipment := range sector.Equipments {
+			summary.writeRow(append([]interface{}{sector.Sector, equipment.IndexPath, equipment.EquipmentMachinery}, summaryCells(equipment.Summary)...)...)
+			for _, line := range equipment.Lines {
+				unestimated := ""
+				if line.Unestimated {
+					unestimated = "x"
+				}
+				realityUnit := line.Unit
+				if line.UnitMismatch {
+					realityUnit = line.RealityUnit
+				}
+				cells := []interface{}{
+					sector.Sector,
+					equipment.IndexPath,
+					equipment.EquipmentMachinery,
+					line.MaterialType,
+					line.Name,
+					line.Unit,
+					line.Estimate,
+					line.Reality,
+					realityUnit,
+					line.Difference,
+					line.Percent,
+					unestimated,
+				}
+				if req.WithCost {
+					cells = append(cells, line.EstimateCost.Float64(), line.RealityCost.Float64())
+				}
+				detail.writeRow(cells...)
+			}
+		}
+		summary.writeRow(append([]interface{}{sector.Sector, "", "Cộng ngành"}, summaryCells(sector.Summary)...)...)
+	}
+	summary.writeRow(append([]interface{}{"", "", "Tổng cộng"}, summaryCells(report.Summary)...)...)
+	if summary.err != nil {
+		return nil, summary.err
+	}
+	if detail.err != nil {
+		return nil, detail.err
+	}
+
+	tempDir := os.TempDir()
+	saveDir := path.Join(tempDir, "report")
+	// create dir if not exist
+	if err := os.MkdirAll(saveDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	fileName := path.Join(
+		saveDir,
+		strings.ReplaceAll(
+			fmt.Sprintf(
+				"variance_%s_%s_%s.xlsx",
+				report.MaintenanceTier,
+				report.ProjectCode,
+				report.MaintenanceNumber,
+			),
+			" ", "_",
+		),
+	)
+	if err := f.SaveAs(fileName); err != nil {
+		return nil, err
+	}
+	return os.Open(fileName)
+}
+
+// buildVarianceLines lists every material estimated or consumed by a profile,
+// replacement materials first, each group sorted by name.
+func buildVarianceLines(mp *types.MaterialsProfile) []*types.VarianceLine {
+	lines := make([]*types.VarianceLine, 0)
+	sections := []struct {
+		materialType string
+		estimate     map[string]types.Material
+		reality      map[string]types.Material
+	}{
+		{types.MATERIAL_TYPE_REPLACEMENT, mp.Estimate.ReplacementMaterials, mp.Reality.ReplacementMaterials},
+		{types.MATERIAL_TYPE_CONSUMABLE, mp.Estimate.ConsumableSupplies, mp.Reality.ConsumableSupplies},
+	}
+	for _, section := range sections {
+		for _, name := range sortedMaterialNames(section.estimate, section.reality) {
+			estimate, hasEstimate := section.estimate[name]
+			reality := section.reality[name]
+			if hasEstimate && reality.Unit != "" {
+				quantity, ok := utils.ConvertQuantity(reality.Quantity, reality.Unit, estimate.Unit, nil)
+				if !ok {
+					lines = append(lines, &types.VarianceLine{
+						MaterialType: section.materialType,
+						Name:         name,
+						Unit:         estimate.Unit,
+						Estimate:     estimate.Quantity,
+						Reality:      reality.Quantity,
+						UnitMismatch: true,
+						RealityUnit:  reality.Unit,
+					})
+					continue
+				}
+				reality.Quantity = quantity
+			}
+			line := &types.VarianceLine{
+				MaterialType: section.materialType,
+				Name:         name,
+				Unit:         estimate.Unit,
+				Estimate:     estimate.Quantity,
+				Reality:      reality.Quantity,
+				Difference:   reality.Quantity - estimate.Quantity,
+				Unestimated:  reality.Quantity > 0 && (!hasEstimate || estimate.Quantity == 0),
+			}
+			if !hasEstimate {
+				line.Unit = reality.Unit
+			}
+			line.Percent = variancePercent(line.Difference, line.Estimate)
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func keepVarianceLine(line *types.VarianceLine, req *types.VarianceReportReq) bool {
+	if req.OnlyOverruns && line.Difference <= 0 {
+		return false
+	}
+	if req.ThresholdPercent > 0 && !line.Unestimated && !line.UnitMismatch && math.Abs(line.Percent) < req.ThresholdPercent {
+		return false
+	}
+	return true
+}
+
+// priceVarianceLines sets the cost of the estimate and the reality of the
+// variance lines of a profile.
+func priceVarianceLines(lines []*types.VarianceLine, mp *types.MaterialsProfile, book *priceBook, at int64) {
+	for _, line := range lines {
+		estimate, reality := mp.Estimate.ConsumableSupplies, mp.Reality.ConsumableSupplies
+		if line.MaterialType == types.MATERIAL_TYPE_REPLACEMENT {
+			estimate, reality = mp.Estimate.ReplacementMaterials, mp.Reality.ReplacementMaterials
+		}
+		if material, ok := estimate[line.Name]; ok {
+			line.EstimateCost = book.line(line.MaterialType, material, at).Cost
+		}
+		if material, ok := reality[line.Name]; ok {
+			line.RealityCost = book.line(line.MaterialType, material, at).Cost
+		}
+	}
+}
+
+func addVarianceLine(summary *types.VarianceSummary, line *types.VarianceLine) {
+	summary.EstimateCost = summary.EstimateCost.Add(line.EstimateCost)
+	summary.RealityCost = summary.RealityCost.Add(line.RealityCost)
+	summary.Lines++
+	if line.UnitMismatch {
+		summary.MismatchLines++
+		return
+	}
+	if line.Difference > 0 {
+		summary.OverrunLines++
+	}
+	if line.Unestimated {
+		summary.UnestimatedLines++
+	}
+}
+
+func variancePercent(difference, estimate float64) float64 {
+	if estimate == 0 {
+		return 0
+	}
+	return math.Round(difference/estimate*10000) / 100
+}
+
+func varianceSummaryCells(summary types.VarianceSummary) []interface{} {
+	return []interface{}{
+		summary.Lines,
+		summary.OverrunLines,
+		summary.UnestimatedLines,
+		summary.MismatchLines,
+	}
+}
+
+// CostReport prices the estimate and reality of every materials profile of a
+// maintenance at a date and every material request at its own date, and rolls
+// the costs up per sector and for the maintenance.
+func (s *reportService) CostReport(ctx context.Context, req *types.CostReportReq) (*types.CostReport, error) {
+	if req.Sector != "" && !utils.Contains(types.SECTOR_LIST, req.Sector) {
+		return nil, types.ErrInvalidSector
+	}
+	maintenance, err := s.maintenanceRepo.FindByID(ctx, req.MaintenanceInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if maintenance.ID == "" {
+		return nil, types.ErrMaintenanceNotFound
+	}
+	materialsProfiles, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: []string{req.MaintenanceInstanceID},
+		Sector:                 req.Sector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	requests, err := s.materialsRequestRepo.Filter(ctx, &types.MaterialRequestFilter{
+		MaintenanceInstanceID: req.MaintenanceInstanceID,
+		Sector:                req.Sector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	emIds := make([]string, 0, len(materialsProfiles))
+	materials := make([]types.MaterialsForEquipment, 0, 2*len(materialsProfiles))
+	for _, mp := range materialsProfiles {
+		emIds = append(emIds, mp.EquipmentMachineryID)
+		materials = append(materials, mp.Estimate, mp.Reality)
+	}
+	for _, request := range requests {
+		for _, m := range request.MaterialsForEquipment {
+			materials = append(materials, m)
+		}
+	}
+	equipmentMachineries, err := s.equipmentMachineryRepo.FindByIDs(ctx, utils.RemoveDuplicates(emIds))
+	if err != nil {
+		return nil, err
+	}
+	book, err := loadPriceBook(ctx, s.catalogRepo, s.priceRepo, materials...)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &types.CostReport{
+		MaintenanceInstanceID: maintenance.ID,
+		Project:               maintenance.Project,
+		ProjectCode:           maintenance.ProjectCode,
+		MaintenanceTier:       maintenance.MaintenanceTier,
+		MaintenanceNumber:     maintenance.MaintenanceNumber,
+		Year:                  maintenance.Year,
+		PricedAt:              req.At,
+		Sectors:               make([]*types.SectorCost, 0),
+		Requests:              make([]*types.RequestCost, 0, len(requests)),
+	}
+	if report.PricedAt == 0 {
+		report.PricedAt = time.Now().Unix()
+	}
+	sectors := make(map[string]*types.SectorCost)
+	sectorCost := func(name string) *types.SectorCost {
+		sector, ok := sectors[name]
+		if !ok {
+			sector = &types.SectorCost{
+				Sector:     name,
+				Equipments: make([]*types.ProfileCost, 0),
+			}
+			sectors[name] = sector
+			report.Sectors = append(report.Sectors, sector)
+		}
+		return sector
+	}
+	for _, mp := range materialsProfiles {
+		equipmentName := ""
+		if em, ok := equipmentMachineries[mp.EquipmentMachineryID]; ok {
+			equipmentName = em.Name
+		}
+		cost := profileCost(mp, equipmentName, book, report.PricedAt)
+		cost.EstimateLines, cost.RealityLines = nil, nil
+		sector := sectorCost(mp.Sector)
+		sector.Equipments = append(sector.Equipments, cost)
+		addCostSummary(&sector.Estimate, cost.Estimate)
+		addCostSummary(&sector.Reality, cost.Reality)
+		addCostSummary(&report.Estimate, cost.Estimate)
+		addCostSummary(&report.Reality, cost.Reality)
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].RequestedAt < requests[j].RequestedAt
+	})
+	for _, request := range requests {
+		cost := requestCost(request, nil, book)
+		cost.Equipments = nil
+		report.Requests = append(report.Requests, cost)
+		addCostSummary(&sectorCost(request.Sector).Requested, cost.Summary)
+		addCostSummary(&report.Requested, cost.Summary)
+	}
+	return report, nil
+}
+
+func (s *reportService) ProfileCost(ctx context.Context, id string, at int64) (*types.ProfileCost, error) {
+	mp, err := s.materialsProfileRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if mp.ID == "" {
+		return nil, types.ErrSomeMaterialsProfileNotFound
+	}
+	equipmentMachineries, err := s.equipmentMachineryRepo.FindByIDs(ctx, []string{mp.EquipmentMachineryID})
+	if err != nil {
+		return nil, err
+	}
+	equipmentName := ""
+	if em, ok := equipmentMachineries[mp.EquipmentMachineryID]; ok {
+		equipmentName = em.Name
+	}
+	book, err := loadPriceBook(ctx, s.catalogRepo, s.priceRepo, mp.Estimate, mp.Reality)
+	if err != nil {
+		return nil, err
+	}
+	if at == 0 {
+		at = time.Now().Unix()
+	}
+	return profileCost(mp, equipmentName, book, at), nil
+}
+
+func (s *reportService) RequestCost(ctx context.Context, id string) (*types.RequestCost, error) {
+	request, err := s.materialsRequestRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.ID == "" {
+		return nil, types.ErrMaterialRequestNotFound
+	}
+	mpIds := make([]string, 0, len(request.MaterialsForEquipment))
+	materials := make([]types.MaterialsForEquipment, 0, len(request.MaterialsForEquipment))
+	for mpID, m := range request.MaterialsForEquipment {
+		mpIds = append(mpIds, mpID)
+		materials = append(materials, m)
+	}
+	materialsProfiles, err := s.materialsProfileRepo.FindByIDs(ctx, mpIds)
+	if err != nil {
+		return nil, err
+	}
+	emIds := make([]string, 0, len(materialsProfiles))
+	for _, mp := range materialsProfiles {
+		emIds = append(emIds, mp.EquipmentMachineryID)
+	}
+	equipmentMachineries, err := s.equipmentMachineryRepo.FindByIDs(ctx, utils.RemoveDuplicates(emIds))
+	if err != nil {
+		return nil, err
+	}
+	equipmentNames := make(map[string]string, len(materialsProfiles))
+	for mpID, mp := range materialsProfiles {
+		if em, ok := equipmentMachineries[mp.EquipmentMachineryID]; ok {
+			equipmentNames[mpID] = em.Name
+		}
+	}
+	book, err := loadPriceBook(ctx, s.catalogRepo, s.priceRepo, materials...)
+	if err != nil {
+		return nil, err
+	}
+	return requestCost(request, equipmentNames, book), nil
+}
+
+func (s *reportService) ExportCostReport(ctx context.Context, req *types.CostReportReq) (*os.File, error) {
+	report, err := s.CostReport(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	summarySheet := "Tổng hợp"
+	requestSheet := "Phiếu yêu cầu"
+	if err := f.SetSheetName(f.GetSheetName(0), summarySheet); err != nil {
+		return nil, err
+	}
+	if _, err := f.NewSheet(requestSheet); err != nil {
+		return nil, err
+	}
+	summary := newSheetWriter(f, summarySheet)
+	requests := newSheetWriter(f, requestSheet)
+
+	summary.writeRow("Dự án", report.Project)
+	summary.writeRow("Cấp sửa chữa", fmt.Sprintf("%s %s/%d", report.MaintenanceTier, report.MaintenanceNumber, report.Year))
+	summary.writeRow("Giá tại ngày", time.Unix(report.PricedAt, 0).Local().Format("02/01/2006"))
+	summary.writeRow()
+	summary.writeRow("Ngành", "STT", "Thiết bị", "Chi phí dự toán", "Chi phí thực tế", "Chênh lệch", "Dòng chưa có giá")
+	for _, sector := range report.Sectors {
+		for _, equipment := range sector.Equipments {
+			summary.writeRow(append([]interface{}{sector.Sector, equipment.IndexPath, equipment.EquipmentMachinery}, costSummaryCells(equipment.Estimate, equipment.Reality)...)...)
+		}
+		summary.writeRow(append([]interface{}{sector.Sector, "", "Cộng ngành"}, costSummaryCells(sector.Estimate, sector.Reality)...)...)
+	}
+	summary.writeRow(append([]interface{}{"", "", "Tổng cộng"}, costSummaryCells(report.Estimate, report.Reality)...)...)
+
+	requests.writeRow("Số phiếu", "Ngành", "Ngày yêu cầu", "Thành tiền", "Dòng chưa có giá")
+	for _, request := range report.Requests {
+		number := ""
+		if request.NumOfRequest > 0 {
+			number = fmt.Sprintf("%d", request.NumOfRequest)
+		}
+		requests.writeRow(
+			number,
+			request.Sector,
+			time.Unix(request.RequestedAt, 0).Local().Format("02/01/2006"),
+			request.Summary.Total.Float64(),
+			request.Summary.UnpricedLines,
+		)
+	}
+	requests.writeRow("", "", "Tổng cộng", report.Requested.Total.Float64(), report.Requested.UnpricedLines)
+	if summary.err != nil {
+		return nil, summary.err
+	}
+	if requests.err != nil {
+		return nil, requests.err
+	}
+
+	tempDir := os.TempDir()
+	saveDir := path.Join(tempDir, "report")
+	// create dir if not exist
+	if err := os.MkdirAll(saveDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	fileName := path.Join(
+		saveDir,
+		strings.ReplaceAll(
+			fmt.Sprintf(
+				"cost_%s_%s_%s.xlsx",
+				report.MaintenanceTier,
+				report.ProjectCode,
+				report.MaintenanceNumber,
+			),
+			" ", "_",
+		),
+	)
+	if err := f.SaveAs(fileName); err != nil {
+		return nil, err
+	}
+	return os.Open(fileName)
+}
+
+// loadProfilesPriceBook loads the prices of the estimate and reality of
+// materials profiles.
+func (s *reportService) loadProfilesPriceBook(ctx context.Context, materialsProfiles []*types.MaterialsProfile) (*priceBook, error) {
+	materials := make([]types.MaterialsForEquipment, 0, 2*len(materialsProfiles))
+	for _, mp := range materialsProfiles {
+		materials = append(materials, mp.Estimate, mp.Reality)
+	}
+	return loadPriceBook(ctx, s.catalogRepo, s.priceRepo, materials...)
+}
+
+func profileCost(mp *types.MaterialsProfile, equipmentName string, book *priceBook, at int64) *types.ProfileCost {
+	cost := &types.ProfileCost{
+		MaterialsProfileID: mp.ID,
+		IndexPath:          utils.IndexPathToString(mp.Index),
+		EquipmentMachinery: equipmentName,
+		Sector:             mp.Sector,
+		EstimateLines:      book.lines(mp.Estimate, at),
+		RealityLines:       book.lines(mp.Reality, at),
+	}
+	cost.Estimate = summarizeCostLines(cost.EstimateLines)
+	cost.Reality = summarizeCostLines(cost.RealityLines)
+	return cost
+}
+
+// requestCost prices a material request at its request date.
+func requestCost(request *types.MaterialRequest, equipmentNames map[string]string, book *priceBook) *types.RequestCost {
+	cost := &types.RequestCost{
+		MaterialRequestID: request.ID,
+		NumOfRequest:      request.NumOfRequest,
+		Sector:            request.Sector,
+		RequestedAt:       request.RequestedAt,
+		Equipments:        make([]*types.RequestEquipmentCost, 0, len(request.MaterialsForEquipment)),
+	}
+	for _, mpID := range slices.Sorted(maps.Keys(request.MaterialsForEquipment)) {
+		equipment := &types.RequestEquipmentCost{
+			MaterialsProfileID: mpID,
+			EquipmentMachinery: equipmentNames[mpID],
+			Lines:              book.lines(request.MaterialsForEquipment[mpID], request.RequestedAt),
+		}
+		equipment.Summary = summarizeCostLines(equipment.Lines)
+		addCostSummary(&cost.Summary, equipment.Summary)
+		cost.Equipments = append(cost.Equipments, equipment)
+	}
+	return cost
+}
+
+func costSummaryCells(estimate, reality types.CostSummary) []interface{} {
+	return []interface{}{
+		estimate.Total.Float64(),
+		reality.Total.Float64(),
+		reality.Total.Sub(estimate.Total).Float64(),
+		estimate.UnpricedLines + reality.UnpricedLines,
+	}
+}
+
+// sheetWriter appends rows to a sheet and keeps the first error.
+type sheetWriter struct {
+	file  *excelize.File
+	sheet string
+	row   int
+	err   error
+}
+
+func newSheetWriter(file *excelize.File, sheet string) *sheetWriter {
+	return &sheetWriter{
+		file:  file,
+		sheet: sheet,
+		row:   1,
+	}
+}
+
+func (w *sheetWriter) writeRow(values ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		w.err = err
+		return
+	}
+	w.row++
+	w.err = w.file.SetSheetRow(w.sheet, cell, &values)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func newTestReportService(db *memoryDatabase) *reportService {
+	return &reportService{
+		materialsProfileRepo:   repository.NewMaterialsProfileRepository(db),
+		materialsRequestRepo:   repository.NewMaterialsRequestRepository(db),
+		maintenanceRepo:        repository.NewMaintenanceRepository(db),
+		equipmentMachineryRepo: repository.NewEquipmentMachineryRepo(db),
+		catalogRepo:            repository.NewCatalogRepository(db),
+		priceRepo:              repository.NewPriceRepository(db),
+	}
+}
+
+func TestVarianceReport(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	s := newTestReportService(db)
+
+	maintenanceID, err := s.maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 01", ProjectCode: "T01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equipmentID, err := s.equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Máy chính", Sector: testSector})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+		MaintenanceInstanceID: maintenanceID,
+		EquipmentMachineryID:  equipmentID,
+		Sector:                testSector,
+		Index:                 1,
+		Estimate: types.MaterialsForEquipment{
+			ConsumableSupplies: map[string]types.Material{
+				"Dầu":     {Name: "Dầu", Unit: "kg", Quantity: 10},
+				"Giẻ lau": {Name: "Giẻ lau", Unit: "kg", Quantity: 5},
+				"Sơn":     {Name: "Sơn", Unit: "thùng", Quantity: 3},
+			},
+		},
+		Reality: types.MaterialsForEquipment{
+			ConsumableSupplies: map[string]types.Material{
+				"Dầu":      {Name: "Dầu", Unit: "kg", Quantity: 12},
+				"Giẻ lau":  {Name: "Giẻ lau", Unit: "g", Quantity: 2000},
+				"Sơn":      {Name: "Sơn", Unit: "lít", Quantity: 40},
+				"Băng keo": {Name: "Băng keo", Unit: "cuộn", Quantity: 1},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.VarianceReport(ctx, &types.VarianceReportReq{MaintenanceInstanceID: maintenanceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Sectors) != 1 || len(report.Sectors[0].Equipments) != 1 {
+		t.Fatalf("report = %+v, want one sector with one equipment", report)
+	}
+	lines := report.Sectors[0].Equipments[0].Lines
+	want := []types.VarianceLine{
+		{MaterialType: types.MATERIAL_TYPE_CONSUMABLE, Name: "Băng keo", Unit: "cuộn", Reality: 1, Difference: 1, Unestimated: true},
+		{MaterialType: types.MATERIAL_TYPE_CONSUMABLE, Name: "Dầu", Unit: "kg", Estimate: 10, Reality: 12, Difference: 2, Percent: 20},
+		// the reality converts to the unit of the estimate
+		{MaterialType: types.MATERIAL_TYPE_CONSUMABLE, Name: "Giẻ lau", Unit: "kg", Estimate: 5, Reality: 2, Difference: -3, Percent: -60},
+		{MaterialType: types.MATERIAL_TYPE_CONSUMABLE, Name: "Sơn", Unit: "thùng", Estimate: 3, Reality: 40, UnitMismatch: true, RealityUnit: "lít"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		if *line != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, *line, want[i])
+		}
+	}
+	summary := report.Summary
+	if summary.Lines != 4 || summary.OverrunLines != 2 || summary.UnestimatedLines != 1 || summary.MismatchLines != 1 {
+		t.Errorf("summary = %+v, want 4 lines, 2 overruns, 1 unestimated and 1 unit mismatch", summary)
+	}
+
+	overruns, err := s.VarianceReport(ctx, &types.VarianceReportReq{MaintenanceInstanceID: maintenanceID, OnlyOverruns: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(overruns.Sectors[0].Equipments[0].Lines); got != 2 {
+		t.Errorf("listed %d overrun lines, want 2", got)
+	}
+	if overruns.Summary.Lines != 4 {
+		t.Errorf("filtered summary covers %d lines, want every line", overruns.Summary.Lines)
+	}
+	threshold, err := s.VarianceReport(ctx, &types.VarianceReportReq{MaintenanceInstanceID: maintenanceID, ThresholdPercent: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unestimated and mismatched lines are always listed
+	if got := len(threshold.Sectors[0].Equipments[0].Lines); got != 3 {
+		t.Errorf("listed %d lines above 50%%, want 3", got)
+	}
+
+	if _, err := s.VarianceReport(ctx, &types.VarianceReportReq{MaintenanceInstanceID: bson.NewObjectID().Hex()}); !errors.Is(err, types.ErrMaintenanceNotFound) {
+		t.Errorf("report of an unknown maintenance: got %v, want ErrMaintenanceNotFound", err)
+	}
+}
+
+func TestCostReportOfUnknownMaintenance(t *testing.T) {
+	s := newTestReportService(newMemoryDatabase())
+	if _, err := s.CostReport(context.Background(), &types.CostReportReq{MaintenanceInstanceID: bson.NewObjectID().Hex()}); !errors.Is(err, types.ErrMaintenanceNotFound) {
+		t.Errorf("cost report of an unknown maintenance: got %v, want ErrMaintenanceNotFound", err)
+	}
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+func (s *purchasingService) ForecastShortages(ctx context.Context, req *types.ShortageForecastReq) (*types.ShortageForecast, error) {
+	if req.Sector != "" && !utils.Contains(types.SECTOR_LIST, req.Sector) {
+		return nil, types.ErrInvalidSector
+	}
+	ids := trimIDs(req.MaintenanceInstanceIDs)
+	if len(ids) == 0 {
+		return nil, types.ErrMaintenanceNotFound
+	}
+	found, err := s.maintenanceRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	maintenances := make([]*types.Maintenance, 0, len(ids))
+	for _, id := range ids {
+		maintenance := found[id]
+		if maintenance == nil {
+			return nil, fmt.Errorf("%w: %s", types.ErrMaintenanceNotFound, id)
+		}
+		maintenances = append(maintenances, maintenance)
+	}
+	// the stock goes to the maintenances needed first, those not scheduled last
+	slices.SortStableFunc(maintenances, func(a, b *types.Maintenance) int {
+		if (a.StartDate == 0) != (b.StartDate == 0) {
+			if a.StartDate == 0 {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a.StartDate, b.StartDate)
+	})
+	warehouses := trimIDs(req.Warehouses)
+	for _, id := range warehouses {
+		warehouse, err := s.warehouseRepo.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if warehouse == nil {
+			return nil, types.ErrWarehouseNotFound
+		}
+	}
+
+	profiles, err := s.materialsProfileRepo.Filter(ctx, &types.MaterialsProfileFilter{
+		MaintenanceInstanceIDs: ids,
+		Sector:                 req.Sector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	profilesByMaintenance := make(map[string][]*types.MaterialsProfile)
+	catalogIDs := make([]string, 0)
+	for _, profile := range profiles {
+		profilesByMaintenance[profile.MaintenanceInstanceID] = append(profilesByMaintenance[profile.MaintenanceInstanceID], profile)
+		for _, section := range []map[string]types.Material{profile.Estimate.ReplacementMaterials, profile.Estimate.ConsumableSupplies} {
+			for _, material := range section {
+				if material.CatalogID != "" {
+					catalogIDs = append(catalogIDs, material.CatalogID)
+				}
+			}
+		}
+	}
+	catalogIDs = utils.RemoveDuplicates(catalogIDs)
+	catalog := make(map[string]*types.CatalogMaterial)
+	if len(catalogIDs) > 0 {
+		if catalog, err = s.catalogRepo.FindByIDs(ctx, catalogIDs); err != nil {
+			return nil, err
+		}
+	}
+	balances, err := s.stockRepo.Filter(ctx, &types.StockBalanceFilter{CatalogIDs: catalogIDs})
+	if err != nil {
+		return nil, err
+	}
+	if len(warehouses) > 0 {
+		balances = slices.DeleteFunc(balances, func(balance *types.StockBalance) bool {
+			return !slices.Contains(warehouses, balance.Warehouse)
+		})
+	}
+	transfers, err := s.stockTransferRepo.Filter(ctx, &types.StockTransferFilter{Status: types.TRANSFER_STATUS_IN_TRANSIT})
+	if err != nil {
+		return nil, err
+	}
+	suppliers, err := s.supplierRepo.FindByCatalogIDs(ctx, catalogIDs)
+	if err != nil {
+		return nil, err
+	}
+	stock := stockOnHand(balances, catalog)
+	for catalogID, quantity := range inTransitStock(transfers, warehouses) {
+		stock[catalogID] += quantity
+	}
+
+	forecast := &types.ShortageForecast{
+		Maintenances: maintenances,
+		Lines:        make([]types.ShortageLine, 0),
+		CreatedAt:    time.Now().Unix(),
+	}
+	for _, maintenance := range maintenances {
+		requisitions, err := s.purchaseRequisitionRepo.Filter(ctx, &types.PurchaseRequisitionFilter{
+			MaintenanceInstanceID: maintenance.ID,
+			Statuses:              types.OPEN_REQUISITION_STATUSES,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// as for requisitions, those of the whole maintenance cover every
+		// sector, those of another sector cover none of the materials of this one
+		requisitions = slices.DeleteFunc(requisitions, func(requisition *types.PurchaseRequisition) bool {
+			return req.Sector != "" && requisition.Sector != "" && requisition.Sector != req.Sector
+		})
+		onOrder := requisitionedQuantities(requisitions)
+		for _, need := range remainingNeeds(profilesByMaintenance[maintenance.ID], catalog) {
+			line := types.ShortageLine{
+				MaintenanceInstanceID: maintenance.ID,
+				Project:               maintenance.Project,
+				ProjectCode:           maintenance.ProjectCode,
+				NeededBy:              maintenance.StartDate,
+				CatalogID:             need.catalogID,
+				Code:                  need.code,
+				Name:                  need.name,
+				Unit:                  need.unit,
+				Remaining:             need.quantity,
+				OnOrder:               roundQuantity(onOrder[needKey(need.catalogID, need.name, need.unit)]),
+			}
+			uncovered := roundQuantity(line.Remaining - line.OnOrder)
+			if material := catalog[need.catalogID]; material != nil && need.unit == material.DefaultUnit && uncovered > 0 {
+				line.Stock = roundQuantity(min(max(stock[need.catalogID], 0), uncovered))
+				stock[need.catalogID] -= line.Stock
+			}
+			line.Shortage = roundQuantity(uncovered - line.Stock)
+			if line.Shortage <= 0 {
+				continue
+			}
+			if supplier := preferredSupplier(need.catalogID, suppliers); supplier != nil {
+				line.SupplierName = supplier.Name
+				line.LeadTimeDays = supplier.LeadTimeDays
+				if line.NeededBy != 0 {
+					line.OrderBy = time.Unix(line.NeededBy, 0).AddDate(0, 0, -supplier.LeadTimeDays).Unix()
+				}
+			}
+			forecast.Lines = append(forecast.Lines, line)
+		}
+	}
+	return forecast, nil
+}
+
+func (s *purchasingService) ExportShortageForecast(ctx context.Context, req *types.ShortageForecastReq) (*os.File, error) {
+	forecast, err := s.ForecastShortages(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "Dự báo thiếu hụt"
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		return nil, err
+	}
+	w := newSheetWriter(f, sheet)
+	w.writeRow("Ngày lập", formatForecastDate(forecast.CreatedAt))
+	if req.Sector != "" {
+		w.writeRow("Ngành", req.Sector)
+	}
+	w.writeRow()
+	w.writeRow("Dự án", "Mã dự án", "Cấp sửa chữa", "Ngày vào", "Ngày ra")
+	codes := make([]string, 0, len(forecast.Maintenances))
+	for _, maintenance := range forecast.Maintenances {
+		w.writeRow(
+			maintenance.Project,
+			maintenance.ProjectCode,
+			fmt.Sprintf("%s %s/%d", maintenance.MaintenanceTier, maintenance.MaintenanceNumber, maintenance.Year),
+			formatForecastDate(maintenance.StartDate),
+			formatForecastDate(maintenance.EndDate),
+		)
+		codes = append(codes, maintenance.ProjectCode)
+	}
+	w.writeRow()
+	w.writeRow(
+		"Mã dự án", "Cần trước ngày", "Mã vật tư", "Tên vật tư", "Đơn vị",
+		"Còn cần", "Tồn kho", "Đang đặt mua", "Thiếu",
+		"Nhà cung cấp", "Thời gian giao (ngày)", "Đặt hàng trước ngày",
+	)
+	for _, line := range forecast.Lines {
+		leadTime := ""
+		if line.SupplierName != "" {
+			leadTime = fmt.Sprintf("%d", line.LeadTimeDays)
+		}
+		w.writeRow(
+			line.ProjectCode,
+			formatForecastDate(line.NeededBy),
+			line.Code,
+			line.Name,
+			line.Unit,
+			line.Remaining,
+			line.Stock,
+			line.OnOrder,
+			line.Shortage,
+			line.SupplierName,
+			leadTime,
+			formatForecastDate(line.OrderBy),
+		)
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	tempDir := os.TempDir()
+	saveDir := path.Join(tempDir, "report")
+	// create dir if not exist
+	if err := os.MkdirAll(saveDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	fileName := path.Join(
+		saveDir,
+		strings.ReplaceAll(
+			fmt.Sprintf("shortage_%s_%d.xlsx", strings.Join(codes, "_"), forecast.CreatedAt),
+			" ", "_",
+		),
+	)
+	if err := f.SaveAs(fileName); err != nil {
+		return nil, err
+	}
+	return os.Open(fileName)
+}
+
+// formatForecastDate formats a date of the forecast, empty when not set.
+func formatForecastDate(at int64) string {
+	if at == 0 {
+		return ""
+	}
+	return time.Unix(at, 0).Local().Format("02/01/2006")
+}
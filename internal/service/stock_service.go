@@ -0,0 +1,947 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"baliance.com/gooxml/color"
+	"baliance.com/gooxml/document"
+	"baliance.com/gooxml/measurement"
+	"baliance.com/gooxml/schema/soo/wml"
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+var _ StockService = &stockService{}
+
+type StockService interface {
+	// receive materials into a warehouse
+	ReceiveMaterials(ctx context.Context, req *types.StockReceiptReq) (*types.StockMovement, error)
+	// issue lines of a numbered material request after checking the stock,
+	// recording the serial or lot numbers of the replacement parts
+	IssueMaterials(ctx context.Context, req *types.IssueMaterialsReq) (*types.StockMovement, error)
+	// take back materials issued against a material request
+	ReturnMaterials(ctx context.Context, req *types.ReturnMaterialsReq) (*types.StockMovement, error)
+	// correct balances by signed quantities
+	AdjustStock(ctx context.Context, req *types.StockAdjustmentReq) (*types.StockMovement, error)
+	// set the quantity on hand of a catalog material in a warehouse from a
+	// count, recorded as an adjustment
+	RecordStockCount(ctx context.Context, req *types.RecordStockCountReq) (*types.StockMovement, error)
+	// balances per catalog material and warehouse
+	StockBalances(ctx context.Context, req *types.StockBalanceRequest) ([]*types.StockBalance, error)
+	// movements of the stock ledger, latest first
+	StockLedger(ctx context.Context, req *types.StockMovementSearchRequest) ([]*types.StockMovement, int64, error)
+	// ship materials from a warehouse to another, in transit until received
+	CreateTransfer(ctx context.Context, req *types.CreateStockTransferReq) (*types.StockTransfer, error)
+	// receive a transfer in transit into its destination
+	ReceiveTransfer(ctx context.Context, id string) (*types.StockTransfer, error)
+	// take a transfer in transit back into its origin
+	CancelTransfer(ctx context.Context, id string) (*types.StockTransfer, error)
+	GetTransfer(ctx context.Context, id string) (*types.StockTransfer, error)
+	SearchTransfers(ctx context.Context, req *types.StockTransferSearchRequest) ([]*types.StockTransfer, int64, error)
+	// create a docx transfer note to download and print
+	ExportTransferNote(ctx context.Context, id string) (*os.File, error)
+}
+
+type stockService struct {
+	stockRepo               repository.StockRepository
+	stockMovementRepo       repository.StockMovementRepository
+	stockTransferRepo       repository.StockTransferRepository
+	warehouseRepo           repository.WarehouseRepository
+	installedPartRepo       repository.InstalledPartRepository
+	catalogRepo             repository.CatalogRepository
+	materialsRequestRepo    repository.MaterialsRequestRepository
+	materialsProfileRepo    repository.MaterialsProfileRepository
+	equipmentMachineryRepo  repository.EquipmentMachineryRepo
+	maintenanceRepo         repository.MaintenanceRepository
+	purchaseRequisitionRepo repository.PurchaseRequisitionRepository
+	counterRepo             repository.CounterRepository
+	transactor              repository.Transactor
+}
+
+func NewStockService(
+	stockRepo repository.StockRepository,
+	stockMovementRepo repository.StockMovementRepository,
+	stockTransferRepo repository.StockTransferRepository,
+	warehouseRepo repository.WarehouseRepository,
+	installedPartRepo repository.InstalledPartRepository,
+	catalogRepo repository.CatalogRepository,
+	materialsRequestRepo repository.MaterialsRequestRepository,
+	materialsProfileRepo repository.MaterialsProfileRepository,
+	equipmentMachineryRepo repository.EquipmentMachineryRepo,
+	maintenanceRepo repository.MaintenanceRepository,
+	purchaseRequisitionRepo repository.PurchaseRequisitionRepository,
+	counterRepo repository.CounterRepository,
+	transactor repository.Transactor,
+) StockService {
+	return &stockService{
+		stockRepo:               stockRepo,
+		stockMovementRepo:       stockMovementRepo,
+		stockTransferRepo:       stockTransferRepo,
+		warehouseRepo:           warehouseRepo,
+		installedPartRepo:       installedPartRepo,
+		catalogRepo:             catalogRepo,
+		materialsRequestRepo:    materialsRequestRepo,
+		materialsProfileRepo:    materialsProfileRepo,
+		equipmentMachineryRepo:  equipmentMachineryRepo,
+		maintenanceRepo:         maintenanceRepo,
+		purchaseRequisitionRepo: purchaseRequisitionRepo,
+		counterRepo:             counterRepo,
+		transactor:              transactor,
+	}
+}
+
+func (s *stockService) ReceiveMaterials(ctx context.Context, req *types.StockReceiptReq) (*types.StockMovement, error) {
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return nil, types.ErrUnauthorized
+	}
+	if _, err := s.findWarehouse(ctx, req.Warehouse); err != nil {
+		return nil, err
+	}
+	if req.PurchaseRequisitionID != "" {
+		requisition, err := s.purchaseRequisitionRepo.FindByID(ctx, req.PurchaseRequisitionID)
+		if err != nil {
+			return nil, err
+		}
+		if requisition == nil {
+			return nil, types.ErrPurchaseRequisitionNotFound
+		}
+	}
+	lines, catalog, err := s.catalogLines(ctx, req.Lines, false)
+	if err != nil {
+		return nil, err
+	}
+	movement := newStockMovement(types.STOCK_MOVEMENT_RECEIPT, req.Warehouse, req.Note, user, lines)
+	movement.PurchaseRequisitionID = req.PurchaseRequisitionID
+	if err := s.record(ctx, movement, catalog); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (s *stockService) AdjustStock(ctx context.Context, req *types.StockAdjustmentReq) (*types.StockMovement, error) {
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return nil, types.ErrUnauthorized
+	}
+	if _, err := s.findWarehouse(ctx, req.Warehouse); err != nil {
+		return nil, err
+	}
+	lines, catalog, err := s.catalogLines(ctx, req.Lines, true)
+	if err != nil {
+		return nil, err
+	}
+	movement := newStockMovement(types.STOCK_MOVEMENT_ADJUSTMENT, req.Warehouse, req.Note, user, lines)
+	if err := s.record(ctx, movement, catalog); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (s *stockService) RecordStockCount(ctx context.Context, req *types.RecordStockCountReq) (*types.StockMovement, error) {
+	if req.Quantity < 0 {
+		return nil, types.ErrInvalidStockQuantity
+	}
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return nil, types.ErrUnauthorized
+	}
+	material, err := s.catalogRepo.FindByID(ctx, req.CatalogID)
+	if err != nil {
+		return nil, err
+	}
+	if material == nil {
+		return nil, types.ErrCatalogMaterialNotFound
+	}
+	unit := utils.NormalizeUnit(req.Unit)
+	if unit == "" {
+		unit = material.DefaultUnit
+	}
+	counted, ok := utils.ConvertQuantity(req.Quantity, unit, material.DefaultUnit, catalogPackSizes(material))
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", types.ErrIncompatibleUnit, unit, material.DefaultUnit)
+	}
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		note = "Kiểm kê"
+	}
+	found, err := s.findWarehouse(ctx, req.Warehouse)
+	if err != nil {
+		return nil, err
+	}
+	warehouse := found.ID
+
+	// the balance read is the balance adjusted: a movement committed in
+	// between makes the transaction retry
+	var movement *types.StockMovement
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		balances, err := s.stockRepo.Filter(ctx, &types.StockBalanceFilter{
+			CatalogIDs: []string{material.ID},
+			Warehouse:  warehouse,
+		})
+		if err != nil {
+			return err
+		}
+		catalog := map[string]*types.CatalogMaterial{material.ID: material}
+		current := warehouseOnHand(balances, warehouse)[material.ID]
+		movement = newStockMovement(types.STOCK_MOVEMENT_ADJUSTMENT, warehouse, note, user, []types.StockMovementLine{{
+			CatalogID: material.ID,
+			Name:      material.Name,
+			Unit:      unit,
+			Quantity:  req.Quantity,
+			Delta:     roundQuantity(counted - current),
+		}})
+		return s.record(ctx, movement, catalog)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (s *stockService) IssueMaterials(ctx context.Context, req *types.IssueMaterialsReq) (*types.StockMovement, error) {
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return nil, types.ErrUnauthorized
+	}
+
+	// the request read is the request updated: an issue or return committed
+	// in between makes the transaction retry
+	var movement *types.StockMovement
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		request, err := s.findNumberedRequest(ctx, req.MaterialRequestID)
+		if err != nil {
+			return err
+		}
+		warehouse, err := s.requestWarehouse(ctx, request, req.Warehouse)
+		if err != nil {
+			return err
+		}
+		requested := req.Lines
+		if len(requested) == 0 {
+			// lines not linked to the catalog are not kept in stock
+			for _, line := range outstandingRequestLines(request) {
+				if material, _ := requestMaterial(request.MaterialsForEquipment, line.MaterialsProfileID, line.MaterialType, line.Name); material.CatalogID != "" {
+					requested = append(requested, line)
+				}
+			}
+			if len(requested) == 0 {
+				return types.ErrNothingToIssue
+			}
+		}
+		lines, catalog, err := s.requestLines(ctx, request, requested, -1)
+		if err != nil {
+			return err
+		}
+		for key, quantity := range requestLineTotals(lines) {
+			material, _ := requestMaterial(request.MaterialsForEquipment, key.profileID, key.materialType, key.name)
+			issued, _ := requestMaterial(request.Issued, key.profileID, key.materialType, key.name)
+			if roundQuantity(issued.Quantity+quantity) > roundQuantity(material.Quantity) {
+				return fmt.Errorf("%w: %s, %v of %v %s left", types.ErrIssueExceedsRequest, key.name, roundQuantity(material.Quantity-issued.Quantity), material.Quantity, material.Unit)
+			}
+		}
+		if err := s.checkSerialsFree(ctx, lines); err != nil {
+			return err
+		}
+		movement = newStockMovement(types.STOCK_MOVEMENT_ISSUE, warehouse.ID, req.Note, user, lines)
+		movement.MaterialRequestID = request.ID
+		movement.NumOfRequest = request.NumOfRequest
+		if err := s.record(ctx, movement, catalog); err != nil {
+			return err
+		}
+		if err := s.installParts(ctx, request, movement); err != nil {
+			return err
+		}
+		addIssued(request, lines, 1)
+		return s.materialsRequestRepo.Update(ctx, movement.MaterialRequestID, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (s *stockService) ReturnMaterials(ctx context.Context, req *types.ReturnMaterialsReq) (*types.StockMovement, error) {
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return nil, types.ErrUnauthorized
+	}
+
+	// the request read is the request updated: an issue or return committed
+	// in between makes the transaction retry
+	var movement *types.StockMovement
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		request, err := s.findNumberedRequest(ctx, req.MaterialRequestID)
+		if err != nil {
+			return err
+		}
+		warehouse, err := s.requestWarehouse(ctx, request, req.Warehouse)
+		if err != nil {
+			return err
+		}
+		lines, catalog, err := s.requestLines(ctx, request, req.Lines, 1)
+		if err != nil {
+			return err
+		}
+		for key, quantity := range requestLineTotals(lines) {
+			issued, _ := requestMaterial(request.Issued, key.profileID, key.materialType, key.name)
+			if roundQuantity(quantity) > roundQuantity(issued.Quantity) {
+				return fmt.Errorf("%w: %s, %v %s issued", types.ErrReturnExceedsIssued, key.name, issued.Quantity, issued.Unit)
+			}
+		}
+		returned, err := s.returnedParts(ctx, request.ID, lines)
+		if err != nil {
+			return err
+		}
+		movement = newStockMovement(types.STOCK_MOVEMENT_RETURN, warehouse.ID, req.Note, user, lines)
+		movement.MaterialRequestID = request.ID
+		movement.NumOfRequest = request.NumOfRequest
+		if err := s.record(ctx, movement, catalog); err != nil {
+			return err
+		}
+		if err := s.installedPartRepo.MarkReturned(ctx, returned, movement.ID, movement.CreatedAt); err != nil {
+			return err
+		}
+		addIssued(request, lines, -1)
+		return s.materialsRequestRepo.Update(ctx, movement.MaterialRequestID, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (s *stockService) StockBalances(ctx context.Context, req *types.StockBalanceRequest) ([]*types.StockBalance, error) {
+	filter := &types.StockBalanceFilter{
+		Warehouse: strings.TrimSpace(req.Warehouse),
+	}
+	if catalogID := strings.TrimSpace(req.CatalogID); catalogID != "" {
+		filter.CatalogIDs = []string{catalogID}
+	}
+	return s.stockRepo.Filter(ctx, filter)
+}
+
+func (s *stockService) StockLedger(ctx context.Context, req *types.StockMovementSearchRequest) ([]*types.StockMovement, int64, error) {
+	if req.Kind != "" && !utils.Contains(types.STOCK_MOVEMENT_KIND_LIST, req.Kind) {
+		return nil, 0, types.ErrInvalidStockMovementKind
+	}
+	filter := &types.StockMovementFilter{
+		Kind:              req.Kind,
+		Warehouse:         strings.TrimSpace(req.Warehouse),
+		CatalogID:         strings.TrimSpace(req.CatalogID),
+		MaterialRequestID: strings.TrimSpace(req.MaterialRequestID),
+		CreatedFrom:       req.From,
+		CreatedTo:         req.To,
+	}
+	return s.stockMovementRepo.Paginate(ctx, filter, req.Page, req.Limit)
+}
+
+func (s *stockService) CreateTransfer(ctx context.Context, req *types.CreateStockTransferReq) (*types.StockTransfer, error) {
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return nil, types.ErrUnauthorized
+	}
+	from, err := s.findWarehouse(ctx, req.FromWarehouse)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.findWarehouse(ctx, req.ToWarehouse)
+	if err != nil {
+		return nil, err
+	}
+	if from.ID == to.ID {
+		return nil, types.ErrSameWarehouse
+	}
+	lines, catalog, err := s.catalogLines(ctx, req.Lines, false)
+	if err != nil {
+		return nil, err
+	}
+
+	shipped := newStockMovement(types.STOCK_MOVEMENT_TRANSFER_OUT, from.ID, req.Note, user, transferLines(lines, -1))
+	transfer := &types.StockTransfer{
+		FromWarehouse: from.ID,
+		ToWarehouse:   to.ID,
+		Status:        types.TRANSFER_STATUS_IN_TRANSIT,
+		Lines:         lines,
+		CatalogIDs:    shipped.CatalogIDs,
+		Note:          shipped.Note,
+		CreatedBy:     shipped.CreatedBy,
+		CreatedAt:     shipped.CreatedAt,
+	}
+	// numbered in the transaction so that a refused transfer leaves no gap
+	err = s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		last, err := s.stockTransferRepo.LastNumber(ctx)
+		if err != nil {
+			return err
+		}
+		if transfer.Number, err = s.counterRepo.Next(ctx, types.STOCK_TRANSFER_COUNTER, last); err != nil {
+			return err
+		}
+		id, err := s.stockTransferRepo.Save(ctx, transfer)
+		if err != nil {
+			return err
+		}
+		transfer.ID = id
+		shipped.TransferID = id
+		return applyStockMovement(ctx, s.stockRepo, s.stockMovementRepo, shipped, catalog)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (s *stockService) ReceiveTransfer(ctx context.Context, id string) (*types.StockTransfer, error) {
+	return s.closeTransfer(ctx, id, types.TRANSFER_STATUS_RECEIVED)
+}
+
+func (s *stockService) CancelTransfer(ctx context.Context, id string) (*types.StockTransfer, error) {
+	return s.closeTransfer(ctx, id, types.TRANSFER_STATUS_CANCELLED)
+}
+
+// closeTransfer puts the materials of a transfer in transit into its
+// destination when received, or back into its origin when cancelled.
+func (s *stockService) closeTransfer(ctx context.Context, id string, status string) (*types.StockTransfer, error) {
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return nil, types.ErrUnauthorized
+	}
+
+	// the transfer read is the transfer closed: closing it twice at once
+	// makes one of the transactions retry and find it closed
+	var transfer *types.StockTransfer
+	err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		found, err := s.GetTransfer(ctx, id)
+		if err != nil {
+			return err
+		}
+		if found.Status != types.TRANSFER_STATUS_IN_TRANSIT {
+			return types.ErrTransferNotInTransit
+		}
+		catalog, err := s.catalogRepo.FindByIDs(ctx, found.CatalogIDs)
+		if err != nil {
+			return err
+		}
+		warehouse, note := found.ToWarehouse, fmt.Sprintf("Nhận %s%d", types.STOCK_TRANSFER_PREFIX, found.Number)
+		if status == types.TRANSFER_STATUS_CANCELLED {
+			warehouse, note = found.FromWarehouse, fmt.Sprintf("Hủy %s%d", types.STOCK_TRANSFER_PREFIX, found.Number)
+		}
+		movement := newStockMovement(types.STOCK_MOVEMENT_TRANSFER_IN, warehouse, note, user, transferLines(found.Lines, 1))
+		movement.TransferID = found.ID
+		if err := applyStockMovement(ctx, s.stockRepo, s.stockMovementRepo, movement, catalog); err != nil {
+			return err
+		}
+		found.Status = status
+		found.ClosedBy = movement.CreatedBy
+		found.ClosedAt = movement.CreatedAt
+		if err := s.stockTransferRepo.Update(ctx, id, found); err != nil {
+			return err
+		}
+		found.ID = id
+		transfer = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (s *stockService) GetTransfer(ctx context.Context, id string) (*types.StockTransfer, error) {
+	transfer, err := s.stockTransferRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, types.ErrStockTransferNotFound
+	}
+	return transfer, nil
+}
+
+func (s *stockService) SearchTransfers(ctx context.Context, req *types.StockTransferSearchRequest) ([]*types.StockTransfer, int64, error) {
+	if req.Status != "" && !utils.Contains(types.TRANSFER_STATUS_LIST, req.Status) {
+		return nil, 0, types.ErrInvalidTransferStatus
+	}
+	filter := &types.StockTransferFilter{
+		Warehouse: strings.TrimSpace(req.Warehouse),
+		Status:    req.Status,
+		CatalogID: strings.TrimSpace(req.CatalogID),
+	}
+	return s.stockTransferRepo.Paginate(ctx, filter, req.Page, req.Limit)
+}
+
+var transferStatusLabels = map[string]string{
+	types.TRANSFER_STATUS_IN_TRANSIT: "Đang vận chuyển",
+	types.TRANSFER_STATUS_RECEIVED:   "Đã nhận",
+	types.TRANSFER_STATUS_CANCELLED:  "Đã hủy",
+}
+
+func (s *stockService) ExportTransferNote(ctx context.Context, id string) (*os.File, error) {
+	transfer, err := s.GetTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.findWarehouse(ctx, transfer.FromWarehouse)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.findWarehouse(ctx, transfer.ToWarehouse)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := s.catalogRepo.FindByIDs(ctx, transfer.CatalogIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := document.New()
+	addLine := func(text string, bold bool) document.Paragraph {
+		paragraph := doc.AddParagraph()
+		run := paragraph.AddRun()
+		run.Properties().SetBold(bold)
+		run.AddText(text)
+		return paragraph
+	}
+	warehouseText := func(warehouse *types.Warehouse) string {
+		return strings.Join(nonEmpty(warehouse.Name, warehouse.Location, warehouse.ResponsibleSector), " - ")
+	}
+	addLine("PHIẾU CHUYỂN KHO", true).Properties().SetAlignment(wml.ST_JcCenter)
+	addLine(fmt.Sprintf("Số: %s%d", types.STOCK_TRANSFER_PREFIX, transfer.Number), false).Properties().SetAlignment(wml.ST_JcCenter)
+	addLine(fmt.Sprintf("Kho xuất: %s", warehouseText(from)), false)
+	addLine(fmt.Sprintf("Kho nhận: %s", warehouseText(to)), false)
+	addLine(fmt.Sprintf("Ngày xuất: %s - Người lập: %s - Trạng thái: %s",
+		time.Unix(transfer.CreatedAt, 0).Local().Format("02/01/2006"),
+		transfer.CreatedBy,
+		transferStatusLabels[transfer.Status],
+	), false)
+	if transfer.Status == types.TRANSFER_STATUS_RECEIVED {
+		addLine(fmt.Sprintf("Ngày nhận: %s - Người nhận: %s", time.Unix(transfer.ClosedAt, 0).Local().Format("02/01/2006"), transfer.ClosedBy), false)
+	}
+	if transfer.Note != "" {
+		addLine(fmt.Sprintf("Ghi chú: %s", transfer.Note), false)
+	}
+	addLine("", false)
+
+	table := doc.AddTable()
+	table.Properties().SetWidthPercent(100)
+	table.Properties().Borders().SetAll(wml.ST_BorderSingle, color.Auto, 1*measurement.Point)
+	header := table.AddRow()
+	for _, title := range []string{"STT", "Mã vật tư", "Tên vật tư", "ĐVT", "Số lượng", "Ghi chú"} {
+		run := header.AddCell().AddParagraph().AddRun()
+		run.Properties().SetBold(true)
+		run.AddText(title)
+	}
+	for i, line := range transfer.Lines {
+		code := ""
+		if material := catalog[line.CatalogID]; material != nil {
+			code = material.Code
+		}
+		row := table.AddRow()
+		row.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%d", i+1))
+		row.AddCell().AddParagraph().AddRun().AddText(code)
+		row.AddCell().AddParagraph().AddRun().AddText(line.Name)
+		row.AddCell().AddParagraph().AddRun().AddText(line.Unit)
+		row.AddCell().AddParagraph().AddRun().AddText(fmt.Sprintf("%.2f", line.Quantity))
+		row.AddCell().AddParagraph().AddRun().AddText("")
+	}
+
+	addLine("", false)
+	signatures := doc.AddTable()
+	signatures.Properties().SetWidthPercent(100)
+	row := signatures.AddRow()
+	for _, title := range []string{"NGƯỜI LẬP", "THỦ KHO XUẤT", "THỦ KHO NHẬN"} {
+		paragraph := row.AddCell().AddParagraph()
+		paragraph.Properties().SetAlignment(wml.ST_JcCenter)
+		run := paragraph.AddRun()
+		run.Properties().SetBold(true)
+		run.AddText(title)
+	}
+
+	tempDir := os.TempDir()
+	saveDir := path.Join(tempDir, "stock_transfer")
+	if err := os.MkdirAll(saveDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	fileName := path.Join(
+		saveDir,
+		fmt.Sprintf(
+			"%s%d-%s.docx",
+			types.STOCK_TRANSFER_PREFIX,
+			transfer.Number,
+			time.Now().Local().Format("2006-01-02"),
+		),
+	)
+	if err := doc.SaveToFile(fileName); err != nil {
+		return nil, err
+	}
+	return os.Open(fileName)
+}
+
+func (s *stockService) findWarehouse(ctx context.Context, id string) (*types.Warehouse, error) {
+	warehouse, err := s.warehouseRepo.FindByID(ctx, strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	if warehouse == nil {
+		return nil, types.ErrWarehouseNotFound
+	}
+	return warehouse, nil
+}
+
+// requestWarehouse returns the warehouse materials of a request are issued
+// from or returned to, by default the one serving the sector of the request.
+// The warehouse must be given when several serve the sector.
+func (s *stockService) requestWarehouse(ctx context.Context, request *types.MaterialRequest, id string) (*types.Warehouse, error) {
+	if strings.TrimSpace(id) != "" {
+		return s.findWarehouse(ctx, id)
+	}
+	warehouses, err := s.warehouseRepo.Filter(ctx, &types.WarehouseFilter{ServedSector: request.Sector})
+	if err != nil {
+		return nil, err
+	}
+	if len(warehouses) == 0 {
+		return nil, fmt.Errorf("%w: %s", types.ErrNoWarehouseForSector, request.Sector)
+	}
+	if len(warehouses) > 1 {
+		return nil, fmt.Errorf("%w: %s", types.ErrSeveralWarehousesForSector, request.Sector)
+	}
+	return warehouses[0], nil
+}
+
+func (s *stockService) findNumberedRequest(ctx context.Context, id string) (*types.MaterialRequest, error) {
+	request, err := s.materialsRequestRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil || request.ID == "" {
+		return nil, types.ErrMaterialRequestNotFound
+	}
+	if request.NumOfRequest == 0 {
+		return nil, types.ErrMaterialRequestNotNumbered
+	}
+	return request, nil
+}
+
+// catalogLines converts quantities of catalog materials to the default unit
+// of the materials. Negative quantities are only allowed for adjustments.
+func (s *stockService) catalogLines(ctx context.Context, requested []types.StockLineReq, allowNegative bool) ([]types.StockMovementLine, map[string]*types.CatalogMaterial, error) {
+	ids := make([]string, 0, len(requested))
+	for _, line := range requested {
+		ids = append(ids, line.CatalogID)
+	}
+	ids = utils.RemoveDuplicates(ids)
+	catalog, err := s.catalogRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(catalog) != len(ids) {
+		return nil, nil, types.ErrSomeCatalogMaterialNotFound
+	}
+	lines := make([]types.StockMovementLine, 0, len(requested))
+	for _, line := range requested {
+		if line.Quantity == 0 || (line.Quantity < 0 && !allowNegative) {
+			return nil, nil, types.ErrInvalidStockQuantity
+		}
+		material := catalog[line.CatalogID]
+		unit := utils.NormalizeUnit(line.Unit)
+		if unit == "" {
+			unit = material.DefaultUnit
+		}
+		delta, ok := utils.ConvertQuantity(line.Quantity, unit, material.DefaultUnit, catalogPackSizes(material))
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s in %s", types.ErrIncompatibleUnit, material.Name, unit)
+		}
+		lines = append(lines, types.StockMovementLine{
+			CatalogID: material.ID,
+			Name:      material.Name,
+			Unit:      unit,
+			Quantity:  line.Quantity,
+			Delta:     delta,
+		})
+	}
+	return lines, catalog, nil
+}
+
+// requestLines converts quantities of material request lines to the default
+// unit of their catalog material, with the sign of the movement.
+func (s *stockService) requestLines(ctx context.Context, request *types.MaterialRequest, requested []types.RequestLineReq, sign float64) ([]types.StockMovementLine, map[string]*types.CatalogMaterial, error) {
+	ids := make([]string, 0, len(requested))
+	materials := make([]types.Material, 0, len(requested))
+	for _, line := range requested {
+		material, ok := requestMaterial(request.MaterialsForEquipment, line.MaterialsProfileID, line.MaterialType, line.Name)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s", types.ErrMaterialRequestLineNotFound, line.Name)
+		}
+		if line.Quantity <= 0 {
+			return nil, nil, types.ErrInvalidStockQuantity
+		}
+		if err := checkTracking(line); err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", err, line.Name)
+		}
+		if material.CatalogID == "" {
+			return nil, nil, fmt.Errorf("%w: %s", types.ErrMaterialNotInCatalog, line.Name)
+		}
+		ids = append(ids, material.CatalogID)
+		materials = append(materials, material)
+	}
+	ids = utils.RemoveDuplicates(ids)
+	catalog, err := s.catalogRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(catalog) != len(ids) {
+		return nil, nil, types.ErrSomeCatalogMaterialNotFound
+	}
+	lines := make([]types.StockMovementLine, 0, len(requested))
+	for i, line := range requested {
+		material := materials[i]
+		catalogMaterial := catalog[material.CatalogID]
+		unit := material.Unit
+		if unit == "" {
+			unit = catalogMaterial.DefaultUnit
+		}
+		delta, ok := utils.ConvertQuantity(line.Quantity, unit, catalogMaterial.DefaultUnit, catalogPackSizes(catalogMaterial))
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s in %s", types.ErrIncompatibleUnit, line.Name, unit)
+		}
+		lines = append(lines, types.StockMovementLine{
+			CatalogID:          catalogMaterial.ID,
+			Name:               material.Name,
+			Unit:               unit,
+			Quantity:           line.Quantity,
+			Delta:              sign * delta,
+			MaterialsProfileID: line.MaterialsProfileID,
+			MaterialType:       line.MaterialType,
+			MaterialKey:        line.Name,
+			PartTracking:       normalizeTracking(line.PartTracking),
+		})
+	}
+	return lines, catalog, nil
+}
+
+// record applies the movement in a transaction, joining the transaction of
+// the caller if any.
+func (s *stockService) record(ctx context.Context, movement *types.StockMovement, catalog map[string]*types.CatalogMaterial) error {
+	return s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+		return applyStockMovement(ctx, s.stockRepo, s.stockMovementRepo, movement, catalog)
+	})
+}
+
+// warehouseOnHand sums the balances of one warehouse per catalog material.
+func warehouseOnHand(balances []*types.StockBalance, warehouse string) map[string]float64 {
+	onHand := make(map[string]float64)
+	for _, balance := range balances {
+		if balance.Warehouse == warehouse {
+			onHand[balance.CatalogID] += balance.Quantity
+		}
+	}
+	return onHand
+}
+
+// applyStockMovement saves a movement and adds its deltas to the balances of
+// its warehouse. It fails with ErrInsufficientStock when a balance does not
+// hold the quantity taken out of it, after the balances of the other
+// materials may have changed: the caller runs it in a transaction.
+func applyStockMovement(ctx context.Context, stockRepo repository.StockRepository, stockMovementRepo repository.StockMovementRepository, movement *types.StockMovement, catalog map[string]*types.CatalogMaterial) error {
+	added := make([]*types.StockBalance, 0, len(movement.CatalogIDs))
+	for _, catalogID := range movement.CatalogIDs {
+		balance := &types.StockBalance{
+			CatalogID: catalogID,
+			Warehouse: movement.Warehouse,
+			UpdatedBy: movement.CreatedBy,
+			UpdatedAt: movement.CreatedAt,
+		}
+		if material := catalog[catalogID]; material != nil {
+			balance.Unit = material.DefaultUnit
+		}
+		for _, line := range movement.Lines {
+			if line.CatalogID == catalogID {
+				balance.Quantity += line.Delta
+			}
+		}
+		balance.Quantity = roundQuantity(balance.Quantity)
+		if balance.Quantity >= 0 {
+			added = append(added, balance)
+			continue
+		}
+		balance.Quantity = -balance.Quantity
+		taken, err := stockRepo.TakeBalance(ctx, balance)
+		if err != nil {
+			return err
+		}
+		if !taken {
+			return insufficientStock(ctx, stockRepo, balance, catalog)
+		}
+	}
+	// a retried transaction saves the movement again
+	movement.ID = ""
+	id, err := stockMovementRepo.Save(ctx, movement)
+	if err != nil {
+		return err
+	}
+	movement.ID = id
+	return stockRepo.AddBalances(ctx, added)
+}
+
+// insufficientStock describes the balance that does not hold the quantity
+// of the balance taken.
+func insufficientStock(ctx context.Context, stockRepo repository.StockRepository, taken *types.StockBalance, catalog map[string]*types.CatalogMaterial) error {
+	balances, err := stockRepo.Filter(ctx, &types.StockBalanceFilter{
+		CatalogIDs: []string{taken.CatalogID},
+		Warehouse:  taken.Warehouse,
+	})
+	if err != nil {
+		return err
+	}
+	name := taken.CatalogID
+	if material := catalog[taken.CatalogID]; material != nil {
+		name = material.Name
+	}
+	onHand := warehouseOnHand(balances, taken.Warehouse)[taken.CatalogID]
+	return fmt.Errorf("%w: %s, %v %s available, %v %s needed", types.ErrInsufficientStock, name, onHand, taken.Unit, taken.Quantity, taken.Unit)
+}
+
+// transferLines copies the lines of a transfer with the sign of a movement.
+func transferLines(lines []types.StockMovementLine, sign float64) []types.StockMovementLine {
+	moved := make([]types.StockMovementLine, 0, len(lines))
+	for _, line := range lines {
+		line.Delta = sign * line.Delta
+		moved = append(moved, line)
+	}
+	return moved
+}
+
+func newStockMovement(kind, warehouse, note string, user *types.User, lines []types.StockMovementLine) *types.StockMovement {
+	movement := &types.StockMovement{
+		Kind:       kind,
+		Warehouse:  strings.TrimSpace(warehouse),
+		Lines:      lines,
+		CatalogIDs: make([]string, 0, len(lines)),
+		Note:       strings.TrimSpace(note),
+		CreatedAt:  time.Now().Unix(),
+	}
+	if user != nil {
+		movement.CreatedBy = user.Username
+	}
+	for _, line := range lines {
+		movement.CatalogIDs = append(movement.CatalogIDs, line.CatalogID)
+	}
+	movement.CatalogIDs = utils.RemoveDuplicates(movement.CatalogIDs)
+	return movement
+}
+
+// requestMaterial returns a line of materials keyed by materials profile like
+// the lines and the issued quantities of a material request.
+func requestMaterial(materials map[string]types.MaterialsForEquipment, profileID, materialType, name string) (types.Material, bool) {
+	forEquipment, ok := materials[profileID]
+	if !ok {
+		return types.Material{}, false
+	}
+	var section map[string]types.Material
+	switch materialType {
+	case types.MATERIAL_TYPE_REPLACEMENT:
+		section = forEquipment.ReplacementMaterials
+	case types.MATERIAL_TYPE_CONSUMABLE:
+		section = forEquipment.ConsumableSupplies
+	}
+	material, ok := section[name]
+	return material, ok
+}
+
+type requestLineKey struct {
+	profileID    string
+	materialType string
+	name         string
+}
+
+// requestLineTotals sums the quantities of the movement lines per request line.
+func requestLineTotals(lines []types.StockMovementLine) map[requestLineKey]float64 {
+	totals := make(map[requestLineKey]float64)
+	for _, line := range lines {
+		totals[requestLineKey{line.MaterialsProfileID, line.MaterialType, line.MaterialKey}] += line.Quantity
+	}
+	return totals
+}
+
+// outstandingRequestLines returns the requested quantities not issued yet,
+// by materials profile, replacement materials first and then by name.
+func outstandingRequestLines(request *types.MaterialRequest) []types.RequestLineReq {
+	profileIDs := make([]string, 0, len(request.MaterialsForEquipment))
+	for id := range request.MaterialsForEquipment {
+		profileIDs = append(profileIDs, id)
+	}
+	slices.Sort(profileIDs)
+	lines := make([]types.RequestLineReq, 0)
+	for _, profileID := range profileIDs {
+		materials := request.MaterialsForEquipment[profileID]
+		sections := []struct {
+			materialType string
+			materials    map[string]types.Material
+		}{
+			{types.MATERIAL_TYPE_REPLACEMENT, materials.ReplacementMaterials},
+			{types.MATERIAL_TYPE_CONSUMABLE, materials.ConsumableSupplies},
+		}
+		for _, section := range sections {
+			for _, name := range sortedMaterialNames(section.materials) {
+				issued, _ := requestMaterial(request.Issued, profileID, section.materialType, name)
+				if outstanding := roundQuantity(section.materials[name].Quantity - issued.Quantity); outstanding > 0 {
+					lines = append(lines, types.RequestLineReq{
+						MaterialsProfileID: profileID,
+						MaterialType:       section.materialType,
+						Name:               name,
+						Quantity:           outstanding,
+					})
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// addIssued adds the quantities of movement lines to the issued quantities of
+// a material request, or subtracts them for returns, dropping the lines
+// issued no more.
+func addIssued(request *types.MaterialRequest, lines []types.StockMovementLine, sign float64) {
+	if request.Issued == nil {
+		request.Issued = make(map[string]types.MaterialsForEquipment)
+	}
+	for _, line := range lines {
+		issued := request.Issued[line.MaterialsProfileID]
+		if issued.ReplacementMaterials == nil {
+			issued.ReplacementMaterials = make(map[string]types.Material)
+		}
+		if issued.ConsumableSupplies == nil {
+			issued.ConsumableSupplies = make(map[string]types.Material)
+		}
+		section := issued.ConsumableSupplies
+		if line.MaterialType == types.MATERIAL_TYPE_REPLACEMENT {
+			section = issued.ReplacementMaterials
+		}
+		material, ok := section[line.MaterialKey]
+		if !ok {
+			material, _ = requestMaterial(request.MaterialsForEquipment, line.MaterialsProfileID, line.MaterialType, line.MaterialKey)
+			material.Quantity = 0
+		}
+		material.Quantity = roundQuantity(material.Quantity + sign*line.Quantity)
+		if material.Quantity > 0 {
+			section[line.MaterialKey] = material
+		} else {
+			delete(section, line.MaterialKey)
+		}
+		if len(issued.ReplacementMaterials) == 0 && len(issued.ConsumableSupplies) == 0 {
+			delete(request.Issued, line.MaterialsProfileID)
+			continue
+		}
+		request.Issued[line.MaterialsProfileID] = issued
+	}
+	if len(request.Issued) == 0 {
+		request.Issued = nil
+	}
+}
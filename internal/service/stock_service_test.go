@@ -0,0 +1,532 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+)
+
+func TestStockLedger(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &types.User{Username: "thukho"})
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	materialsRequestRepo := repository.NewMaterialsRequestRepository(db)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	s := NewStockService(
+		repository.NewStockRepository(db),
+		repository.NewStockMovementRepository(db),
+		repository.NewStockTransferRepository(db),
+		warehouseRepo,
+		repository.NewInstalledPartRepository(db),
+		catalogRepo,
+		materialsRequestRepo,
+		repository.NewMaterialsProfileRepository(db),
+		repository.NewEquipmentMachineryRepo(db),
+		repository.NewMaintenanceRepository(db),
+		repository.NewPurchaseRequisitionRepository(db),
+		repository.NewCounterRepository(db),
+		repository.NewTransactor(db),
+	)
+
+	boltID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{
+		Code:        "BL-01",
+		Name:        "Bu lông M12",
+		DefaultUnit: "cái",
+		PackSizes:   map[string]float64{"hộp": 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warehouseID, err := warehouseRepo.Save(ctx, &types.Warehouse{Name: "Kho cơ khí", ServedSectors: []string{types.SECTOR_MECHANICAL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestID, err := materialsRequestRepo.Save(ctx, &types.MaterialRequest{
+		NumOfRequest: 12,
+		Sector:       types.SECTOR_MECHANICAL,
+		MaterialsForEquipment: map[string]types.MaterialsForEquipment{
+			"profile-1": {
+				ReplacementMaterials: map[string]types.Material{
+					"Bu lông": {Name: "Bu lông", Unit: "hộp", Quantity: 3, CatalogID: boltID},
+				},
+				ConsumableSupplies: map[string]types.Material{
+					"Giẻ lau": {Name: "Giẻ lau", Unit: "kg", Quantity: 4},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance := func() float64 {
+		t.Helper()
+		balances, err := s.StockBalances(ctx, &types.StockBalanceRequest{CatalogID: boltID, Warehouse: warehouseID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(balances) != 1 {
+			t.Fatalf("got %d balances, want 1", len(balances))
+		}
+		return balances[0].Quantity
+	}
+
+	if _, err := s.ReceiveMaterials(ctx, &types.StockReceiptReq{
+		Warehouse: warehouseID,
+		Lines:     []types.StockLineReq{{CatalogID: boltID, Quantity: 2, Unit: "hộp"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != 20 {
+		t.Fatalf("balance after receipt = %v, want 20", got)
+	}
+
+	// all the outstanding lines linked to the catalog, 3 boxes of bolts, from
+	// the warehouse serving the sector of the request
+	_, err = s.IssueMaterials(ctx, &types.IssueMaterialsReq{MaterialRequestID: requestID})
+	if !errors.Is(err, types.ErrInsufficientStock) {
+		t.Fatalf("issue beyond stock: got %v, want ErrInsufficientStock", err)
+	}
+	if got := balance(); got != 20 {
+		t.Fatalf("balance after refused issue = %v, want 20", got)
+	}
+
+	line := types.RequestLineReq{
+		MaterialsProfileID: "profile-1",
+		MaterialType:       types.MATERIAL_TYPE_REPLACEMENT,
+		Name:               "Bu lông",
+		Quantity:           1.5,
+	}
+	movement, err := s.IssueMaterials(ctx, &types.IssueMaterialsReq{
+		MaterialRequestID: requestID,
+		Warehouse:         warehouseID,
+		Lines:             []types.RequestLineReq{line},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if movement.NumOfRequest != 12 || movement.Lines[0].Delta != -15 {
+		t.Fatalf("issue = %+v, want request 12 and delta -15", movement)
+	}
+	if got := balance(); got != 5 {
+		t.Fatalf("balance after issue = %v, want 5", got)
+	}
+	request, err := materialsRequestRepo.FindByID(ctx, requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := request.Issued["profile-1"].ReplacementMaterials["Bu lông"]; got.Quantity != 1.5 || got.Unit != "hộp" {
+		t.Fatalf("issued = %+v, want 1.5 hộp", got)
+	}
+
+	line.Quantity = 2
+	_, err = s.IssueMaterials(ctx, &types.IssueMaterialsReq{MaterialRequestID: requestID, Warehouse: warehouseID, Lines: []types.RequestLineReq{line}})
+	if !errors.Is(err, types.ErrIssueExceedsRequest) {
+		t.Fatalf("issue beyond request: got %v, want ErrIssueExceedsRequest", err)
+	}
+	_, err = s.ReturnMaterials(ctx, &types.ReturnMaterialsReq{MaterialRequestID: requestID, Warehouse: warehouseID, Lines: []types.RequestLineReq{line}})
+	if !errors.Is(err, types.ErrReturnExceedsIssued) {
+		t.Fatalf("return beyond issued: got %v, want ErrReturnExceedsIssued", err)
+	}
+
+	line.Quantity = 0.5
+	if _, err := s.ReturnMaterials(ctx, &types.ReturnMaterialsReq{MaterialRequestID: requestID, Warehouse: warehouseID, Lines: []types.RequestLineReq{line}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(); got != 10 {
+		t.Fatalf("balance after return = %v, want 10", got)
+	}
+	request, err = materialsRequestRepo.FindByID(ctx, requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := request.Issued["profile-1"].ReplacementMaterials["Bu lông"].Quantity; got != 1 {
+		t.Fatalf("issued after return = %v, want 1", got)
+	}
+
+	count, err := s.RecordStockCount(ctx, &types.RecordStockCountReq{CatalogID: boltID, Warehouse: warehouseID, Quantity: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.Kind != types.STOCK_MOVEMENT_ADJUSTMENT || count.Lines[0].Delta != -2 {
+		t.Fatalf("count = %+v, want an adjustment of -2", count)
+	}
+	if got := balance(); got != 8 {
+		t.Fatalf("balance after count = %v, want 8", got)
+	}
+
+	movements, total, err := s.StockLedger(ctx, &types.StockMovementSearchRequest{Page: 1, Limit: 20, CatalogID: boltID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 || len(movements) != 4 {
+		t.Fatalf("got %d of %d movements, want 4", len(movements), total)
+	}
+
+	// registered before sectors could be served by one warehouse only
+	if _, err := warehouseRepo.Save(ctx, &types.Warehouse{Name: "Kho phụ", ServedSectors: []string{types.SECTOR_MECHANICAL}}); err != nil {
+		t.Fatal(err)
+	}
+	line.Quantity = 0.5
+	_, err = s.IssueMaterials(ctx, &types.IssueMaterialsReq{MaterialRequestID: requestID, Lines: []types.RequestLineReq{line}})
+	if !errors.Is(err, types.ErrSeveralWarehousesForSector) {
+		t.Fatalf("issue without a warehouse: got %v, want ErrSeveralWarehousesForSector", err)
+	}
+}
+
+func TestStockMovementTakesStockAtomically(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &types.User{Username: "thukho"})
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	stockRepo := repository.NewStockRepository(db)
+	stockMovementRepo := repository.NewStockMovementRepository(db)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	s := NewStockService(
+		stockRepo,
+		stockMovementRepo,
+		repository.NewStockTransferRepository(db),
+		warehouseRepo,
+		repository.NewInstalledPartRepository(db),
+		catalogRepo,
+		repository.NewMaterialsRequestRepository(db),
+		repository.NewMaterialsProfileRepository(db),
+		repository.NewEquipmentMachineryRepo(db),
+		repository.NewMaintenanceRepository(db),
+		repository.NewPurchaseRequisitionRepository(db),
+		repository.NewCounterRepository(db),
+		repository.NewTransactor(db),
+	)
+
+	boltID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{Code: "BL-01", Name: "Bu lông M12", DefaultUnit: "cái"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paintID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{Code: "SO-01", Name: "Sơn chống gỉ", DefaultUnit: "lít"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warehouseID, err := warehouseRepo.Save(ctx, &types.Warehouse{Name: "Kho cơ khí"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReceiveMaterials(ctx, &types.StockReceiptReq{
+		Warehouse: warehouseID,
+		Lines:     []types.StockLineReq{{CatalogID: boltID, Quantity: 10}, {CatalogID: paintID, Quantity: 2}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	onHand := func() map[string]float64 {
+		t.Helper()
+		balances, err := s.StockBalances(ctx, &types.StockBalanceRequest{Warehouse: warehouseID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return warehouseOnHand(balances, warehouseID)
+	}
+
+	// the bolts taken before the paint falls short are put back
+	_, err = s.AdjustStock(ctx, &types.StockAdjustmentReq{
+		Warehouse: warehouseID,
+		Lines:     []types.StockLineReq{{CatalogID: boltID, Quantity: -5}, {CatalogID: paintID, Quantity: -5}},
+	})
+	if !errors.Is(err, types.ErrInsufficientStock) {
+		t.Fatalf("adjustment beyond stock: got %v, want ErrInsufficientStock", err)
+	}
+	if got := onHand(); got[boltID] != 10 || got[paintID] != 2 {
+		t.Fatalf("balances after refused adjustment = %v, want 10 bolts and 2 liters", got)
+	}
+	if _, total, err := stockMovementRepo.Paginate(ctx, &types.StockMovementFilter{}, 1, 20); err != nil || total != 1 {
+		t.Fatalf("got %d movements (%v), want the receipt only", total, err)
+	}
+
+	// concurrent takes never leave the balance negative
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	taken := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := stockRepo.TakeBalance(ctx, &types.StockBalance{CatalogID: boltID, Warehouse: warehouseID, Quantity: 1})
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if got := onHand(); taken != 10 || got[boltID] != 0 {
+		t.Fatalf("took %d bolts leaving %v, want 10 leaving 0", taken, got[boltID])
+	}
+	// concurrent receipts of a new material cannot create two balances
+	indexes := db.uniqueIndexes["stock_balances"]
+	if !slices.ContainsFunc(indexes, func(keys []string) bool { return slices.Equal(keys, []string{"catalog_id", "warehouse"}) }) {
+		t.Errorf("unique indexes of the balances = %v, want one on catalog_id and warehouse", indexes)
+	}
+}
+
+func TestStockTransfer(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &types.User{Username: "thukho"})
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	stockTransferRepo := repository.NewStockTransferRepository(db)
+	s := NewStockService(
+		repository.NewStockRepository(db),
+		repository.NewStockMovementRepository(db),
+		stockTransferRepo,
+		warehouseRepo,
+		repository.NewInstalledPartRepository(db),
+		catalogRepo,
+		repository.NewMaterialsRequestRepository(db),
+		repository.NewMaterialsProfileRepository(db),
+		repository.NewEquipmentMachineryRepo(db),
+		repository.NewMaintenanceRepository(db),
+		repository.NewPurchaseRequisitionRepository(db),
+		repository.NewCounterRepository(db),
+		repository.NewTransactor(db),
+	)
+
+	paintID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{Code: "SO-01", Name: "Sơn chống gỉ", DefaultUnit: "lít"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// numbering continues after the transfers made before it was counted
+	if _, err := stockTransferRepo.Save(ctx, &types.StockTransfer{Number: 6, Status: types.TRANSFER_STATUS_RECEIVED}); err != nil {
+		t.Fatal(err)
+	}
+	centralID, err := warehouseRepo.Save(ctx, &types.Warehouse{Name: "Kho trung tâm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockID, err := warehouseRepo.Save(ctx, &types.Warehouse{Name: "Kho đà", ResponsibleSector: types.SECTOR_DOCK})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReceiveMaterials(ctx, &types.StockReceiptReq{
+		Warehouse: centralID,
+		Lines:     []types.StockLineReq{{CatalogID: paintID, Quantity: 10}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	balance := func(warehouse string) float64 {
+		t.Helper()
+		balances, err := s.StockBalances(ctx, &types.StockBalanceRequest{CatalogID: paintID, Warehouse: warehouse})
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := 0.0
+		for _, balance := range balances {
+			total += balance.Quantity
+		}
+		return total
+	}
+
+	lines := []types.StockLineReq{{CatalogID: paintID, Quantity: 12}}
+	_, err = s.CreateTransfer(ctx, &types.CreateStockTransferReq{FromWarehouse: centralID, ToWarehouse: dockID, Lines: lines})
+	if !errors.Is(err, types.ErrInsufficientStock) {
+		t.Fatalf("transfer beyond stock: got %v, want ErrInsufficientStock", err)
+	}
+	_, err = s.CreateTransfer(ctx, &types.CreateStockTransferReq{FromWarehouse: centralID, ToWarehouse: centralID, Lines: lines})
+	if !errors.Is(err, types.ErrSameWarehouse) {
+		t.Fatalf("transfer to the same warehouse: got %v, want ErrSameWarehouse", err)
+	}
+
+	lines[0].Quantity = 4
+	first, err := s.CreateTransfer(ctx, &types.CreateStockTransferReq{FromWarehouse: centralID, ToWarehouse: dockID, Lines: lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreateTransfer(ctx, &types.CreateStockTransferReq{FromWarehouse: centralID, ToWarehouse: dockID, Lines: lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Number != 7 || second.Number != 8 || first.Status != types.TRANSFER_STATUS_IN_TRANSIT {
+		t.Fatalf("transfers = %+v and %+v, want numbers 7 and 8 in transit", first, second)
+	}
+	// in transit, neither in the origin nor in the destination
+	if got, want := [2]float64{balance(centralID), balance(dockID)}, [2]float64{2, 0}; got != want {
+		t.Fatalf("balances in transit = %v, want %v", got, want)
+	}
+
+	if _, err := s.ReceiveTransfer(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CancelTransfer(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReceiveTransfer(ctx, second.ID); !errors.Is(err, types.ErrTransferNotInTransit) {
+		t.Fatalf("receive a cancelled transfer: got %v, want ErrTransferNotInTransit", err)
+	}
+	if got, want := [2]float64{balance(centralID), balance(dockID)}, [2]float64{6, 4}; got != want {
+		t.Fatalf("balances = %v, want %v", got, want)
+	}
+	received, err := s.GetTransfer(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Status != types.TRANSFER_STATUS_RECEIVED || received.ClosedBy != "thukho" {
+		t.Fatalf("received transfer = %+v", received)
+	}
+}
+
+func TestPartTraceability(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "user", &types.User{Username: "thukho"})
+	db := newMemoryDatabase()
+	catalogRepo := repository.NewCatalogRepository(db)
+	materialsRequestRepo := repository.NewMaterialsRequestRepository(db)
+	materialsProfileRepo := repository.NewMaterialsProfileRepository(db)
+	equipmentMachineryRepo := repository.NewEquipmentMachineryRepo(db)
+	maintenanceRepo := repository.NewMaintenanceRepository(db)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	installedPartRepo := repository.NewInstalledPartRepository(db)
+	s := NewStockService(
+		repository.NewStockRepository(db),
+		repository.NewStockMovementRepository(db),
+		repository.NewStockTransferRepository(db),
+		warehouseRepo,
+		installedPartRepo,
+		catalogRepo,
+		materialsRequestRepo,
+		materialsProfileRepo,
+		equipmentMachineryRepo,
+		maintenanceRepo,
+		repository.NewPurchaseRequisitionRepository(db),
+		repository.NewCounterRepository(db),
+		repository.NewTransactor(db),
+	)
+	traceability := NewTraceabilityService(installedPartRepo)
+
+	pumpID, err := catalogRepo.Save(ctx, &types.CatalogMaterial{Code: "BOM-01", Name: "Bơm nước", DefaultUnit: "cái"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warehouseID, err := warehouseRepo.Save(ctx, &types.Warehouse{Name: "Kho cơ khí", ServedSectors: []string{types.SECTOR_MECHANICAL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	maintenanceID, err := maintenanceRepo.Save(ctx, &types.Maintenance{Project: "Tàu 01", ProjectCode: "T01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equipmentID, err := equipmentMachineryRepo.Save(ctx, &types.EquipmentMachinery{Name: "Máy chính"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	profileID, err := materialsProfileRepo.Save(ctx, &types.MaterialsProfile{
+		MaintenanceInstanceID: maintenanceID,
+		EquipmentMachineryID:  equipmentID,
+		Sector:                types.SECTOR_MECHANICAL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestID, err := materialsRequestRepo.Save(ctx, &types.MaterialRequest{
+		NumOfRequest:          7,
+		MaintenanceInstanceID: maintenanceID,
+		Sector:                types.SECTOR_MECHANICAL,
+		MaterialsForEquipment: map[string]types.MaterialsForEquipment{
+			profileID: {
+				ReplacementMaterials: map[string]types.Material{
+					"Bơm": {Name: "Bơm", Unit: "cái", Quantity: 2, CatalogID: pumpID},
+				},
+				ConsumableSupplies: map[string]types.Material{
+					"Dầu": {Name: "Dầu", Unit: "lít", Quantity: 4},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReceiveMaterials(ctx, &types.StockReceiptReq{
+		Warehouse: warehouseID,
+		Lines:     []types.StockLineReq{{CatalogID: pumpID, Quantity: 3}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	issue := func(lines ...types.RequestLineReq) error {
+		t.Helper()
+		_, err := s.IssueMaterials(ctx, &types.IssueMaterialsReq{MaterialRequestID: requestID, Lines: lines})
+		return err
+	}
+	line := types.RequestLineReq{
+		MaterialsProfileID: profileID,
+		MaterialType:       types.MATERIAL_TYPE_REPLACEMENT,
+		Name:               "Bơm",
+		Quantity:           2,
+	}
+	line.Serials = []string{"SN-1"}
+	if err := issue(line); !errors.Is(err, types.ErrSerialCountMismatch) {
+		t.Fatalf("one serial for two pieces: got %v, want ErrSerialCountMismatch", err)
+	}
+	line.Serials = []string{"SN-1", " SN-1 "}
+	if err := issue(line); !errors.Is(err, types.ErrDuplicateSerial) {
+		t.Fatalf("repeated serial: got %v, want ErrDuplicateSerial", err)
+	}
+	consumable := types.RequestLineReq{MaterialsProfileID: profileID, MaterialType: types.MATERIAL_TYPE_CONSUMABLE, Name: "Dầu", Quantity: 1}
+	consumable.Lot = "L-01"
+	if err := issue(consumable); !errors.Is(err, types.ErrTrackingOnConsumable) {
+		t.Fatalf("lot on a consumable: got %v, want ErrTrackingOnConsumable", err)
+	}
+
+	line.Quantity = 1
+	line.Serials = []string{"SN-1"}
+	line.Manufacturer = "Ebara"
+	if err := issue(line); err != nil {
+		t.Fatal(err)
+	}
+	if err := issue(line); !errors.Is(err, types.ErrSerialAlreadyInstalled) {
+		t.Fatalf("serial issued twice: got %v, want ErrSerialAlreadyInstalled", err)
+	}
+
+	parts, total, err := traceability.SearchInstalledParts(ctx, &types.InstalledPartSearchRequest{Page: 1, Limit: 20, EquipmentMachineryID: equipmentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || parts[0].Serial != "SN-1" || parts[0].ProjectCode != "T01" || parts[0].EquipmentName != "Máy chính" || parts[0].IssuedBy != "thukho" {
+		t.Fatalf("installed parts = %+v, want SN-1 on Máy chính of T01", parts)
+	}
+	if _, _, err := traceability.SearchInstalledParts(ctx, &types.InstalledPartSearchRequest{Page: 1, Limit: 20}); !errors.Is(err, types.ErrMissingPartQuery) {
+		t.Fatalf("search without a serial or an equipment: got %v, want ErrMissingPartQuery", err)
+	}
+
+	line.Serials = []string{"SN-2"}
+	_, err = s.ReturnMaterials(ctx, &types.ReturnMaterialsReq{MaterialRequestID: requestID, Lines: []types.RequestLineReq{line}})
+	if !errors.Is(err, types.ErrSerialNotIssued) {
+		t.Fatalf("return of a serial not issued: got %v, want ErrSerialNotIssued", err)
+	}
+	line.Serials = []string{"SN-1"}
+	if _, err := s.ReturnMaterials(ctx, &types.ReturnMaterialsReq{MaterialRequestID: requestID, Lines: []types.RequestLineReq{line}}); err != nil {
+		t.Fatal(err)
+	}
+	// returned to stock, the pump can be fitted again
+	if err := issue(line); err != nil {
+		t.Fatal(err)
+	}
+	history, err := traceability.SerialHistory(ctx, "SN-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("got %d pieces in the history of SN-1, want 2", len(history))
+	}
+	returned := 0
+	for _, part := range history {
+		if part.ReturnedAt != 0 {
+			returned++
+		}
+	}
+	if returned != 1 {
+		t.Fatalf("got %d returned pieces in the history of SN-1, want 1", returned)
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+// suggestionPercentile is the share of past maintenances whose consumption
+// the suggested estimate covers.
+const suggestionPercentile = 75
+
+var _ SuggestionService = &suggestionService{}
+
+type SuggestionService interface {
+	SuggestEstimate(ctx context.Context, req *types.EstimateSuggestionReq) (*types.EstimateSuggestion, error)
+}
+
+type suggestionService struct {
+	materialsProfileRepo repository.MaterialsProfileRepository
+	maintenanceRepo      repository.MaintenanceRepository
+}
+
+func NewSuggestionService(
+	materialsProfileRepo repository.MaterialsProfileRepository,
+	maintenanceRepo repository.MaintenanceRepository,
+) SuggestionService {
+	return &suggestionService{
+		materialsProfileRepo: materialsProfileRepo,
+		maintenanceRepo:      maintenanceRepo,
+	}
+}
+
+// SuggestEstimate derives an estimate for an equipment from its reality in
+// past maintenances of the same tier. Every past maintenance where the
+// equipment had a profile counts, those without consumption of a material
+// count as zero.
+func (s *suggestionService) SuggestEstimate(ctx context.Context, req *types.EstimateSuggestionReq) (*types.EstimateSuggestion, error) {
+	equipmentMachineryID := req.EquipmentMachineryID
+	maintenanceTier := req.MaintenanceTier
+	excludedMaintenanceID := ""
+	var current *types.MaterialsForEquipment
+	if req.MaterialsProfileID != "" {
+		materialsProfile, err := s.materialsProfileRepo.FindByID(ctx, req.MaterialsProfileID)
+		if err != nil {
+			return nil, err
+		}
+		if materialsProfile.ID == "" {
+			return nil, types.ErrSomeMaterialsProfileNotFound
+		}
+		maintenance, err := s.maintenanceRepo.FindByID(ctx, materialsProfile.MaintenanceInstanceID)
+		if err != nil {
+			return nil, err
+		}
+		equipmentMachineryID = materialsProfile.EquipmentMachineryID
+		if maintenanceTier == "" {
+			maintenanceTier = maintenance.MaintenanceTier
+		}
+		excludedMaintenanceID = materialsProfile.MaintenanceInstanceID
+		current = &materialsProfile.Estimate
+	}
+	if equipmentMachineryID == "" || maintenanceTier == "" {
+		return nil, types.ErrInvalidSuggestionRequest
+	}
+	if !utils.Contains(types.MAINTENANCE_TIER_LIST, maintenanceTier) {
+		return nil, types.ErrInvalidMaintenanceTier
+	}
+
+	suggestion := &types.EstimateSuggestion{
+		EquipmentMachineryID: equipmentMachineryID,
+		MaintenanceTier:      maintenanceTier,
+		Materials:            make([]*types.MaterialSuggestion, 0),
+	}
+	maintenances, err := s.maintenanceRepo.Filter(ctx, &types.MaintenanceFilter{
+		MaintenanceTier: maintenanceTier,
+	})
+	if err != nil {
+		return nil, err
+	}
+	maintenanceIds := make([]string, 0, len(maintenances))
+	for _, maintenance := range maintenances {
+		if maintenance.ID != excludedMaintenanceID {
+			maintenanceIds = append(maintenanceIds, maintenance.ID)
+		}
+	}
+	history := &types.ConsumptionHistory{}
+	if len(maintenanceIds) > 0 {
+		history, err = s.materialsProfileRepo.ConsumptionHistory(ctx, equipmentMachineryID, maintenanceIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+	suggestion.Maintenances = len(history.MaintenanceInstanceIDs)
+
+	suggested := make(map[string]*types.MaterialSuggestion)
+	for _, consumption := range history.Materials {
+		item := newMaterialSuggestion(consumption, suggestion.Maintenances)
+		suggested[suggestionKey(item.MaterialType, item.Name, item.Unit)] = item
+		suggestion.Materials = append(suggestion.Materials, item)
+	}
+	if current == nil {
+		return suggestion, nil
+	}
+
+	// compare with the current estimate, listing estimated materials that were
+	// never consumed, or only in another unit, as well
+	sections := []struct {
+		materialType string
+		estimate     map[string]types.Material
+	}{
+		{types.MATERIAL_TYPE_REPLACEMENT, current.ReplacementMaterials},
+		{types.MATERIAL_TYPE_CONSUMABLE, current.ConsumableSupplies},
+	}
+	for _, section := range sections {
+		for _, name := range sortedMaterialNames(section.estimate) {
+			material := section.estimate[name]
+			quantity := material.Quantity
+			item, ok := suggested[suggestionKey(section.materialType, name, material.Unit)]
+			if !ok {
+				item = &types.MaterialSuggestion{
+					MaterialType: section.materialType,
+					Name:         name,
+					Unit:         material.Unit,
+				}
+				suggestion.Materials = append(suggestion.Materials, item)
+			}
+			item.CurrentEstimate = &quantity
+		}
+	}
+	return suggestion, nil
+}
+
+func suggestionKey(materialType, name, unit string) string {
+	return materialType + "\x00" + name + "\x00" + utils.NormalizeUnit(unit)
+}
+
+func newMaterialSuggestion(consumption *types.MaterialConsumption, maintenances int) *types.MaterialSuggestion {
+	values := make([]float64, maintenances)
+	copy(values, consumption.Quantities)
+	item := &types.MaterialSuggestion{
+		MaterialType: consumption.MaterialType,
+		Name:         consumption.Name,
+		Unit:         consumption.Unit,
+		Occurrences:  len(consumption.Quantities),
+		Mean:         math.Round(utils.Mean(values)*100) / 100,
+		Median:       utils.Median(values),
+		Max:          utils.Max(values),
+	}
+	suggested := utils.Percentile(values, suggestionPercentile)
+	if item.MaterialType == types.MATERIAL_TYPE_REPLACEMENT {
+		// replacement parts are counted in whole pieces
+		item.Suggested = math.Ceil(suggested)
+	} else {
+		item.Suggested = math.Ceil(suggested*100) / 100
+	}
+	return item
+}
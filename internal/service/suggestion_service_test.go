@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// historyRepository serves a fixed consumption history and records the
+// maintenances it was asked about.
+type historyRepository struct {
+	repository.MaterialsProfileRepository
+	history                *types.ConsumptionHistory
+	equipmentMachineryID   string
+	maintenanceInstanceIDs []string
+}
+
+func (r *historyRepository) ConsumptionHistory(ctx context.Context, equipmentMachineryID string, maintenanceInstanceIDs []string) (*types.ConsumptionHistory, error) {
+	r.equipmentMachineryID = equipmentMachineryID
+	r.maintenanceInstanceIDs = maintenanceInstanceIDs
+	return r.history, nil
+}
+
+func TestSuggestEstimate(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	maintenanceRepo := repository.NewMaintenanceRepository(db)
+	profileRepo := &historyRepository{MaterialsProfileRepository: repository.NewMaterialsProfileRepository(db)}
+	s := NewSuggestionService(profileRepo, maintenanceRepo)
+
+	save := func(tier string) string {
+		t.Helper()
+		id, err := maintenanceRepo.Save(ctx, &types.Maintenance{MaintenanceTier: tier})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	current := save(types.MAINTENANCE_TIER_MEDIUM)
+	past := []string{
+		save(types.MAINTENANCE_TIER_MEDIUM),
+		save(types.MAINTENANCE_TIER_MEDIUM),
+		save(types.MAINTENANCE_TIER_MEDIUM),
+		save(types.MAINTENANCE_TIER_MEDIUM),
+	}
+	save(types.MAINTENANCE_TIER_SMALL)
+	profileID, err := profileRepo.Save(ctx, &types.MaterialsProfile{
+		MaintenanceInstanceID: current,
+		EquipmentMachineryID:  "pump",
+		Sector:                testSector,
+		Estimate: types.MaterialsForEquipment{
+			ReplacementMaterials: map[string]types.Material{
+				"Bạc đạn": {Name: "Bạc đạn", Unit: "Cái", Quantity: 2},
+			},
+			ConsumableSupplies: map[string]types.Material{
+				"Sơn": {Name: "Sơn", Unit: "lít", Quantity: 5},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 of the 4 past maintenances replaced bearings
+	profileRepo.history = &types.ConsumptionHistory{
+		MaintenanceInstanceIDs: past,
+		Materials: []*types.MaterialConsumption{
+			{MaterialType: types.MATERIAL_TYPE_REPLACEMENT, Name: "Bạc đạn", Unit: "cái", Quantities: []float64{1, 3}},
+			{MaterialType: types.MATERIAL_TYPE_CONSUMABLE, Name: "Giẻ lau", Unit: "kg", Quantities: []float64{2.5, 2.5, 2.5, 4}},
+		},
+	}
+
+	suggestion, err := s.SuggestEstimate(ctx, &types.EstimateSuggestionReq{MaterialsProfileID: profileID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileRepo.equipmentMachineryID != "pump" {
+		t.Errorf("history of %q, want the equipment of the profile", profileRepo.equipmentMachineryID)
+	}
+	asked := slices.Clone(profileRepo.maintenanceInstanceIDs)
+	slices.Sort(asked)
+	want := slices.Clone(past)
+	slices.Sort(want)
+	if !slices.Equal(asked, want) {
+		t.Errorf("history over %v, want the other maintenances of the tier %v", asked, want)
+	}
+	if suggestion.MaintenanceTier != types.MAINTENANCE_TIER_MEDIUM || suggestion.Maintenances != 4 {
+		t.Errorf("suggestion over %d maintenances of %s, want 4 of %s", suggestion.Maintenances, suggestion.MaintenanceTier, types.MAINTENANCE_TIER_MEDIUM)
+	}
+
+	estimate := func(quantity float64) *float64 { return &quantity }
+	wantMaterials := []*types.MaterialSuggestion{
+		{
+			MaterialType: types.MATERIAL_TYPE_REPLACEMENT, Name: "Bạc đạn", Unit: "cái",
+			// maintenances without replacement count as zero: 0, 0, 1, 3
+			Occurrences: 2, Mean: 1, Median: 0.5, Max: 3, Suggested: 2,
+			CurrentEstimate: estimate(2),
+		},
+		{
+			MaterialType: types.MATERIAL_TYPE_CONSUMABLE, Name: "Giẻ lau", Unit: "kg",
+			Occurrences: 4, Mean: 2.88, Median: 2.5, Max: 4, Suggested: 2.88,
+		},
+		{
+			MaterialType: types.MATERIAL_TYPE_CONSUMABLE, Name: "Sơn", Unit: "lít",
+			CurrentEstimate: estimate(5),
+		},
+	}
+	if !reflect.DeepEqual(suggestion.Materials, wantMaterials) {
+		for i, item := range suggestion.Materials {
+			t.Logf("material %d = %+v", i, *item)
+		}
+		t.Errorf("suggested materials differ from %d wanted", len(wantMaterials))
+	}
+}
+
+func TestSuggestEstimateWithoutHistory(t *testing.T) {
+	db := newMemoryDatabase()
+	profileRepo := &historyRepository{MaterialsProfileRepository: repository.NewMaterialsProfileRepository(db)}
+	s := NewSuggestionService(profileRepo, repository.NewMaintenanceRepository(db))
+
+	suggestion, err := s.SuggestEstimate(context.Background(), &types.EstimateSuggestionReq{
+		EquipmentMachineryID: "pump",
+		MaintenanceTier:      types.MAINTENANCE_TIER_DOCK,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profileRepo.maintenanceInstanceIDs != nil {
+		t.Errorf("history asked over %v, want no lookup without past maintenances", profileRepo.maintenanceInstanceIDs)
+	}
+	if suggestion.Maintenances != 0 || len(suggestion.Materials) != 0 {
+		t.Errorf("suggestion = %+v, want none", suggestion)
+	}
+}
+
+func TestConsumptionHistoryPipeline(t *testing.T) {
+	db := newMemoryDatabase()
+	repo := repository.NewMaterialsProfileRepository(db)
+	db.aggregateResults("materials_profiles", bson.M{
+		"maintenances": bson.A{bson.M{"_id": "m1"}, bson.M{"_id": "m2"}},
+		"materials": bson.A{bson.M{
+			"material_type": types.MATERIAL_TYPE_CONSUMABLE,
+			"name":          "Giẻ lau",
+			"unit":          "kg",
+			"quantities":    bson.A{2.5, 4.0},
+		}},
+	})
+
+	history, err := repo.ConsumptionHistory(context.Background(), "pump", []string{"m1", "m2", "m3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &types.ConsumptionHistory{
+		MaintenanceInstanceIDs: []string{"m1", "m2"},
+		Materials: []*types.MaterialConsumption{
+			{MaterialType: types.MATERIAL_TYPE_CONSUMABLE, Name: "Giẻ lau", Unit: "kg", Quantities: []float64{2.5, 4}},
+		},
+	}
+	if !reflect.DeepEqual(history, want) {
+		t.Errorf("history = %+v, want %+v", history, want)
+	}
+
+	pipeline := db.lastPipeline("materials_profiles")
+	if len(pipeline) != 2 {
+		t.Fatalf("pipeline = %v, want a $match and a $facet", pipeline)
+	}
+	match, _ := pipeline[0].(bson.M)["$match"].(bson.M)
+	wantMatch := bson.M{
+		"equipment_machinery_id":  "pump",
+		"maintenance_instance_id": bson.M{"$in": []string{"m1", "m2", "m3"}},
+		"archived":                bson.M{"$ne": true},
+	}
+	if !reflect.DeepEqual(match, wantMatch) {
+		t.Errorf("$match = %v, want %v", match, wantMatch)
+	}
+	facet, _ := pipeline[1].(bson.M)["$facet"].(bson.M)
+	materials, _ := facet["materials"].(bson.A)
+	var groups []bson.M
+	for _, stage := range materials {
+		if group, ok := stage.(bson.M)["$group"].(bson.M); ok {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) != 2 {
+		t.Fatalf("materials facet = %v, want a sum per maintenance then a list per material", materials)
+	}
+	// quantities are summed per maintenance and unit, never across units
+	wantKey := bson.M{
+		"maintenance":   "$maintenance_instance_id",
+		"material_type": "$items.material_type",
+		"name":          "$items.name",
+		"unit":          "$items.unit",
+	}
+	if !reflect.DeepEqual(groups[0]["_id"], wantKey) {
+		t.Errorf("first $group key = %v, want %v", groups[0]["_id"], wantKey)
+	}
+	if _, ok := groups[1]["_id"].(bson.M)["maintenance"]; ok {
+		t.Errorf("second $group key = %v, want the material only", groups[1]["_id"])
+	}
+}
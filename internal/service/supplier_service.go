@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+var _ SupplierService = &supplierService{}
+
+type SupplierService interface {
+	CreateSupplier(ctx context.Context, req *types.CreateSupplierReq) (string, error)
+	UpdateSupplier(ctx context.Context, req *types.UpdateSupplierReq) error
+	GetSupplier(ctx context.Context, id string) (*types.Supplier, error)
+	SearchSuppliers(ctx context.Context, req *types.SupplierSearchRequest) ([]*types.Supplier, int64, error)
+	DeleteSupplier(ctx context.Context, id string) error
+}
+
+type supplierService struct {
+	supplierRepo repository.SupplierRepository
+	catalogRepo  repository.CatalogRepository
+}
+
+func NewSupplierService(supplierRepo repository.SupplierRepository, catalogRepo repository.CatalogRepository) SupplierService {
+	return &supplierService{
+		supplierRepo: supplierRepo,
+		catalogRepo:  catalogRepo,
+	}
+}
+
+func (s *supplierService) CreateSupplier(ctx context.Context, req *types.CreateSupplierReq) (string, error) {
+	supplier, err := newSupplier(req)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkCatalogIDs(ctx, supplier.CatalogIDs); err != nil {
+		return "", err
+	}
+	supplier.CreatedAt = time.Now().Unix()
+	supplier.UpdatedAt = supplier.CreatedAt
+	return s.supplierRepo.Save(ctx, supplier)
+}
+
+func (s *supplierService) UpdateSupplier(ctx context.Context, req *types.UpdateSupplierReq) error {
+	current, err := s.supplierRepo.FindByID(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return types.ErrSupplierNotFound
+	}
+	supplier, err := newSupplier(&req.CreateSupplierReq)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCatalogIDs(ctx, supplier.CatalogIDs); err != nil {
+		return err
+	}
+	supplier.CreatedAt = current.CreatedAt
+	supplier.UpdatedAt = time.Now().Unix()
+	return s.supplierRepo.Update(ctx, req.ID, supplier)
+}
+
+func (s *supplierService) GetSupplier(ctx context.Context, id string) (*types.Supplier, error) {
+	supplier, err := s.supplierRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if supplier == nil {
+		return nil, types.ErrSupplierNotFound
+	}
+	return supplier, nil
+}
+
+func (s *supplierService) SearchSuppliers(ctx context.Context, req *types.SupplierSearchRequest) ([]*types.Supplier, int64, error) {
+	filter := &types.SupplierFilter{
+		Query:     strings.TrimSpace(req.Query),
+		CatalogID: strings.TrimSpace(req.CatalogID),
+	}
+	return s.supplierRepo.Paginate(ctx, filter, req.Page, req.Limit)
+}
+
+// DeleteSupplier removes a supplier. Purchase requisitions keep the name of
+// the suppliers they were grouped by.
+func (s *supplierService) DeleteSupplier(ctx context.Context, id string) error {
+	supplier, err := s.supplierRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if supplier == nil {
+		return types.ErrSupplierNotFound
+	}
+	return s.supplierRepo.Delete(ctx, id)
+}
+
+func (s *supplierService) checkCatalogIDs(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	materials, err := s.catalogRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if len(materials) != len(ids) {
+		return types.ErrSomeCatalogMaterialNotFound
+	}
+	return nil
+}
+
+// newSupplier trims the texts, drops blank contacts and repeated materials,
+// and checks that the preferred materials are supplied.
+func newSupplier(req *types.CreateSupplierReq) (*types.Supplier, error) {
+	if req.LeadTimeDays < 0 {
+		return nil, types.ErrInvalidLeadTime
+	}
+	supplier := &types.Supplier{
+		Name:         strings.TrimSpace(req.Name),
+		Address:      strings.TrimSpace(req.Address),
+		TaxCode:      strings.TrimSpace(req.TaxCode),
+		Contacts:     make([]types.SupplierContact, 0, len(req.Contacts)),
+		CatalogIDs:   trimIDs(req.CatalogIDs),
+		PreferredFor: trimIDs(req.PreferredFor),
+		LeadTimeDays: req.LeadTimeDays,
+		Note:         strings.TrimSpace(req.Note),
+	}
+	for _, contact := range req.Contacts {
+		contact = types.SupplierContact{
+			Name:  strings.TrimSpace(contact.Name),
+			Role:  strings.TrimSpace(contact.Role),
+			Phone: strings.TrimSpace(contact.Phone),
+			Email: strings.TrimSpace(contact.Email),
+		}
+		if contact != (types.SupplierContact{}) {
+			supplier.Contacts = append(supplier.Contacts, contact)
+		}
+	}
+	for _, id := range supplier.PreferredFor {
+		if !utils.Contains(supplier.CatalogIDs, id) {
+			return nil, types.ErrInvalidPreferredMaterial
+		}
+	}
+	return supplier, nil
+}
+
+func trimIDs(ids []string) []string {
+	trimmed := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" {
+			trimmed = append(trimmed, id)
+		}
+	}
+	return utils.RemoveDuplicates(trimmed)
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/remiehneppo/material-management/types"
+)
+
+// normalizeTracking trims the texts and drops the blank serial numbers.
+func normalizeTracking(tracking types.PartTracking) types.PartTracking {
+	normalized := types.PartTracking{
+		Lot:          strings.TrimSpace(tracking.Lot),
+		Manufacturer: strings.TrimSpace(tracking.Manufacturer),
+		Certificate:  strings.TrimSpace(tracking.Certificate),
+	}
+	for _, serial := range tracking.Serials {
+		if serial = strings.TrimSpace(serial); serial != "" {
+			normalized.Serials = append(normalized.Serials, serial)
+		}
+	}
+	return normalized
+}
+
+func isTracked(tracking types.PartTracking) bool {
+	return len(tracking.Serials) > 0 || tracking.Lot != "" || tracking.Manufacturer != "" || tracking.Certificate != ""
+}
+
+// checkTracking checks that only replacement materials are tracked and that
+// serial numbers are given for every piece of the line.
+func checkTracking(line types.RequestLineReq) error {
+	tracking := normalizeTracking(line.PartTracking)
+	if !isTracked(tracking) {
+		return nil
+	}
+	if line.MaterialType != types.MATERIAL_TYPE_REPLACEMENT {
+		return types.ErrTrackingOnConsumable
+	}
+	if len(tracking.Serials) > 0 && float64(len(tracking.Serials)) != line.Quantity {
+		return types.ErrSerialCountMismatch
+	}
+	return nil
+}
+
+// movementSerials returns the serial numbers of the lines per catalog material.
+func movementSerials(lines []types.StockMovementLine) (map[string]map[string]bool, error) {
+	serials := make(map[string]map[string]bool)
+	for _, line := range lines {
+		for _, serial := range line.Serials {
+			if serials[line.CatalogID] == nil {
+				serials[line.CatalogID] = make(map[string]bool)
+			}
+			if serials[line.CatalogID][serial] {
+				return nil, fmt.Errorf("%w: %s", types.ErrDuplicateSerial, serial)
+			}
+			serials[line.CatalogID][serial] = true
+		}
+	}
+	return serials, nil
+}
+
+func serialList(serials map[string]map[string]bool) []string {
+	list := make([]string, 0)
+	for _, set := range serials {
+		for serial := range set {
+			list = append(list, serial)
+		}
+	}
+	return list
+}
+
+// checkSerialsFree checks that the serial numbers of an issue are not fitted
+// to an equipment already.
+func (s *stockService) checkSerialsFree(ctx context.Context, lines []types.StockMovementLine) error {
+	serials, err := movementSerials(lines)
+	if err != nil || len(serials) == 0 {
+		return err
+	}
+	installed, err := s.installedPartRepo.Filter(ctx, &types.InstalledPartFilter{Serials: serialList(serials)})
+	if err != nil {
+		return err
+	}
+	for _, part := range installed {
+		if serials[part.CatalogID][part.Serial] {
+			return fmt.Errorf("%w: %s on %s", types.ErrSerialAlreadyInstalled, part.Serial, part.EquipmentName)
+		}
+	}
+	return nil
+}
+
+// installParts records the tracked replacement parts of an issue with the
+// ship and the equipment of the request line they were issued against.
+func (s *stockService) installParts(ctx context.Context, request *types.MaterialRequest, movement *types.StockMovement) error {
+	profileIDs := make([]string, 0)
+	for _, line := range movement.Lines {
+		if isTracked(line.PartTracking) {
+			profileIDs = append(profileIDs, line.MaterialsProfileID)
+		}
+	}
+	if len(profileIDs) == 0 {
+		return nil
+	}
+	maintenance, err := s.maintenanceRepo.FindByID(ctx, request.MaintenanceInstanceID)
+	if err != nil {
+		return err
+	}
+	if maintenance == nil {
+		maintenance = &types.Maintenance{}
+	}
+	profiles, err := s.materialsProfileRepo.FindByIDs(ctx, profileIDs)
+	if err != nil {
+		return err
+	}
+	equipmentIDs := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		equipmentIDs = append(equipmentIDs, profile.EquipmentMachineryID)
+	}
+	equipments, err := s.equipmentMachineryRepo.FindByIDs(ctx, equipmentIDs)
+	if err != nil {
+		return err
+	}
+
+	parts := make([]*types.InstalledPart, 0)
+	for _, line := range movement.Lines {
+		if !isTracked(line.PartTracking) {
+			continue
+		}
+		part := types.InstalledPart{
+			CatalogID:             line.CatalogID,
+			Name:                  line.Name,
+			Unit:                  line.Unit,
+			Quantity:              line.Quantity,
+			Lot:                   line.Lot,
+			Manufacturer:          line.Manufacturer,
+			Certificate:           line.Certificate,
+			MaintenanceInstanceID: request.MaintenanceInstanceID,
+			Project:               maintenance.Project,
+			ProjectCode:           maintenance.ProjectCode,
+			MaterialsProfileID:    line.MaterialsProfileID,
+			Sector:                request.Sector,
+			MaterialRequestID:     request.ID,
+			NumOfRequest:          request.NumOfRequest,
+			IssueMovementID:       movement.ID,
+			IssuedBy:              movement.CreatedBy,
+			IssuedAt:              movement.CreatedAt,
+		}
+		if profile := profiles[line.MaterialsProfileID]; profile != nil {
+			part.EquipmentMachineryID = profile.EquipmentMachineryID
+			if equipment := equipments[profile.EquipmentMachineryID]; equipment != nil {
+				part.EquipmentName = equipment.Name
+			}
+		}
+		if len(line.Serials) == 0 {
+			parts = append(parts, &part)
+			continue
+		}
+		for _, serial := range line.Serials {
+			piece := part
+			piece.Serial = serial
+			piece.Quantity = 1
+			parts = append(parts, &piece)
+		}
+	}
+	return s.installedPartRepo.SaveMany(ctx, parts)
+}
+
+// returnedParts returns the installed pieces whose serial numbers are given
+// by the lines of a return, which must have been issued against the same
+// request line and not returned yet.
+func (s *stockService) returnedParts(ctx context.Context, requestID string, lines []types.StockMovementLine) ([]string, error) {
+	serials, err := movementSerials(lines)
+	if err != nil || len(serials) == 0 {
+		return nil, err
+	}
+	installed, err := s.installedPartRepo.Filter(ctx, &types.InstalledPartFilter{
+		Serials:           serialList(serials),
+		MaterialRequestID: requestID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0)
+	for _, line := range lines {
+		for _, serial := range line.Serials {
+			found := ""
+			for _, part := range installed {
+				if part.Serial == serial && part.CatalogID == line.CatalogID && part.MaterialsProfileID == line.MaterialsProfileID {
+					found = part.ID
+					break
+				}
+			}
+			if found == "" {
+				return nil, fmt.Errorf("%w: %s", types.ErrSerialNotIssued, serial)
+			}
+			ids = append(ids, found)
+		}
+	}
+	return ids, nil
+}
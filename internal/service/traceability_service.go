@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+)
+
+var _ TraceabilityService = &traceabilityService{}
+
+type TraceabilityService interface {
+	// the ships and equipments a serial number was fitted to, latest first,
+	// including the pieces returned to stock since
+	SerialHistory(ctx context.Context, serial string) ([]*types.InstalledPart, error)
+	// the replacement parts fitted to an equipment or carrying a serial number
+	SearchInstalledParts(ctx context.Context, req *types.InstalledPartSearchRequest) ([]*types.InstalledPart, int64, error)
+}
+
+type traceabilityService struct {
+	installedPartRepo repository.InstalledPartRepository
+}
+
+func NewTraceabilityService(installedPartRepo repository.InstalledPartRepository) TraceabilityService {
+	return &traceabilityService{
+		installedPartRepo: installedPartRepo,
+	}
+}
+
+func (s *traceabilityService) SerialHistory(ctx context.Context, serial string) ([]*types.InstalledPart, error) {
+	serial = strings.TrimSpace(serial)
+	if serial == "" {
+		return nil, types.ErrMissingPartQuery
+	}
+	return s.installedPartRepo.Filter(ctx, &types.InstalledPartFilter{
+		Serials:         []string{serial},
+		IncludeReturned: true,
+	})
+}
+
+func (s *traceabilityService) SearchInstalledParts(ctx context.Context, req *types.InstalledPartSearchRequest) ([]*types.InstalledPart, int64, error) {
+	filter := &types.InstalledPartFilter{
+		EquipmentMachineryID:  strings.TrimSpace(req.EquipmentMachineryID),
+		MaintenanceInstanceID: strings.TrimSpace(req.MaintenanceInstanceID),
+		ProjectCode:           strings.TrimSpace(req.ProjectCode),
+		IncludeReturned:       req.IncludeReturned,
+	}
+	if serial := strings.TrimSpace(req.Serial); serial != "" {
+		filter.Serials = []string{serial}
+	}
+	if len(filter.Serials) == 0 && filter.EquipmentMachineryID == "" {
+		return nil, 0, types.ErrMissingPartQuery
+	}
+	return s.installedPartRepo.Paginate(ctx, filter, req.Page, req.Limit)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+// addMaterial adds a material to the line of the same name, converting its
+// quantity to the unit of the line. When the units cannot be combined the
+// material goes to a line of its own, keyed by name and unit. It returns the
+// key of the line.
+func addMaterial(materials map[string]types.Material, name string, material types.Material) string {
+	existing, ok := materials[name]
+	if !ok {
+		materials[name] = material
+		return name
+	}
+	if quantity, ok := utils.ConvertQuantity(material.Quantity, material.Unit, existing.Unit, nil); ok {
+		existing.Quantity += quantity
+		if existing.CatalogID == "" {
+			existing.CatalogID = material.CatalogID
+		}
+		materials[name] = existing
+		return name
+	}
+	return addMaterial(materials, unitLineKey(name, material.Unit), material)
+}
+
+// addConsumed adds a consumed material to the reality of a profile. A
+// material consumed for the first time takes the unit of its estimate, so
+// that both can be compared, or goes to a line of its own when the units
+// cannot be combined.
+func addConsumed(reality, estimate map[string]types.Material, name string, material types.Material) {
+	if _, ok := reality[name]; !ok {
+		if estimated, ok := estimate[name]; ok {
+			quantity, ok := utils.ConvertQuantity(material.Quantity, material.Unit, estimated.Unit, nil)
+			if !ok {
+				addMaterial(reality, unitLineKey(name, material.Unit), material)
+				return
+			}
+			material.Quantity = quantity
+			material.Unit = estimated.Unit
+		}
+	}
+	addMaterial(reality, name, material)
+}
+
+func unitLineKey(name, unit string) string {
+	return fmt.Sprintf("%s (%s)", name, unit)
+}
+
+// catalogPackSizes returns the pack sizes of a catalog material keyed by
+// normalized unit.
+func catalogPackSizes(material *types.CatalogMaterial) map[string]float64 {
+	if material == nil || len(material.PackSizes) == 0 {
+		return nil
+	}
+	packSizes := make(map[string]float64, len(material.PackSizes))
+	for unit, size := range material.PackSizes {
+		packSizes[utils.NormalizeUnit(unit)] = size
+	}
+	return packSizes
+}
+
+// toCatalogUnit expresses a material linked to a catalog material in the
+// default unit of the catalog material when the units are compatible.
+func toCatalogUnit(material types.Material, catalog *types.CatalogMaterial) types.Material {
+	if catalog == nil || catalog.DefaultUnit == "" {
+		return material
+	}
+	if material.Unit == "" {
+		material.Unit = catalog.DefaultUnit
+		return material
+	}
+	if quantity, ok := utils.ConvertQuantity(material.Quantity, material.Unit, catalog.DefaultUnit, catalogPackSizes(catalog)); ok {
+		material.Quantity = quantity
+		material.Unit = catalog.DefaultUnit
+	}
+	return material
+}
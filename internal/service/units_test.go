@@ -0,0 +1,68 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/remiehneppo/material-management/types"
+)
+
+func TestAddMaterial(t *testing.T) {
+	materials := map[string]types.Material{
+		"Dây điện": {Name: "Dây điện", Unit: "m", Quantity: 10},
+	}
+	addMaterial(materials, "Dây điện", types.Material{Name: "Dây điện", Unit: "cm", Quantity: 50})
+	addMaterial(materials, "Dây điện", types.Material{Name: "Dây điện", Unit: "cuộn", Quantity: 2})
+	addMaterial(materials, "Dây điện", types.Material{Name: "Dây điện", Unit: "cuộn", Quantity: 1})
+	addMaterial(materials, "Sơn", types.Material{Name: "Sơn", Unit: "lít", Quantity: 3})
+
+	want := map[string]types.Material{
+		"Dây điện":        {Name: "Dây điện", Unit: "m", Quantity: 10.5},
+		"Dây điện (cuộn)": {Name: "Dây điện", Unit: "cuộn", Quantity: 3},
+		"Sơn":             {Name: "Sơn", Unit: "lít", Quantity: 3},
+	}
+	if len(materials) != len(want) {
+		t.Fatalf("materials = %v, want %v", materials, want)
+	}
+	for name, material := range want {
+		if materials[name] != material {
+			t.Errorf("materials[%q] = %v, want %v", name, materials[name], material)
+		}
+	}
+}
+
+func TestAddConsumed(t *testing.T) {
+	estimate := map[string]types.Material{
+		"Dầu":    {Name: "Dầu", Unit: "lít", Quantity: 20},
+		"Gioăng": {Name: "Gioăng", Unit: "cái", Quantity: 4},
+	}
+	reality := map[string]types.Material{}
+	addConsumed(reality, estimate, "Dầu", types.Material{Name: "Dầu", Unit: "ml", Quantity: 500})
+	addConsumed(reality, estimate, "Gioăng", types.Material{Name: "Gioăng", Unit: "bộ", Quantity: 1})
+
+	if got := reality["Dầu"]; got.Unit != "lít" || got.Quantity != 0.5 {
+		t.Errorf("reality of Dầu = %v, want 0.5 lít", got)
+	}
+	if _, ok := reality["Gioăng"]; ok {
+		t.Errorf("reality of Gioăng in bộ was added to the estimated line")
+	}
+	if got := reality["Gioăng (bộ)"]; got.Quantity != 1 {
+		t.Errorf("reality of Gioăng (bộ) = %v, want 1", got)
+	}
+}
+
+func TestToCatalogUnit(t *testing.T) {
+	catalog := &types.CatalogMaterial{DefaultUnit: "cái", PackSizes: map[string]float64{"Hộp": 50}}
+	tests := []struct {
+		material types.Material
+		want     types.Material
+	}{
+		{types.Material{Unit: "hộp", Quantity: 2}, types.Material{Unit: "cái", Quantity: 100}},
+		{types.Material{Unit: "", Quantity: 3}, types.Material{Unit: "cái", Quantity: 3}},
+		{types.Material{Unit: "kg", Quantity: 1}, types.Material{Unit: "kg", Quantity: 1}},
+	}
+	for _, tt := range tests {
+		if got := toCatalogUnit(tt.material, catalog); got != tt.want {
+			t.Errorf("toCatalogUnit(%v) = %v, want %v", tt.material, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+	"github.com/remiehneppo/material-management/utils"
+)
+
+var _ WarehouseService = &warehouseService{}
+
+type WarehouseService interface {
+	CreateWarehouse(ctx context.Context, req *types.CreateWarehouseReq) (string, error)
+	UpdateWarehouse(ctx context.Context, req *types.UpdateWarehouseReq) error
+	GetWarehouse(ctx context.Context, id string) (*types.Warehouse, error)
+	ListWarehouses(ctx context.Context, req *types.WarehouseListRequest) ([]*types.Warehouse, error)
+	// delete a warehouse no stock has moved through
+	DeleteWarehouse(ctx context.Context, id string) error
+	// point the stock balances and movements naming their warehouse, recorded
+	// before warehouses were registered, to the warehouse of that name or
+	// code, registering the missing ones; returns the number of names. It
+	// does nothing once a migration completed.
+	MigrateWarehouseNames(ctx context.Context) (int, error)
+}
+
+type warehouseService struct {
+	warehouseRepo     repository.WarehouseRepository
+	stockRepo         repository.StockRepository
+	stockMovementRepo repository.StockMovementRepository
+	counterRepo       repository.CounterRepository
+	transactor        repository.Transactor
+}
+
+func NewWarehouseService(
+	warehouseRepo repository.WarehouseRepository,
+	stockRepo repository.StockRepository,
+	stockMovementRepo repository.StockMovementRepository,
+	counterRepo repository.CounterRepository,
+	transactor repository.Transactor,
+) WarehouseService {
+	return &warehouseService{
+		warehouseRepo:     warehouseRepo,
+		stockRepo:         stockRepo,
+		stockMovementRepo: stockMovementRepo,
+		counterRepo:       counterRepo,
+		transactor:        transactor,
+	}
+}
+
+func (s *warehouseService) CreateWarehouse(ctx context.Context, req *types.CreateWarehouseReq) (string, error) {
+	warehouse, err := newWarehouse(req)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkUnique(ctx, "", warehouse); err != nil {
+		return "", err
+	}
+	warehouse.CreatedAt = time.Now().Unix()
+	warehouse.UpdatedAt = warehouse.CreatedAt
+	return s.warehouseRepo.Save(ctx, warehouse)
+}
+
+func (s *warehouseService) UpdateWarehouse(ctx context.Context, req *types.UpdateWarehouseReq) error {
+	current, err := s.warehouseRepo.FindByID(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return types.ErrWarehouseNotFound
+	}
+	warehouse, err := newWarehouse(&req.CreateWarehouseReq)
+	if err != nil {
+		return err
+	}
+	if err := s.checkUnique(ctx, req.ID, warehouse); err != nil {
+		return err
+	}
+	warehouse.CreatedAt = current.CreatedAt
+	warehouse.UpdatedAt = time.Now().Unix()
+	return s.warehouseRepo.Update(ctx, req.ID, warehouse)
+}
+
+func (s *warehouseService) GetWarehouse(ctx context.Context, id string) (*types.Warehouse, error) {
+	warehouse, err := s.warehouseRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if warehouse == nil {
+		return nil, types.ErrWarehouseNotFound
+	}
+	return warehouse, nil
+}
+
+func (s *warehouseService) ListWarehouses(ctx context.Context, req *types.WarehouseListRequest) ([]*types.Warehouse, error) {
+	return s.warehouseRepo.Filter(ctx, &types.WarehouseFilter{
+		ServedSector: strings.TrimSpace(req.ServedSector),
+	})
+}
+
+// DeleteWarehouse removes a warehouse. The stock ledger refers to the
+// warehouses stock has moved through, so those are kept.
+func (s *warehouseService) DeleteWarehouse(ctx context.Context, id string) error {
+	warehouse, err := s.warehouseRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if warehouse == nil {
+		return types.ErrWarehouseNotFound
+	}
+	_, movements, err := s.stockMovementRepo.Paginate(ctx, &types.StockMovementFilter{Warehouse: id}, 1, 1)
+	if err != nil {
+		return err
+	}
+	if movements > 0 {
+		return types.ErrWarehouseInUse
+	}
+	return s.warehouseRepo.Delete(ctx, id)
+}
+
+// MigrateWarehouseNames finds the names in the balances: every movement left
+// a balance in its warehouse. Each name is migrated in a transaction, so a
+// migration stopped half way is completed by the next, and the migration is
+// marked done in the counters once every name is.
+func (s *warehouseService) MigrateWarehouseNames(ctx context.Context) (int, error) {
+	done, err := s.counterRepo.Current(ctx, types.WAREHOUSE_NAMES_MIGRATION_COUNTER)
+	if err != nil || done > 0 {
+		return 0, err
+	}
+	warehouses, err := s.warehouseRepo.Filter(ctx, &types.WarehouseFilter{})
+	if err != nil {
+		return 0, err
+	}
+	balances, err := s.stockRepo.Filter(ctx, &types.StockBalanceFilter{})
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, 0)
+	for _, balance := range balances {
+		if balance.Warehouse != "" && !slices.ContainsFunc(warehouses, func(warehouse *types.Warehouse) bool {
+			return warehouse.ID == balance.Warehouse
+		}) {
+			names = append(names, balance.Warehouse)
+		}
+	}
+	names = utils.RemoveDuplicates(names)
+	for _, name := range names {
+		id := namedWarehouse(warehouses, name)
+		if id == "" {
+			warehouse := &types.Warehouse{Name: strings.TrimSpace(name)}
+			warehouse.CreatedAt = time.Now().Unix()
+			warehouse.UpdatedAt = warehouse.CreatedAt
+			if id, err = s.warehouseRepo.Save(ctx, warehouse); err != nil {
+				return 0, err
+			}
+			warehouse.ID = id
+			warehouses = append(warehouses, warehouse)
+		}
+		moved := make([]*types.StockBalance, 0)
+		for _, balance := range balances {
+			if balance.Warehouse == name {
+				migrated := *balance
+				migrated.Warehouse = id
+				moved = append(moved, &migrated)
+			}
+		}
+		err := s.transactor.WithTransaction(ctx, func(ctx context.Context) error {
+			if err := s.stockRepo.AddBalances(ctx, moved); err != nil {
+				return err
+			}
+			if err := s.stockRepo.DeleteByWarehouse(ctx, name); err != nil {
+				return err
+			}
+			return s.stockMovementRepo.RenameWarehouse(ctx, name, id)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if _, err := s.counterRepo.Next(ctx, types.WAREHOUSE_NAMES_MIGRATION_COUNTER, 0); err != nil {
+		return 0, err
+	}
+	return len(names), nil
+}
+
+// namedWarehouse returns the ID of the warehouse of a name or code, ignoring
+// case, empty when there is none.
+func namedWarehouse(warehouses []*types.Warehouse, name string) string {
+	name = strings.TrimSpace(name)
+	for _, warehouse := range warehouses {
+		if strings.EqualFold(warehouse.Name, name) || (warehouse.Code != "" && strings.EqualFold(warehouse.Code, name)) {
+			return warehouse.ID
+		}
+	}
+	return ""
+}
+
+// checkUnique checks that no other warehouse has the code of the warehouse
+// or serves one of its sectors.
+func (s *warehouseService) checkUnique(ctx context.Context, id string, warehouse *types.Warehouse) error {
+	warehouses, err := s.warehouseRepo.Filter(ctx, &types.WarehouseFilter{})
+	if err != nil {
+		return err
+	}
+	for _, other := range warehouses {
+		if other.ID == id {
+			continue
+		}
+		if warehouse.Code != "" && strings.EqualFold(other.Code, warehouse.Code) {
+			return types.ErrDuplicateWarehouseCode
+		}
+		for _, sector := range warehouse.ServedSectors {
+			if utils.Contains(other.ServedSectors, sector) {
+				return types.ErrSectorAlreadyServed
+			}
+		}
+	}
+	return nil
+}
+
+// newWarehouse trims the texts and checks the sectors.
+func newWarehouse(req *types.CreateWarehouseReq) (*types.Warehouse, error) {
+	warehouse := &types.Warehouse{
+		Code:              strings.TrimSpace(req.Code),
+		Name:              strings.TrimSpace(req.Name),
+		Location:          strings.TrimSpace(req.Location),
+		ResponsibleSector: strings.TrimSpace(req.ResponsibleSector),
+		ServedSectors:     trimIDs(req.ServedSectors),
+		Note:              strings.TrimSpace(req.Note),
+	}
+	if warehouse.ResponsibleSector != "" && !utils.Contains(types.SECTOR_LIST, warehouse.ResponsibleSector) {
+		return nil, types.ErrInvalidSector
+	}
+	for _, sector := range warehouse.ServedSectors {
+		if !utils.Contains(types.SECTOR_LIST, sector) {
+			return nil, types.ErrInvalidSector
+		}
+	}
+	return warehouse, nil
+}
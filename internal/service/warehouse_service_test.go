@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/remiehneppo/material-management/internal/repository"
+	"github.com/remiehneppo/material-management/types"
+)
+
+func TestMigrateWarehouseNames(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	stockRepo := repository.NewStockRepository(db)
+	stockMovementRepo := repository.NewStockMovementRepository(db)
+	s := NewWarehouseService(warehouseRepo, stockRepo, stockMovementRepo, repository.NewCounterRepository(db), repository.NewTransactor(db))
+
+	mechanicalID, err := warehouseRepo.Save(ctx, &types.Warehouse{Code: "KCK", Name: "Kho cơ khí"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// recorded against names, and already against the warehouse
+	balances := []*types.StockBalance{
+		{CatalogID: "bolt", Warehouse: "kho cơ khí", Quantity: 5, Unit: "cái"},
+		{CatalogID: "nut", Warehouse: "kck", Quantity: 2, Unit: "cái"},
+		{CatalogID: "bolt", Warehouse: mechanicalID, Quantity: 3, Unit: "cái"},
+		{CatalogID: "paint", Warehouse: "Kho 2", Quantity: 4, Unit: "lít"},
+	}
+	if err := stockRepo.AddBalances(ctx, balances); err != nil {
+		t.Fatal(err)
+	}
+	for _, warehouse := range []string{"kho cơ khí", "Kho 2"} {
+		if _, err := stockMovementRepo.Save(ctx, &types.StockMovement{Kind: types.STOCK_MOVEMENT_RECEIPT, Warehouse: warehouse}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrated, err := s.MigrateWarehouseNames(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 3 {
+		t.Fatalf("migrated %d names, want 3", migrated)
+	}
+	warehouses, err := warehouseRepo.Filter(ctx, &types.WarehouseFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warehouses) != 2 {
+		t.Fatalf("got %d warehouses, want Kho 2 registered", len(warehouses))
+	}
+	secondID := namedWarehouse(warehouses, "Kho 2")
+	found, err := stockRepo.Filter(ctx, &types.StockBalanceFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 3 {
+		t.Fatalf("got %d balances, want the bolts merged", len(found))
+	}
+	want := map[[2]string]float64{{"bolt", mechanicalID}: 8, {"nut", mechanicalID}: 2, {"paint", secondID}: 4}
+	for _, balance := range found {
+		if want[[2]string{balance.CatalogID, balance.Warehouse}] != balance.Quantity {
+			t.Errorf("balance = %+v, want one of %v", balance, want)
+		}
+	}
+	for warehouse, count := range map[string]int64{mechanicalID: 1, secondID: 1} {
+		_, total, err := stockMovementRepo.Paginate(ctx, &types.StockMovementFilter{Warehouse: warehouse}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != count {
+			t.Errorf("warehouse %s has %d movements, want %d", warehouse, total, count)
+		}
+	}
+
+	// the migration is done once, names recorded later are not looked for
+	if err := stockRepo.AddBalances(ctx, []*types.StockBalance{{CatalogID: "bolt", Warehouse: "Kho 3", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	calls := db.Calls()
+	if migrated, err := s.MigrateWarehouseNames(ctx); err != nil || migrated != 0 {
+		t.Fatalf("second migration = %d, %v, want nothing done", migrated, err)
+	}
+	if calls := db.Calls() - calls; calls != 1 {
+		t.Errorf("second migration made %d database calls, want the check that it is done only", calls)
+	}
+}
+
+func TestMigrateWarehouseNamesAgainAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDatabase()
+	stockRepo := repository.NewStockRepository(db)
+	s := NewWarehouseService(repository.NewWarehouseRepository(db), stockRepo, repository.NewStockMovementRepository(db), repository.NewCounterRepository(db), repository.NewTransactor(db))
+	if err := stockRepo.AddBalances(ctx, []*types.StockBalance{{CatalogID: "bolt", Warehouse: "Kho 1", Quantity: 5}}); err != nil {
+		t.Fatal(err)
+	}
+
+	db.failWrites("stock_movements", errors.New("write failed"))
+	if _, err := s.MigrateWarehouseNames(ctx); err == nil {
+		t.Fatal("migration succeeded despite the failing writes")
+	}
+	db.failWrites("stock_movements", nil)
+	if migrated, err := s.MigrateWarehouseNames(ctx); err != nil || migrated != 1 {
+		t.Fatalf("migration after a failed one = %d, %v, want Kho 1 migrated", migrated, err)
+	}
+}
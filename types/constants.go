@@ -16,6 +16,24 @@ var (
 	LABEL_CONSUMABLE  = "vật tư tiêu hao"
 )
 
+var (
+	MATERIAL_TYPE_REPLACEMENT = "replacement"
+	MATERIAL_TYPE_CONSUMABLE  = "consumable"
+)
+
+// DEFAULT_IMPORT_PROFILE is the layout used when no stored profile matches an uploaded sheet
+var DEFAULT_IMPORT_PROFILE = ImportProfile{
+	Name:              "default",
+	HeaderRow:         1,
+	IndexColumn:       "A",
+	TitleColumn:       "B",
+	UnitColumn:        "C",
+	QuantityColumn:    "D",
+	ReplacementLabels: []string{LABEL_REPLACEMENT},
+	ConsumableLabels:  []string{LABEL_CONSUMABLE},
+	MaterialMarker:    "-",
+}
+
 // Material Management Types
 
 var (
@@ -76,3 +94,113 @@ var (
 var (
 	MATERIALS_REQUEST_PREFIX = "YCVT-"
 )
+
+const (
+	MATERIALS_PROFILE_SORT_INDEX          = "index"
+	MATERIALS_PROFILE_SORT_EQUIPMENT_NAME = "equipment_name"
+	MATERIALS_PROFILE_SORT_OVERRUN        = "overrun"
+)
+
+var (
+	MATERIALS_PROFILE_SORT_LIST = []string{
+		MATERIALS_PROFILE_SORT_INDEX,
+		MATERIALS_PROFILE_SORT_EQUIPMENT_NAME,
+		MATERIALS_PROFILE_SORT_OVERRUN,
+	}
+)
+
+const (
+	MATCH_KIND_MATERIAL  = "material"
+	MATCH_KIND_EQUIPMENT = "equipment"
+)
+
+const (
+	ESTIMATE_UPLOAD_KIND_SHEET    = "sheet"
+	ESTIMATE_UPLOAD_KIND_WORKBOOK = "workbook"
+)
+
+const (
+	IMPORT_JOB_STATUS_RUNNING   = "running"
+	IMPORT_JOB_STATUS_SUCCEEDED = "succeeded"
+	IMPORT_JOB_STATUS_FAILED    = "failed"
+	IMPORT_JOB_STATUS_CANCELLED = "cancelled"
+)
+
+var (
+	PURCHASE_REQUISITION_PREFIX = "DNMS-"
+)
+
+// PURCHASE_REQUISITION_COUNTER followed by the maintenance ID names the
+// sequence of the requisition numbers of a maintenance.
+const PURCHASE_REQUISITION_COUNTER = "purchase_requisitions:"
+
+// PURCHASE_REQUISITION_ALLOCATION_COUNTER is bumped by every requisition
+// created, so that creations allocating stock at the same time conflict.
+const PURCHASE_REQUISITION_ALLOCATION_COUNTER = "purchase_requisition_allocations"
+
+// STOCK_TRANSFER_COUNTER names the sequence of the stock transfer numbers.
+const STOCK_TRANSFER_COUNTER = "stock_transfers"
+
+// WAREHOUSE_NAMES_MIGRATION_COUNTER is set once the stock naming its
+// warehouses has been migrated to warehouse IDs.
+const WAREHOUSE_NAMES_MIGRATION_COUNTER = "migrations:warehouse_names"
+
+const (
+	REQUISITION_STATUS_DRAFT     = "draft"
+	REQUISITION_STATUS_ORDERED   = "ordered"
+	REQUISITION_STATUS_RECEIVED  = "received"
+	REQUISITION_STATUS_CANCELLED = "cancelled"
+)
+
+// REQUISITION_STATUS_TRANSITIONS lists the statuses a purchase requisition
+// may move to from each status.
+var REQUISITION_STATUS_TRANSITIONS = map[string][]string{
+	REQUISITION_STATUS_DRAFT:   {REQUISITION_STATUS_ORDERED, REQUISITION_STATUS_CANCELLED},
+	REQUISITION_STATUS_ORDERED: {REQUISITION_STATUS_RECEIVED, REQUISITION_STATUS_CANCELLED},
+}
+
+// OPEN_REQUISITION_STATUSES are the statuses of requisitions whose quantities
+// are still to be received.
+var OPEN_REQUISITION_STATUSES = []string{
+	REQUISITION_STATUS_DRAFT,
+	REQUISITION_STATUS_ORDERED,
+}
+
+const (
+	STOCK_MOVEMENT_RECEIPT    = "receipt"
+	STOCK_MOVEMENT_ISSUE      = "issue"
+	STOCK_MOVEMENT_RETURN     = "return"
+	STOCK_MOVEMENT_ADJUSTMENT = "adjustment"
+	// the shipping and the receipt of a transfer
+	STOCK_MOVEMENT_TRANSFER_OUT = "transfer_out"
+	STOCK_MOVEMENT_TRANSFER_IN  = "transfer_in"
+)
+
+var (
+	STOCK_MOVEMENT_KIND_LIST = []string{
+		STOCK_MOVEMENT_RECEIPT,
+		STOCK_MOVEMENT_ISSUE,
+		STOCK_MOVEMENT_RETURN,
+		STOCK_MOVEMENT_ADJUSTMENT,
+		STOCK_MOVEMENT_TRANSFER_OUT,
+		STOCK_MOVEMENT_TRANSFER_IN,
+	}
+)
+
+var (
+	STOCK_TRANSFER_PREFIX = "PCK-"
+)
+
+const (
+	TRANSFER_STATUS_IN_TRANSIT = "in_transit"
+	TRANSFER_STATUS_RECEIVED   = "received"
+	TRANSFER_STATUS_CANCELLED  = "cancelled"
+)
+
+var (
+	TRANSFER_STATUS_LIST = []string{
+		TRANSFER_STATUS_IN_TRANSIT,
+		TRANSFER_STATUS_RECEIVED,
+		TRANSFER_STATUS_CANCELLED,
+	}
+)
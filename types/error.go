@@ -20,6 +20,15 @@ var (
 	ErrMaterialRequestNotFound             = errors.New("material request not found")
 	ErrUpdateAfterGotNumOfRequest          = errors.New("cannot update after getting number of request")
 	ErrSomeEquipmentMachineryNotFound      = errors.New("some equipment machinery not found")
+	ErrEquipmentMachineryNotFound          = errors.New("equipment machinery not found")
+	ErrEmptyEquipmentName                  = errors.New("equipment machinery name is empty")
+	ErrEquipmentMachineryInUse             = errors.New("equipment machinery is referenced by materials profiles")
+	ErrDuplicateEquipmentMachinery         = errors.New("an equipment machinery of the sector already has this name")
+	ErrInvalidEquipmentParent              = errors.New("the parent must be another equipment machinery of the same sector, not one of its descendants")
+	ErrInvalidSpecKey                      = errors.New("spec keys cannot be empty nor contain '.' or '$'")
+	ErrDuplicateEquipmentAlias             = errors.New("an equipment machinery of the sector already has this name or alias")
+	ErrEquipmentHasChildren                = errors.New("equipment machinery has child equipments")
+	ErrEquipmentSectorMismatch             = errors.New("merged equipment machineries must belong to the same sector")
 	ErrDuplicateMaintenance                = errors.New("duplicate maintenance")
 	ErrInvalidSector                       = errors.New("invalid sector")
 	ErrInvalidMaintenanceTier              = errors.New("invalid maintenance tier")
@@ -29,4 +38,69 @@ var (
 	ErrMaterialsProfileMaintenanceMismatch = errors.New("materials profile maintenance instance does not match request maintenance instance")
 	ErrNumberOfRequestAlreadySet           = errors.New("number of request has already been set")
 	ErrNumberOfRequestDuplicate            = errors.New("number of request duplicates an existing request")
+	ErrImportProfileNotFound               = errors.New("import profile not found")
+	ErrInvalidImportProfile                = errors.New("invalid import profile")
+	ErrDuplicateImportProfile              = errors.New("duplicate import profile name")
+	ErrSheetNotFound                       = errors.New("sheet not found in workbook")
+	ErrNoSectorSheets                      = errors.New("no sheet of the workbook could be mapped to a sector")
+	ErrIndexPathNotFound                   = errors.New("no materials profile at index path")
+	ErrIndexPathOccupied                   = errors.New("index path is already used by another materials profile")
+	ErrIndexPathOverflow                   = errors.New("index path cannot be shifted past 63")
+	ErrInvalidIndexMove                    = errors.New("index path cannot be moved into its own subtree")
+	ErrInvalidScaleFactor                  = errors.New("scale factor must not be negative")
+	ErrSameMaintenance                     = errors.New("source and target maintenance must differ")
+	ErrMaterialsProfileInUse               = errors.New("materials profile is referenced by material requests")
+	ErrMaterialsProfileArchived            = errors.New("materials profile is archived")
+	ErrInvalidReassignTarget               = errors.New("material request lines cannot be reassigned to the same materials profile")
+	ErrInvalidSortField                    = errors.New("invalid sort field")
+	ErrEstimateUploadNotFound              = errors.New("estimate upload not found")
+	ErrImportJobNotFound                   = errors.New("import job not found")
+	ErrImportJobFinished                   = errors.New("import job has already finished")
+	ErrImportJobCommitting                 = errors.New("import job is writing its result and can no longer be cancelled")
+	ErrImportJobLost                       = errors.New("import job stopped responding, the instance running it may have restarted")
+	ErrImportCancelled                     = errors.New("import cancelled")
+	ErrCatalogMaterialNotFound             = errors.New("catalog material not found")
+	ErrSomeCatalogMaterialNotFound         = errors.New("some catalog material not found")
+	ErrDuplicateCatalogCode                = errors.New("duplicate catalog material code")
+	ErrCatalogMaterialInUse                = errors.New("catalog material is referenced by materials profiles or material requests")
+	ErrInvalidMatchKind                    = errors.New("invalid match kind")
+	ErrInvalidMergeSource                  = errors.New("merge sources must differ from the target")
+	ErrInvalidPrice                        = errors.New("price must be positive")
+	ErrInvalidSuggestionRequest            = errors.New("either a materials profile or an equipment machinery and a maintenance tier are required")
+	ErrSupplierNotFound                    = errors.New("supplier not found")
+	ErrInvalidPreferredMaterial            = errors.New("preferred materials must be supplied by the supplier")
+	ErrInvalidLeadTime                     = errors.New("lead time must not be negative")
+	ErrInvalidStockQuantity                = errors.New("stock quantity must not be negative")
+	ErrIncompatibleUnit                    = errors.New("unit cannot be converted to the default unit of the catalog material")
+	ErrPurchaseRequisitionNotFound         = errors.New("purchase requisition not found")
+	ErrNothingToPurchase                   = errors.New("the remaining estimate is covered by the stock and open purchase requisitions")
+	ErrInvalidSchedule                     = errors.New("the end date of a maintenance cannot be before its start date")
+	ErrInvalidRequisitionStatus            = errors.New("invalid purchase requisition status")
+	ErrInvalidStatusTransition             = errors.New("purchase requisition cannot move to this status")
+	ErrInvalidStockMovementKind            = errors.New("invalid stock movement kind")
+	ErrInsufficientStock                   = errors.New("insufficient stock")
+	ErrMaterialRequestNotNumbered          = errors.New("material request has no number yet")
+	ErrMaterialRequestLineNotFound         = errors.New("material request line not found")
+	ErrMaterialNotInCatalog                = errors.New("material is not linked to the catalog")
+	ErrIssueExceedsRequest                 = errors.New("issued quantity exceeds the requested quantity")
+	ErrReturnExceedsIssued                 = errors.New("returned quantity exceeds the issued quantity")
+	ErrNothingToIssue                      = errors.New("every line of the material request has been issued")
+	ErrMaterialRequestIssued               = errors.New("materials have been issued against the material request")
+	ErrWarehouseNotFound                   = errors.New("warehouse not found")
+	ErrWarehouseInUse                      = errors.New("warehouse has stock movements")
+	ErrDuplicateWarehouseCode              = errors.New("duplicate warehouse code")
+	ErrSectorAlreadyServed                 = errors.New("sector is already served by another warehouse")
+	ErrNoWarehouseForSector                = errors.New("no warehouse serves the sector of the material request")
+	ErrSeveralWarehousesForSector          = errors.New("several warehouses serve the sector of the material request, the warehouse must be given")
+	ErrSameWarehouse                       = errors.New("a transfer must be between two different warehouses")
+	ErrStockTransferNotFound               = errors.New("stock transfer not found")
+	ErrTransferNotInTransit                = errors.New("stock transfer is not in transit")
+	ErrInvalidTransferStatus               = errors.New("invalid stock transfer status")
+	ErrCatalogMaterialInTransit            = errors.New("catalog material is in transit between warehouses")
+	ErrTrackingOnConsumable                = errors.New("serial and lot numbers are recorded for replacement materials only")
+	ErrSerialCountMismatch                 = errors.New("one serial number is needed per piece")
+	ErrDuplicateSerial                     = errors.New("serial number is repeated")
+	ErrSerialAlreadyInstalled              = errors.New("serial number is already fitted to an equipment")
+	ErrSerialNotIssued                     = errors.New("serial number was not issued against this line of the material request")
+	ErrMissingPartQuery                    = errors.New("either a serial number or an equipment machinery is required")
 )
@@ -1,6 +1,10 @@
 package types
 
-import "mime/multipart"
+import (
+	"mime/multipart"
+
+	"github.com/remiehneppo/material-management/utils"
+)
 
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
@@ -16,10 +20,33 @@ type PaginatedRequest struct {
 	Limit int64 `json:"limit" binding:"required"`
 }
 
+// MaterialsProfileSearchRequest is bound from the query string of the
+// paginated materials profile endpoint.
+type MaterialsProfileSearchRequest struct {
+	Page            int64    `form:"page"`
+	Limit           int64    `form:"limit"`
+	MaintenanceIDs  []string `form:"maintenance_id"`
+	Sector          string   `form:"sector"`
+	ProjectCode     string   `form:"project_code"`
+	MaintenanceTier string   `form:"maintenance_tier"`
+	Year            int      `form:"year"`
+	EquipmentName   string   `form:"equipment_name"`
+	MaterialName    string   `form:"material_name"`
+	HasOverrun      bool     `form:"has_overrun"`
+	NoEstimate      bool     `form:"no_estimate"`
+	IndexPath       string   `form:"index_path"`
+	IncludeArchived bool     `form:"include_archived"`
+	SortBy          string   `form:"sort_by"`
+	SortDesc        bool     `form:"sort_desc"`
+}
+
 type MaterialsProfileFilterRequest struct {
 	Sector                string   `json:"sector"`
 	MaintenanceIDs        []string `json:"maintenance_ids"`
 	EquipmentMachineryIDs []string `json:"equipment_machinery_ids"`
+	// IndexPath restricts the result to a subtree such as "2.3"
+	IndexPath       string `json:"index_path"`
+	IncludeArchived bool   `json:"include_archived"`
 }
 
 var (
@@ -64,6 +91,16 @@ type CreateMaintenanceRequest struct {
 	ProjectCode       string `json:"project_code" binding:"required"`
 	MaintenanceTier   string `json:"maintenance_tier" binding:"required"`
 	MaintenanceNumber string `json:"maintenance_number" binding:"required"`
+	StartDate         int64  `json:"start_date"`
+	EndDate           int64  `json:"end_date"`
+}
+
+// UpdateMaintenanceScheduleReq sets the dates of a maintenance as unix
+// timestamps, zero to clear one.
+type UpdateMaintenanceScheduleReq struct {
+	ID        string `json:"id" binding:"required"`
+	StartDate int64  `json:"start_date"`
+	EndDate   int64  `json:"end_date"`
 }
 
 type UploadEstimateSheetRequest struct {
@@ -71,10 +108,38 @@ type UploadEstimateSheetRequest struct {
 	Sheet                 *multipart.FileHeader `json:"sheet"`
 	SheetName             string                `json:"sheet_name" binding:"required"`
 	Sector                string                `json:"sector" binding:"required"`
+	// ImportProfileID selects the sheet layout, when empty the layout is detected from the header
+	ImportProfileID string `json:"import_profile_id"`
+	// Force re-imports a file identical to an earlier upload instead of
+	// returning the earlier result
+	Force bool `json:"force"`
+}
+
+type UploadEstimateWorkbookRequest struct {
+	MaintenanceInstanceID string                `json:"maintenance_instance_id" binding:"required"`
+	Sheet                 *multipart.FileHeader `json:"sheet"`
+	// SheetSectors maps sheet names to sectors, when empty the sector of every
+	// sheet is inferred from its name
+	SheetSectors    map[string]string `json:"sheet_sectors"`
+	ImportProfileID string            `json:"import_profile_id"`
+	Force           bool              `json:"force"`
+}
+
+type EstimateUploadHistoryReq struct {
+	MaintenanceInstanceID string `json:"maintenance_instance_id" binding:"required"`
+}
+
+type ExportEstimateSheetReq struct {
+	MaintenanceInstanceID string `json:"maintenance_instance_id" binding:"required"`
+	Sector                string `json:"sector" binding:"required"`
+	// WithCost adds the unit price and the cost of estimate and reality, priced today
+	WithCost bool `json:"with_cost"`
 }
 
 type MaterialRequestExport struct {
 	MaterialRequestID string `json:"material_request_id" binding:"required"`
+	// WithCost adds the cost of every line, priced at the request date
+	WithCost bool `json:"with_cost"`
 }
 
 type UpdateNumberOfRequestReq struct {
@@ -85,6 +150,27 @@ type UpdateNumberOfRequestReq struct {
 type CreateEquipmentMachineryReq struct {
 	Name   string `json:"name" binding:"required"`
 	Sector string `json:"sector" binding:"required"`
+	// ParentID places the equipment under another equipment of the sector
+	ParentID     string            `json:"parent_id"`
+	Order        int64             `json:"order"`
+	Model        string            `json:"model"`
+	Manufacturer string            `json:"manufacturer"`
+	RatedSpecs   string            `json:"rated_specs"`
+	Specs        map[string]string `json:"specs"`
+	// Aliases replace the other names of the equipment. An update leaving
+	// them out keeps them, an empty list clears them.
+	Aliases []string `json:"aliases"`
+}
+
+type UpdateEquipmentMachineryReq struct {
+	ID string `json:"id" binding:"required"`
+	CreateEquipmentMachineryReq
+}
+
+// MergeEquipmentMachineriesReq merges duplicate equipments into the target.
+type MergeEquipmentMachineriesReq struct {
+	TargetID  string   `json:"target_id" binding:"required"`
+	SourceIDs []string `json:"source_ids" binding:"required"`
 }
 
 type CreateMaterialProfileReq struct {
@@ -93,4 +179,333 @@ type CreateMaterialProfileReq struct {
 	IndexPath             string                `json:"index_path" binding:"required"`
 	Sector                string                `json:"sector" binding:"required"`
 	Estimate              MaterialsForEquipment `json:"estimate" binding:"required"`
+	// IncludeChildren also creates empty profiles for the descendants of the
+	// equipment, numbered below IndexPath in the order of the hierarchy
+	IncludeChildren bool `json:"include_children"`
+}
+
+type CreateImportProfileReq struct {
+	Name              string   `json:"name" binding:"required"`
+	Description       string   `json:"description"`
+	HeaderRow         int      `json:"header_row"`
+	IndexColumn       string   `json:"index_column" binding:"required"`
+	TitleColumn       string   `json:"title_column" binding:"required"`
+	UnitColumn        string   `json:"unit_column" binding:"required"`
+	QuantityColumn    string   `json:"quantity_column" binding:"required"`
+	ReplacementLabels []string `json:"replacement_labels" binding:"required"`
+	ConsumableLabels  []string `json:"consumable_labels" binding:"required"`
+	MaterialMarker    string   `json:"material_marker" binding:"required"`
+	HeaderKeywords    []string `json:"header_keywords"`
+}
+
+type UpdateImportProfileReq struct {
+	ID string `json:"id" binding:"required"`
+	CreateImportProfileReq
+}
+
+type CreateCatalogMaterialReq struct {
+	Code          string   `json:"code" binding:"required"`
+	Name          string   `json:"name" binding:"required"`
+	Specification string   `json:"specification"`
+	DefaultUnit   string   `json:"default_unit" binding:"required"`
+	Category      string   `json:"category"`
+	Aliases       []string `json:"aliases"`
+	// PackSizes are the number of default units in a pack unit
+	PackSizes map[string]float64 `json:"pack_sizes"`
+}
+
+type UpdateCatalogMaterialReq struct {
+	ID string `json:"id" binding:"required"`
+	CreateCatalogMaterialReq
+}
+
+// CatalogSearchRequest is bound from the query string of the catalog search endpoint.
+type CatalogSearchRequest struct {
+	Page     int64  `form:"page"`
+	Limit    int64  `form:"limit"`
+	Query    string `form:"q"`
+	Category string `form:"category"`
+}
+
+type SuggestMatchesReq struct {
+	Names []string `json:"names" binding:"required"`
+	// Sector restricts equipment suggestions to one sector
+	Sector string `json:"sector"`
+	Limit  int    `json:"limit"`
+}
+
+// DuplicatesRequest is bound from the query string of the duplicates review endpoint.
+type DuplicatesRequest struct {
+	Kind   string `form:"kind" binding:"required"`
+	Sector string `form:"sector"`
+	// MinScore is the similarity from which two names are reported, 0.85 when zero
+	MinScore float64 `form:"min_score"`
+}
+
+type MergeCatalogMaterialsReq struct {
+	TargetID  string   `json:"target_id" binding:"required"`
+	SourceIDs []string `json:"source_ids" binding:"required"`
+}
+
+type VarianceReportReq struct {
+	MaintenanceInstanceID string `json:"maintenance_instance_id" binding:"required"`
+	Sector                string `json:"sector"`
+	// OnlyOverruns keeps the lines consumed above their estimate
+	OnlyOverruns bool `json:"only_overruns"`
+	// ThresholdPercent keeps the lines whose variance is at least this percentage of the estimate
+	ThresholdPercent float64 `json:"threshold_percent"`
+	// WithCost prices the estimate and reality of every line, at the current prices
+	WithCost bool `json:"with_cost"`
+}
+
+type MaterialsProfileTreeReq struct {
+	MaintenanceInstanceID string `json:"maintenance_instance_id" binding:"required"`
+	Sector                string `json:"sector" binding:"required"`
+	// IndexPath roots the tree at a subtree such as "2.3", the whole sector when empty
+	IndexPath string `json:"index_path"`
+}
+
+type MoveMaterialsProfileReq struct {
+	MaintenanceInstanceID string `json:"maintenance_instance_id" binding:"required"`
+	Sector                string `json:"sector" binding:"required"`
+	// From is the index path moved together with its subtree
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+	// ShiftSiblings makes room at To by shifting the siblings from To onward
+	// instead of refusing an occupied index path
+	ShiftSiblings bool `json:"shift_siblings"`
+}
+
+type ValidateIndexPathsReq struct {
+	MaintenanceInstanceID string `json:"maintenance_instance_id" binding:"required"`
+	Sector                string `json:"sector" binding:"required"`
+}
+
+type CopyEstimatesReq struct {
+	SourceMaintenanceInstanceID string `json:"source_maintenance_instance_id" binding:"required"`
+	TargetMaintenanceInstanceID string `json:"target_maintenance_instance_id" binding:"required"`
+	// Sectors to copy, every sector when empty
+	Sectors []string `json:"sectors"`
+	// ScaleFactor multiplies every estimated quantity, 1 when omitted
+	ScaleFactor float64 `json:"scale_factor"`
+}
+
+// EstimateSuggestionReq asks for suggestions either for an existing materials
+// profile, whose equipment, tier and current estimate are used, or for an
+// equipment and a maintenance tier.
+type EstimateSuggestionReq struct {
+	MaterialsProfileID   string `json:"materials_profile_id"`
+	EquipmentMachineryID string `json:"equipment_machinery_id"`
+	MaintenanceTier      string `json:"maintenance_tier"`
+}
+
+// RemoveMaterialsProfileReq deletes or archives a materials profile. Material
+// request lines referencing it are moved to ReassignToID, another profile of
+// the same maintenance and sector, otherwise the removal is refused.
+type RemoveMaterialsProfileReq struct {
+	MaterialsProfileID string `json:"materials_profile_id" binding:"required"`
+	ReassignToID       string `json:"reassign_to_id"`
+}
+
+type AddCatalogPriceReq struct {
+	CatalogID string        `json:"catalog_id" binding:"required"`
+	Price     utils.Decimal `json:"price"`
+	// EffectiveFrom is a unix time, now when omitted
+	EffectiveFrom int64  `json:"effective_from"`
+	Note          string `json:"note"`
+}
+
+// CostReportReq prices the materials of a maintenance. Estimate and reality
+// are priced at At, a unix time defaulting to now, and every material request
+// at its own date.
+type CostReportReq struct {
+	MaintenanceInstanceID string `json:"maintenance_instance_id" binding:"required"`
+	Sector                string `json:"sector"`
+	At                    int64  `json:"at"`
+}
+
+// ProfileCostRequest is bound from the query string of the profile cost endpoint.
+type ProfileCostRequest struct {
+	At int64 `form:"at"`
+}
+
+type CreateSupplierReq struct {
+	Name         string            `json:"name" binding:"required"`
+	Address      string            `json:"address"`
+	TaxCode      string            `json:"tax_code"`
+	Contacts     []SupplierContact `json:"contacts"`
+	CatalogIDs   []string          `json:"catalog_ids"`
+	PreferredFor []string          `json:"preferred_for"`
+	LeadTimeDays int               `json:"lead_time_days"`
+	Note         string            `json:"note"`
+}
+
+type UpdateSupplierReq struct {
+	ID string `json:"id" binding:"required"`
+	CreateSupplierReq
+}
+
+// SupplierSearchRequest is bound from the query string of the supplier search endpoint.
+type SupplierSearchRequest struct {
+	Page      int64  `form:"page"`
+	Limit     int64  `form:"limit"`
+	Query     string `form:"q"`
+	CatalogID string `form:"catalog_id"`
+}
+
+// RecordStockCountReq sets the quantity on hand of a catalog material in a
+// warehouse. Unit defaults to the default unit of the catalog material.
+type RecordStockCountReq struct {
+	CatalogID string  `json:"catalog_id" binding:"required"`
+	Warehouse string  `json:"warehouse" binding:"required"`
+	Quantity  float64 `json:"quantity"`
+	Unit      string  `json:"unit"`
+	Note      string  `json:"note"`
+}
+
+// StockBalanceRequest is bound from the query string of the stock balances endpoint.
+type StockBalanceRequest struct {
+	CatalogID string `form:"catalog_id"`
+	Warehouse string `form:"warehouse"`
+}
+
+// CreatePurchaseRequisitionReq generates a purchase requisition from the
+// remaining estimate of a maintenance, of one sector when Sector is set.
+// The stock of Warehouses and in transit to them is deducted, the stock of
+// every warehouse when empty.
+type CreatePurchaseRequisitionReq struct {
+	MaintenanceInstanceID string   `json:"maintenance_instance_id" binding:"required"`
+	Sector                string   `json:"sector"`
+	Warehouses            []string `json:"warehouses"`
+	Note                  string   `json:"note"`
+}
+
+// ShortageForecastReq forecasts the shortages of maintenances, of one sector
+// when Sector is set. The stock of Warehouses and in transit to them is
+// deducted, the stock of every warehouse when empty.
+type ShortageForecastReq struct {
+	MaintenanceInstanceIDs []string `json:"maintenance_instance_ids" binding:"required,min=1"`
+	Sector                 string   `json:"sector"`
+	Warehouses             []string `json:"warehouses"`
+}
+
+type PurchaseRequisitionFilterReq struct {
+	MaintenanceInstanceID string   `json:"maintenance_instance_id"`
+	Sector                string   `json:"sector"`
+	Statuses              []string `json:"statuses"`
+}
+
+type UpdateRequisitionStatusReq struct {
+	ID     string `json:"id" binding:"required"`
+	Status string `json:"status" binding:"required"`
+}
+
+// StockLineReq is a quantity of a catalog material, in Unit or in the default
+// unit of the material when Unit is empty.
+type StockLineReq struct {
+	CatalogID string  `json:"catalog_id" binding:"required"`
+	Quantity  float64 `json:"quantity"`
+	Unit      string  `json:"unit"`
+}
+
+type StockReceiptReq struct {
+	Warehouse string         `json:"warehouse" binding:"required"`
+	Lines     []StockLineReq `json:"lines" binding:"required,dive"`
+	// PurchaseRequisitionID is the requisition the materials were ordered with
+	PurchaseRequisitionID string `json:"purchase_requisition_id"`
+	Note                  string `json:"note"`
+}
+
+// StockAdjustmentReq corrects balances, a negative quantity lowers the balance.
+type StockAdjustmentReq struct {
+	Warehouse string         `json:"warehouse" binding:"required"`
+	Lines     []StockLineReq `json:"lines" binding:"required,dive"`
+	Note      string         `json:"note"`
+}
+
+// RequestLineReq is a quantity of a line of a material request, in the unit
+// of the line. Name is the key of the line. Replacement materials may be
+// tracked by serial numbers, one per piece, or by lot; the serials of a
+// return are those of the pieces taken back.
+type RequestLineReq struct {
+	MaterialsProfileID string  `json:"materials_profile_id" binding:"required"`
+	MaterialType       string  `json:"material_type" binding:"required"`
+	Name               string  `json:"name" binding:"required"`
+	Quantity           float64 `json:"quantity"`
+	PartTracking
+}
+
+type IssueMaterialsReq struct {
+	MaterialRequestID string `json:"material_request_id" binding:"required"`
+	// Warehouse defaults to the warehouse serving the sector of the request
+	Warehouse string `json:"warehouse"`
+	// Lines to issue, every line not issued yet when empty
+	Lines []RequestLineReq `json:"lines" binding:"dive"`
+	Note  string           `json:"note"`
+}
+
+type ReturnMaterialsReq struct {
+	MaterialRequestID string `json:"material_request_id" binding:"required"`
+	// Warehouse defaults to the warehouse serving the sector of the request
+	Warehouse string           `json:"warehouse"`
+	Lines     []RequestLineReq `json:"lines" binding:"required,dive"`
+	Note      string           `json:"note"`
+}
+
+// StockMovementSearchRequest is bound from the query string of the stock ledger endpoint.
+type StockMovementSearchRequest struct {
+	Page              int64  `form:"page"`
+	Limit             int64  `form:"limit"`
+	Kind              string `form:"kind"`
+	Warehouse         string `form:"warehouse"`
+	CatalogID         string `form:"catalog_id"`
+	MaterialRequestID string `form:"material_request_id"`
+	From              int64  `form:"from"`
+	To                int64  `form:"to"`
+}
+
+type CreateWarehouseReq struct {
+	Code              string   `json:"code"`
+	Name              string   `json:"name" binding:"required"`
+	Location          string   `json:"location"`
+	ResponsibleSector string   `json:"responsible_sector"`
+	ServedSectors     []string `json:"served_sectors"`
+	Note              string   `json:"note"`
+}
+
+type UpdateWarehouseReq struct {
+	ID string `json:"id" binding:"required"`
+	CreateWarehouseReq
+}
+
+// WarehouseListRequest is bound from the query string of the warehouse list endpoint.
+type WarehouseListRequest struct {
+	ServedSector string `form:"served_sector"`
+}
+
+type CreateStockTransferReq struct {
+	FromWarehouse string         `json:"from_warehouse" binding:"required"`
+	ToWarehouse   string         `json:"to_warehouse" binding:"required"`
+	Lines         []StockLineReq `json:"lines" binding:"required,dive"`
+	Note          string         `json:"note"`
+}
+
+// StockTransferSearchRequest is bound from the query string of the stock transfer search endpoint.
+type StockTransferSearchRequest struct {
+	Page      int64  `form:"page"`
+	Limit     int64  `form:"limit"`
+	Warehouse string `form:"warehouse"`
+	Status    string `form:"status"`
+	CatalogID string `form:"catalog_id"`
+}
+
+// InstalledPartSearchRequest is bound from the query string of the installed parts endpoint.
+type InstalledPartSearchRequest struct {
+	Page                  int64  `form:"page"`
+	Limit                 int64  `form:"limit"`
+	Serial                string `form:"serial"`
+	EquipmentMachineryID  string `form:"equipment_machinery_id"`
+	MaintenanceInstanceID string `form:"maintenance_instance_id"`
+	ProjectCode           string `form:"project_code"`
+	IncludeReturned       bool   `form:"include_returned"`
 }
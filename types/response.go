@@ -1,5 +1,7 @@
 package types
 
+import "github.com/remiehneppo/material-management/utils"
+
 type Response struct {
 	Status  bool        `json:"status"`
 	Message string      `json:"message"`
@@ -44,6 +46,8 @@ type MaterialRequestResponse struct {
 	RequestedBy           string                                   `json:"requested_by"`
 	RequestedAt           int64                                    `json:"requested_at"`
 	NumOfRequest          int                                      `json:"num_of_request"`
+	// Issued is keyed like MaterialsForEquipment
+	Issued map[string]MaterialsForEquipment `json:"issued,omitempty"`
 }
 
 type MaterialsProfileResponse struct {
@@ -59,3 +63,291 @@ type MaterialsProfileResponse struct {
 	Estimate           MaterialsForEquipment `json:"estimate" bson:"estimate"`
 	Reality            MaterialsForEquipment `json:"reality" bson:"reality"`
 }
+
+type EstimateImportResult struct {
+	SheetName            string `json:"sheet_name" bson:"sheet_name"`
+	Sector               string `json:"sector" bson:"sector"`
+	ImportProfile        string `json:"import_profile" bson:"import_profile"`
+	Equipments           int    `json:"equipments" bson:"equipments"`
+	ReplacementMaterials int    `json:"replacement_materials" bson:"replacement_materials"`
+	ConsumableSupplies   int    `json:"consumable_supplies" bson:"consumable_supplies"`
+	CreatedEquipments    int    `json:"created_equipments" bson:"created_equipments"`
+	CreatedProfiles      int    `json:"created_profiles" bson:"created_profiles"`
+	// CatalogLinks counts the materials linked to the catalog by their normalized name
+	CatalogLinks int `json:"catalog_links" bson:"catalog_links"`
+	// AmbiguousEquipments are the equipment names matching several
+	// equipments, whose materials are not imported
+	AmbiguousEquipments []*AmbiguousEquipment `json:"ambiguous_equipments,omitempty" bson:"ambiguous_equipments,omitempty"`
+	// UploadID is the upload record of the file, Duplicate is set when the
+	// file was imported before and the earlier result is returned
+	UploadID  string `json:"upload_id,omitempty" bson:"-"`
+	Duplicate bool   `json:"duplicate,omitempty" bson:"-"`
+}
+
+// AmbiguousEquipment is an equipment name of an estimate whose normalized
+// form is the name or an alias of more than one equipment of the sector.
+type AmbiguousEquipment struct {
+	Name       string               `json:"name" bson:"name"`
+	Candidates []EquipmentCandidate `json:"candidates" bson:"candidates"`
+}
+
+type EquipmentCandidate struct {
+	ID         string `json:"id" bson:"id"`
+	Name       string `json:"name" bson:"name"`
+	ParentName string `json:"parent_name,omitempty" bson:"parent_name,omitempty"`
+	// Matched is the name or alias of the equipment the estimate name matched
+	Matched string `json:"matched" bson:"matched"`
+}
+
+type EstimateWorkbookImportResult struct {
+	Sheets        []*EstimateImportResult `json:"sheets" bson:"sheets"`
+	SkippedSheets []string                `json:"skipped_sheets" bson:"skipped_sheets"`
+	UploadID      string                  `json:"upload_id,omitempty" bson:"-"`
+	Duplicate     bool                    `json:"duplicate,omitempty" bson:"-"`
+}
+
+// EstimateUploadResponse is an entry of the upload history of a maintenance.
+type EstimateUploadResponse struct {
+	*EstimateUpload
+	DownloadURL string `json:"download_url"`
+}
+
+type VarianceLine struct {
+	MaterialType string  `json:"material_type"`
+	Name         string  `json:"name"`
+	Unit         string  `json:"unit"`
+	Estimate     float64 `json:"estimate"`
+	Reality      float64 `json:"reality"`
+	// Difference is reality minus estimate, positive on overrun
+	Difference float64 `json:"difference"`
+	// Percent is the difference relative to the estimate, zero for unestimated lines
+	Percent     float64 `json:"percent"`
+	Unestimated bool    `json:"unestimated"`
+	// UnitMismatch is set when the reality is in a unit that does not convert
+	// to the unit of the estimate, the line is then not compared
+	UnitMismatch bool   `json:"unit_mismatch,omitempty"`
+	RealityUnit  string `json:"reality_unit,omitempty"`
+	// EstimateCost and RealityCost are only priced on request
+	EstimateCost utils.Decimal `json:"estimate_cost,omitzero"`
+	RealityCost  utils.Decimal `json:"reality_cost,omitzero"`
+}
+
+// VarianceSummary counts the variance lines of a group. Quantities are not
+// added up since the lines are in different units, only their costs are.
+type VarianceSummary struct {
+	Lines            int `json:"lines"`
+	OverrunLines     int `json:"overrun_lines"`
+	UnestimatedLines int `json:"unestimated_lines"`
+	MismatchLines    int `json:"mismatch_lines"`
+	// EstimateCost and RealityCost are only priced on request
+	EstimateCost utils.Decimal `json:"estimate_cost,omitzero"`
+	RealityCost  utils.Decimal `json:"reality_cost,omitzero"`
+}
+
+type EquipmentVariance struct {
+	MaterialsProfileID string          `json:"materials_profile_id"`
+	IndexPath          string          `json:"index_path"`
+	EquipmentMachinery string          `json:"equipment_machinery"`
+	Summary            VarianceSummary `json:"summary"`
+	Lines              []*VarianceLine `json:"lines"`
+}
+
+type SectorVariance struct {
+	Sector     string               `json:"sector"`
+	Summary    VarianceSummary      `json:"summary"`
+	Equipments []*EquipmentVariance `json:"equipments"`
+}
+
+type VarianceReport struct {
+	MaintenanceInstanceID string            `json:"maintenance_instance_id"`
+	Project               string            `json:"project"`
+	ProjectCode           string            `json:"project_code"`
+	MaintenanceTier       string            `json:"maintenance_tier"`
+	MaintenanceNumber     string            `json:"maintenance_number"`
+	Year                  int               `json:"year"`
+	Summary               VarianceSummary   `json:"summary"`
+	Sectors               []*SectorVariance `json:"sectors"`
+}
+
+// MaterialsProfileTreeNode is a level of the index path hierarchy. Nodes
+// without a materials profile only group their children.
+type MaterialsProfileTreeNode struct {
+	IndexPath            string                      `json:"index_path"`
+	MaterialsProfileID   string                      `json:"materials_profile_id,omitempty"`
+	EquipmentMachineryID string                      `json:"equipment_machinery_id,omitempty"`
+	EquipmentMachinery   string                      `json:"equipment_machinery,omitempty"`
+	Summary              VarianceSummary             `json:"summary"`
+	Children             []*MaterialsProfileTreeNode `json:"children"`
+}
+
+type MaterialsProfileTree struct {
+	MaintenanceInstanceID string                      `json:"maintenance_instance_id"`
+	Sector                string                      `json:"sector"`
+	IndexPath             string                      `json:"index_path"`
+	Summary               VarianceSummary             `json:"summary"`
+	Nodes                 []*MaterialsProfileTreeNode `json:"nodes"`
+}
+
+type DuplicateIndexPath struct {
+	IndexPath           string   `json:"index_path"`
+	MaterialsProfileIDs []string `json:"materials_profile_ids"`
+}
+
+type SkippedMaterialsProfile struct {
+	Sector                     string `json:"sector"`
+	IndexPath                  string `json:"index_path"`
+	EquipmentMachinery         string `json:"equipment_machinery"`
+	SourceMaterialsProfileID   string `json:"source_materials_profile_id"`
+	ExistingMaterialsProfileID string `json:"existing_materials_profile_id"`
+	// IndexPathTaken tells that the existing profile holds the index path
+	// rather than a profile of the same equipment
+	IndexPathTaken bool `json:"index_path_taken"`
+}
+
+type CopyEstimatesResult struct {
+	Copied  int                        `json:"copied"`
+	Skipped []*SkippedMaterialsProfile `json:"skipped"`
+}
+
+type MaterialSuggestion struct {
+	MaterialType string `json:"material_type"`
+	Name         string `json:"name"`
+	Unit         string `json:"unit"`
+	// Occurrences is the number of past maintenances where the material was consumed
+	Occurrences int `json:"occurrences"`
+	// Mean, Median and Max count maintenances without consumption as zero
+	Mean      float64 `json:"mean"`
+	Median    float64 `json:"median"`
+	Max       float64 `json:"max"`
+	Suggested float64 `json:"suggested"`
+	// CurrentEstimate is set when suggesting for an existing materials profile
+	CurrentEstimate *float64 `json:"current_estimate,omitempty"`
+}
+
+type EstimateSuggestion struct {
+	EquipmentMachineryID string `json:"equipment_machinery_id"`
+	MaintenanceTier      string `json:"maintenance_tier"`
+	// Maintenances is the number of past maintenances the statistics are based on
+	Maintenances int                   `json:"maintenances"`
+	Materials    []*MaterialSuggestion `json:"materials"`
+}
+
+// NameMatch is a record proposed for a name. Matched is the name or alias of
+// the record that scored best.
+type NameMatch struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Code    string  `json:"code,omitempty"`
+	Sector  string  `json:"sector,omitempty"`
+	Matched string  `json:"matched"`
+	Score   float64 `json:"score"`
+}
+
+type NameSuggestions struct {
+	Name    string       `json:"name"`
+	Matches []*NameMatch `json:"matches"`
+}
+
+// CreateMaterialRequestResult is a created material request with the catalog
+// materials proposed for the names of its lines that reference none.
+type CreateMaterialRequestResult struct {
+	ID                 string             `json:"id"`
+	CatalogSuggestions []*NameSuggestions `json:"catalog_suggestions"`
+}
+
+// DuplicateGroup gathers records whose names likely denote the same thing.
+// Score is the best similarity between two records of the group.
+type DuplicateGroup struct {
+	Items []*NameMatch `json:"items"`
+	Score float64      `json:"score"`
+}
+
+type MergeCatalogMaterialsResult struct {
+	MergedMaterials int `json:"merged_materials"`
+	UpdatedProfiles int `json:"updated_profiles"`
+	UpdatedRequests int `json:"updated_requests"`
+	// UpdatedSuppliers supplied a merged material and now supply the target
+	UpdatedSuppliers int `json:"updated_suppliers"`
+}
+
+// EquipmentNode is an equipment with its children, in order.
+type EquipmentNode struct {
+	*EquipmentMachinery
+	Children []*EquipmentNode `json:"children"`
+}
+
+type MergeEquipmentMachineriesResult struct {
+	MergedEquipments int   `json:"merged_equipments"`
+	UpdatedProfiles  int64 `json:"updated_profiles"`
+}
+
+// CostSummary totals the cost of material lines. Unpriced lines have neither
+// a unit price nor a catalog price at the date, or a unit that does not
+// convert to the unit of the price, and are left out of the total.
+type CostSummary struct {
+	Total         utils.Decimal `json:"total"`
+	PricedLines   int           `json:"priced_lines"`
+	UnpricedLines int           `json:"unpriced_lines"`
+}
+
+type CostLine struct {
+	MaterialType string        `json:"material_type"`
+	Name         string        `json:"name"`
+	Unit         string        `json:"unit"`
+	Quantity     float64       `json:"quantity"`
+	UnitPrice    utils.Decimal `json:"unit_price"`
+	Cost         utils.Decimal `json:"cost"`
+	Priced       bool          `json:"priced"`
+}
+
+type ProfileCost struct {
+	MaterialsProfileID string      `json:"materials_profile_id"`
+	IndexPath          string      `json:"index_path"`
+	EquipmentMachinery string      `json:"equipment_machinery"`
+	Sector             string      `json:"sector"`
+	Estimate           CostSummary `json:"estimate"`
+	Reality            CostSummary `json:"reality"`
+	// Lines are only listed for a single profile
+	EstimateLines []*CostLine `json:"estimate_lines,omitempty"`
+	RealityLines  []*CostLine `json:"reality_lines,omitempty"`
+}
+
+type RequestEquipmentCost struct {
+	MaterialsProfileID string      `json:"materials_profile_id"`
+	EquipmentMachinery string      `json:"equipment_machinery"`
+	Summary            CostSummary `json:"summary"`
+	Lines              []*CostLine `json:"lines,omitempty"`
+}
+
+type RequestCost struct {
+	MaterialRequestID string                  `json:"material_request_id"`
+	NumOfRequest      int                     `json:"num_of_request"`
+	Sector            string                  `json:"sector"`
+	RequestedAt       int64                   `json:"requested_at"`
+	Summary           CostSummary             `json:"summary"`
+	Equipments        []*RequestEquipmentCost `json:"equipments,omitempty"`
+}
+
+type SectorCost struct {
+	Sector   string      `json:"sector"`
+	Estimate CostSummary `json:"estimate"`
+	Reality  CostSummary `json:"reality"`
+	// Requested totals the material requests of the sector
+	Requested  CostSummary    `json:"requested"`
+	Equipments []*ProfileCost `json:"equipments"`
+}
+
+type CostReport struct {
+	MaintenanceInstanceID string         `json:"maintenance_instance_id"`
+	Project               string         `json:"project"`
+	ProjectCode           string         `json:"project_code"`
+	MaintenanceTier       string         `json:"maintenance_tier"`
+	MaintenanceNumber     string         `json:"maintenance_number"`
+	Year                  int            `json:"year"`
+	PricedAt              int64          `json:"priced_at"`
+	Estimate              CostSummary    `json:"estimate"`
+	Reality               CostSummary    `json:"reality"`
+	Requested             CostSummary    `json:"requested"`
+	Sectors               []*SectorCost  `json:"sectors"`
+	Requests              []*RequestCost `json:"requests"`
+}
@@ -1,5 +1,7 @@
 package types
 
+import "github.com/remiehneppo/material-management/utils"
+
 const (
 	USER_ROLE_ADMIN = "admin"
 )
@@ -56,12 +58,31 @@ type EquipmentMachinery struct {
 	ID     string `json:"id" bson:"_id,omitempty"`
 	Name   string `json:"name" bson:"name"`
 	Sector string `json:"sector" bson:"sector"`
+	// ParentID is the system or assembly the equipment is part of, empty
+	// for a ship system
+	ParentID string `json:"parent_id,omitempty" bson:"parent_id"`
+	// Order sorts the equipment among its siblings
+	Order        int64  `json:"order" bson:"order"`
+	Model        string `json:"model,omitempty" bson:"model"`
+	Manufacturer string `json:"manufacturer,omitempty" bson:"manufacturer"`
+	// RatedSpecs are the rated characteristics, e.g. "500 kW, 1500 vòng/phút"
+	RatedSpecs string `json:"rated_specs,omitempty" bson:"rated_specs"`
+	// Specs is the free-form spec sheet, e.g. "Điện áp": "380 V"
+	Specs map[string]string `json:"specs,omitempty" bson:"specs"`
+	// Aliases are other names the equipment is known by in estimates,
+	// matched by the importer as its name is
+	Aliases []string `json:"aliases,omitempty" bson:"aliases"`
 }
 
 type Material struct {
 	Name     string  `json:"name" bson:"name"`
 	Unit     string  `json:"unit" bson:"unit"`
 	Quantity float64 `json:"quantity" bson:"quantity"`
+	// CatalogID references the catalog entry of the material, Name is kept for display
+	CatalogID string `json:"catalog_id,omitempty" bson:"catalog_id,omitempty"`
+	// UnitPrice is the price of one Unit agreed for this line, it takes
+	// precedence over the catalog price
+	UnitPrice utils.Decimal `json:"unit_price,omitzero" bson:"unit_price,omitempty"`
 }
 
 type MaterialsForEquipment struct {
@@ -75,6 +96,10 @@ type Maintenance struct {
 	MaintenanceTier   string `json:"maintenance_tier" bson:"maintenance_tier"`
 	MaintenanceNumber string `json:"maintenance_number" bson:"maintenance_number"`
 	Year              int    `json:"year" bson:"year"`
+	// StartDate is the day the ship arrives at the yard, when its materials
+	// are needed, and EndDate the day it leaves. Zero when not scheduled.
+	StartDate int64 `json:"start_date" bson:"start_date"`
+	EndDate   int64 `json:"end_date" bson:"end_date"`
 }
 
 type MaterialsProfile struct {
@@ -85,6 +110,9 @@ type MaterialsProfile struct {
 	Sector                string                `json:"sector" bson:"sector"`
 	Estimate              MaterialsForEquipment `json:"estimate" bson:"estimate"`
 	Reality               MaterialsForEquipment `json:"reality" bson:"reality"`
+	// Archived profiles are hidden from filters unless asked for
+	Archived   bool  `json:"archived" bson:"archived"`
+	ArchivedAt int64 `json:"archived_at" bson:"archived_at"`
 }
 
 type MaterialRequest struct {
@@ -96,31 +124,471 @@ type MaterialRequest struct {
 	MaterialsForEquipment map[string]MaterialsForEquipment `json:"materials_for_equipment" bson:"materials_for_equipment"`
 	RequestedBy           string                           `json:"requested_by" bson:"requested_by"`
 	RequestedAt           int64                            `json:"requested_at" bson:"requested_at"`
+	// Issued mirrors MaterialsForEquipment with the quantities issued from the
+	// warehouse, net of returns, in the unit of the requested line
+	Issued map[string]MaterialsForEquipment `json:"issued,omitempty" bson:"issued,omitempty"`
+}
+
+// EstimateUpload records an uploaded estimate file, the options it was
+// imported with and the outcome of the import.
+type EstimateUpload struct {
+	ID                    string `json:"id" bson:"_id,omitempty"`
+	MaintenanceInstanceID string `json:"maintenance_instance_id" bson:"maintenance_instance_id"`
+	Kind                  string `json:"kind" bson:"kind"`
+	FileName              string `json:"file_name" bson:"file_name"`
+	FilePath              string `json:"-" bson:"file_path"`
+	// ContentHash is the hex encoded SHA-256 of the file content
+	ContentHash     string            `json:"content_hash" bson:"content_hash"`
+	Size            int64             `json:"size" bson:"size"`
+	SheetName       string            `json:"sheet_name,omitempty" bson:"sheet_name,omitempty"`
+	Sector          string            `json:"sector,omitempty" bson:"sector,omitempty"`
+	SheetSectors    map[string]string `json:"sheet_sectors,omitempty" bson:"sheet_sectors,omitempty"`
+	ImportProfileID string            `json:"import_profile_id,omitempty" bson:"import_profile_id,omitempty"`
+	// DuplicateOf is the earlier upload of the same file when it was re-imported
+	DuplicateOf    string                        `json:"duplicate_of,omitempty" bson:"duplicate_of,omitempty"`
+	Result         *EstimateImportResult         `json:"result,omitempty" bson:"result,omitempty"`
+	WorkbookResult *EstimateWorkbookImportResult `json:"workbook_result,omitempty" bson:"workbook_result,omitempty"`
+	// Error is the reason a failed import was rejected
+	Error      string `json:"error,omitempty" bson:"error,omitempty"`
+	UploadedBy string `json:"uploaded_by" bson:"uploaded_by"`
+	UploadedAt int64  `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+// ImportJob is the state of an estimate import running in the background.
+// TotalRows grows as the sheets of a workbook are read.
+type ImportJob struct {
+	ID                    string   `json:"id"`
+	Kind                  string   `json:"kind"`
+	MaintenanceInstanceID string   `json:"maintenance_instance_id"`
+	FileName              string   `json:"file_name"`
+	Status                string   `json:"status"`
+	TotalRows             int      `json:"total_rows"`
+	ProcessedRows         int      `json:"processed_rows"`
+	Warnings              []string `json:"warnings"`
+	// Committing is set once the job started writing, it can no longer be cancelled
+	Committing bool `json:"committing,omitempty"`
+	// Result is the EstimateImportResult or EstimateWorkbookImportResult of
+	// a succeeded job
+	Result     any    `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+	CreatedBy  string `json:"created_by"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+	FinishedAt int64  `json:"finished_at,omitempty"`
+}
+
+// MaterialConsumption is the reality of a material for one equipment with
+// one quantity per past maintenance where it was consumed.
+type MaterialConsumption struct {
+	MaterialType string    `json:"material_type" bson:"material_type"`
+	Name         string    `json:"name" bson:"name"`
+	Unit         string    `json:"unit" bson:"unit"`
+	Quantities   []float64 `json:"quantities" bson:"quantities"`
+}
+
+// ConsumptionHistory is the past reality of an equipment over the
+// maintenances where it had a materials profile.
+type ConsumptionHistory struct {
+	MaintenanceInstanceIDs []string               `json:"maintenance_instance_ids"`
+	Materials              []*MaterialConsumption `json:"materials"`
+}
+
+// ImportProfile describes the layout of an estimate workbook so that sheets
+// from different technical offices can be imported without reformatting.
+type ImportProfile struct {
+	ID          string `json:"id" bson:"_id,omitempty"`
+	Name        string `json:"name" bson:"name"`
+	Description string `json:"description" bson:"description"`
+	// HeaderRow is the 1-based row number of the header, data starts right
+	// after it, 0 when the sheet has no header
+	HeaderRow      int    `json:"header_row" bson:"header_row"`
+	IndexColumn    string `json:"index_column" bson:"index_column"`
+	TitleColumn    string `json:"title_column" bson:"title_column"`
+	UnitColumn     string `json:"unit_column" bson:"unit_column"`
+	QuantityColumn string `json:"quantity_column" bson:"quantity_column"`
+	// ReplacementLabels and ConsumableLabels are case-insensitive regular expressions
+	// matched against the title cell to detect the start of a section
+	ReplacementLabels []string `json:"replacement_labels" bson:"replacement_labels"`
+	ConsumableLabels  []string `json:"consumable_labels" bson:"consumable_labels"`
+	// MaterialMarker is the value of the index cell on material rows
+	MaterialMarker string `json:"material_marker" bson:"material_marker"`
+	// HeaderKeywords are texts expected in the header row, used to auto-detect the profile
+	HeaderKeywords []string `json:"header_keywords" bson:"header_keywords"`
+}
+
+// CatalogMaterial is an entry of the material master catalog. Aliases are
+// other names the material is known by in estimates and requests.
+type CatalogMaterial struct {
+	ID            string   `json:"id" bson:"_id,omitempty"`
+	Code          string   `json:"code" bson:"code"`
+	Name          string   `json:"name" bson:"name"`
+	Specification string   `json:"specification" bson:"specification"`
+	DefaultUnit   string   `json:"default_unit" bson:"default_unit"`
+	Category      string   `json:"category" bson:"category"`
+	Aliases       []string `json:"aliases" bson:"aliases"`
+	// PackSizes are the number of default units in a pack unit, e.g. {"hộp": 50}
+	PackSizes map[string]float64 `json:"pack_sizes,omitempty" bson:"pack_sizes,omitempty"`
+	// NormalizedNames are the name and aliases as given by utils.NormalizeName
+	NormalizedNames []string `json:"-" bson:"normalized_names"`
+	CreatedAt       int64    `json:"created_at" bson:"created_at"`
+	UpdatedAt       int64    `json:"updated_at" bson:"updated_at"`
+}
+
+// CatalogPrice is a dated price of a catalog material per default unit. The
+// price of a date is the latest one effective from that date or before.
+type CatalogPrice struct {
+	ID            string        `json:"id" bson:"_id,omitempty"`
+	CatalogID     string        `json:"catalog_id" bson:"catalog_id"`
+	Price         utils.Decimal `json:"price" bson:"price"`
+	EffectiveFrom int64         `json:"effective_from" bson:"effective_from"`
+	Note          string        `json:"note" bson:"note"`
+	CreatedBy     string        `json:"created_by" bson:"created_by"`
+	CreatedAt     int64         `json:"created_at" bson:"created_at"`
+}
+
+type SupplierContact struct {
+	Name  string `json:"name" bson:"name"`
+	Role  string `json:"role" bson:"role"`
+	Phone string `json:"phone" bson:"phone"`
+	Email string `json:"email" bson:"email"`
+}
+
+// Supplier is a vendor of catalog materials. A material is purchased from
+// the supplier preferred for it, otherwise from the supplier with the
+// shortest lead time among those supplying it.
+type Supplier struct {
+	ID       string            `json:"id" bson:"_id,omitempty"`
+	Name     string            `json:"name" bson:"name"`
+	Address  string            `json:"address" bson:"address"`
+	TaxCode  string            `json:"tax_code" bson:"tax_code"`
+	Contacts []SupplierContact `json:"contacts" bson:"contacts"`
+	// CatalogIDs are the catalog materials the supplier supplies
+	CatalogIDs []string `json:"catalog_ids" bson:"catalog_ids"`
+	// PreferredFor are the catalog materials purchased from this supplier first,
+	// a subset of CatalogIDs
+	PreferredFor []string `json:"preferred_for" bson:"preferred_for"`
+	// LeadTimeDays is the usual number of days between order and delivery
+	LeadTimeDays int    `json:"lead_time_days" bson:"lead_time_days"`
+	Note         string `json:"note" bson:"note"`
+	CreatedAt    int64  `json:"created_at" bson:"created_at"`
+	UpdatedAt    int64  `json:"updated_at" bson:"updated_at"`
+}
+
+// Warehouse is a store of the yard, the central store or the dock-side store
+// of a workshop. Material requests of the ServedSectors are issued from it
+// unless another warehouse is given; a sector is served by one warehouse.
+type Warehouse struct {
+	ID       string `json:"id" bson:"_id,omitempty"`
+	Code     string `json:"code" bson:"code"`
+	Name     string `json:"name" bson:"name"`
+	Location string `json:"location" bson:"location"`
+	// ResponsibleSector keeps the warehouse, empty for the central store
+	ResponsibleSector string   `json:"responsible_sector" bson:"responsible_sector"`
+	ServedSectors     []string `json:"served_sectors" bson:"served_sectors"`
+	Note              string   `json:"note" bson:"note"`
+	CreatedAt         int64    `json:"created_at" bson:"created_at"`
+	UpdatedAt         int64    `json:"updated_at" bson:"updated_at"`
+}
+
+// StockBalance is the quantity on hand of a catalog material in a warehouse,
+// in the default unit of the catalog material. Warehouse is the warehouse ID.
+type StockBalance struct {
+	ID        string  `json:"id" bson:"_id,omitempty"`
+	CatalogID string  `json:"catalog_id" bson:"catalog_id"`
+	Warehouse string  `json:"warehouse" bson:"warehouse"`
+	Quantity  float64 `json:"quantity" bson:"quantity"`
+	Unit      string  `json:"unit" bson:"unit"`
+	UpdatedBy string  `json:"updated_by" bson:"updated_by"`
+	UpdatedAt int64   `json:"updated_at" bson:"updated_at"`
+}
+
+// StockMovementLine moves a catalog material. Quantity is in Unit, as
+// entered or as requested, and Delta is the change of the balance in the
+// default unit of the catalog material.
+type StockMovementLine struct {
+	CatalogID string  `json:"catalog_id" bson:"catalog_id"`
+	Name      string  `json:"name" bson:"name"`
+	Unit      string  `json:"unit" bson:"unit"`
+	Quantity  float64 `json:"quantity" bson:"quantity"`
+	Delta     float64 `json:"delta" bson:"delta"`
+	// MaterialsProfileID, MaterialType and MaterialKey locate the request line
+	// an issue or a return is made against
+	MaterialsProfileID string `json:"materials_profile_id,omitempty" bson:"materials_profile_id,omitempty"`
+	MaterialType       string `json:"material_type,omitempty" bson:"material_type,omitempty"`
+	MaterialKey        string `json:"material_key,omitempty" bson:"material_key,omitempty"`
+	PartTracking       `bson:",inline"`
+}
+
+// PartTracking identifies replacement parts for warranty and for the next
+// maintenance: one serial number per piece, or the lot they come from.
+type PartTracking struct {
+	Serials      []string `json:"serials,omitempty" bson:"serials,omitempty"`
+	Lot          string   `json:"lot,omitempty" bson:"lot,omitempty"`
+	Manufacturer string   `json:"manufacturer,omitempty" bson:"manufacturer,omitempty"`
+	Certificate  string   `json:"certificate,omitempty" bson:"certificate,omitempty"`
+}
+
+// InstalledPart is a replacement part issued against a material request and
+// fitted to an equipment of a ship: one piece with its serial number, or the
+// quantity of a line tracked by lot only. Ship and equipment are copied from
+// the request so that the part can be found by either.
+type InstalledPart struct {
+	ID           string  `json:"id" bson:"_id,omitempty"`
+	CatalogID    string  `json:"catalog_id" bson:"catalog_id"`
+	Name         string  `json:"name" bson:"name"`
+	Unit         string  `json:"unit" bson:"unit"`
+	Quantity     float64 `json:"quantity" bson:"quantity"`
+	Serial       string  `json:"serial,omitempty" bson:"serial,omitempty"`
+	Lot          string  `json:"lot,omitempty" bson:"lot,omitempty"`
+	Manufacturer string  `json:"manufacturer,omitempty" bson:"manufacturer,omitempty"`
+	Certificate  string  `json:"certificate,omitempty" bson:"certificate,omitempty"`
+	// the ship the part was fitted to
+	MaintenanceInstanceID string `json:"maintenance_instance_id" bson:"maintenance_instance_id"`
+	Project               string `json:"project" bson:"project"`
+	ProjectCode           string `json:"project_code" bson:"project_code"`
+	// the equipment the part was fitted to
+	MaterialsProfileID   string `json:"materials_profile_id" bson:"materials_profile_id"`
+	EquipmentMachineryID string `json:"equipment_machinery_id" bson:"equipment_machinery_id"`
+	EquipmentName        string `json:"equipment_name" bson:"equipment_name"`
+	Sector               string `json:"sector" bson:"sector"`
+	MaterialRequestID    string `json:"material_request_id" bson:"material_request_id"`
+	NumOfRequest         int    `json:"num_of_request" bson:"num_of_request"`
+	IssueMovementID      string `json:"issue_movement_id" bson:"issue_movement_id"`
+	IssuedBy             string `json:"issued_by" bson:"issued_by"`
+	IssuedAt             int64  `json:"issued_at" bson:"issued_at"`
+	// ReturnMovementID and ReturnedAt are set when the piece was returned to stock
+	ReturnMovementID string `json:"return_movement_id,omitempty" bson:"return_movement_id,omitempty"`
+	ReturnedAt       int64  `json:"returned_at,omitempty" bson:"returned_at,omitempty"`
+}
+
+// StockMovement is an entry of the stock ledger: a receipt, an issue or a
+// return against a numbered material request, an adjustment, or one side of
+// a transfer. Balances are the sum of the deltas of the movements.
+type StockMovement struct {
+	ID                    string              `json:"id" bson:"_id,omitempty"`
+	Kind                  string              `json:"kind" bson:"kind"`
+	Warehouse             string              `json:"warehouse" bson:"warehouse"`
+	MaterialRequestID     string              `json:"material_request_id,omitempty" bson:"material_request_id,omitempty"`
+	NumOfRequest          int                 `json:"num_of_request,omitempty" bson:"num_of_request,omitempty"`
+	PurchaseRequisitionID string              `json:"purchase_requisition_id,omitempty" bson:"purchase_requisition_id,omitempty"`
+	TransferID            string              `json:"transfer_id,omitempty" bson:"transfer_id,omitempty"`
+	Lines                 []StockMovementLine `json:"lines" bson:"lines"`
+	// CatalogIDs are the catalog materials of the lines, to filter on
+	CatalogIDs []string `json:"-" bson:"catalog_ids"`
+	Note       string   `json:"note" bson:"note"`
+	CreatedBy  string   `json:"created_by" bson:"created_by"`
+	CreatedAt  int64    `json:"created_at" bson:"created_at"`
+}
+
+// StockTransfer moves materials between two warehouses. Shipping takes them
+// out of FromWarehouse and they are in transit until received into
+// ToWarehouse, or back into FromWarehouse when the transfer is cancelled.
+// The deltas of the lines are positive.
+type StockTransfer struct {
+	ID            string              `json:"id" bson:"_id,omitempty"`
+	Number        int                 `json:"number" bson:"number"`
+	FromWarehouse string              `json:"from_warehouse" bson:"from_warehouse"`
+	ToWarehouse   string              `json:"to_warehouse" bson:"to_warehouse"`
+	Status        string              `json:"status" bson:"status"`
+	Lines         []StockMovementLine `json:"lines" bson:"lines"`
+	// CatalogIDs are the catalog materials of the lines, to filter on
+	CatalogIDs []string `json:"-" bson:"catalog_ids"`
+	Note       string   `json:"note" bson:"note"`
+	CreatedBy  string   `json:"created_by" bson:"created_by"`
+	CreatedAt  int64    `json:"created_at" bson:"created_at"`
+	// ClosedBy and ClosedAt record the receipt or the cancellation
+	ClosedBy string `json:"closed_by,omitempty" bson:"closed_by,omitempty"`
+	ClosedAt int64  `json:"closed_at,omitempty" bson:"closed_at,omitempty"`
+}
+
+// RequisitionLine is a material to purchase. Quantities are in Unit, the
+// default unit of the catalog material when the line references one.
+type RequisitionLine struct {
+	CatalogID string `json:"catalog_id,omitempty" bson:"catalog_id,omitempty"`
+	Code      string `json:"code" bson:"code"`
+	Name      string `json:"name" bson:"name"`
+	Unit      string `json:"unit" bson:"unit"`
+	// Remaining is the estimate not consumed yet
+	Remaining float64 `json:"remaining" bson:"remaining"`
+	// Stock is the part of the quantity on hand and in transit, in the
+	// warehouses the requisition was made for or in every warehouse, counted
+	// against this line. Stock other open requisitions counted on is left out.
+	Stock float64 `json:"stock" bson:"stock"`
+	// OnOrder is the quantity of earlier open requisitions of the maintenance
+	OnOrder  float64 `json:"on_order" bson:"on_order"`
+	Quantity float64 `json:"quantity" bson:"quantity"`
+}
+
+// RequisitionGroup is the part of a purchase requisition ordered from one
+// supplier. SupplierID is empty for the materials no supplier supplies.
+type RequisitionGroup struct {
+	SupplierID   string            `json:"supplier_id" bson:"supplier_id"`
+	SupplierName string            `json:"supplier_name" bson:"supplier_name"`
+	LeadTimeDays int               `json:"lead_time_days" bson:"lead_time_days"`
+	Lines        []RequisitionLine `json:"lines" bson:"lines"`
+}
+
+// PurchaseRequisition is the list of materials to purchase for a
+// maintenance, the remaining estimate minus the stock on hand and the
+// quantities already requisitioned, grouped by supplier.
+type PurchaseRequisition struct {
+	ID                    string `json:"id" bson:"_id,omitempty"`
+	MaintenanceInstanceID string `json:"maintenance_instance_id" bson:"maintenance_instance_id"`
+	Number                int    `json:"number" bson:"number"`
+	Sector                string `json:"sector" bson:"sector"`
+	// Warehouses are those whose stock was deducted, every warehouse when empty
+	Warehouses []string           `json:"warehouses,omitempty" bson:"warehouses,omitempty"`
+	Status     string             `json:"status" bson:"status"`
+	Groups     []RequisitionGroup `json:"groups" bson:"groups"`
+	Note       string             `json:"note" bson:"note"`
+	CreatedBy  string             `json:"created_by" bson:"created_by"`
+	CreatedAt  int64              `json:"created_at" bson:"created_at"`
+	UpdatedBy  string             `json:"updated_by" bson:"updated_by"`
+	UpdatedAt  int64              `json:"updated_at" bson:"updated_at"`
+}
+
+// ShortageForecast lists the materials upcoming maintenances will be short
+// of: their remaining estimate minus the stock and the open purchase
+// quantities.
+type ShortageForecast struct {
+	Maintenances []*Maintenance `json:"maintenances"`
+	Lines        []ShortageLine `json:"lines"`
+	CreatedAt    int64          `json:"created_at"`
+}
+
+// ShortageLine is a material a maintenance will be short of. The stock is
+// allocated to the maintenances needed first; the quantities on order are
+// those of the open requisitions of the maintenance.
+type ShortageLine struct {
+	MaintenanceInstanceID string `json:"maintenance_instance_id"`
+	Project               string `json:"project"`
+	ProjectCode           string `json:"project_code"`
+	// NeededBy is the start date of the maintenance, zero when not scheduled
+	NeededBy  int64   `json:"needed_by"`
+	CatalogID string  `json:"catalog_id,omitempty"`
+	Code      string  `json:"code,omitempty"`
+	Name      string  `json:"name"`
+	Unit      string  `json:"unit"`
+	Remaining float64 `json:"remaining"`
+	Stock     float64 `json:"stock"`
+	OnOrder   float64 `json:"on_order"`
+	Shortage  float64 `json:"shortage"`
+	// OrderBy is NeededBy minus the lead time of the preferred supplier
+	SupplierName string `json:"supplier_name,omitempty"`
+	LeadTimeDays int    `json:"lead_time_days,omitempty"`
+	OrderBy      int64  `json:"order_by,omitempty"`
+}
+
+type CatalogMaterialFilter struct {
+	// Query matches part of the code, name, specification or an alias
+	Query    string `json:"query" bson:"query"`
+	Category string `json:"category" bson:"category"`
 }
 
 type MaterialsProfileFilter struct {
 	MaintenanceInstanceIDs []string `json:"maintenance_instance_ids" bson:"maintenance_instance_ids"`
 	EquipmentMachineryIDs  []string `json:"equipment_machinery_ids" bson:"equipment_machinery_ids"`
 	Sector                 string   `json:"sector" bson:"sector"`
+	// IndexFrom and IndexTo bound the encoded index path, both inclusive.
+	// The range is ignored when IndexTo is zero.
+	IndexFrom       int64 `json:"index_from" bson:"index_from"`
+	IndexTo         int64 `json:"index_to" bson:"index_to"`
+	IncludeArchived bool  `json:"include_archived" bson:"include_archived"`
+	// MaterialName matches part of a material name in estimate or reality
+	MaterialName string `json:"material_name" bson:"material_name"`
+	// HasOverrun keeps profiles where some material was consumed beyond its estimate
+	HasOverrun bool `json:"has_overrun" bson:"has_overrun"`
+	NoEstimate bool `json:"no_estimate" bson:"no_estimate"`
+	// CatalogID keeps profiles with a material referencing this catalog entry
+	CatalogID string `json:"catalog_id" bson:"catalog_id"`
+	// SortBy is one of MATERIALS_PROFILE_SORT_LIST, index path when empty
+	SortBy   string `json:"sort_by" bson:"sort_by"`
+	SortDesc bool   `json:"sort_desc" bson:"sort_desc"`
 }
 
 type MaterialRequestFilter struct {
 	MaintenanceInstanceID string `json:"maintenance_instance_id" bson:"maintenance_instance_id"`
 	EquipmentMachineryID  string `json:"equipment_machinery_id" bson:"equipment_machinery_id"`
+	MaterialsProfileID    string `json:"materials_profile_id" bson:"materials_profile_id"`
 	NumOfRequest          int    `json:"num_of_request" bson:"num_of_request"`
 	Sector                string `json:"sector" bson:"sector"`
 	RequestedBy           string `json:"requested_by" bson:"requested_by"`
 	RequestedAtStart      int64  `json:"requested_at_start" bson:"requested_at_start"`
 	RequestedAtEnd        int64  `json:"requested_at_end" bson:"requested_at_end"`
+	// CatalogID keeps requests with a line referencing this catalog entry
+	CatalogID string `json:"catalog_id" bson:"catalog_id"`
 }
 
 type EquipmentMachineryFilter struct {
 	Name   string `json:"name" bson:"name"`
 	Sector string `json:"sector" bson:"sector"`
+	// ParentID keeps the children of an equipment, RootsOnly the ship systems
+	ParentID  string `json:"parent_id" bson:"parent_id"`
+	RootsOnly bool   `json:"roots_only" bson:"roots_only"`
+	// Model, Manufacturer and RatedSpecs match part of the attribute, ignoring case
+	Model        string `json:"model" bson:"model"`
+	Manufacturer string `json:"manufacturer" bson:"manufacturer"`
+	RatedSpecs   string `json:"rated_specs" bson:"rated_specs"`
+	// SpecKey keeps the equipments whose spec sheet has the key, with a value
+	// matching part of SpecValue when set
+	SpecKey   string `json:"spec_key" bson:"spec_key"`
+	SpecValue string `json:"spec_value" bson:"spec_value"`
 }
 
 type MaintenanceFilter struct {
 	ProjectCode       string `json:"project_code" bson:"project_code"`
 	MaintenanceTier   string `json:"maintenance_tier" bson:"maintenance_tier"`
 	MaintenanceNumber string `json:"maintenance_number" bson:"maintenance_number"`
+	Year              int    `json:"year" bson:"year"`
+}
+
+type SupplierFilter struct {
+	// Query matches part of the name or a contact name
+	Query string `json:"query" bson:"query"`
+	// CatalogID keeps the suppliers supplying this catalog material
+	CatalogID string `json:"catalog_id" bson:"catalog_id"`
+}
+
+type StockBalanceFilter struct {
+	CatalogIDs []string `json:"catalog_ids" bson:"catalog_ids"`
+	Warehouse  string   `json:"warehouse" bson:"warehouse"`
+}
+
+type WarehouseFilter struct {
+	// ServedSector keeps the warehouse serving this sector by default
+	ServedSector string `json:"served_sector" bson:"served_sector"`
+}
+
+type StockTransferFilter struct {
+	// Warehouse keeps the transfers from or to this warehouse
+	Warehouse string `json:"warehouse" bson:"warehouse"`
+	Status    string `json:"status" bson:"status"`
+	CatalogID string `json:"catalog_id" bson:"catalog_id"`
+}
+
+type InstalledPartFilter struct {
+	Serials               []string `json:"serials" bson:"serials"`
+	CatalogID             string   `json:"catalog_id" bson:"catalog_id"`
+	EquipmentMachineryID  string   `json:"equipment_machinery_id" bson:"equipment_machinery_id"`
+	MaintenanceInstanceID string   `json:"maintenance_instance_id" bson:"maintenance_instance_id"`
+	ProjectCode           string   `json:"project_code" bson:"project_code"`
+	MaterialRequestID     string   `json:"material_request_id" bson:"material_request_id"`
+	// IncludeReturned keeps the pieces returned to stock
+	IncludeReturned bool `json:"include_returned" bson:"include_returned"`
+}
+
+type PurchaseRequisitionFilter struct {
+	MaintenanceInstanceID string   `json:"maintenance_instance_id" bson:"maintenance_instance_id"`
+	Sector                string   `json:"sector" bson:"sector"`
+	Statuses              []string `json:"statuses" bson:"statuses"`
+}
+
+type StockMovementFilter struct {
+	Kind              string `json:"kind" bson:"kind"`
+	Warehouse         string `json:"warehouse" bson:"warehouse"`
+	CatalogID         string `json:"catalog_id" bson:"catalog_id"`
+	MaterialRequestID string `json:"material_request_id" bson:"material_request_id"`
+	// CreatedFrom and CreatedTo bound the creation time, both inclusive, when not zero
+	CreatedFrom int64 `json:"created_from" bson:"created_from"`
+	CreatedTo   int64 `json:"created_to" bson:"created_to"`
 }
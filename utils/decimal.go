@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// decimalPlaces is the number of fractional digits of a Decimal.
+const decimalPlaces = 2
+
+const decimalScale = 100
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+// Decimal is an exact amount of money with two fractional digits, so that
+// prices and costs are summed without the rounding errors of float64. It is
+// written as a JSON number and stored as a BSON decimal128.
+type Decimal struct {
+	cents int64
+}
+
+// NewDecimal returns the decimal of an integral amount.
+func NewDecimal(value int64) Decimal {
+	return Decimal{cents: value * decimalScale}
+}
+
+// ParseDecimal parses a decimal such as "1250000" or "-12.345", rounding half
+// away from zero to two fractional digits.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	rat, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/") {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, s)
+	}
+	cents, ok := roundRat(rat.Mul(rat, big.NewRat(decimalScale, 1)))
+	if !ok {
+		return Decimal{}, fmt.Errorf("%w: %q is out of range", ErrInvalidDecimal, s)
+	}
+	return Decimal{cents: cents}, nil
+}
+
+// DecimalFromFloat returns the decimal closest to the shortest decimal text
+// of f, so that 0.1 becomes exactly 0.10.
+func DecimalFromFloat(f float64) Decimal {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}
+	}
+	d, _ := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	return d
+}
+
+// roundRat rounds a rational half away from zero to an int64.
+func roundRat(rat *big.Rat) (int64, bool) {
+	num, denom := rat.Num(), rat.Denom()
+	quotient, remainder := new(big.Int).QuoRem(num, denom, new(big.Int))
+	// |remainder| * 2 >= denom rounds away from zero
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(denom) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(num.Sign())))
+	}
+	if !quotient.IsInt64() {
+		return 0, false
+	}
+	return quotient.Int64(), true
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{cents: d.cents + other.cents}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{cents: d.cents - other.cents}
+}
+
+// MulQuantity returns the cost of quantity units at the price d, rounded half
+// away from zero to two fractional digits.
+func (d Decimal) MulQuantity(quantity float64) Decimal {
+	if math.IsNaN(quantity) || math.IsInf(quantity, 0) {
+		return Decimal{}
+	}
+	rat, ok := new(big.Rat).SetString(strconv.FormatFloat(quantity, 'f', -1, 64))
+	if !ok {
+		return Decimal{}
+	}
+	cents, ok := roundRat(rat.Mul(rat, new(big.Rat).SetInt64(d.cents)))
+	if !ok {
+		return Decimal{}
+	}
+	return Decimal{cents: cents}
+}
+
+// Cmp returns -1, 0 or 1 as d is less than, equal to or greater than other.
+func (d Decimal) Cmp(other Decimal) int {
+	switch {
+	case d.cents < other.cents:
+		return -1
+	case d.cents > other.cents:
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) Sign() int {
+	return d.Cmp(Decimal{})
+}
+
+func (d Decimal) IsZero() bool {
+	return d.cents == 0
+}
+
+// Float64 returns the nearest float64, for spreadsheet cells only.
+func (d Decimal) Float64() float64 {
+	return float64(d.cents) / decimalScale
+}
+
+// String formats the decimal without trailing fractional zeros, e.g. "12.5".
+func (d Decimal) String() string {
+	sign := ""
+	cents := d.cents
+	if cents < 0 {
+		sign = "-"
+	}
+	abs := new(big.Int).Abs(big.NewInt(cents))
+	integral, fraction := new(big.Int).QuoRem(abs, big.NewInt(decimalScale), new(big.Int))
+	if fraction.Sign() == 0 {
+		return sign + integral.String()
+	}
+	digits := strings.TrimRight(fmt.Sprintf("%0*d", decimalPlaces, fraction.Int64()), "0")
+	return sign + integral.String() + "." + digits
+}
+
+// Format writes the decimal for printed documents in the Vietnamese
+// convention, dots grouping thousands and a comma before the fraction, e.g.
+// "1.250.000,5".
+func (d Decimal) Format() string {
+	text := strings.TrimPrefix(d.String(), "-")
+	integral, fraction, _ := strings.Cut(text, ".")
+	var grouped strings.Builder
+	for i, digit := range integral {
+		if i > 0 && (len(integral)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(digit)
+	}
+	if d.cents < 0 {
+		text = "-" + grouped.String()
+	} else {
+		text = grouped.String()
+	}
+	if fraction != "" {
+		text += "," + fraction
+	}
+	return text
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a number or a string holding one.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := ParseDecimal(text)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) MarshalBSONValue() (byte, []byte, error) {
+	value, ok := bson.ParseDecimal128FromBigInt(big.NewInt(d.cents), -decimalPlaces)
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: %s", ErrInvalidDecimal, d)
+	}
+	typ, data, err := bson.MarshalValue(value)
+	return byte(typ), data, err
+}
+
+// UnmarshalBSONValue reads a decimal128, and numbers and strings written by
+// hand in the database.
+func (d *Decimal) UnmarshalBSONValue(typ byte, data []byte) error {
+	raw := bson.RawValue{Type: bson.Type(typ), Value: data}
+	switch raw.Type {
+	case bson.TypeNull, bson.TypeUndefined:
+		*d = Decimal{}
+		return nil
+	case bson.TypeDecimal128:
+		value, ok := raw.Decimal128OK()
+		if !ok {
+			return ErrInvalidDecimal
+		}
+		parsed, err := ParseDecimal(value.String())
+		if err != nil {
+			return err
+		}
+		*d = parsed
+	case bson.TypeDouble:
+		*d = DecimalFromFloat(raw.Double())
+	case bson.TypeInt32, bson.TypeInt64:
+		value, _ := raw.AsInt64OK()
+		*d = NewDecimal(value)
+	case bson.TypeString:
+		parsed, err := ParseDecimal(raw.StringValue())
+		if err != nil {
+			return err
+		}
+		*d = parsed
+	default:
+		return fmt.Errorf("%w: cannot read BSON %s", ErrInvalidDecimal, raw.Type)
+	}
+	return nil
+}
+
+// SumDecimals adds up amounts exactly.
+func SumDecimals(values ...Decimal) Decimal {
+	var total Decimal
+	for _, value := range values {
+		total = total.Add(value)
+	}
+	return total
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{text: "1250000", want: "1250000", ok: true},
+		{text: " 12.50 ", want: "12.5", ok: true},
+		{text: "0.005", want: "0.01", ok: true},
+		{text: "-0.005", want: "-0.01", ok: true},
+		{text: "1.25E+3", want: "1250", ok: true},
+		{text: "3/4", ok: false},
+		{text: "abc", ok: false},
+		{text: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.text)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseDecimal(%q) error = %v, want ok %v", tt.text, err, tt.ok)
+			continue
+		}
+		if tt.ok && got.String() != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	// ten times 0.1 is exactly 1, unlike with float64
+	var total Decimal
+	for range 10 {
+		total = total.Add(DecimalFromFloat(0.1))
+	}
+	if total.Cmp(NewDecimal(1)) != 0 {
+		t.Errorf("10 × 0.1 = %s, want 1", total)
+	}
+
+	price, _ := ParseDecimal("15500.25")
+	tests := []struct {
+		quantity float64
+		want     string
+	}{
+		{quantity: 2, want: "31000.5"},
+		{quantity: 0.5, want: "7750.13"},
+		{quantity: 0.333, want: "5161.58"},
+		{quantity: -1, want: "-15500.25"},
+		{quantity: 0, want: "0"},
+	}
+	for _, tt := range tests {
+		if got := price.MulQuantity(tt.quantity).String(); got != tt.want {
+			t.Errorf("%s × %v = %s, want %s", price, tt.quantity, got, tt.want)
+		}
+	}
+	if got := SumDecimals(price, NewDecimal(-500)).Sub(DecimalFromFloat(0.25)).String(); got != "15000" {
+		t.Errorf("sum = %s, want 15000", got)
+	}
+}
+
+func TestDecimalFormat(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "0", want: "0"},
+		{text: "999", want: "999"},
+		{text: "1000", want: "1.000"},
+		{text: "1250000.5", want: "1.250.000,5"},
+		{text: "-123456.75", want: "-123.456,75"},
+	}
+	for _, tt := range tests {
+		d, _ := ParseDecimal(tt.text)
+		if got := d.Format(); got != tt.want {
+			t.Errorf("ParseDecimal(%q).Format() = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalEncoding(t *testing.T) {
+	type line struct {
+		Price Decimal `json:"price" bson:"price"`
+	}
+	price, _ := ParseDecimal("1234.5")
+
+	data, err := json.Marshal(line{Price: price})
+	if err != nil || string(data) != `{"price":1234.5}` {
+		t.Errorf("json.Marshal = %s, %v", data, err)
+	}
+	for _, text := range []string{`{"price":1234.5}`, `{"price":"1234.50"}`} {
+		var decoded line
+		if err := json.Unmarshal([]byte(text), &decoded); err != nil || decoded.Price != price {
+			t.Errorf("json.Unmarshal(%s) = %s, %v", text, decoded.Price, err)
+		}
+	}
+
+	raw, err := bson.Marshal(line{Price: price})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ := bson.Raw(raw).Lookup("price").Type; typ != bson.TypeDecimal128 {
+		t.Errorf("price stored as %s, want decimal128", typ)
+	}
+	var decoded line
+	if err := bson.Unmarshal(raw, &decoded); err != nil || decoded.Price != price {
+		t.Errorf("bson round trip = %s, %v", decoded.Price, err)
+	}
+	// numbers written by hand are read too
+	raw, _ = bson.Marshal(bson.M{"price": 99.9})
+	if err := bson.Unmarshal(raw, &decoded); err != nil || decoded.Price.String() != "99.9" {
+		t.Errorf("bson double = %s, %v", decoded.Price, err)
+	}
+}
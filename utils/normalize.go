@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeName folds a material or equipment name to a comparable form:
+// diacritics are removed (đ becomes d), letters are lower-cased, punctuation
+// and repeated spaces collapse to a single space and numbers are split from
+// the letters they are glued to, so that "Gioăng cao su DN50" and
+// "gioang  cao-su DN 50" both give "gioang cao su dn 50".
+func NormalizeName(name string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name)
+	if err != nil {
+		folded = name
+	}
+
+	var b strings.Builder
+	b.Grow(len(folded))
+	var previous rune
+	space := false
+	for _, r := range folded {
+		switch r {
+		case 'đ', 'Đ':
+			r = 'd'
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			space = b.Len() > 0
+			previous = 0
+			continue
+		}
+		r = unicode.ToLower(r)
+		if previous != 0 && unicode.IsDigit(previous) != unicode.IsDigit(r) {
+			space = true
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+		previous = r
+	}
+	return b.String()
+}
+
+// NameTokens returns the words of the normalized name.
+func NameTokens(name string) []string {
+	return strings.Fields(NormalizeName(name))
+}
+
+// NameSimilarity scores how likely two names denote the same thing, from 0
+// to 1. It averages a token score, where a word may be abbreviated by one of
+// its prefixes ("b. nước" for "bơm nước") but numbers must be equal, with
+// the overlap of letter pairs, which tolerates typos.
+func NameSimilarity(a, b string) float64 {
+	return NormalizedNameSimilarity(NormalizeName(a), NormalizeName(b))
+}
+
+// NormalizedNameSimilarity is NameSimilarity for names already given by
+// NormalizeName, to compare many names without normalizing them each time.
+func NormalizedNameSimilarity(normalizedA, normalizedB string) float64 {
+	if normalizedA == "" || normalizedB == "" {
+		return 0
+	}
+	if normalizedA == normalizedB {
+		return 1
+	}
+	tokens := tokenSimilarity(strings.Fields(normalizedA), strings.Fields(normalizedB))
+	letters := bigramSimilarity(strings.ReplaceAll(normalizedA, " ", ""), strings.ReplaceAll(normalizedB, " ", ""))
+	return (tokens + letters) / 2
+}
+
+// tokenSimilarity pairs every token of the shorter list with its best unused
+// token of the other and returns the Dice coefficient of the weighted pairs.
+func tokenSimilarity(a, b []string) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	used := make([]bool, len(b))
+	matched := 0.0
+	for _, tokenA := range a {
+		best, bestIndex := 0.0, -1
+		for i, tokenB := range b {
+			if used[i] {
+				continue
+			}
+			if score := tokenScore(tokenA, tokenB); score > best {
+				best, bestIndex = score, i
+			}
+		}
+		if bestIndex >= 0 {
+			used[bestIndex] = true
+			matched += best
+		}
+	}
+	return 2 * matched / float64(len(a)+len(b))
+}
+
+func tokenScore(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if isNumber(a) || isNumber(b) {
+		return 0
+	}
+	short, long := a, b
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	if !strings.HasPrefix(long, short) {
+		return 0
+	}
+	if len(short) == 1 {
+		return 0.6
+	}
+	return 0.8
+}
+
+func isNumber(token string) bool {
+	for _, r := range token {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// bigramSimilarity is the Dice coefficient of the letter pairs of a and b.
+func bigramSimilarity(a, b string) float64 {
+	bigramsA, bigramsB := bigrams(a), bigrams(b)
+	if len(bigramsA) == 0 || len(bigramsB) == 0 {
+		if a == b {
+			return 1
+		}
+		return 0
+	}
+	counts := make(map[string]int, len(bigramsA))
+	for _, bigram := range bigramsA {
+		counts[bigram]++
+	}
+	common := 0
+	for _, bigram := range bigramsB {
+		if counts[bigram] > 0 {
+			counts[bigram]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(bigramsA)+len(bigramsB))
+}
+
+func bigrams(s string) []string {
+	letters := []rune(s)
+	if len(letters) < 2 {
+		return nil
+	}
+	result := make([]string, 0, len(letters)-1)
+	for i := 0; i < len(letters)-1; i++ {
+		result = append(result, string(letters[i:i+2]))
+	}
+	return result
+}
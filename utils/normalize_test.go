@@ -0,0 +1,76 @@
+package utils
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "", want: ""},
+		{name: "  Bơm   nước  ", want: "bom nuoc"},
+		{name: "Gioăng cao su DN50", want: "gioang cao su dn 50"},
+		{name: "gioăng cao-su DN 50", want: "gioang cao su dn 50"},
+		{name: "ĐỘNG CƠ ĐIỆN", want: "dong co dien"},
+		{name: "Bu lông M12x1.5", want: "bu long m 12 x 1 5"},
+		{name: "B.nước (biển)", want: "b nuoc bien"},
+		{name: "Dầu, mỡ; nhớt.", want: "dau mo nhot"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeName(tt.name); got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	t.Run("variants are identical", func(t *testing.T) {
+		pairs := [][2]string{
+			{"Gioăng cao su DN50", "gioăng cao su DN 50"},
+			{"Van xả", "VAN  XẢ"},
+			{"Động cơ điện", "dong co dien"},
+		}
+		for _, pair := range pairs {
+			if got := NameSimilarity(pair[0], pair[1]); got != 1 {
+				t.Errorf("NameSimilarity(%q, %q) = %v, want 1", pair[0], pair[1], got)
+			}
+		}
+	})
+
+	t.Run("empty names never match", func(t *testing.T) {
+		if got := NameSimilarity("", ""); got != 0 {
+			t.Errorf("NameSimilarity of empty names = %v, want 0", got)
+		}
+	})
+
+	t.Run("is symmetric", func(t *testing.T) {
+		a, b := "B. nước biển", "Bơm nước biển làm mát"
+		if NameSimilarity(a, b) != NameSimilarity(b, a) {
+			t.Errorf("NameSimilarity(%q, %q) = %v but reversed %v", a, b, NameSimilarity(a, b), NameSimilarity(b, a))
+		}
+	})
+
+	// each case lists a name, a closer candidate and a farther one
+	rankings := []struct {
+		name, closer, farther string
+	}{
+		{name: "B. nước", closer: "Bơm nước", farther: "Bình nước nóng lạnh"},
+		{name: "Gioăng cao su DN50", closer: "Gioang cao su DN 50 mm", farther: "Gioăng cao su DN65"},
+		{name: "Vòng bi 6205", closer: "Vong bi 6205 2RS", farther: "Vòng bi 6305"},
+		{name: "Máy phát điện", closer: "May phat dien so 1", farther: "Máy nén khí"},
+		{name: "Bơm dầu", closer: "Bơm dầu nhờn", farther: "Bơm nước"},
+	}
+	for _, tt := range rankings {
+		closer, farther := NameSimilarity(tt.name, tt.closer), NameSimilarity(tt.name, tt.farther)
+		if closer <= farther {
+			t.Errorf("NameSimilarity(%q): %q scored %v, not above %q with %v", tt.name, tt.closer, closer, tt.farther, farther)
+		}
+	}
+
+	t.Run("different sizes stay below a match", func(t *testing.T) {
+		if got := NameSimilarity("Gioăng cao su DN50", "Gioăng cao su DN65"); got >= 0.9 {
+			t.Errorf("NameSimilarity of different sizes = %v, want below 0.9", got)
+		}
+	})
+}
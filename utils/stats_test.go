@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		mean   float64
+		median float64
+		max    float64
+		p75    float64
+	}{
+		{name: "empty", values: nil},
+		{name: "single", values: []float64{4}, mean: 4, median: 4, max: 4, p75: 4},
+		{name: "odd", values: []float64{3, 1, 2}, mean: 2, median: 2, max: 3, p75: 2.5},
+		{name: "even", values: []float64{4, 1, 3, 2}, mean: 2.5, median: 2.5, max: 4, p75: 3.25},
+		{name: "with zeros", values: []float64{0, 0, 10, 6}, mean: 4, median: 3, max: 10, p75: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := func(label string, got, want float64) {
+				if math.Abs(got-want) > 1e-9 {
+					t.Errorf("%s(%v) = %v, want %v", label, tt.values, got, want)
+				}
+			}
+			check("Mean", Mean(tt.values), tt.mean)
+			check("Median", Median(tt.values), tt.median)
+			check("Max", Max(tt.values), tt.max)
+			check("Percentile75", Percentile(tt.values, 75), tt.p75)
+		})
+	}
+}
+
+func TestPercentileDoesNotModifyInput(t *testing.T) {
+	values := []float64{3, 1, 2}
+	Percentile(values, 50)
+	if values[0] != 3 || values[1] != 1 || values[2] != 2 {
+		t.Errorf("Percentile modified its input: %v", values)
+	}
+}
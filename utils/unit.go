@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+)
+
+// Dimensions of the unit registry. Quantities convert only within a
+// dimension, except pack units which convert to pieces with the pack size of
+// a material.
+const (
+	DimensionMass   = "mass"
+	DimensionLength = "length"
+	DimensionVolume = "volume"
+	DimensionArea   = "area"
+	DimensionCount  = "count"
+	DimensionPack   = "pack"
+)
+
+// Unit is a unit of measure of the registry. Factor converts a quantity in
+// the unit to the base unit of its dimension: kg, m, lít, m2 and cái.
+type Unit struct {
+	Symbol    string   `json:"symbol"`
+	Dimension string   `json:"dimension"`
+	Factor    float64  `json:"factor"`
+	Aliases   []string `json:"aliases"`
+}
+
+var unitRegistry = []Unit{
+	{Symbol: "kg", Dimension: DimensionMass, Factor: 1, Aliases: []string{"kilogam", "kilogram"}},
+	{Symbol: "g", Dimension: DimensionMass, Factor: 0.001, Aliases: []string{"gam", "gram", "gr"}},
+	{Symbol: "tấn", Dimension: DimensionMass, Factor: 1000, Aliases: []string{"t", "ton", "tonne"}},
+	{Symbol: "m", Dimension: DimensionLength, Factor: 1, Aliases: []string{"mét"}},
+	{Symbol: "cm", Dimension: DimensionLength, Factor: 0.01, Aliases: []string{"centimet", "xăng ti mét"}},
+	{Symbol: "mm", Dimension: DimensionLength, Factor: 0.001, Aliases: []string{"milimet", "li"}},
+	{Symbol: "km", Dimension: DimensionLength, Factor: 1000, Aliases: []string{"kilomet"}},
+	{Symbol: "lít", Dimension: DimensionVolume, Factor: 1, Aliases: []string{"l", "litre", "liter", "dm3"}},
+	{Symbol: "ml", Dimension: DimensionVolume, Factor: 0.001, Aliases: []string{"mililit", "cc", "cm3"}},
+	{Symbol: "m3", Dimension: DimensionVolume, Factor: 1000, Aliases: []string{"khối", "mét khối"}},
+	{Symbol: "m2", Dimension: DimensionArea, Factor: 1, Aliases: []string{"mét vuông"}},
+	{Symbol: "cái", Dimension: DimensionCount, Factor: 1, Aliases: []string{"chiếc", "con", "cái/chiếc", "pcs", "pc", "ea"}},
+	{Symbol: "bộ", Dimension: DimensionPack, Aliases: []string{"set"}},
+	{Symbol: "hộp", Dimension: DimensionPack, Aliases: []string{"box"}},
+	{Symbol: "thùng", Dimension: DimensionPack},
+	{Symbol: "cuộn", Dimension: DimensionPack, Aliases: []string{"roll"}},
+	{Symbol: "bao", Dimension: DimensionPack},
+}
+
+// unitIndex maps the normalized symbols and aliases to the registry.
+var unitIndex = func() map[string]*Unit {
+	index := make(map[string]*Unit)
+	for i := range unitRegistry {
+		unit := &unitRegistry[i]
+		for _, name := range append([]string{unit.Symbol}, unit.Aliases...) {
+			index[unitKey(name)] = unit
+		}
+	}
+	return index
+}()
+
+// unitKey folds a unit like NormalizeName without spaces, keeping the
+// superscripts of m² and m³.
+func unitKey(unit string) string {
+	unit = strings.NewReplacer("²", "2", "³", "3").Replace(unit)
+	return strings.ReplaceAll(NormalizeName(unit), " ", "")
+}
+
+// Units returns the registry sorted by dimension and factor.
+func Units() []Unit {
+	units := make([]Unit, len(unitRegistry))
+	copy(units, unitRegistry)
+	sort.SliceStable(units, func(i, j int) bool {
+		if units[i].Dimension != units[j].Dimension {
+			return units[i].Dimension < units[j].Dimension
+		}
+		return units[i].Factor < units[j].Factor
+	})
+	return units
+}
+
+// LookupUnit finds a unit of the registry by its symbol or an alias, ignoring
+// case, diacritics and spacing, so that "Lit", "LÍT" and "l" are all lít.
+func LookupUnit(unit string) (Unit, bool) {
+	found, ok := unitIndex[unitKey(unit)]
+	if !ok {
+		return Unit{}, false
+	}
+	return *found, true
+}
+
+// NormalizeUnit returns the symbol of a known unit and the trimmed lower
+// case text of an unknown one.
+func NormalizeUnit(unit string) string {
+	if found, ok := LookupUnit(unit); ok {
+		return found.Symbol
+	}
+	return strings.ToLower(strings.TrimSpace(unit))
+}
+
+// ConvertQuantity converts a quantity between two units. Units of the same
+// dimension convert with their factors; a pack unit converts to and from
+// pieces with packSizes, the number of pieces per pack keyed by normalized
+// unit. It returns false when the units cannot be combined. Equal unknown
+// units are compatible.
+func ConvertQuantity(quantity float64, from, to string, packSizes map[string]float64) (float64, bool) {
+	from, to = NormalizeUnit(from), NormalizeUnit(to)
+	if from == to {
+		return quantity, true
+	}
+	fromDimension, fromFactor, ok := unitFactor(from, packSizes)
+	if !ok {
+		return 0, false
+	}
+	toDimension, toFactor, ok := unitFactor(to, packSizes)
+	if !ok || fromDimension != toDimension {
+		return 0, false
+	}
+	return quantity * fromFactor / toFactor, true
+}
+
+// CompatibleUnits reports whether quantities in both units can be combined.
+func CompatibleUnits(a, b string, packSizes map[string]float64) bool {
+	_, ok := ConvertQuantity(1, a, b, packSizes)
+	return ok
+}
+
+// unitFactor returns the dimension of a normalized unit and its factor to
+// the base unit, pack units being counted in pieces.
+func unitFactor(unit string, packSizes map[string]float64) (string, float64, bool) {
+	if size, ok := packSizes[unit]; ok && size > 0 {
+		return DimensionCount, size, true
+	}
+	found, ok := LookupUnit(unit)
+	if !ok || found.Dimension == DimensionPack {
+		return "", 0, false
+	}
+	return found.Dimension, found.Factor, true
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeUnit(t *testing.T) {
+	tests := []struct {
+		unit string
+		want string
+	}{
+		{unit: "KG", want: "kg"},
+		{unit: " Kilogam ", want: "kg"},
+		{unit: "Lit", want: "lít"},
+		{unit: "LÍT", want: "lít"},
+		{unit: "m³", want: "m3"},
+		{unit: "M2", want: "m2"},
+		{unit: "m", want: "m"},
+		{unit: "Chiếc", want: "cái"},
+		{unit: "Bộ", want: "bộ"},
+		{unit: " Vỉ ", want: "vỉ"},
+		{unit: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeUnit(tt.unit); got != tt.want {
+			t.Errorf("NormalizeUnit(%q) = %q, want %q", tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestConvertQuantity(t *testing.T) {
+	packs := map[string]float64{"bộ": 4, "vỉ": 10}
+	tests := []struct {
+		quantity float64
+		from, to string
+		want     float64
+		ok       bool
+	}{
+		{quantity: 1500, from: "g", to: "kg", want: 1.5, ok: true},
+		{quantity: 2, from: "tấn", to: "kg", want: 2000, ok: true},
+		{quantity: 250, from: "mm", to: "m", want: 0.25, ok: true},
+		{quantity: 500, from: "ml", to: "lít", want: 0.5, ok: true},
+		{quantity: 3, from: "chiếc", to: "cái", want: 3, ok: true},
+		{quantity: 2, from: "bộ", to: "cái", want: 8, ok: true},
+		{quantity: 6, from: "cái", to: "bộ", want: 1.5, ok: true},
+		{quantity: 3, from: "vỉ", to: "bộ", want: 7.5, ok: true},
+		{quantity: 4, from: "Vỉ", to: "vỉ", want: 4, ok: true},
+		{quantity: 1, from: "kg", to: "m", ok: false},
+		{quantity: 1, from: "kg", to: "lít", ok: false},
+		{quantity: 1, from: "hộp", to: "cái", ok: false},
+		{quantity: 1, from: "cuộn", to: "m", ok: false},
+		{quantity: 1, from: "tấm", to: "cái", ok: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ConvertQuantity(tt.quantity, tt.from, tt.to, packs)
+		if ok != tt.ok {
+			t.Errorf("ConvertQuantity(%v, %q, %q) ok = %v, want %v", tt.quantity, tt.from, tt.to, ok, tt.ok)
+			continue
+		}
+		if ok && math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("ConvertQuantity(%v, %q, %q) = %v, want %v", tt.quantity, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestUnitsHaveDistinctAliases(t *testing.T) {
+	seen := make(map[string]string)
+	for _, unit := range Units() {
+		for _, name := range append([]string{unit.Symbol}, unit.Aliases...) {
+			key := unitKey(name)
+			if other, ok := seen[key]; ok {
+				t.Errorf("%q of %s is already an alias of %s", name, unit.Symbol, other)
+			}
+			seen[key] = unit.Symbol
+		}
+	}
+}
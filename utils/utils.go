@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -126,3 +129,86 @@ func RemoveDuplicates(input []string) []string {
 	}
 	return result
 }
+
+// IndexPathDepth returns the number of levels of an encoded index path.
+func IndexPathDepth(path int64) int {
+	depth := 0
+	for shift := 54; shift >= 0; shift -= 6 {
+		if (path>>shift)&0x3F == 0 {
+			break
+		}
+		depth++
+	}
+	return depth
+}
+
+// IndexPathParent returns the encoded parent of an index path, 0 for a top
+// level path.
+func IndexPathParent(path int64) int64 {
+	depth := IndexPathDepth(path)
+	if depth <= 1 {
+		return 0
+	}
+	return path &^ (int64(1)<<(60-6*(depth-1)) - 1)
+}
+
+// IndexPathRange returns the inclusive range of encoded values covering an
+// index path and all of its descendants, e.g. "2.3" covers "2.3", "2.3.1"
+// and "2.3.63.5" but not "2.4".
+func IndexPathRange(path int64) (int64, int64) {
+	depth := IndexPathDepth(path)
+	if depth == 0 {
+		return 0, int64(1)<<60 - 1
+	}
+	return path, path | (int64(1)<<(60-6*depth) - 1)
+}
+
+// IndexPathStep returns the encoded distance between two consecutive siblings
+// at the given depth, e.g. from "2.2" to "2.3" for depth 2.
+func IndexPathStep(depth int) int64 {
+	if depth < 1 || depth > 10 {
+		return 0
+	}
+	return int64(1) << (60 - 6*depth)
+}
+
+// IndexPathLastSegment returns the last level of an index path, 3 for "2.3".
+func IndexPathLastSegment(path int64) int64 {
+	depth := IndexPathDepth(path)
+	if depth == 0 {
+		return 0
+	}
+	return (path >> (60 - 6*depth)) & 0x3F
+}
+
+// IndexPathRebase replaces the prefix from of path with to, e.g. rebasing
+// "2.3.1" from "2.3" to "4" gives "4.1". It fails when path is not under
+// from or when the result would be deeper than 10 levels.
+func IndexPathRebase(path, from, to int64) (int64, error) {
+	start, end := IndexPathRange(from)
+	if path < start || path > end {
+		return 0, fmt.Errorf("index path %s is not under %s", IndexPathToString(path), IndexPathToString(from))
+	}
+	fromDepth := IndexPathDepth(from)
+	toDepth := IndexPathDepth(to)
+	suffix := path - from
+	if toDepth > fromDepth {
+		shift := 6 * (toDepth - fromDepth)
+		if suffix&(int64(1)<<shift-1) != 0 {
+			return 0, fmt.Errorf("index path too deep (max 10 levels)")
+		}
+		suffix >>= shift
+	} else {
+		suffix <<= 6 * (fromDepth - toDepth)
+	}
+	return to | suffix, nil
+}
+
+// ContentHash returns the hex encoded SHA-256 of everything read from r.
+func ContentHash(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
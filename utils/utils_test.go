@@ -1,6 +1,9 @@
 package utils
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestIndexPathRoundTrip(t *testing.T) {
 	tests := []string{
@@ -252,3 +255,177 @@ func TestIndexPathSortOrder(t *testing.T) {
 		}
 	})
 }
+
+func TestIndexPathDepthAndParent(t *testing.T) {
+	tests := []struct {
+		path   string
+		depth  int
+		parent string
+	}{
+		{"2", 1, ""},
+		{"2.3", 2, "2"},
+		{"2.3.1", 3, "2.3"},
+		{"63.63.63", 3, "63.63"},
+		{"1.2.3.4.5.6.7.8.9.10", 10, "1.2.3.4.5.6.7.8.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			encoded, err := StringToIndexPath(tt.path)
+			if err != nil {
+				t.Fatalf("StringToIndexPath(%q) error: %v", tt.path, err)
+			}
+			if depth := IndexPathDepth(encoded); depth != tt.depth {
+				t.Errorf("IndexPathDepth(%q) = %d, want %d", tt.path, depth, tt.depth)
+			}
+			if parent := IndexPathToString(IndexPathParent(encoded)); parent != tt.parent {
+				t.Errorf("IndexPathParent(%q) = %q, want %q", tt.path, parent, tt.parent)
+			}
+		})
+	}
+
+	if depth := IndexPathDepth(0); depth != 0 {
+		t.Errorf("IndexPathDepth(0) = %d, want 0", depth)
+	}
+}
+
+func TestIndexPathRange(t *testing.T) {
+	tests := []struct {
+		root    string
+		inside  []string
+		outside []string
+	}{
+		{
+			root:    "2.3",
+			inside:  []string{"2.3", "2.3.1", "2.3.63", "2.3.63.63.63.63.63.63.63.63"},
+			outside: []string{"2", "2.2", "2.2.63", "2.4", "3", "1.3"},
+		},
+		{
+			root:    "5",
+			inside:  []string{"5", "5.1", "5.63.1"},
+			outside: []string{"4.63", "6", "6.1"},
+		},
+		{
+			root:    "1.2.3.4.5.6.7.8.9.10",
+			inside:  []string{"1.2.3.4.5.6.7.8.9.10"},
+			outside: []string{"1.2.3.4.5.6.7.8.9.11", "1.2.3.4.5.6.7.8.9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.root, func(t *testing.T) {
+			root, err := StringToIndexPath(tt.root)
+			if err != nil {
+				t.Fatalf("StringToIndexPath(%q) error: %v", tt.root, err)
+			}
+			from, to := IndexPathRange(root)
+			for _, path := range tt.inside {
+				val, err := StringToIndexPath(path)
+				if err != nil {
+					t.Fatalf("StringToIndexPath(%q) error: %v", path, err)
+				}
+				if val < from || val > to {
+					t.Errorf("%q (%d) should be within [%d, %d]", path, val, from, to)
+				}
+			}
+			for _, path := range tt.outside {
+				val, err := StringToIndexPath(path)
+				if err != nil {
+					t.Fatalf("StringToIndexPath(%q) error: %v", path, err)
+				}
+				if val >= from && val <= to {
+					t.Errorf("%q (%d) should be outside [%d, %d]", path, val, from, to)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexPathStepAndLastSegment(t *testing.T) {
+	tests := []struct {
+		path string
+		next string
+		last int64
+	}{
+		{"2", "3", 2},
+		{"2.2", "2.3", 2},
+		{"2.3.62", "2.3.63", 62},
+		{"1.2.3.4.5.6.7.8.9.10", "1.2.3.4.5.6.7.8.9.11", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			val, err := StringToIndexPath(tt.path)
+			if err != nil {
+				t.Fatalf("StringToIndexPath(%q) error: %v", tt.path, err)
+			}
+			next := IndexPathToString(val + IndexPathStep(IndexPathDepth(val)))
+			if next != tt.next {
+				t.Errorf("next sibling of %q = %q, want %q", tt.path, next, tt.next)
+			}
+			if last := IndexPathLastSegment(val); last != tt.last {
+				t.Errorf("IndexPathLastSegment(%q) = %d, want %d", tt.path, last, tt.last)
+			}
+		})
+	}
+}
+
+func TestIndexPathRebase(t *testing.T) {
+	tests := []struct {
+		path    string
+		from    string
+		to      string
+		want    string
+		wantErr bool
+	}{
+		{path: "2.3", from: "2.3", to: "4", want: "4"},
+		{path: "2.3.1", from: "2.3", to: "4", want: "4.1"},
+		{path: "2.3.1.5", from: "2.3", to: "2.4", want: "2.4.1.5"},
+		{path: "2.3.1", from: "2.3", to: "5.6.7", want: "5.6.7.1"},
+		{path: "2", from: "2", to: "1.1", want: "1.1"},
+		{path: "1.2.3.4.5.6.7.8.9", from: "1", to: "1.1", want: "1.1.2.3.4.5.6.7.8.9"},
+		{path: "1.2.3.4.5.6.7.8.9.10", from: "1", to: "1.1", wantErr: true},
+		{path: "2.4.1", from: "2.3", to: "4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+"->"+tt.to, func(t *testing.T) {
+			path, _ := StringToIndexPath(tt.path)
+			from, _ := StringToIndexPath(tt.from)
+			to, _ := StringToIndexPath(tt.to)
+			got, err := IndexPathRebase(path, from, to)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("IndexPathRebase(%q, %q, %q) expected error, got %q", tt.path, tt.from, tt.to, IndexPathToString(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("IndexPathRebase(%q, %q, %q) error: %v", tt.path, tt.from, tt.to, err)
+			}
+			if IndexPathToString(got) != tt.want {
+				t.Errorf("IndexPathRebase(%q, %q, %q) = %q, want %q", tt.path, tt.from, tt.to, IndexPathToString(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "", want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{content: "abc", want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for _, tt := range tests {
+		got, err := ContentHash(strings.NewReader(tt.content))
+		if err != nil {
+			t.Fatalf("ContentHash(%q) error: %v", tt.content, err)
+		}
+		if got != tt.want {
+			t.Errorf("ContentHash(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}